@@ -0,0 +1,180 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual/simulator"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const (
+	defaultRunAddress     = "localhost:9090"
+	defaultLogLevel       = "INFO"
+	defaultProcessingTime = 2 * time.Second
+
+	envRunAddress         = "RUN_ADDRESS"
+	envLogLevel           = "LOG_LEVEL"
+	envLatency            = "ACCRUAL_SIM_LATENCY"
+	envProcessingTime     = "ACCRUAL_SIM_PROCESSING_TIME"
+	envInvalidProbability = "ACCRUAL_SIM_INVALID_PROBABILITY"
+	envPerMinute          = "ACCRUAL_SIM_PER_MINUTE"
+	envRewards            = "ACCRUAL_SIM_REWARDS"
+	envBatchLookup        = "ACCRUAL_SIM_BATCH_LOOKUP"
+)
+
+type config struct {
+	runAddr  string
+	logLevel string
+	rules    simulator.Rules
+	rewards  []simulator.Reward
+}
+
+func parseConfig() config {
+	cfg := config{
+		rules: simulator.Rules{ProcessingTime: defaultProcessingTime},
+	}
+
+	flag.StringVar(&cfg.runAddr, "a", defaultRunAddress, "Server address: host:port")
+	flag.StringVar(&cfg.logLevel, "l", defaultLogLevel, "Log level")
+	flag.DurationVar(&cfg.rules.Latency, "latency", 0, "Delay of every status answer")
+	flag.DurationVar(&cfg.rules.ProcessingTime, "processing", defaultProcessingTime, "Time from registration to the final status")
+	flag.Func("invalid", "Probability of an order to end INVALID, from 0 to 1 (default 0)", func(s string) (err error) {
+		cfg.rules.InvalidProbability, err = parseProbability(s)
+		return err
+	})
+	flag.Func("rpm", "Status requests allowed per minute, 429 after that (default 0, unlimited)", func(s string) (err error) {
+		cfg.rules.PerMinute, err = parsePerMinute(s)
+		return err
+	})
+	flag.BoolVar(&cfg.rules.BatchLookup, "batch", false, "Serve the batch status endpoint POST /api/orders/batch")
+	flag.Func("rewards", `Rewards registered at start: "Bork=10%,Samsung=50pt"`, func(s string) (err error) {
+		cfg.rewards, err = parseRewards(s)
+		return err
+	})
+
+	flag.Parse()
+
+	parseEnvs(&cfg)
+
+	return cfg
+}
+
+func parseEnvs(cfg *config) {
+	var err error
+
+	if value := os.Getenv(envRunAddress); value != "" {
+		cfg.runAddr = value
+	}
+
+	if value := os.Getenv(envLogLevel); value != "" {
+		cfg.logLevel = value
+	}
+
+	for env, dst := range map[string]*time.Duration{
+		envLatency:        &cfg.rules.Latency,
+		envProcessingTime: &cfg.rules.ProcessingTime,
+	} {
+		if value := os.Getenv(env); value != "" {
+			if *dst, err = time.ParseDuration(value); err != nil {
+				logEnvError(env, value, err)
+				panic("failed to parse config: " + env)
+			}
+		}
+	}
+
+	if value := os.Getenv(envInvalidProbability); value != "" {
+		if cfg.rules.InvalidProbability, err = parseProbability(value); err != nil {
+			logEnvError(envInvalidProbability, value, err)
+			panic("failed to parse config: " + envInvalidProbability)
+		}
+	}
+
+	if value := os.Getenv(envPerMinute); value != "" {
+		if cfg.rules.PerMinute, err = parsePerMinute(value); err != nil {
+			logEnvError(envPerMinute, value, err)
+			panic("failed to parse config: " + envPerMinute)
+		}
+	}
+
+	if value := os.Getenv(envBatchLookup); value != "" {
+		if cfg.rules.BatchLookup, err = strconv.ParseBool(value); err != nil {
+			logEnvError(envBatchLookup, value, err)
+			panic("failed to parse config: " + envBatchLookup)
+		}
+	}
+
+	if value := os.Getenv(envRewards); value != "" {
+		if cfg.rewards, err = parseRewards(value); err != nil {
+			logEnvError(envRewards, value, err)
+			panic("failed to parse config: " + envRewards)
+		}
+	}
+}
+
+func parseProbability(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	if v < 0 || v > 1 {
+		return 0, fmt.Errorf("must be between 0 and 1, got %v", v)
+	}
+
+	return v, nil
+}
+
+func parsePerMinute(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if v < 0 {
+		return 0, fmt.Errorf("must not be negative, got %d", v)
+	}
+
+	return v, nil
+}
+
+// parseRewards parses "<match>=<reward>%" and "<match>=<reward>pt" separated by commas.
+func parseRewards(s string) ([]simulator.Reward, error) {
+	var rewards []simulator.Reward
+
+	for _, item := range strings.Split(s, ",") {
+		match, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok {
+			return nil, fmt.Errorf("reward %q: expected <match>=<reward>%% or <match>=<reward>pt", item)
+		}
+
+		reward := simulator.Reward{Match: match}
+		switch {
+		case strings.HasSuffix(value, string(simulator.RewardPercent)):
+			reward.Type = simulator.RewardPercent
+		case strings.HasSuffix(value, string(simulator.RewardPoints)):
+			reward.Type = simulator.RewardPoints
+		default:
+			return nil, fmt.Errorf("reward %q: unknown reward type", item)
+		}
+
+		points, err := model.ParsePoints(strings.TrimSuffix(value, string(reward.Type)))
+		if err != nil {
+			return nil, fmt.Errorf("reward %q: %w", item, err)
+		}
+		reward.Reward = points
+
+		rewards = append(rewards, reward)
+	}
+
+	return rewards, nil
+}
+
+func logEnvError(env, value string, err error) {
+	log.Printf("failed to parse environment variable %s=%s: %v", env, value, err)
+}
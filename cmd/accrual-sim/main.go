@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	nativeLog "log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/middleware"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual/simulator"
+)
+
+// Runs an in-memory stand-in for the accrual system, so the accrual worker of gophermart can be run locally and in
+// CI. Orders and rewards are registered through the admin endpoints or the -rewards flag:
+//
+//	curl -d '{"match":"Bork","reward":10,"reward_type":"%"}' localhost:9090/api/goods
+//	curl -d '{"order":"12345678903","goods":[{"description":"Чайник Bork","price":7000}]}' localhost:9090/api/orders
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	cfg := parseConfig()
+
+	log, err := logger.NewZapLog(cfg.logLevel)
+	if err != nil {
+		nativeLog.Fatalf("faild to create logger: %v", err)
+	}
+	defer log.Close()
+
+	sim := simulator.New(cfg.rules)
+	for _, reward := range cfg.rewards {
+		if err := sim.AddReward(reward); err != nil {
+			log.WithError(err).WithField("match", reward.Match).Error("Invalid reward")
+			os.Exit(1)
+		}
+	}
+
+	server := &http.Server{
+		Addr:    cfg.runAddr,
+		Handler: middleware.LogRequest(log)(sim.Handler()),
+	}
+
+	go func() {
+		<-ctx.Done()
+		log.Info("Graceful shutting down server")
+		_ = server.Shutdown(context.Background())
+	}()
+
+	log.WithField("address", cfg.runAddr).
+		WithField("latency", cfg.rules.Latency).
+		WithField("processing_time", cfg.rules.ProcessingTime).
+		WithField("invalid_probability", cfg.rules.InvalidProbability).
+		WithField("per_minute", cfg.rules.PerMinute).
+		WithField("batch_lookup", cfg.rules.BatchLookup).
+		Info("Starting accrual system simulator")
+
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.WithError(err).Error("Server error")
+		os.Exit(1)
+	}
+}
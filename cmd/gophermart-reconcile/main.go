@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	nativeLog "log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/config"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/db/pg"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/usecase/ledger/reconcile"
+)
+
+const (
+	exitDrift = 1
+	exitError = 2
+)
+
+// Reconciles the ledger with transactions and accruals and prints a JSON drift report to stdout.
+// Exits with code 1 when unresolved drift is found, so it can be run by cron and alerted on.
+func main() {
+	repair := flag.Bool("repair", false, "Repair drift with audit adjustment entries")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	cfg := config.Parse()
+	if cfg == nil {
+		nativeLog.Fatal("config is nil")
+	}
+
+	log, err := logger.NewZapLog(cfg.LogLevel())
+	if err != nil {
+		nativeLog.Fatalf("faild to create logger: %v", err)
+	}
+	defer log.Close()
+
+	os.Exit(run(ctx, cfg, log, *repair))
+}
+
+func run(ctx context.Context, cfg *config.Configuration, log logger.Logger, repair bool) int {
+	db, err := pg.Connect(cfg.DatabaseURI())
+	if err != nil {
+		log.WithError(err).Error("Unable to connect to database")
+		return exitError
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	usecase := reconcile.NewUsecase(repository.NewLedgerPG(db), new(guid.Generator))
+
+	result, err := usecase.Reconcile(ctx, repair)
+	if err != nil {
+		log.WithError(err).Error("Reconciliation failed")
+		return exitError
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(newReport(result)); err != nil {
+		log.WithError(err).Error("Could not write report")
+		return exitError
+	}
+
+	log.WithField("accounts", result.AccountsChecked).
+		WithField("drifts", len(result.Drifts)).
+		WithField("unresolved", result.Unresolved()).
+		Info("Reconciliation finished")
+
+	if result.Unresolved() > 0 {
+		return exitDrift
+	}
+
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/ledger/reconcile"
+)
+
+type report struct {
+	CheckedAt       api.Datetime `json:"checked_at"`
+	AccountsChecked int          `json:"accounts_checked"`
+	Unresolved      int          `json:"unresolved"`
+	Drifts          []drift      `json:"drifts"`
+}
+
+type drift struct {
+	Kind        string       `json:"kind"`
+	AccountGUID string       `json:"account_guid"`
+	OrderNumber string       `json:"order_number,omitempty"`
+	Expected    model.Points `json:"expected"`
+	Actual      model.Points `json:"actual"`
+	Diff        model.Points `json:"diff"`
+	Repaired    bool         `json:"repaired"`
+	RepairError string       `json:"repair_error,omitempty"`
+}
+
+func newReport(r *reconcile.Report) report {
+	resp := report{
+		CheckedAt:       api.Datetime(r.CheckedAt.Truncate(time.Second)),
+		AccountsChecked: r.AccountsChecked,
+		Unresolved:      r.Unresolved(),
+		Drifts:          make([]drift, 0, len(r.Drifts)),
+	}
+
+	for _, d := range r.Drifts {
+		item := drift{
+			Kind:        string(d.Kind),
+			AccountGUID: d.AccountGUID,
+			OrderNumber: d.OrderNumber,
+			Expected:    d.Expected,
+			Actual:      d.Actual,
+			Diff:        d.Expected - d.Actual,
+			Repaired:    d.Repaired,
+		}
+
+		if d.RepairErr != nil {
+			item.RepairError = d.RepairErr.Error()
+		}
+
+		resp.Drifts = append(resp.Drifts, item)
+	}
+
+	return resp
+}
@@ -3,14 +3,18 @@ package main
 import (
 	nativeLog "log"
 	"os"
+	"runtime"
 
 	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/config"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/db/migrator"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/db/pg"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/notifier"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
 )
 
 func mustInitConfig() *config.Configuration {
@@ -22,6 +26,41 @@ func mustInitConfig() *config.Configuration {
 	return cfg
 }
 
+// initLoginThrottle only one instance can count failed logins in memory, replicas share the counts in Postgres.
+func initLoginThrottle(store string, db *sqlx.DB) repository.LoginThrottleRepo {
+	if store == config.LoginThrottleMemory {
+		return repository.NewLoginThrottleMemory()
+	}
+
+	return repository.NewLoginThrottlePG(db)
+}
+
+// initHasher hashing is CPU-bound, so more hashes at once than GOMAXPROCS only take memory without being faster.
+func initHasher(cfg *config.Configuration) auth.IHasher {
+	limit := runtime.GOMAXPROCS(0)
+
+	if cfg.PasswordHasher() == config.PasswordHasherBcrypt {
+		return auth.NewLimitedHasher(auth.NewHasher(auth.WithCost(cfg.BcryptCost())), limit)
+	}
+
+	params := auth.DefaultArgon2Params
+	params.Memory = cfg.Argon2Memory()
+	params.Time = cfg.Argon2Time()
+	params.Threads = cfg.Argon2Threads()
+
+	return auth.NewLimitedHasher(auth.NewArgon2Hasher(params), limit)
+}
+
+// initNotifier reset tokens are only delivered to a file so far. Without it there is no channel, nil is returned and
+// the password reset is off: a token must never get to the log.
+func initNotifier(resetFile string) notifier.Notifier {
+	if resetFile == "" {
+		return nil
+	}
+
+	return notifier.NewFileNotifier(resetFile)
+}
+
 func mustInitLog(level string) *logger.ZapLog {
 	log, err := logger.NewZapLog(level)
 	if err != nil {
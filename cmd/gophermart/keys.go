@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+)
+
+// mustInitKeySet loads the signing and verification keys. It returns nil without a signing key, tokens are signed
+// with the shared secret then. A key loaded on SIGHUP is published for publish before it signs, the key it replaces
+// verifies for grace after that.
+func mustInitKeySet(signingPath string, verifyPaths []string, grace, publish time.Duration, log logger.Logger) *auth.KeySet {
+	if signingPath == "" {
+		return nil
+	}
+
+	signing, err := auth.LoadKey(signingPath)
+	if err != nil {
+		log.WithError(err).Error("Unable to load JWT signing key")
+		os.Exit(1)
+	}
+
+	verify := make([]*auth.Key, 0, len(verifyPaths))
+	for _, path := range verifyPaths {
+		key, err := auth.LoadKey(path)
+		if err != nil {
+			log.WithError(err).Error("Unable to load JWT verification key")
+			os.Exit(1)
+		}
+		verify = append(verify, key)
+	}
+
+	keys, err := auth.NewKeySet(signing, verify, grace, publish)
+	if err != nil {
+		log.WithError(err).WithField("path", signingPath).Error("Unable to use JWT signing key")
+		os.Exit(1)
+	}
+
+	log.WithField("kid", signing.ID).WithField("alg", signing.Method.Alg()).Info("JWT signing key loaded")
+
+	return keys
+}
+
+// keyReloader reads the signing key file again on SIGHUP and rotates to it if the key has changed, so a key is
+// replaced without a restart.
+type keyReloader struct {
+	keys *auth.KeySet
+	path string
+	log  logger.Logger
+	wg   sync.WaitGroup
+}
+
+func newKeyReloader(keys *auth.KeySet, path string, log logger.Logger) *keyReloader {
+	return &keyReloader{
+		keys: keys,
+		path: path,
+		log:  log,
+	}
+}
+
+func (r *keyReloader) run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				r.reload()
+			}
+		}
+	}()
+}
+
+func (r *keyReloader) reload() {
+	key, err := auth.LoadKey(r.path)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to reload JWT signing key, the current key is kept")
+		return
+	}
+
+	previous := r.keys.SigningKey()
+
+	signsAt, err := r.keys.Rotate(key)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to rotate JWT signing key, the current key is kept")
+		return
+	}
+
+	if key.ID == previous.ID {
+		r.log.WithField("kid", key.ID).Info("JWT signing key has not changed")
+		return
+	}
+
+	r.log.
+		WithField("kid", key.ID).
+		WithField("previous_kid", previous.ID).
+		WithField("signs_at", signsAt.Format(time.RFC3339)).
+		Info("JWT signing key published, it signs once the JWKS caches expire")
+}
+
+func (r *keyReloader) wait() {
+	r.wg.Wait()
+}
@@ -9,23 +9,47 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/bjlag/go-loyalty/internal/api/handler/accrual/callback"
 	"github.com/bjlag/go-loyalty/internal/api/handler/balance/get"
 	"github.com/bjlag/go-loyalty/internal/api/handler/balance/withdraw"
+	"github.com/bjlag/go-loyalty/internal/api/handler/jwks"
 	"github.com/bjlag/go-loyalty/internal/api/handler/order/list"
 	"github.com/bjlag/go-loyalty/internal/api/handler/order/upload"
+	"github.com/bjlag/go-loyalty/internal/api/handler/status"
+	"github.com/bjlag/go-loyalty/internal/api/handler/transactions"
+	"github.com/bjlag/go-loyalty/internal/api/handler/transactions/export"
 	"github.com/bjlag/go-loyalty/internal/api/handler/user/login"
+	"github.com/bjlag/go-loyalty/internal/api/handler/user/logout"
+	"github.com/bjlag/go-loyalty/internal/api/handler/user/password/change"
+	"github.com/bjlag/go-loyalty/internal/api/handler/user/password/reset"
+	"github.com/bjlag/go-loyalty/internal/api/handler/user/password/reset/confirm"
 	"github.com/bjlag/go-loyalty/internal/api/handler/user/register"
+	"github.com/bjlag/go-loyalty/internal/api/handler/user/token/refresh"
+	webhookCreate "github.com/bjlag/go-loyalty/internal/api/handler/webhook/create"
+	"github.com/bjlag/go-loyalty/internal/api/handler/webhook/deliveries"
+	webhookList "github.com/bjlag/go-loyalty/internal/api/handler/webhook/list"
+	"github.com/bjlag/go-loyalty/internal/api/handler/webhook/redeliver"
+	"github.com/bjlag/go-loyalty/internal/api/handler/webhook/remove"
 	"github.com/bjlag/go-loyalty/internal/api/handler/withdrawals"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/middleware"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/publisher"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/ratelimit"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	ucApplyAccrual "github.com/bjlag/go-loyalty/internal/usecase/accrual/apply"
 	ucCreateAccrual "github.com/bjlag/go-loyalty/internal/usecase/accrual/create"
 	ucUpdateAccrual "github.com/bjlag/go-loyalty/internal/usecase/accrual/update"
+	ucRelay "github.com/bjlag/go-loyalty/internal/usecase/outbox/relay"
 	ucLogin "github.com/bjlag/go-loyalty/internal/usecase/user/login"
+	ucPassword "github.com/bjlag/go-loyalty/internal/usecase/user/password"
 	ucRegister "github.com/bjlag/go-loyalty/internal/usecase/user/register"
+	ucToken "github.com/bjlag/go-loyalty/internal/usecase/user/token"
+	ucCreateWebhook "github.com/bjlag/go-loyalty/internal/usecase/webhook/create"
+	ucDeliverWebhook "github.com/bjlag/go-loyalty/internal/usecase/webhook/deliver"
 	ucCreateWithdraw "github.com/bjlag/go-loyalty/internal/usecase/withdraw/create"
 )
 
@@ -33,6 +57,44 @@ const (
 	accrualTimeout       = 200 * time.Millisecond
 	accrualRetryCount    = 2
 	accrualRetryWaitTime = 100 * time.Millisecond
+	// longer than the usual Retry-After of the accrual system, so a paused order is not taken over by another replica
+	accrualLeaseTTL    = 2 * time.Minute
+	accrualBackoffBase = time.Second
+	accrualBackoffMax  = 10 * time.Minute
+	// orders asked from the accrual system in one request, if it has the batch endpoint
+	accrualLookupSize = 50
+	// a single probe is enough to tell the accrual system is back
+	accrualBreakerProbes = 1
+
+	outboxBatchSize = 100
+	// publishers only write to the database, a batch takes well under this
+	outboxClaimTTL    = time.Minute
+	outboxBackoffBase = time.Second
+	outboxBackoffMax  = 10 * time.Minute
+	outboxMaxAttempts = 20
+
+	idempotencyLockTTL = time.Minute
+	idempotencyKeyTTL  = 24 * time.Hour
+
+	sweepInterval = 10 * time.Minute
+
+	// a token revoked on another replica keeps working here for at most this long
+	revocationCacheTTL = 5 * time.Second
+
+	// attempts are rejected for 1s, 2s, 4s... after each failed login until the lockout
+	loginDelayBase = time.Second
+
+	passwordResetTTL = time.Hour
+
+	webhookTimeout   = 5 * time.Second
+	webhookWorkers   = 4
+	webhookBatchSize = 100
+	// the last delivery of a leased batch waits for the ones before it, each up to the timeout
+	webhookLeaseTTL    = (webhookBatchSize/webhookWorkers+1)*webhookTimeout + time.Minute
+	webhookBackoffBase = 5 * time.Second
+	webhookBackoffMax  = time.Hour
+	// with the backoff above a delivery is retried for about 16 hours
+	webhookMaxAttempts = 30
 )
 
 func main() {
@@ -46,8 +108,18 @@ func main() {
 	log.Infof("Log level %q", cfg.LogLevel())
 	log.Infof("Run address \"%s:%d\"", cfg.RunAddrHost(), cfg.RunAddrPort())
 	log.Infof("Accrual address %q", cfg.AccrualSystemAddress())
+	log.Infof("Accrual workers %d, batch size %d, orders per tick %d", cfg.AccrualWorkers(), cfg.AccrualBatchSize(), cfg.AccrualTickBudget())
+	log.Infof("Accrual max attempts %d", cfg.AccrualMaxAttempts())
+	log.Infof("Accrual callback enabled %t", cfg.AccrualCallbackSecret() != "")
+	log.Infof("Accrual circuit opens after %d failures for %s", cfg.AccrualBreakerFailures(), cfg.AccrualBreakerTimeout())
+	log.Infof("Login locks after %d failures per login, %d per address within %s for %s", cfg.LoginMaxFailures(), cfg.LoginMaxFailuresPerIP(), cfg.LoginFailureWindow(), cfg.LoginLockoutTime())
+	log.Infof("Login throttle store %q", cfg.LoginThrottleStore())
+	log.Infof("Trusted proxies %q", cfg.TrustedProxies())
+	log.Infof("Password hasher %q", cfg.PasswordHasher())
 	log.Infof("JWT secret key %q", cfg.JWTSecretKey())
+	log.Infof("JWT signing key %q, verification keys %q", cfg.JWTSigningKey(), cfg.JWTVerifyKeys())
 	log.Infof("JWT expiration time %q", cfg.JWTExpTime())
+	log.Infof("JWT refresh expiration time %q", cfg.JWTRefreshExpTime())
 	log.Infof("Database URI %q", cfg.DatabaseURI())
 	log.Infof("Path to migration source files %q", cfg.MigratePath())
 
@@ -58,45 +130,192 @@ func main() {
 	accrualRepo := repository.NewAccrualPG(db)
 	accountRepo := repository.NewAccountPG(db)
 	transactionRepo := repository.NewTransactionPG(db)
+	idempotencyRepo := repository.NewIdempotencyPG(db)
+	outboxRepo := repository.NewOutboxPG(db)
+	webhookRepo := repository.NewWebhookPG(db)
+	tokenRepo := repository.NewTokenPG(db)
+	loginThrottle := initLoginThrottle(cfg.LoginThrottleStore(), db)
+	loginLockoutRepo := repository.NewLoginLockoutPG(db)
+	passwordResetRepo := repository.NewPasswordResetPG(db)
+
+	// hashes of the other algorithm or with other parameters are upgraded on login
+	hasher := initHasher(cfg)
+	// a replaced key verifies the tokens it has signed until they expire, a new key signs once the JWKS caches expire
+	keys := mustInitKeySet(cfg.JWTSigningKey(), cfg.JWTVerifyKeys(), cfg.JWTExpTime(), jwks.MaxAge, log)
 
-	hasher := auth.NewHasher()
-	jwtBuilder := auth.NewJWTBuilder(cfg.JWTSecretKey(), cfg.JWTExpTime())
+	var jwtOpts []auth.JWTOption
+	if keys != nil {
+		jwtOpts = append(jwtOpts, auth.WithKeySet(keys))
 
-	accrualClient := accrual.NewAccrualClient(
+		reloader := newKeyReloader(keys, cfg.JWTSigningKey(), log)
+		reloader.run(ctx)
+		defer reloader.wait()
+	}
+
+	jwtBuilder := auth.NewJWTBuilder(cfg.JWTSecretKey(), cfg.JWTExpTime(), jwtOpts...)
+	revocations := auth.NewRevocationCache(tokenRepo, revocationCacheTTL)
+	checkAuth := middleware.CheckAuth(jwtBuilder, revocations, log)
+
+	accrualBreaker := client.NewBreaker(
 		client.NewRestyClient(
 			client.WithTimeout(accrualTimeout),
 			client.WithRetryCount(accrualRetryCount),
 			client.WithRetryWaitTime(accrualRetryWaitTime),
 			client.WithLogger(log),
 		),
-		cfg.AccrualSystemAddress(),
+		client.BreakerSettings{
+			FailureThreshold: cfg.AccrualBreakerFailures(),
+			OpenTimeout:      cfg.AccrualBreakerTimeout(),
+			HalfOpenRequests: accrualBreakerProbes,
+			OnStateChange: func(from, to client.State) {
+				log := log.WithField("from", from.String()).WithField("to", to.String())
+				if to == client.StateOpen {
+					log.Warn("Accrual circuit opened")
+					return
+				}
+				log.Info("Accrual circuit state changed")
+			},
+		},
 	)
+	accrualClient := accrual.NewAccrualClient(accrualBreaker, cfg.AccrualSystemAddress())
 
 	guidGen := new(guid.Generator)
 
-	usecaseRegister := ucRegister.NewUsecase(userRepo, guidGen, hasher, jwtBuilder)
-	usecaseLogin := ucLogin.NewUsecase(userRepo, hasher, jwtBuilder)
+	usecaseToken := ucToken.NewUsecase(tokenRepo, jwtBuilder, guidGen, revocations, cfg.JWTRefreshExpTime())
+	usecaseRegister := ucRegister.NewUsecase(userRepo, guidGen, hasher, usecaseToken)
+	usecaseLogin := ucLogin.NewUsecase(userRepo, hasher, usecaseToken, loginThrottle, loginLockoutRepo, guidGen, ucLogin.Limits{
+		MaxFailures:      cfg.LoginMaxFailures(),
+		MaxFailuresPerIP: cfg.LoginMaxFailuresPerIP(),
+		Window:           cfg.LoginFailureWindow(),
+		LockoutTime:      cfg.LoginLockoutTime(),
+		DelayBase:        loginDelayBase,
+	}, log)
+	resetNotifier := initNotifier(cfg.PasswordResetFile())
+	usecasePassword := ucPassword.NewUsecase(
+		userRepo,
+		passwordResetRepo,
+		hasher,
+		usecaseToken,
+		usecaseLogin,
+		resetNotifier,
+		guidGen,
+		passwordResetTTL,
+	)
 	usecaseCreateAccrual := ucCreateAccrual.NewUsecase(accrualRepo)
-	usecaseUpdateAccrual := ucUpdateAccrual.NewUsecase(accrualClient, accrualRepo, guidGen)
-	usecaseCreateWithdraw := ucCreateWithdraw.NewUsecase(accrualRepo, accountRepo, guidGen)
+	// the rate is unknown until the accrual system reports it with the first 429
+	accrualLimiter := ratelimit.NewLimiter(0)
 
-	worker := newAccrualWorker(usecaseUpdateAccrual, log)
+	instance := guidGen.Generate()
+	log.Infof("Instance %q", instance)
+
+	usecaseUpdateAccrual := ucUpdateAccrual.NewUsecase(accrualClient, accrualRepo, guidGen, accrualLimiter, ucUpdateAccrual.Limits{
+		Workers:     cfg.AccrualWorkers(),
+		BatchSize:   cfg.AccrualBatchSize(),
+		LookupSize:  accrualLookupSize,
+		TickBudget:  cfg.AccrualTickBudget(),
+		LeaseTTL:    accrualLeaseTTL,
+		BackoffBase: accrualBackoffBase,
+		BackoffMax:  accrualBackoffMax,
+		MaxAttempts: cfg.AccrualMaxAttempts(),
+	}, instance)
+	usecaseCreateWithdraw := ucCreateWithdraw.NewUsecase(accrualRepo, guidGen)
+
+	worker := newAccrualWorker(usecaseUpdateAccrual, accrualBreaker, log)
 	worker.run(ctx)
+	defer worker.wait()
+
+	eventPublisher := publisher.NewFanout(
+		publisher.NewLogPublisher(log),
+		publisher.NewWebhookPublisher(webhookRepo),
+	)
+	usecaseRelay := ucRelay.NewUsecase(outboxRepo, eventPublisher, ucRelay.Limits{
+		BatchSize:   outboxBatchSize,
+		ClaimTTL:    outboxClaimTTL,
+		BackoffBase: outboxBackoffBase,
+		BackoffMax:  outboxBackoffMax,
+		MaxAttempts: outboxMaxAttempts,
+	})
+
+	outbox := newOutboxRelay(usecaseRelay, log)
+	outbox.run(ctx)
+	defer outbox.wait()
+
+	usecaseCreateWebhook := ucCreateWebhook.NewUsecase(webhookRepo, guidGen)
+	usecaseDeliverWebhook := ucDeliverWebhook.NewUsecase(webhook.NewClient(webhookTimeout), webhookRepo, ucDeliverWebhook.Limits{
+		Workers:     webhookWorkers,
+		BatchSize:   webhookBatchSize,
+		LeaseTTL:    webhookLeaseTTL,
+		BackoffBase: webhookBackoffBase,
+		BackoffMax:  webhookBackoffMax,
+		MaxAttempts: webhookMaxAttempts,
+	})
+
+	sender := newWebhookSender(usecaseDeliverWebhook, log)
+	sender.run(ctx)
+	defer sender.wait()
 
-	app := newApp(
+	cleanup := newSweeper(sweepInterval, log,
+		sweepTask{name: "idempotency_keys", sweep: func(ctx context.Context) (int64, error) {
+			return idempotencyRepo.DeleteExpired(ctx, time.Now().Add(-idempotencyKeyTTL))
+		}},
+		sweepTask{name: "refresh_tokens", sweep: func(ctx context.Context) (int64, error) {
+			return tokenRepo.DeleteExpiredRefreshTokens(ctx, time.Now())
+		}},
+		sweepTask{name: "revoked_tokens", sweep: func(ctx context.Context) (int64, error) {
+			return tokenRepo.DeleteExpiredRevocations(ctx, time.Now())
+		}},
+		sweepTask{name: "login_throttles", sweep: func(ctx context.Context) (int64, error) {
+			return loginThrottle.DeleteExpired(ctx, time.Now().Add(-cfg.LoginFailureWindow()))
+		}},
+	)
+	cleanup.run(ctx)
+	defer cleanup.wait()
+
+	opts := []option{
 		withRunAddr(cfg.RunAddrHost(), cfg.RunAddrPort()),
 		withLogger(log),
 
+		withAPIHandler(http.MethodGet, "/api/status", status.NewHandler(accrualBreaker, log).Handle, checkAuth),
+		withAPIHandler(http.MethodGet, "/.well-known/jwks.json", jwks.NewHandler(keys, log).Handle),
+
 		withAPIHandler(http.MethodPost, "/api/user/register", register.NewHandler(usecaseRegister, log).Handle),
-		withAPIHandler(http.MethodPost, "/api/user/login", login.NewHandler(usecaseLogin, log).Handle),
+		withAPIHandler(http.MethodPost, "/api/user/login", login.NewHandler(usecaseLogin, cfg.TrustedProxies(), log).Handle),
+		withAPIHandler(http.MethodPost, "/api/user/token/refresh", refresh.NewHandler(usecaseToken, log).Handle),
+		withAPIHandler(http.MethodPost, "/api/user/logout", logout.NewHandler(usecaseToken, log).Handle, checkAuth),
+		withAPIHandler(http.MethodPost, "/api/user/password", change.NewHandler(usecasePassword, log).Handle, checkAuth),
 
-		withAPIHandler(http.MethodPost, "/api/user/orders", upload.NewHandler(usecaseCreateAccrual, log).Handle, middleware.CheckAuth(jwtBuilder, log)),
-		withAPIHandler(http.MethodGet, "/api/user/orders", list.NewHandler(accrualRepo, log).Handle, middleware.CheckAuth(jwtBuilder, log)),
+		withAPIHandler(http.MethodPost, "/api/user/orders", upload.NewHandler(usecaseCreateAccrual, log).Handle, checkAuth, middleware.Idempotency(idempotencyRepo, idempotencyLockTTL, log)),
+		withAPIHandler(http.MethodGet, "/api/user/orders", list.NewHandler(accrualRepo, log).Handle, checkAuth),
 
-		withAPIHandler(http.MethodGet, "/api/user/balance", get.NewHandler(accountRepo, log).Handle, middleware.CheckAuth(jwtBuilder, log)),
-		withAPIHandler(http.MethodPost, "/api/user/balance/withdraw", withdraw.NewHandler(usecaseCreateWithdraw, log).Handle, middleware.CheckAuth(jwtBuilder, log)),
-		withAPIHandler(http.MethodGet, "/api/user/withdrawals", withdrawals.NewHandler(transactionRepo, log).Handle, middleware.CheckAuth(jwtBuilder, log)),
-	)
+		withAPIHandler(http.MethodGet, "/api/user/balance", get.NewHandler(accountRepo, log).Handle, checkAuth),
+		withAPIHandler(http.MethodPost, "/api/user/balance/withdraw", withdraw.NewHandler(usecaseCreateWithdraw, log).Handle, checkAuth, middleware.Idempotency(idempotencyRepo, idempotencyLockTTL, log)),
+		withAPIHandler(http.MethodGet, "/api/user/withdrawals", withdrawals.NewHandler(transactionRepo, log).Handle, checkAuth),
+		withAPIHandler(http.MethodGet, "/api/user/transactions", transactions.NewHandler(transactionRepo, log).Handle, checkAuth),
+		withAPIHandler(http.MethodGet, "/api/user/transactions/export", export.NewHandler(transactionRepo, log).Handle, checkAuth),
+
+		withAPIHandler(http.MethodPost, "/api/user/webhooks", webhookCreate.NewHandler(usecaseCreateWebhook, log).Handle, checkAuth),
+		withAPIHandler(http.MethodGet, "/api/user/webhooks", webhookList.NewHandler(webhookRepo, log).Handle, checkAuth),
+		withAPIHandler(http.MethodDelete, "/api/user/webhooks/{id}", remove.NewHandler(webhookRepo, log).Handle, checkAuth),
+		withAPIHandler(http.MethodGet, "/api/user/webhooks/{id}/deliveries", deliveries.NewHandler(webhookRepo, log).Handle, checkAuth),
+		withAPIHandler(http.MethodPost, "/api/user/webhooks/deliveries/{id}/redeliver", redeliver.NewHandler(webhookRepo, log).Handle, checkAuth),
+	}
+
+	if resetNotifier != nil {
+		opts = append(opts,
+			withAPIHandler(http.MethodPost, "/api/user/password/reset", reset.NewHandler(usecasePassword, log).Handle),
+			withAPIHandler(http.MethodPost, "/api/user/password/reset/confirm", confirm.NewHandler(usecasePassword, log).Handle),
+		)
+	} else {
+		log.Warn("Password reset is disabled, there is no channel to deliver reset tokens")
+	}
+
+	// the accrual system pushes statuses only if it shares a secret with us, polling goes on either way
+	if secret := cfg.AccrualCallbackSecret(); secret != "" {
+		usecaseApplyAccrual := ucApplyAccrual.NewUsecase(accrualRepo, guidGen)
+		opts = append(opts, withAPIHandler(http.MethodPost, "/internal/accrual/callback", callback.NewHandler(usecaseApplyAccrual, secret, log).Handle))
+	}
+
+	app := newApp(opts...)
 
 	if err := app.run(ctx); err != nil {
 		if errors.Is(err, http.ErrServerClosed) {
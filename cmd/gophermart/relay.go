@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/outbox/relay"
+)
+
+type outboxRelay struct {
+	usecase *relay.Usecase
+	log     logger.Logger
+	wg      sync.WaitGroup
+}
+
+func newOutboxRelay(usecase *relay.Usecase, log logger.Logger) *outboxRelay {
+	return &outboxRelay{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+func (r *outboxRelay) run(ctx context.Context) {
+	r.wg.Add(1)
+
+	go func() {
+		defer r.wg.Done()
+
+		r.log.Info("Outbox relay started")
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.log.Info("Stopped outbox relay")
+				return
+			case <-ticker.C:
+				result, err := r.usecase.Relay(ctx)
+				for _, failErr := range result.Failed {
+					r.log.WithError(failErr).Warn("Failed to publish event")
+				}
+				if err != nil {
+					r.log.WithError(err).Error("Failed to relay outbox")
+					continue
+				}
+
+				if result.Published > 0 {
+					r.log.WithField("published", result.Published).Debug("Outbox relayed")
+				}
+			}
+		}
+	}()
+}
+
+// wait blocks until the batch in flight is marked as published.
+func (r *outboxRelay) wait() {
+	r.wg.Wait()
+}
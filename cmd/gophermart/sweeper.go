@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+)
+
+// sweepTask deletes rows that are no longer needed and returns how many.
+type sweepTask struct {
+	name  string
+	sweep func(ctx context.Context) (int64, error)
+}
+
+type sweeper struct {
+	tasks    []sweepTask
+	interval time.Duration
+	log      logger.Logger
+	wg       sync.WaitGroup
+}
+
+func newSweeper(interval time.Duration, log logger.Logger, tasks ...sweepTask) *sweeper {
+	return &sweeper{
+		tasks:    tasks,
+		interval: interval,
+		log:      log,
+	}
+}
+
+func (s *sweeper) run(ctx context.Context) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		s.log.Info("Sweeper started")
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.log.Info("Stopped sweeper")
+				return
+			case <-ticker.C:
+				for _, task := range s.tasks {
+					deleted, err := task.sweep(ctx)
+					if err != nil {
+						s.log.WithError(err).WithField("task", task.name).Error("Failed to sweep")
+						continue
+					}
+
+					if deleted > 0 {
+						s.log.WithField("task", task.name).WithField("deleted", deleted).Debug("Swept")
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (s *sweeper) wait() {
+	s.wg.Wait()
+}
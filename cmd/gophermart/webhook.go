@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/webhook/deliver"
+)
+
+type webhookSender struct {
+	usecase *deliver.Usecase
+	log     logger.Logger
+	wg      sync.WaitGroup
+}
+
+func newWebhookSender(usecase *deliver.Usecase, log logger.Logger) *webhookSender {
+	return &webhookSender{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+func (s *webhookSender) run(ctx context.Context) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		s.log.Info("Webhook sender started")
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.log.Info("Stopped webhook sender")
+				return
+			case <-ticker.C:
+				results, err := s.usecase.Deliver(ctx)
+				for _, result := range results {
+					s.logResult(result)
+				}
+				if err != nil {
+					s.log.WithError(err).Error("Failed to deliver webhooks")
+				}
+			}
+		}
+	}()
+}
+
+func (s *webhookSender) logResult(result deliver.Result) {
+	log := s.log.
+		WithField("delivery", result.DeliveryGUID).
+		WithField("webhook", result.WebhookGUID).
+		WithField("event", string(result.EventType)).
+		WithField("status_code", result.StatusCode)
+
+	switch {
+	case result.Err == nil:
+		log.Info("Webhook delivered")
+	case result.Status == model.DeliveryFailed:
+		log.WithError(result.Err).Error("Webhook delivery failed, attempts exhausted")
+	default:
+		log.WithError(result.Err).Warn("Webhook delivery failed")
+	}
+}
+
+// wait blocks until the requests in flight are finished and recorded.
+func (s *webhookSender) wait() {
+	s.wg.Wait()
+}
@@ -2,20 +2,26 @@ package main
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
 	"github.com/bjlag/go-loyalty/internal/usecase/accrual/update"
 )
 
 type accrualWorker struct {
 	usecase *update.Usecase
+	breaker *client.Breaker
 	log     logger.Logger
+	wg      sync.WaitGroup
 }
 
-func newAccrualWorker(usecase *update.Usecase, log logger.Logger) *accrualWorker {
+func newAccrualWorker(usecase *update.Usecase, breaker *client.Breaker, log logger.Logger) *accrualWorker {
 	return &accrualWorker{
 		usecase: usecase,
+		breaker: breaker,
 		log:     log,
 	}
 }
@@ -23,7 +29,13 @@ func newAccrualWorker(usecase *update.Usecase, log logger.Logger) *accrualWorker
 func (w *accrualWorker) run(ctx context.Context) {
 	resultCh := make(chan *update.Result)
 
+	w.wg.Add(2)
+
 	go func() {
+		defer w.wg.Done()
+		// Update doesn't send after it returns, so the channel is closed once polling is stopped
+		defer close(resultCh)
+
 		w.log.Info("Accrual worker started")
 
 		ticker := time.NewTicker(time.Second)
@@ -35,6 +47,11 @@ func (w *accrualWorker) run(ctx context.Context) {
 				w.log.Info("Stopped accrual worker")
 				return
 			case <-ticker.C:
+				// the accrual system is down, orders are not even leased until a probe is allowed
+				if w.breaker.State() == client.StateOpen {
+					continue
+				}
+
 				err := w.usecase.Update(ctx, resultCh)
 				if err != nil {
 					w.log.WithError(err).Error("Failed to update accrual")
@@ -45,6 +62,8 @@ func (w *accrualWorker) run(ctx context.Context) {
 	}()
 
 	go func() {
+		defer w.wg.Done()
+
 		for result := range resultCh {
 			if result == nil {
 				continue
@@ -52,8 +71,13 @@ func (w *accrualWorker) run(ctx context.Context) {
 
 			log := w.log.
 				WithField("order", result.OrderNumber).
-				WithField("user", result.UserGUID)
+				WithField("user", result.UserGUID).
+				WithField("request_id", result.RequestID)
 
+			if errors.Is(result.Err, update.ErrGaveUp) {
+				log.WithError(result.Err).Warn("Accrual given up")
+				continue
+			}
 			if result.Err != nil {
 				log.WithError(result.Err).Error("Failed to update accrual")
 				continue
@@ -67,3 +91,8 @@ func (w *accrualWorker) run(ctx context.Context) {
 		}
 	}()
 }
+
+// wait blocks until the orders in flight are saved and their results are logged.
+func (w *accrualWorker) wait() {
+	w.wg.Wait()
+}
@@ -0,0 +1,99 @@
+package callback
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	serviceAccrual "github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/validator"
+	"github.com/bjlag/go-loyalty/internal/usecase/accrual/apply"
+)
+
+const (
+	// HeaderSignature carries "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">", the scheme of our own
+	// webhooks, signed with the shared secret.
+	HeaderSignature = "X-Accrual-Signature"
+
+	// signatureTolerance how old a signature may be, so a captured request cannot be replayed later
+	signatureTolerance = 5 * time.Minute
+	maxBodySize        = 64 << 10
+)
+
+type Handler struct {
+	usecase *apply.Usecase
+	secret  string
+	log     logger.Logger
+}
+
+func NewHandler(usecase *apply.Usecase, secret string, log logger.Logger) *Handler {
+	return &Handler{
+		usecase: usecase,
+		secret:  secret,
+		log:     log,
+	}
+}
+
+// Handle applies an order status pushed by the accrual system. A repeated or stale push is answered 200, so the
+// accrual system doesn't retry it; orders nobody has uploaded are 404.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = webhook.Verify(h.secret, r.Header.Get(HeaderSignature), body, signatureTolerance)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid accrual callback signature")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var req serviceAccrual.Response
+	if err = json.Unmarshal(body, &req); err == nil && !validator.CheckLuhn(req.Order) {
+		err = errors.New("invalid order")
+	}
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	log := h.log.WithField("order", req.Order)
+
+	change, err := h.usecase.ApplyPushed(r.Context(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, apply.ErrStatusUnchanged), errors.Is(err, apply.ErrStatusOutdated),
+			errors.Is(err, repository.ErrAccrualAlreadyFinal):
+			w.WriteHeader(http.StatusOK)
+			return
+		case errors.Is(err, apply.ErrOrderNotFound):
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		case errors.Is(err, apply.ErrUnknownStatus):
+			log.WithError(err).Warn("Invalid request")
+			http.Error(w, http.StatusText(http.StatusUnprocessableEntity), http.StatusUnprocessableEntity)
+			return
+		}
+
+		log.WithError(err).Error("Failed to apply pushed accrual")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	log.WithField("user", change.Old.UserGUID).
+		WithField("old_status", change.Old.Status.String()).
+		WithField("new_status", change.NewStatus.String()).
+		WithField("new_accrual", change.NewAccrual).
+		Info("Accrual pushed")
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,184 @@
+package callback_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bjlag/go-loyalty/internal/api/handler/accrual/callback"
+	mockGuid "github.com/bjlag/go-loyalty/internal/infrastructure/guid/mock"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/accrual/apply"
+)
+
+const (
+	secret      = "callback_secret"
+	userGUID    = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+	orderNumber = "2377225624"
+)
+
+func TestHandler_Handle(t *testing.T) {
+	serve := func(t *testing.T, repo *mockRep.MockAccrualRepo, body, signature string) int {
+		ctrl := gomock.NewController(t)
+		log := mockLog.NewMockLogger(ctrl)
+		log.EXPECT().WithError(gomock.Any()).Return(log).AnyTimes()
+		log.EXPECT().WithField(gomock.Any(), gomock.Any()).Return(log).AnyTimes()
+		log.EXPECT().Warn(gomock.Any()).AnyTimes()
+		log.EXPECT().Error(gomock.Any()).AnyTimes()
+		log.EXPECT().Info(gomock.Any()).AnyTimes()
+
+		guidGen := mockGuid.NewMockIGenerator(ctrl)
+		guidGen.EXPECT().Generate().Return("c0d1b0d4-8c4d-4f0a-9f7e-0b7c1d8e4a11").AnyTimes()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/internal/accrual/callback", strings.NewReader(body))
+		r.Header.Set(callback.HeaderSignature, signature)
+
+		callback.NewHandler(apply.NewUsecase(repo, guidGen), secret, log).Handle(w, r)
+
+		return w.Code
+	}
+
+	sign := func(body string) string {
+		return webhook.Sign(secret, time.Now(), []byte(body))
+	}
+
+	inWork := func(repo *mockRep.MockAccrualRepo, status model.AccrualStatus) {
+		repo.EXPECT().AccrualByOrderNumber(gomock.Any(), orderNumber).Return(&model.Accrual{
+			OrderNumber: orderNumber,
+			UserGUID:    userGUID,
+			Status:      status,
+		}, nil)
+	}
+
+	processed := fmt.Sprintf(`{"order":%q,"status":"PROCESSED","accrual":500.5}`, orderNumber)
+
+	t.Run("processed_is_credited", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.Processing)
+		repo.EXPECT().
+			AddBalance(gomock.Any(), gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ any, accrual model.Accrual, transaction model.Transaction) error {
+				assert.Equal(t, model.Processed, accrual.Status)
+				assert.Equal(t, model.NewPoints(500, 50), accrual.Accrual)
+				assert.Equal(t, userGUID, transaction.AccountGUID)
+				assert.Equal(t, model.NewPoints(500, 50), transaction.Sum)
+				return nil
+			})
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, processed, sign(processed)))
+	})
+
+	t.Run("status_without_accrual", func(t *testing.T) {
+		body := fmt.Sprintf(`{"order":%q,"status":"INVALID"}`, orderNumber)
+
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.New)
+		repo.EXPECT().UpdateStatus(gomock.Any(), orderNumber, model.Invalid).Return(nil)
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, body, sign(body)))
+	})
+
+	t.Run("accrual_is_credited_only_when_processed", func(t *testing.T) {
+		body := fmt.Sprintf(`{"order":%q,"status":"PROCESSING","accrual":500.5}`, orderNumber)
+
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.New)
+		repo.EXPECT().UpdateStatus(gomock.Any(), orderNumber, model.Processing).Return(nil)
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, body, sign(body)))
+
+		repo = mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.Processing)
+		repo.EXPECT().AddBalance(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(1)
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, processed, sign(processed)))
+	})
+
+	t.Run("repeated_push", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.Processed)
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, processed, sign(processed)))
+	})
+
+	t.Run("outdated_push", func(t *testing.T) {
+		body := fmt.Sprintf(`{"order":%q,"status":"REGISTERED"}`, orderNumber)
+
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.Processing)
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, body, sign(body)))
+	})
+
+	t.Run("status_of_finished_order", func(t *testing.T) {
+		body := fmt.Sprintf(`{"order":%q,"status":"INVALID"}`, orderNumber)
+
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.Processed)
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, body, sign(body)))
+	})
+
+	t.Run("finished_while_applying", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.Processing)
+		repo.EXPECT().AddBalance(gomock.Any(), gomock.Any(), gomock.Any()).Return(repository.ErrAccrualAlreadyFinal)
+
+		assert.Equal(t, http.StatusOK, serve(t, repo, processed, sign(processed)))
+	})
+
+	t.Run("unknown_order", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		repo.EXPECT().AccrualByOrderNumber(gomock.Any(), orderNumber).Return(nil, nil)
+
+		assert.Equal(t, http.StatusNotFound, serve(t, repo, processed, sign(processed)))
+	})
+
+	t.Run("unknown_status", func(t *testing.T) {
+		body := fmt.Sprintf(`{"order":%q,"status":"LOST"}`, orderNumber)
+
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		inWork(repo, model.New)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, serve(t, repo, body, sign(body)))
+	})
+
+	rejected := []struct {
+		name      string
+		body      string
+		signature string
+		want      int
+	}{
+		{name: "no_signature", body: processed, want: http.StatusUnauthorized},
+		{name: "wrong_secret", body: processed, signature: webhook.Sign("another", time.Now(), []byte(processed)), want: http.StatusUnauthorized},
+		{name: "replayed", body: processed, signature: webhook.Sign(secret, time.Now().Add(-time.Hour), []byte(processed)), want: http.StatusUnauthorized},
+		{name: "not_json", body: "order=1", signature: sign("order=1"), want: http.StatusBadRequest},
+		{name: "invalid_order", body: `{"order":"123","status":"PROCESSED"}`, signature: sign(`{"order":"123","status":"PROCESSED"}`), want: http.StatusBadRequest},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			assert.Equal(t, tt.want, serve(t, mockRep.NewMockAccrualRepo(ctrl), tt.body, tt.signature))
+		})
+	}
+}
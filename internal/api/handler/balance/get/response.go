@@ -1,6 +1,8 @@
 package get
 
+import "github.com/bjlag/go-loyalty/internal/model"
+
 type Response struct {
-	Current   float64 `json:"current"`
-	Withdrawn float64 `json:"withdrawn"`
+	Current   model.Points `json:"current"`
+	Withdrawn model.Points `json:"withdrawn"`
 }
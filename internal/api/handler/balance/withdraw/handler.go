@@ -42,9 +42,13 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 
 	err = h.usecase.CreateWithdraw(r.Context(), userGUID, req.Order, req.Sum)
 	if err != nil {
-		if errors.Is(err, create.ErrInsufficientBalanceOnAccount) {
+		switch {
+		case errors.Is(err, create.ErrInsufficientBalanceOnAccount):
 			http.Error(w, http.StatusText(http.StatusPaymentRequired), http.StatusPaymentRequired)
 			return
+		case errors.Is(err, create.ErrOrderAlreadyPaid):
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
 		}
 
 		h.log.WithError(err).Error("Withdraw error")
@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/bjlag/go-loyalty/internal/infrastructure/validator"
+	"github.com/bjlag/go-loyalty/internal/model"
 )
 
 var (
@@ -13,8 +14,8 @@ var (
 )
 
 type Request struct {
-	Order string  `json:"order"`
-	Sum   float64 `json:"sum"`
+	Order string       `json:"order"`
+	Sum   model.Points `json:"sum"`
 }
 
 func (r *Request) UnmarshalJSON(b []byte) error {
@@ -0,0 +1,50 @@
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+)
+
+// MaxAge is how long the keys are cached by the services checking our tokens. A new key is published at least this
+// long before it signs.
+const MaxAge = 5 * time.Minute
+
+var cacheControl = fmt.Sprintf("public, max-age=%d", int(MaxAge.Seconds()))
+
+type Handler struct {
+	keys *auth.KeySet
+	log  logger.Logger
+}
+
+// NewHandler publishes the keys of the set. Without a set tokens are signed with the shared secret, which is never
+// published, and the key list is empty.
+func NewHandler(keys *auth.KeySet, log logger.Logger) *Handler {
+	return &Handler{
+		keys: keys,
+		log:  log,
+	}
+}
+
+func (h *Handler) Handle(w http.ResponseWriter, _ *http.Request) {
+	resp := &Response{
+		Keys: []auth.JWK{},
+	}
+
+	if h.keys != nil {
+		resp.Keys = h.keys.JWKS()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControl)
+
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		h.log.WithError(err).Error("Could not write response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,7 @@
+package jwks
+
+import "github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+
+type Response struct {
+	Keys []auth.JWK `json:"keys"`
+}
@@ -2,6 +2,7 @@ package list
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strings"
 
@@ -9,6 +10,7 @@ import (
 	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
 )
 
 type Handler struct {
@@ -32,26 +34,55 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rows, err := h.repo.AccrualsByUser(ctx, userGUID)
+	req, err := parseRequest(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	var rows []model.Accrual
+	if req.Empty() {
+		rows, err = h.repo.AccrualsByUser(ctx, userGUID)
+	} else {
+		rows, err = h.repo.AccrualsByUserFiltered(ctx, userGUID, req.Filter())
+	}
 	if err != nil {
 		h.log.WithError(err).Error("Could not get accruals")
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
-	if rows == nil {
+	if len(rows) == 0 {
 		http.Error(w, http.StatusText(http.StatusNoContent), http.StatusNoContent)
 		return
 	}
 
+	if req.Limit > 0 && len(rows) > req.Limit {
+		rows = rows[:req.Limit]
+		last := rows[len(rows)-1]
+
+		cursor := api.EncodeCursor(last.UploadedAt, last.OrderNumber)
+
+		w.Header().Set("X-Next-Cursor", cursor)
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", api.NextPageURL(r.URL, cursor)))
+	}
+
 	resp := make(Response, 0, len(rows))
 	for _, row := range rows {
-		resp = append(resp, Order{
+		order := Order{
 			Number:     row.OrderNumber,
 			Status:     strings.ToUpper(row.Status.String()),
 			Accrual:    row.Accrual,
 			UploadedAt: api.Datetime(row.UploadedAt),
-		})
+		}
+
+		// errors of orders in work are transient and internal, they are not shown
+		if row.Status == model.Invalid || row.GaveUp {
+			order.Reason = row.LastError
+		}
+
+		resp = append(resp, order)
 	}
 
 	data, err := json.Marshal(resp)
@@ -0,0 +1,217 @@
+package list_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/api/handler/order/list"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+
+type order struct {
+	Number     string    `json:"number"`
+	Status     string    `json:"status"`
+	UploadedAt time.Time `json:"uploaded_at"`
+	Reason     string    `json:"reason"`
+}
+
+func accruals(n int) []model.Accrual {
+	start := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	result := make([]model.Accrual, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, model.Accrual{
+			OrderNumber: "1234567890" + string(rune('0'+i)),
+			UserGUID:    userGUID,
+			Status:      model.Processed,
+			Accrual:     model.NewPoints(500, 0),
+			UploadedAt:  start.Add(-time.Duration(i) * time.Minute),
+		})
+	}
+
+	return result
+}
+
+func TestHandler_Handle(t *testing.T) {
+	serve := func(t *testing.T, repo *mockRep.MockAccrualRepo, target string) *http.Response {
+		ctrl := gomock.NewController(t)
+		log := mockLog.NewMockLogger(ctrl)
+		log.EXPECT().WithError(gomock.Any()).Return(log).AnyTimes()
+		log.EXPECT().Warn(gomock.Any()).AnyTimes()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserGUIDKey, userGUID))
+
+		list.NewHandler(repo, log).Handle(w, r)
+
+		return w.Result()
+	}
+
+	t.Run("unpaginated_by_default", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		repo.EXPECT().AccrualsByUser(gomock.Any(), userGUID).Return(accruals(3), nil)
+
+		resp := serve(t, repo, "/api/user/orders")
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		var body []order
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, body, 3)
+		assert.Empty(t, resp.Header.Get("Link"))
+		assert.Equal(t, "PROCESSED", body[0].Status)
+	})
+
+	t.Run("reason_of_invalid_order", func(t *testing.T) {
+		rows := accruals(3)
+		rows[0].Status = model.Processing
+		rows[0].GaveUp = true
+		rows[0].LastError = "no final status from the accrual system after 30 checks"
+		rows[1].Status = model.Processing
+		rows[1].LastError = "order not registered"
+		rows[2].Status = model.Invalid
+		rows[2].LastError = "rejected"
+
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		repo.EXPECT().AccrualsByUser(gomock.Any(), userGUID).Return(rows, nil)
+
+		resp := serve(t, repo, "/api/user/orders")
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		var body []order
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		require.Len(t, body, 3)
+		assert.Equal(t, "PROCESSING", body[0].Status)
+		assert.Equal(t, "no final status from the accrual system after 30 checks", body[0].Reason)
+		assert.Empty(t, body[1].Reason)
+		assert.Equal(t, "rejected", body[2].Reason)
+	})
+
+	t.Run("no_content", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		repo.EXPECT().AccrualsByUserFiltered(gomock.Any(), userGUID, gomock.Any()).Return(nil, nil)
+
+		resp := serve(t, repo, "/api/user/orders?status=invalid")
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("first_page_and_next_cursor", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		repo.EXPECT().
+			AccrualsByUserFiltered(gomock.Any(), userGUID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, filter model.AccrualFilter) ([]model.Accrual, error) {
+				assert.Equal(t, 3, filter.Limit)
+				assert.Nil(t, filter.After)
+				assert.Equal(t, []model.AccrualStatus{model.New, model.Processed}, filter.Statuses)
+				return accruals(3), nil
+			})
+
+		resp := serve(t, repo, "/api/user/orders?limit=2&status=NEW,processed")
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		var body []order
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body, 2)
+
+		cursor := resp.Header.Get("X-Next-Cursor")
+		require.NotEmpty(t, cursor)
+
+		link := resp.Header.Get("Link")
+		assert.True(t, strings.HasSuffix(link, `>; rel="next"`))
+		assert.Contains(t, link, "cursor="+url.QueryEscape(cursor))
+		assert.Contains(t, link, "limit=2")
+
+		t.Run("next_page", func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			repo := mockRep.NewMockAccrualRepo(ctrl)
+			repo.EXPECT().
+				AccrualsByUserFiltered(gomock.Any(), userGUID, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ string, filter model.AccrualFilter) ([]model.Accrual, error) {
+					require.NotNil(t, filter.After)
+					assert.Equal(t, body[1].Number, filter.After.OrderNumber)
+					assert.True(t, body[1].UploadedAt.Equal(filter.After.UploadedAt))
+					return accruals(3)[2:], nil
+				})
+
+			resp := serve(t, repo, "/api/user/orders?limit=2&cursor="+cursor)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Empty(t, resp.Header.Get("X-Next-Cursor"))
+		})
+	})
+
+	t.Run("date_range", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		repo.EXPECT().
+			AccrualsByUserFiltered(gomock.Any(), userGUID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, filter model.AccrualFilter) ([]model.Accrual, error) {
+				assert.Equal(t, time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC), filter.UploadedFrom)
+				assert.Equal(t, time.Date(2024, 11, 20, 15, 4, 5, 0, time.FixedZone("", 3*60*60)), filter.UploadedTo)
+				assert.Zero(t, filter.Limit)
+				return accruals(1), nil
+			})
+
+		resp := serve(t, repo, "/api/user/orders?from=2024-11-01&to="+url.QueryEscape("2024-11-20T15:04:05+03:00"))
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("bad_request", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/user/orders?limit=0",
+			"/api/user/orders?limit=abc",
+			"/api/user/orders?limit=100000",
+			"/api/user/orders?cursor=broken",
+			"/api/user/orders?status=unknown",
+			"/api/user/orders?from=yesterday",
+		} {
+			ctrl := gomock.NewController(t)
+
+			resp := serve(t, mockRep.NewMockAccrualRepo(ctrl), target)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, target)
+		}
+	})
+}
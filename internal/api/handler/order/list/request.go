@@ -0,0 +1,93 @@
+package list
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var errInvalidStatus = errors.New("invalid status")
+
+// Request query parameters of the orders list. Without any of them the whole list is returned, as the spec requires.
+type Request struct {
+	Limit    int
+	Cursor   *model.AccrualCursor
+	Statuses []model.AccrualStatus
+	From     time.Time
+	To       time.Time
+}
+
+func parseRequest(r *http.Request) (*Request, error) {
+	q := r.URL.Query()
+	req := &Request{}
+
+	var (
+		errs []error
+		err  error
+	)
+
+	if req.Limit, err = api.ParseLimit(q.Get("limit")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		uploadedAt, orderNumber, err := api.DecodeCursor(v)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		req.Cursor = &model.AccrualCursor{
+			UploadedAt:  uploadedAt,
+			OrderNumber: orderNumber,
+		}
+	}
+
+	for _, v := range q["status"] {
+		for _, s := range strings.Split(v, ",") {
+			status, ok := model.ParseAccrualStatus(strings.TrimSpace(s))
+			if !ok {
+				errs = append(errs, fmt.Errorf("%w: %q", errInvalidStatus, s))
+				continue
+			}
+			req.Statuses = append(req.Statuses, status)
+		}
+	}
+
+	if req.From, err = api.ParseDate(q.Get("from")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if req.To, err = api.ParseDateTo(q.Get("to")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (r Request) Filter() model.AccrualFilter {
+	filter := model.AccrualFilter{
+		Statuses:     r.Statuses,
+		UploadedFrom: r.From,
+		UploadedTo:   r.To,
+		After:        r.Cursor,
+	}
+
+	// one extra row tells whether there is a next page
+	if r.Limit > 0 {
+		filter.Limit = r.Limit + 1
+	}
+
+	return filter
+}
+
+func (r Request) Empty() bool {
+	return r.Limit == 0 && r.Cursor == nil && len(r.Statuses) == 0 && r.From.IsZero() && r.To.IsZero()
+}
@@ -2,6 +2,7 @@ package list
 
 import (
 	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
 )
 
 type Response []Order
@@ -9,6 +10,8 @@ type Response []Order
 type Order struct {
 	Number     string       `json:"number"`
 	Status     string       `json:"status"`
-	Accrual    float64      `json:"accrual"`
+	Accrual    model.Points `json:"accrual"`
 	UploadedAt api.Datetime `json:"uploaded_at"`
+	// Reason why the order was rejected or is no longer checked
+	Reason string `json:"reason,omitempty"`
 }
@@ -0,0 +1,43 @@
+package status
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+)
+
+type Handler struct {
+	breaker *client.Breaker
+	log     logger.Logger
+}
+
+func NewHandler(breaker *client.Breaker, log logger.Logger) *Handler {
+	return &Handler{
+		breaker: breaker,
+		log:     log,
+	}
+}
+
+// Handle reports the state of the circuit breaker around the accrual system. The service itself is up as long as
+// it answers, so the response is 200 even if the circuit is open. Errors of the accrual system are only logged: they
+// tell about the internal network.
+func (h *Handler) Handle(w http.ResponseWriter, _ *http.Request) {
+	stats := h.breaker.Stats()
+
+	resp := &Response{
+		Accrual: Circuit{
+			State:    stats.State.String(),
+			Failures: stats.Failures,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		h.log.WithError(err).Error("Could not write response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,41 @@
+package status_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/api/handler/status"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+)
+
+// down fails every request with an error that names an internal host.
+type down struct {
+	client.Client
+}
+
+func (down) Do(context.Context, string, string, io.Reader, ...client.RequestOption) (*http.Response, error) {
+	return nil, errors.New("dial tcp 10.0.0.7:8080: connection refused")
+}
+
+func TestHandler_Handle(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	breaker := client.NewBreaker(down{}, client.BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute})
+	_, _ = breaker.Get(context.Background(), "/")
+
+	w := httptest.NewRecorder()
+	status.NewHandler(breaker, mockLog.NewMockLogger(ctrl)).Handle(w, httptest.NewRequest(http.MethodGet, "/api/status", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"accrual":{"state":"open","failures":1}}`, w.Body.String())
+}
@@ -0,0 +1,10 @@
+package status
+
+type Response struct {
+	Accrual Circuit `json:"accrual"`
+}
+
+type Circuit struct {
+	State    string `json:"state"`
+	Failures int    `json:"failures"`
+}
@@ -0,0 +1,90 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type encoder interface {
+	ContentType() string
+	Begin() error
+	Encode(entry model.StatementEntry) error
+	// Flush pushes buffered rows to the underlying writer.
+	Flush() error
+}
+
+func newEncoder(format string, w io.Writer) encoder {
+	if format == formatJSONL {
+		return &jsonlEncoder{enc: json.NewEncoder(w)}
+	}
+
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (e *csvEncoder) Begin() error {
+	return e.w.Write([]string{"order", "type", "sum", "balance", "processed_at"})
+}
+
+func (e *csvEncoder) Encode(entry model.StatementEntry) error {
+	return e.w.Write([]string{
+		entry.OrderNumber,
+		strings.ToUpper(entry.Type.String()),
+		entry.Sum.String(),
+		entry.Balance.String(),
+		entry.ProcessedAt.Format(time.RFC3339),
+	})
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type jsonlEncoder struct {
+	enc *json.Encoder
+}
+
+type line struct {
+	Order       string       `json:"order"`
+	Type        string       `json:"type"`
+	Sum         model.Points `json:"sum"`
+	Balance     model.Points `json:"balance"`
+	ProcessedAt api.Datetime `json:"processed_at"`
+}
+
+func (e *jsonlEncoder) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (e *jsonlEncoder) Begin() error {
+	return nil
+}
+
+// Encode writes the entry as one line: json.Encoder terminates every value with a newline.
+func (e *jsonlEncoder) Encode(entry model.StatementEntry) error {
+	return e.enc.Encode(line{
+		Order:       entry.OrderNumber,
+		Type:        strings.ToUpper(entry.Type.String()),
+		Sum:         entry.Sum,
+		Balance:     entry.Balance,
+		ProcessedAt: api.Datetime(entry.ProcessedAt),
+	})
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return nil
+}
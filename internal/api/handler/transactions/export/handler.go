@@ -0,0 +1,109 @@
+package export
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// flushEvery rows the response is flushed to the client, so a long statement arrives while it is being read.
+const flushEvery = 500
+
+type Handler struct {
+	repo repository.TransactionRepo
+	log  logger.Logger
+}
+
+func NewHandler(repo repository.TransactionRepo, log logger.Logger) *Handler {
+	return &Handler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userGUID, err := auth.UserGUIDFromContext(ctx)
+	if err != nil {
+		h.log.WithError(err).Error("Could not get user GUID from context")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	req, err := parseRequest(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	enc := newEncoder(req.Format, w)
+	rc := http.NewResponseController(w)
+
+	// Headers are sent with the first row, so an error before it is still reported with a proper status.
+	var started bool
+	begin := func() error {
+		started = true
+
+		w.Header().Set("Content-Type", enc.ContentType())
+		w.Header().Set("Content-Disposition", `attachment; filename="statement.`+req.Format+`"`)
+		w.WriteHeader(http.StatusOK)
+
+		return enc.Begin()
+	}
+
+	var rows int
+	err = h.repo.StreamStatement(ctx, userGUID, req.Filter(), func(entry model.StatementEntry) error {
+		if !started {
+			if err := begin(); err != nil {
+				return err
+			}
+		}
+
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%flushEvery == 0 {
+			return flush(enc, rc)
+		}
+
+		return nil
+	})
+	if err == nil && !started {
+		err = begin()
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+
+	if err != nil {
+		if !started {
+			h.log.WithError(err).Error("Could not export account statement")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		// The status is already sent. Abort the connection so the client doesn't take a truncated file for a complete one.
+		h.log.WithError(err).WithField("rows", rows).Error("Account statement export interrupted")
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func flush(enc encoder, rc *http.ResponseController) error {
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+
+	err := rc.Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package export_test
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/api/handler/transactions/export"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+
+func statement() []model.StatementEntry {
+	processedAt := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	return []model.StatementEntry{
+		{
+			Transaction: model.NewAddTransaction("1f2e3d4c-5b6a-4978-8695-a4b3c2d1e0f9", userGUID, "4561261212345467", model.NewPoints(500, 0), processedAt),
+			Balance:     model.NewPoints(500, 0),
+		},
+		{
+			Transaction: model.NewWithdrawTransaction("c0d1b0d4-8c4d-4f0a-9f7e-0b7c1d8e4a11", userGUID, "2377225624", model.NewPoints(100, 25), processedAt.Add(time.Hour)),
+			Balance:     model.NewPoints(399, 75),
+		},
+	}
+}
+
+func stream(entries []model.StatementEntry, err error) func(context.Context, string, model.StatementFilter, func(model.StatementEntry) error) error {
+	return func(_ context.Context, _ string, _ model.StatementFilter, fn func(model.StatementEntry) error) error {
+		for _, e := range entries {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+}
+
+func TestHandler_Handle(t *testing.T) {
+	serve := func(t *testing.T, repo *mockRep.MockTransactionRepo, target string) *httptest.ResponseRecorder {
+		ctrl := gomock.NewController(t)
+		log := mockLog.NewMockLogger(ctrl)
+		log.EXPECT().WithError(gomock.Any()).Return(log).AnyTimes()
+		log.EXPECT().WithField(gomock.Any(), gomock.Any()).Return(log).AnyTimes()
+		log.EXPECT().Warn(gomock.Any()).AnyTimes()
+		log.EXPECT().Error(gomock.Any()).AnyTimes()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserGUIDKey, userGUID))
+
+		export.NewHandler(repo, log).Handle(w, r)
+
+		return w
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().
+			StreamStatement(gomock.Any(), userGUID, model.StatementFilter{
+				ProcessedFrom: time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC),
+				OldestFirst:   true,
+			}, gomock.Any()).
+			DoAndReturn(stream(statement(), nil))
+
+		w := serve(t, repo, "/api/user/transactions/export?from=2024-11-01")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="statement.csv"`, w.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"order", "type", "sum", "balance", "processed_at"},
+			{"4561261212345467", "ACCRUAL", "500", "500", "2024-11-20T12:00:00Z"},
+			{"2377225624", "WITHDRAWAL", "100.25", "399.75", "2024-11-20T13:00:00Z"},
+		}, records)
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().StreamStatement(gomock.Any(), userGUID, gomock.Any(), gomock.Any()).DoAndReturn(stream(statement(), nil))
+
+		w := serve(t, repo, "/api/user/transactions/export?format=jsonl")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="statement.jsonl"`, w.Header().Get("Content-Disposition"))
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Equal(t, []string{
+			`{"order":"4561261212345467","type":"ACCRUAL","sum":500,"balance":500,"processed_at":"2024-11-20T12:00:00Z"}`,
+			`{"order":"2377225624","type":"WITHDRAWAL","sum":100.25,"balance":399.75,"processed_at":"2024-11-20T13:00:00Z"}`,
+		}, lines)
+	})
+
+	t.Run("empty_statement", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().StreamStatement(gomock.Any(), userGUID, gomock.Any(), gomock.Any()).DoAndReturn(stream(nil, nil))
+
+		w := serve(t, repo, "/api/user/transactions/export")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "order,type,sum,balance,processed_at\n", w.Body.String())
+	})
+
+	t.Run("error_before_first_row", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().StreamStatement(gomock.Any(), userGUID, gomock.Any(), gomock.Any()).DoAndReturn(stream(nil, errors.New("some error")))
+
+		w := serve(t, repo, "/api/user/transactions/export")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("error_mid_stream_aborts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().StreamStatement(gomock.Any(), userGUID, gomock.Any(), gomock.Any()).DoAndReturn(stream(statement(), errors.New("some error")))
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			serve(t, repo, "/api/user/transactions/export")
+		})
+	})
+
+	t.Run("bad_request", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/user/transactions/export?format=xlsx",
+			"/api/user/transactions/export?from=yesterday",
+		} {
+			ctrl := gomock.NewController(t)
+
+			w := serve(t, mockRep.NewMockTransactionRepo(ctrl), target)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const (
+	formatCSV   = "csv"
+	formatJSONL = "jsonl"
+)
+
+var errInvalidFormat = errors.New("invalid format")
+
+// Request query parameters of the statement export.
+type Request struct {
+	Format string
+	From   time.Time
+	To     time.Time
+}
+
+func parseRequest(r *http.Request) (*Request, error) {
+	q := r.URL.Query()
+	req := &Request{
+		Format: formatCSV,
+	}
+
+	var (
+		errs []error
+		err  error
+	)
+
+	if v := q.Get("format"); v != "" {
+		if v != formatCSV && v != formatJSONL {
+			errs = append(errs, fmt.Errorf("%w: %q", errInvalidFormat, v))
+		}
+		req.Format = v
+	}
+
+	if req.From, err = api.ParseDate(q.Get("from")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if req.To, err = api.ParseDateTo(q.Get("to")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// Filter exports the statement in chronological order, as accountants read it.
+func (r Request) Filter() model.StatementFilter {
+	return model.StatementFilter{
+		ProcessedFrom: r.From,
+		ProcessedTo:   r.To,
+		OldestFirst:   true,
+	}
+}
@@ -0,0 +1,90 @@
+package transactions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+)
+
+type Handler struct {
+	repo repository.TransactionRepo
+	log  logger.Logger
+}
+
+func NewHandler(repo repository.TransactionRepo, log logger.Logger) *Handler {
+	return &Handler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userGUID, err := auth.UserGUIDFromContext(ctx)
+	if err != nil {
+		h.log.WithError(err).Error("Could not get user GUID from context")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	req, err := parseRequest(r)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	rows, err := h.repo.Statement(ctx, userGUID, req.Filter())
+	if err != nil {
+		h.log.WithError(err).Error("Could not get account statement")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if len(rows) == 0 {
+		http.Error(w, http.StatusText(http.StatusNoContent), http.StatusNoContent)
+		return
+	}
+
+	if req.Limit > 0 && len(rows) > req.Limit {
+		rows = rows[:req.Limit]
+		last := rows[len(rows)-1]
+
+		cursor := api.EncodeCursor(last.ProcessedAt, last.GUID)
+
+		w.Header().Set("X-Next-Cursor", cursor)
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", api.NextPageURL(r.URL, cursor)))
+	}
+
+	resp := make(Response, 0, len(rows))
+	for _, row := range rows {
+		resp = append(resp, Transaction{
+			Order:       row.OrderNumber,
+			Type:        strings.ToUpper(row.Type.String()),
+			Sum:         row.Sum,
+			Balance:     row.Balance,
+			ProcessedAt: api.Datetime(row.ProcessedAt),
+		})
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		h.log.WithError(err).Error("Could not marshal response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(data)
+	if err != nil {
+		h.log.WithError(err).Error("Could not write response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,159 @@
+package transactions_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/api/handler/transactions"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+
+type transaction struct {
+	Order       string       `json:"order"`
+	Type        string       `json:"type"`
+	Sum         model.Points `json:"sum"`
+	Balance     model.Points `json:"balance"`
+	ProcessedAt time.Time    `json:"processed_at"`
+}
+
+func statement() []model.StatementEntry {
+	processedAt := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	return []model.StatementEntry{
+		{
+			Transaction: model.NewWithdrawTransaction("c0d1b0d4-8c4d-4f0a-9f7e-0b7c1d8e4a11", userGUID, "2377225624", model.NewPoints(100, 25), processedAt),
+			Balance:     model.NewPoints(650, 25),
+		},
+		{
+			Transaction: model.NewAddTransaction("9b8c3a52-6a0e-4a5f-8e0a-3c1d2e4f5a61", userGUID, "12345678903", model.NewPoints(250, 50), processedAt.Add(-time.Hour)),
+			Balance:     model.NewPoints(750, 50),
+		},
+		{
+			Transaction: model.NewAddTransaction("1f2e3d4c-5b6a-4978-8695-a4b3c2d1e0f9", userGUID, "4561261212345467", model.NewPoints(500, 0), processedAt.Add(-2*time.Hour)),
+			Balance:     model.NewPoints(500, 0),
+		},
+	}
+}
+
+func TestHandler_Handle(t *testing.T) {
+	serve := func(t *testing.T, repo *mockRep.MockTransactionRepo, target string) *http.Response {
+		ctrl := gomock.NewController(t)
+		log := mockLog.NewMockLogger(ctrl)
+		log.EXPECT().WithError(gomock.Any()).Return(log).AnyTimes()
+		log.EXPECT().Warn(gomock.Any()).AnyTimes()
+		log.EXPECT().Error(gomock.Any()).AnyTimes()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserGUIDKey, userGUID))
+
+		transactions.NewHandler(repo, log).Handle(w, r)
+
+		return w.Result()
+	}
+
+	t.Run("full_statement", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().Statement(gomock.Any(), userGUID, model.StatementFilter{}).Return(statement(), nil)
+
+		resp := serve(t, repo, "/api/user/transactions")
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		var body []transaction
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body, 3)
+		assert.Equal(t, transaction{
+			Order:       "2377225624",
+			Type:        "WITHDRAWAL",
+			Sum:         model.NewPoints(100, 25),
+			Balance:     model.NewPoints(650, 25),
+			ProcessedAt: time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC),
+		}, body[0])
+		assert.Equal(t, "ACCRUAL", body[1].Type)
+		assert.Empty(t, resp.Header.Get("X-Next-Cursor"))
+	})
+
+	t.Run("filtered_page", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().
+			Statement(gomock.Any(), userGUID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ string, filter model.StatementFilter) ([]model.StatementEntry, error) {
+				assert.Equal(t, []model.TransactionType{model.Add}, filter.Types)
+				assert.Equal(t, time.Date(2024, 11, 1, 0, 0, 0, 0, time.UTC), filter.ProcessedFrom)
+				assert.Equal(t, time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), filter.ProcessedTo)
+				assert.Equal(t, 2, filter.Limit)
+				return statement()[1:], nil
+			})
+
+		resp := serve(t, repo, "/api/user/transactions?type=accrual&from=2024-11-01&to=2024-11-30&limit=1")
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		var body []transaction
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Len(t, body, 1)
+		assert.NotEmpty(t, resp.Header.Get("X-Next-Cursor"))
+		assert.NotEmpty(t, resp.Header.Get("Link"))
+	})
+
+	t.Run("no_content", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().Statement(gomock.Any(), userGUID, gomock.Any()).Return(nil, nil)
+
+		resp := serve(t, repo, "/api/user/transactions")
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	})
+
+	t.Run("repository_error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockTransactionRepo(ctrl)
+		repo.EXPECT().Statement(gomock.Any(), userGUID, gomock.Any()).Return(nil, errors.New("some error"))
+
+		resp := serve(t, repo, "/api/user/transactions")
+		_ = resp.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("bad_request", func(t *testing.T) {
+		for _, target := range []string{
+			"/api/user/transactions?type=refund",
+			"/api/user/transactions?limit=-1",
+			"/api/user/transactions?cursor=broken",
+			"/api/user/transactions?to=tomorrow",
+		} {
+			ctrl := gomock.NewController(t)
+
+			resp := serve(t, mockRep.NewMockTransactionRepo(ctrl), target)
+			_ = resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, target)
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package transactions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var errInvalidType = errors.New("invalid transaction type")
+
+// Request query parameters of the account statement.
+type Request struct {
+	Limit  int
+	Cursor *model.StatementCursor
+	Types  []model.TransactionType
+	From   time.Time
+	To     time.Time
+}
+
+func parseRequest(r *http.Request) (*Request, error) {
+	q := r.URL.Query()
+	req := &Request{}
+
+	var (
+		errs []error
+		err  error
+	)
+
+	if req.Limit, err = api.ParseLimit(q.Get("limit")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		processedAt, guid, err := api.DecodeCursor(v)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		req.Cursor = &model.StatementCursor{
+			ProcessedAt: processedAt,
+			GUID:        guid,
+		}
+	}
+
+	for _, v := range q["type"] {
+		for _, s := range strings.Split(v, ",") {
+			t, ok := model.ParseTransactionType(strings.TrimSpace(s))
+			if !ok {
+				errs = append(errs, fmt.Errorf("%w: %q", errInvalidType, s))
+				continue
+			}
+			req.Types = append(req.Types, t)
+		}
+	}
+
+	if req.From, err = api.ParseDate(q.Get("from")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if req.To, err = api.ParseDateTo(q.Get("to")); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+func (r Request) Filter() model.StatementFilter {
+	filter := model.StatementFilter{
+		Types:         r.Types,
+		ProcessedFrom: r.From,
+		ProcessedTo:   r.To,
+		After:         r.Cursor,
+	}
+
+	// one extra row tells whether there is a next page
+	if r.Limit > 0 {
+		filter.Limit = r.Limit + 1
+	}
+
+	return filter
+}
@@ -0,0 +1,16 @@
+package transactions
+
+import (
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type Response []Transaction
+
+type Transaction struct {
+	Order       string       `json:"order"`
+	Type        string       `json:"type"`
+	Sum         model.Points `json:"sum"`
+	Balance     model.Points `json:"balance"`
+	ProcessedAt api.Datetime `json:"processed_at"`
+}
@@ -4,20 +4,29 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/bjlag/go-loyalty/internal/usecase/user/login"
+	"math"
+	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
 
 	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/login"
 )
 
 type Handler struct {
 	usecase *login.Usecase
+	proxies []netip.Prefix
 	log     logger.Logger
 }
 
-func NewHandler(usecase *login.Usecase, log logger.Logger) *Handler {
+// NewHandler proxies are the addresses of the reverse proxies in front of the service, whose X-Forwarded-For is
+// trusted.
+func NewHandler(usecase *login.Usecase, proxies []netip.Prefix, log logger.Logger) *Handler {
 	return &Handler{
 		usecase: usecase,
+		proxies: proxies,
 		log:     log,
 	}
 }
@@ -38,8 +47,16 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.usecase.LoginUser(r.Context(), req.Login, req.Password)
+	pair, err := h.usecase.LoginUser(r.Context(), req.Login, req.Password, h.remoteIP(r))
 	if err != nil {
+		var throttled *login.ThrottledError
+		if errors.As(err, &throttled) {
+			h.log.WithError(err).Warn("Login attempts are throttled")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
 		if errors.Is(err, login.ErrUserNotFound) || errors.Is(err, login.ErrWrongPassword) {
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
@@ -51,7 +68,8 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := Response{
-		Token: token,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
 	}
 
 	data, err := json.Marshal(resp)
@@ -62,7 +80,7 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", pair.AccessToken))
 
 	_, err = w.Write(data)
 	if err != nil {
@@ -70,3 +88,43 @@ func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 }
+
+// remoteIP the address of the client. X-Forwarded-For is followed from the right only while the hop before is a
+// trusted proxy: the rest of the header is set by the client, who could escape the per-address limit with it.
+func (h *Handler) remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0 && h.trusted(ip); i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+	}
+
+	return ip
+}
+
+func (h *Handler) trusted(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, proxy := range h.proxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
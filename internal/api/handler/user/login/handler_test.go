@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"testing"
 	"time"
 
@@ -15,31 +16,43 @@ import (
 
 	"github.com/bjlag/go-loyalty/internal/api/handler/user/login"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
 	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
 	"github.com/bjlag/go-loyalty/internal/model"
 	ucLogin "github.com/bjlag/go-loyalty/internal/usecase/user/login"
+	ucToken "github.com/bjlag/go-loyalty/internal/usecase/user/token"
 )
 
+func reserved(key string, failures int) *model.LoginThrottle {
+	return &model.LoginThrottle{Key: key, Failures: failures, WindowStartedAt: time.Now()}
+}
+
 func TestHandler_Handle(t *testing.T) {
 	hasher := auth.NewHasher()
 	jwtBuilder := auth.NewJWTBuilder("secret", 1*time.Hour)
+	revocations := auth.NewRevocationCache(nil, time.Minute)
 
 	type args struct {
-		repo func(ctrl *gomock.Controller) *mockRep.MockUserRepository
-		log  func(ctrl *gomock.Controller) *mock.MockLogger
+		repo     func(ctrl *gomock.Controller) *mockRep.MockUserRepository
+		tokens   func(ctrl *gomock.Controller) *mockRep.MockTokenRepo
+		throttle func(ctrl *gomock.Controller) *mockRep.MockLoginThrottleRepo
+		log      func(ctrl *gomock.Controller) *mock.MockLogger
 	}
 
 	type want struct {
-		status int
-		err    bool
+		status     int
+		retryAfter string
+		err        bool
 	}
 
 	tests := []struct {
-		name string
-		args args
-		body string
-		want want
+		name         string
+		args         args
+		proxies      []netip.Prefix
+		forwardedFor string
+		body         string
+		want         want
 	}{
 		{
 			name: "success",
@@ -54,6 +67,19 @@ func TestHandler_Handle(t *testing.T) {
 
 					return repUserMock
 				},
+				tokens: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+					repTokenMock := mockRep.NewMockTokenRepo(ctrl)
+					repTokenMock.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+					return repTokenMock
+				},
+				throttle: func(ctrl *gomock.Controller) *mockRep.MockLoginThrottleRepo {
+					repThrottleMock := mockRep.NewMockLoginThrottleRepo(ctrl)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "ip:127.0.0.1", time.Hour, gomock.Any()).Return(reserved("ip:127.0.0.1", 1), true, nil)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "login:abcd", time.Hour, gomock.Any()).Return(reserved("login:abcd", 1), true, nil)
+					repThrottleMock.EXPECT().Release(gomock.Any(), "ip:127.0.0.1").Return(nil)
+					repThrottleMock.EXPECT().Reset(gomock.Any(), "login:abcd").Return(nil)
+					return repThrottleMock
+				},
 				log: mock.NewMockLogger,
 			},
 			body: `{"login": "abcd", "password": "123456"}`,
@@ -75,6 +101,13 @@ func TestHandler_Handle(t *testing.T) {
 
 					return repUserMock
 				},
+				tokens: mockRep.NewMockTokenRepo,
+				throttle: func(ctrl *gomock.Controller) *mockRep.MockLoginThrottleRepo {
+					repThrottleMock := mockRep.NewMockLoginThrottleRepo(ctrl)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "ip:127.0.0.1", time.Hour, gomock.Any()).Return(reserved("ip:127.0.0.1", 1), true, nil)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "login:abcd", time.Hour, gomock.Any()).Return(reserved("login:abcd", 1), true, nil)
+					return repThrottleMock
+				},
 				log: mock.NewMockLogger,
 			},
 			body: `{"login": "abcd", "password": "wrong"}`,
@@ -83,14 +116,112 @@ func TestHandler_Handle(t *testing.T) {
 				err:    true,
 			},
 		},
+		{
+			name: "throttled",
+			args: args{
+				repo:   mockRep.NewMockUserRepository,
+				tokens: mockRep.NewMockTokenRepo,
+				throttle: func(ctrl *gomock.Controller) *mockRep.MockLoginThrottleRepo {
+					repThrottleMock := mockRep.NewMockLoginThrottleRepo(ctrl)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "ip:127.0.0.1", time.Hour, gomock.Any()).Return(reserved("ip:127.0.0.1", 1), true, nil)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "login:abcd", time.Hour, gomock.Any()).Return(&model.LoginThrottle{
+						Key:             "login:abcd",
+						Failures:        5,
+						WindowStartedAt: time.Now().Add(-time.Minute),
+						BlockedUntil:    time.Now().Add(90 * time.Second),
+					}, false, nil)
+					repThrottleMock.EXPECT().Release(gomock.Any(), "ip:127.0.0.1").Return(nil)
+					return repThrottleMock
+				},
+				log: func(ctrl *gomock.Controller) *mock.MockLogger {
+					logMock := mock.NewMockLogger(ctrl)
+					logMock.EXPECT().WithError(gomock.Any()).Return(logMock)
+					logMock.EXPECT().Warn(gomock.Any())
+					return logMock
+				},
+			},
+			body: `{"login": "abcd", "password": "123456"}`,
+			want: want{
+				status:     http.StatusTooManyRequests,
+				retryAfter: "90",
+				err:        true,
+			},
+		},
+		{
+			name: "forwarded_by_trusted_proxy",
+			args: args{
+				repo: func(ctrl *gomock.Controller) *mockRep.MockUserRepository {
+					repUserMock := mockRep.NewMockUserRepository(ctrl)
+					repUserMock.EXPECT().FindByLogin(gomock.Any(), "abcd").Return(&model.User{
+						GUID:     "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7",
+						Login:    "abcd",
+						Password: "$2a$10$wEwL0jTt5ryuBRzCv56A3eq0odey9nSFrcuqqubJttyLjAw3SF2/.",
+					}, nil)
+
+					return repUserMock
+				},
+				tokens: mockRep.NewMockTokenRepo,
+				throttle: func(ctrl *gomock.Controller) *mockRep.MockLoginThrottleRepo {
+					repThrottleMock := mockRep.NewMockLoginThrottleRepo(ctrl)
+					// the first address is set by the client, the second one by the proxy
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "ip:203.0.113.7", time.Hour, gomock.Any()).Return(reserved("ip:203.0.113.7", 1), true, nil)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "login:abcd", time.Hour, gomock.Any()).Return(reserved("login:abcd", 1), true, nil)
+					return repThrottleMock
+				},
+				log: mock.NewMockLogger,
+			},
+			proxies:      []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+			forwardedFor: "198.51.100.9, 203.0.113.7",
+			body:         `{"login": "abcd", "password": "wrong"}`,
+			want: want{
+				status: http.StatusUnauthorized,
+				err:    true,
+			},
+		},
+		{
+			name: "forwarded_by_client",
+			args: args{
+				repo: func(ctrl *gomock.Controller) *mockRep.MockUserRepository {
+					repUserMock := mockRep.NewMockUserRepository(ctrl)
+					repUserMock.EXPECT().FindByLogin(gomock.Any(), "abcd").Return(&model.User{
+						GUID:     "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7",
+						Login:    "abcd",
+						Password: "$2a$10$wEwL0jTt5ryuBRzCv56A3eq0odey9nSFrcuqqubJttyLjAw3SF2/.",
+					}, nil)
+
+					return repUserMock
+				},
+				tokens: mockRep.NewMockTokenRepo,
+				throttle: func(ctrl *gomock.Controller) *mockRep.MockLoginThrottleRepo {
+					repThrottleMock := mockRep.NewMockLoginThrottleRepo(ctrl)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "ip:127.0.0.1", time.Hour, gomock.Any()).Return(reserved("ip:127.0.0.1", 1), true, nil)
+					repThrottleMock.EXPECT().Reserve(gomock.Any(), "login:abcd", time.Hour, gomock.Any()).Return(reserved("login:abcd", 1), true, nil)
+					return repThrottleMock
+				},
+				log: mock.NewMockLogger,
+			},
+			forwardedFor: "203.0.113.7",
+			body:         `{"login": "abcd", "password": "wrong"}`,
+			want: want{
+				status: http.StatusUnauthorized,
+				err:    true,
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
-			usecase := ucLogin.NewUsecase(tt.args.repo(ctrl), hasher, jwtBuilder)
-			handler := http.HandlerFunc(login.NewHandler(usecase, tt.args.log(ctrl)).Handle)
+			tokens := ucToken.NewUsecase(tt.args.tokens(ctrl), jwtBuilder, new(guid.Generator), revocations, 24*time.Hour)
+			usecase := ucLogin.NewUsecase(tt.args.repo(ctrl), hasher, tokens, tt.args.throttle(ctrl), mockRep.NewMockLoginLockoutRepo(ctrl), new(guid.Generator), ucLogin.Limits{
+				MaxFailures:      5,
+				MaxFailuresPerIP: 20,
+				Window:           time.Hour,
+				LockoutTime:      time.Hour,
+				DelayBase:        time.Second,
+			}, mock.NewMockLogger(ctrl))
+			handler := http.HandlerFunc(login.NewHandler(usecase, tt.proxies, tt.args.log(ctrl)).Handle)
 
 			srv := httptest.NewServer(handler)
 			defer srv.Close()
@@ -100,6 +231,9 @@ func TestHandler_Handle(t *testing.T) {
 			req.URL = srv.URL
 			req.SetHeader("Content-Type", "application/json")
 			req.SetBody(tt.body)
+			if tt.forwardedFor != "" {
+				req.SetHeader("X-Forwarded-For", tt.forwardedFor)
+			}
 
 			resp, err := req.Send()
 			require.NoError(t, err, "error making HTTP request")
@@ -110,11 +244,13 @@ func TestHandler_Handle(t *testing.T) {
 				require.NoError(t, err, "error unmarshaling HTTP response body")
 
 				assert.NotEmpty(t, respUnmarshalled.Token, "token is empty")
+				assert.NotEmpty(t, respUnmarshalled.RefreshToken, "refresh token is empty")
 				assert.Equal(t, resp.Header().Get("Content-Type"), "application/json")
 				assert.Equal(t, resp.Header().Get("Authorization"), fmt.Sprintf("Bearer %s", respUnmarshalled.Token))
 			}
 
 			assert.Equal(t, tt.want.status, resp.StatusCode(), "unexpected status code")
+			assert.Equal(t, tt.want.retryAfter, resp.Header().Get("Retry-After"))
 		})
 	}
 }
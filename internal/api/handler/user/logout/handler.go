@@ -0,0 +1,40 @@
+package logout
+
+import (
+	"net/http"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
+)
+
+type Handler struct {
+	usecase *token.Usecase
+	log     logger.Logger
+}
+
+func NewHandler(usecase *token.Usecase, log logger.Logger) *Handler {
+	return &Handler{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+// Handle revokes the access token of the request and its refresh token family.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	claims, err := auth.ClaimsFromContext(r.Context())
+	if err != nil {
+		h.log.WithError(err).Error("Could not get token claims from context")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	err = h.usecase.Revoke(r.Context(), claims)
+	if err != nil {
+		h.log.WithError(err).Error("Error revoking token")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,95 @@
+package change
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/login"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/password"
+)
+
+type Handler struct {
+	usecase *password.Usecase
+	log     logger.Logger
+}
+
+func NewHandler(usecase *password.Usecase, log logger.Logger) *Handler {
+	return &Handler{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+// Handle changes the password of the user and responds with new tokens, the old ones are revoked.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	userGUID, err := auth.UserGUIDFromContext(r.Context())
+	if err != nil {
+		h.log.WithError(err).Error("Could not get user GUID from context")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var req Request
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	pair, err := h.usecase.Change(r.Context(), userGUID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		var throttled *login.ThrottledError
+		if errors.As(err, &throttled) {
+			h.log.WithError(err).Warn("Password checks are throttled")
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		// the request is authenticated, 401 would make the client refresh its token instead
+		if errors.Is(err, password.ErrWrongPassword) {
+			h.log.WithError(err).Warn("Wrong current password")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		if errors.Is(err, auth.ErrPasswordTooLong) {
+			h.log.WithError(err).Warn("Invalid request")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		h.log.WithError(err).Error("Error changing password")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := Response{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		h.log.WithError(err).Error("Could not marshal response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", pair.AccessToken))
+
+	_, err = w.Write(data)
+	if err != nil {
+		h.log.WithError(err).Error("Could not write response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
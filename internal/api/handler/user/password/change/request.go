@@ -0,0 +1,52 @@
+package change
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	maxLenPassword = 1024
+	minLenPassword = 6
+)
+
+var (
+	errInvalidCurrentPassword = errors.New("invalid current password")
+	errInvalidNewPassword     = errors.New("invalid new password")
+)
+
+type Request struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type RequestAlias Request
+
+	aliasValue := &struct {
+		*RequestAlias
+	}{
+		RequestAlias: (*RequestAlias)(r),
+	}
+
+	err := json.Unmarshal(b, &aliasValue)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if r.CurrentPassword == "" {
+		errs = append(errs, fmt.Errorf("%w: empty password", errInvalidCurrentPassword))
+	}
+
+	if len(r.NewPassword) < minLenPassword {
+		errs = append(errs, fmt.Errorf("%w: password length less than %d bytes", errInvalidNewPassword, minLenPassword))
+	}
+
+	if len(r.NewPassword) > maxLenPassword {
+		errs = append(errs, fmt.Errorf("%w: password length exceeds %d bytes", errInvalidNewPassword, maxLenPassword))
+	}
+
+	return errors.Join(errs...)
+}
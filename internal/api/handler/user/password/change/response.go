@@ -0,0 +1,6 @@
+package change
+
+type Response struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
@@ -0,0 +1,55 @@
+package confirm
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/password"
+)
+
+type Handler struct {
+	usecase *password.Usecase
+	log     logger.Logger
+}
+
+func NewHandler(usecase *password.Usecase, log logger.Logger) *Handler {
+	return &Handler{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+// Handle sets the password with a reset token. The user logs in with the new password afterwards.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	var req Request
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = h.usecase.Reset(r.Context(), req.Token, req.Password)
+	if err != nil {
+		if errors.Is(err, password.ErrInvalidResetToken) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		if errors.Is(err, auth.ErrPasswordTooLong) {
+			h.log.WithError(err).Warn("Invalid request")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		h.log.WithError(err).Error("Error resetting password")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,52 @@
+package confirm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	maxLenPassword = 1024
+	minLenPassword = 6
+)
+
+var (
+	errInvalidToken    = errors.New("empty reset token")
+	errInvalidPassword = errors.New("invalid password")
+)
+
+type Request struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type RequestAlias Request
+
+	aliasValue := &struct {
+		*RequestAlias
+	}{
+		RequestAlias: (*RequestAlias)(r),
+	}
+
+	err := json.Unmarshal(b, &aliasValue)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	if r.Token == "" {
+		errs = append(errs, errInvalidToken)
+	}
+
+	if len(r.Password) < minLenPassword {
+		errs = append(errs, fmt.Errorf("%w: password length less than %d bytes", errInvalidPassword, minLenPassword))
+	}
+
+	if len(r.Password) > maxLenPassword {
+		errs = append(errs, fmt.Errorf("%w: password length exceeds %d bytes", errInvalidPassword, maxLenPassword))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,42 @@
+package reset
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/password"
+)
+
+type Handler struct {
+	usecase *password.Usecase
+	log     logger.Logger
+}
+
+func NewHandler(usecase *password.Usecase, log logger.Logger) *Handler {
+	return &Handler{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+// Handle sends a reset token to the user. It answers 202 whether the login exists or not.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	var req Request
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = h.usecase.RequestReset(r.Context(), req.Login)
+	if err != nil {
+		h.log.WithError(err).Error("Error requesting password reset")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -0,0 +1,33 @@
+package reset
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var errInvalidLogin = errors.New("empty login")
+
+type Request struct {
+	Login string `json:"login"`
+}
+
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type RequestAlias Request
+
+	aliasValue := &struct {
+		*RequestAlias
+	}{
+		RequestAlias: (*RequestAlias)(r),
+	}
+
+	err := json.Unmarshal(b, &aliasValue)
+	if err != nil {
+		return err
+	}
+
+	if r.Login == "" {
+		return errInvalidLogin
+	}
+
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
 	"github.com/bjlag/go-loyalty/internal/usecase/user/register"
 )
@@ -32,20 +33,27 @@ func (h Handler) Handle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.usecase.RegisterUser(r.Context(), req.Login, req.Password)
+	pair, err := h.usecase.RegisterUser(r.Context(), req.Login, req.Password)
 	if err != nil {
 		if errors.Is(err, register.ErrUserAlreadyExists) {
 			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
 			return
 		}
 
+		if errors.Is(err, auth.ErrPasswordTooLong) {
+			h.log.WithError(err).Warn("invalid request")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
 		h.log.WithError(err).Error("error registering user")
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
 	resp := Response{
-		Token: token,
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
 	}
 
 	data, err := json.Marshal(resp)
@@ -56,7 +64,7 @@ func (h Handler) Handle(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", pair.AccessToken))
 
 	_, err = w.Write(data)
 	if err != nil {
@@ -17,18 +17,22 @@ import (
 	"github.com/bjlag/go-loyalty/internal/api/handler/user/register"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
 	mockAuth "github.com/bjlag/go-loyalty/internal/infrastructure/auth/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
 	mockGuid "github.com/bjlag/go-loyalty/internal/infrastructure/guid/mock"
 	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
 	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
 	"github.com/bjlag/go-loyalty/internal/model"
 	ucRegister "github.com/bjlag/go-loyalty/internal/usecase/user/register"
+	ucToken "github.com/bjlag/go-loyalty/internal/usecase/user/token"
 )
 
 func TestHandler_Handle(t *testing.T) {
 	jwtBuilder := auth.NewJWTBuilder("secret", 1*time.Hour)
+	revocations := auth.NewRevocationCache(nil, time.Minute)
 
 	type args struct {
 		repo      func(ctrl *gomock.Controller) *mockRep.MockUserRepository
+		tokens    func(ctrl *gomock.Controller) *mockRep.MockTokenRepo
 		hasher    func(ctrl *gomock.Controller) *mockAuth.MockIHasher
 		generator func(ctrl *gomock.Controller) *mockGuid.MockIGenerator
 		log       func(ctrl *gomock.Controller) *mockLog.MockLogger
@@ -64,6 +68,11 @@ func TestHandler_Handle(t *testing.T) {
 
 					return repUserMock
 				},
+				tokens: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+					repTokenMock := mockRep.NewMockTokenRepo(ctrl)
+					repTokenMock.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+					return repTokenMock
+				},
 				generator: func(ctrl *gomock.Controller) *mockGuid.MockIGenerator {
 					genMock := mockGuid.NewMockIGenerator(ctrl)
 					genMock.EXPECT().Generate().Return("41d2f86c-6ce5-4732-a485-6d09d7a9b3f7")
@@ -88,7 +97,8 @@ func TestHandler_Handle(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
 
-			usecase := ucRegister.NewUsecase(tt.args.repo(ctrl), tt.args.generator(ctrl), tt.args.hasher(ctrl), jwtBuilder)
+			tokens := ucToken.NewUsecase(tt.args.tokens(ctrl), jwtBuilder, new(guid.Generator), revocations, 24*time.Hour)
+			usecase := ucRegister.NewUsecase(tt.args.repo(ctrl), tt.args.generator(ctrl), tt.args.hasher(ctrl), tokens)
 			handler := http.HandlerFunc(register.NewHandler(usecase, tt.args.log(ctrl)).Handle)
 
 			srv := httptest.NewServer(handler)
@@ -109,6 +119,7 @@ func TestHandler_Handle(t *testing.T) {
 				require.NoError(t, err, "error unmarshaling HTTP response body")
 
 				assert.NotEmpty(t, respUnmarshalled.Token, "token is empty")
+				assert.NotEmpty(t, respUnmarshalled.RefreshToken, "refresh token is empty")
 				assert.Equal(t, resp.Header().Get("Content-Type"), "application/json")
 				assert.Equal(t, resp.Header().Get("Authorization"), fmt.Sprintf("Bearer %s", respUnmarshalled.Token))
 			}
@@ -9,8 +9,8 @@ import (
 const (
 	// database requirement
 	maxLenLogin = 20
-	// bcrypt: password length should not exceed 72 bytes
-	maxLenPassword = 72
+	// bounds the work of hashing; bcrypt takes at most 72 bytes, the hasher rejects longer passwords itself
+	maxLenPassword = 1024
 	minLenPassword = 6
 )
 
@@ -1,5 +1,6 @@
 package register
 
 type Response struct {
-	Token string `json:"token"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
 }
@@ -0,0 +1,73 @@
+package refresh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
+)
+
+type Handler struct {
+	usecase *token.Usecase
+	log     logger.Logger
+}
+
+func NewHandler(usecase *token.Usecase, log logger.Logger) *Handler {
+	return &Handler{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	var req Request
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	pair, err := h.usecase.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, token.ErrRefreshTokenReused) {
+			h.log.WithError(err).Warn("Refresh token reused, token family revoked")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		if errors.Is(err, token.ErrInvalidRefreshToken) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		h.log.WithError(err).Error("Error refreshing token")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := Response{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		h.log.WithError(err).Error("Could not marshal response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Authorization", fmt.Sprintf("Bearer %s", pair.AccessToken))
+
+	_, err = w.Write(data)
+	if err != nil {
+		h.log.WithError(err).Error("Could not write response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,33 @@
+package refresh
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+var errInvalidRefreshToken = errors.New("empty refresh token")
+
+type Request struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type RequestAlias Request
+
+	aliasValue := &struct {
+		*RequestAlias
+	}{
+		RequestAlias: (*RequestAlias)(r),
+	}
+
+	err := json.Unmarshal(b, &aliasValue)
+	if err != nil {
+		return err
+	}
+
+	if r.RefreshToken == "" {
+		return errInvalidRefreshToken
+	}
+
+	return nil
+}
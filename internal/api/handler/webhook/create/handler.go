@@ -0,0 +1,70 @@
+package create
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/usecase/webhook/create"
+)
+
+type Handler struct {
+	usecase *create.Usecase
+	log     logger.Logger
+}
+
+func NewHandler(usecase *create.Usecase, log logger.Logger) *Handler {
+	return &Handler{
+		usecase: usecase,
+		log:     log,
+	}
+}
+
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userGUID, err := auth.UserGUIDFromContext(ctx)
+	if err != nil {
+		h.log.WithError(err).Error("Could not get user GUID from context")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	var req Request
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.usecase.CreateWebhook(ctx, userGUID, req.URL, req.Events)
+	if err != nil {
+		h.log.WithError(err).Error("Could not create webhook")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.Marshal(Response{
+		ID:        webhook.GUID,
+		URL:       webhook.URL,
+		Events:    webhook.Subscribed(),
+		Secret:    webhook.Secret,
+		CreatedAt: api.Datetime(webhook.CreatedAt),
+	})
+	if err != nil {
+		h.log.WithError(err).Error("Could not marshal response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+
+	_, err = w.Write(data)
+	if err != nil {
+		h.log.WithError(err).Error("Could not write response")
+	}
+}
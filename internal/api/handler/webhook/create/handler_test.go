@@ -0,0 +1,124 @@
+package create_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/api/handler/webhook/create"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	mockGuid "github.com/bjlag/go-loyalty/internal/infrastructure/guid/mock"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+	ucCreate "github.com/bjlag/go-loyalty/internal/usecase/webhook/create"
+)
+
+const (
+	userGUID    = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+	webhookGUID = "8f0f3b9e-3c4a-4b1e-9d55-2f7b0b5f5e01"
+)
+
+type webhook struct {
+	ID        string            `json:"id"`
+	URL       string            `json:"url"`
+	Events    []model.EventType `json:"events"`
+	Secret    string            `json:"secret"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func TestHandler_Handle(t *testing.T) {
+	serve := func(t *testing.T, repo *mockRep.MockWebhookRepo, body string) *http.Response {
+		ctrl := gomock.NewController(t)
+		log := mockLog.NewMockLogger(ctrl)
+		log.EXPECT().WithError(gomock.Any()).Return(log).AnyTimes()
+		log.EXPECT().Warn(gomock.Any()).AnyTimes()
+		log.EXPECT().Error(gomock.Any()).AnyTimes()
+
+		guidGen := mockGuid.NewMockIGenerator(ctrl)
+		guidGen.EXPECT().Generate().Return(webhookGUID).AnyTimes()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/user/webhooks", strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserGUIDKey, userGUID))
+
+		create.NewHandler(ucCreate.NewUsecase(repo, guidGen), log).Handle(w, r)
+
+		return w.Result()
+	}
+
+	t.Run("created", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().Create(gomock.Any(), gomock.Any()).DoAndReturn(func(_ context.Context, w *model.Webhook) error {
+			assert.Equal(t, webhookGUID, w.GUID)
+			assert.Equal(t, userGUID, w.UserGUID)
+			assert.Equal(t, "https://partner.example/hooks", w.URL)
+			assert.Equal(t, []model.EventType{model.EventAccrualInvalid}, w.Events)
+			assert.Len(t, w.Secret, 64)
+			return nil
+		})
+
+		resp := serve(t, repo, `{"url":"https://partner.example/hooks","events":["accrual.invalid","accrual.invalid"]}`)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var body webhook
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, webhookGUID, body.ID)
+		assert.Equal(t, []model.EventType{model.EventAccrualInvalid}, body.Events)
+		assert.Len(t, body.Secret, 64)
+	})
+
+	t.Run("all_events_by_default", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil)
+
+		resp := serve(t, repo, `{"url":"http://partner.example:8081/"}`)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var body webhook
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		assert.Equal(t, model.WebhookEventTypes, body.Events)
+	})
+
+	invalid := map[string]string{
+		"not_json":       `url=https://partner.example`,
+		"relative_url":   `{"url":"/hooks"}`,
+		"ftp_url":        `{"url":"ftp://partner.example/hooks"}`,
+		"unknown_event":  `{"url":"https://partner.example","events":["accrual.processing"]}`,
+		"withdraw_event": `{"url":"https://partner.example","events":["balance.withdrawn"]}`,
+		"localhost":      `{"url":"http://localhost:8081/"}`,
+		"private_ip":     `{"url":"http://10.0.0.5/hooks"}`,
+		"metadata_ip":    `{"url":"http://169.254.169.254/latest/meta-data/"}`,
+		"loopback_ipv6":  `{"url":"http://[::1]:8080/"}`,
+	}
+	for name, body := range invalid {
+		t.Run(name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			resp := serve(t, mockRep.NewMockWebhookRepo(ctrl), body)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package create
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var (
+	errInvalidURL   = errors.New("invalid url")
+	errInvalidEvent = errors.New("invalid event")
+)
+
+type Request struct {
+	URL    string            `json:"url"`
+	Events []model.EventType `json:"events"`
+}
+
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type RequestAlias Request
+
+	aliasValue := &struct {
+		*RequestAlias
+	}{
+		RequestAlias: (*RequestAlias)(r),
+	}
+
+	err := json.Unmarshal(b, &aliasValue)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || !publicHost(u.Hostname()) {
+		errs = append(errs, fmt.Errorf("%w: %q", errInvalidURL, r.URL))
+	}
+
+	for _, e := range r.Events {
+		if !slices.Contains(model.WebhookEventTypes, e) {
+			errs = append(errs, fmt.Errorf("%w: %q", errInvalidEvent, e))
+		}
+	}
+
+	slices.Sort(r.Events)
+	r.Events = slices.Compact(r.Events)
+
+	return errors.Join(errs...)
+}
+
+// publicHost rejects hosts that are known to be internal without a DNS lookup. Host names are checked by the webhook
+// client once they are resolved.
+func publicHost(host string) bool {
+	if host == "localhost" {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return true
+	}
+
+	return webhook.IsPublicAddr(addr)
+}
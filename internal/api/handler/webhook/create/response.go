@@ -0,0 +1,14 @@
+package create
+
+import (
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type Response struct {
+	ID        string            `json:"id"`
+	URL       string            `json:"url"`
+	Events    []model.EventType `json:"events"`
+	Secret    string            `json:"secret"`
+	CreatedAt api.Datetime      `json:"created_at"`
+}
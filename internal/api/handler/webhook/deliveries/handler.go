@@ -0,0 +1,122 @@
+package deliveries
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const defaultLimit = 100
+
+type Handler struct {
+	repo repository.WebhookRepo
+	log  logger.Logger
+}
+
+func NewHandler(repo repository.WebhookRepo, log logger.Logger) *Handler {
+	return &Handler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// Handle returns the delivery log of the webhook, newest first.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userGUID, err := auth.UserGUIDFromContext(ctx)
+	if err != nil {
+		h.log.WithError(err).Error("Could not get user GUID from context")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	limit, err := api.ParseLimit(r.URL.Query().Get("limit"))
+	if err == nil {
+		err = uuid.Validate(id)
+	}
+	if err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if limit == 0 {
+		limit = defaultLimit
+	}
+
+	webhook, err := h.repo.WebhookByGUID(ctx, id, userGUID)
+	if err != nil {
+		h.log.WithError(err).Error("Could not get webhook")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if webhook == nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	rows, err := h.repo.Deliveries(ctx, webhook.GUID, limit)
+	if err != nil {
+		h.log.WithError(err).Error("Could not get webhook deliveries")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	if rows == nil {
+		http.Error(w, http.StatusText(http.StatusNoContent), http.StatusNoContent)
+		return
+	}
+
+	resp := make(Response, 0, len(rows))
+	for _, row := range rows {
+		d := Delivery{
+			ID:           row.GUID,
+			EventID:      row.EventGUID,
+			Event:        row.EventType,
+			Status:       strings.ToUpper(row.Status.String()),
+			Attempts:     row.Attempts,
+			ResponseCode: row.LastStatusCode,
+			Error:        row.LastError,
+			Payload:      row.Payload,
+			CreatedAt:    api.Datetime(row.CreatedAt),
+		}
+
+		if row.Status == model.DeliveryPending {
+			next := api.Datetime(row.NextAttemptAt)
+			d.NextAttemptAt = &next
+		}
+
+		if row.DeliveredAt != nil {
+			delivered := api.Datetime(*row.DeliveredAt)
+			d.DeliveredAt = &delivered
+		}
+
+		resp = append(resp, d)
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		h.log.WithError(err).Error("Could not marshal response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err = w.Write(data)
+	if err != nil {
+		h.log.WithError(err).Error("Could not write response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,24 @@
+package deliveries
+
+import (
+	"encoding/json"
+
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type Response []Delivery
+
+type Delivery struct {
+	ID            string          `json:"id"`
+	EventID       string          `json:"event_id"`
+	Event         model.EventType `json:"event"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	NextAttemptAt *api.Datetime   `json:"next_attempt_at,omitempty"`
+	ResponseCode  int             `json:"response_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     api.Datetime    `json:"created_at"`
+	DeliveredAt   *api.Datetime   `json:"delivered_at,omitempty"`
+}
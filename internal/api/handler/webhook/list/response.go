@@ -0,0 +1,15 @@
+package list
+
+import (
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type Response []Webhook
+
+type Webhook struct {
+	ID        string            `json:"id"`
+	URL       string            `json:"url"`
+	Events    []model.EventType `json:"events"`
+	CreatedAt api.Datetime      `json:"created_at"`
+}
@@ -0,0 +1,57 @@
+package redeliver
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+)
+
+type Handler struct {
+	repo repository.WebhookRepo
+	log  logger.Logger
+}
+
+func NewHandler(repo repository.WebhookRepo, log logger.Logger) *Handler {
+	return &Handler{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// Handle queues the delivery to be sent again. The request is sent by the webhook sender, so the answer is 202.
+func (h *Handler) Handle(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userGUID, err := auth.UserGUIDFromContext(ctx)
+	if err != nil {
+		h.log.WithError(err).Error("Could not get user GUID from context")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err = uuid.Validate(id); err != nil {
+		h.log.WithError(err).Warn("Invalid request")
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err = h.repo.Redeliver(ctx, id, userGUID)
+	if err != nil {
+		if errors.Is(err, repository.ErrWebhookDeliveryNotFound) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		h.log.WithError(err).Error("Could not redeliver webhook")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
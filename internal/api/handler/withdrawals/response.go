@@ -1,11 +1,14 @@
 package withdrawals
 
-import "github.com/bjlag/go-loyalty/internal/api"
+import (
+	"github.com/bjlag/go-loyalty/internal/api"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
 
 type Response []Withdraw
 
 type Withdraw struct {
 	Order       string       `json:"order"`
-	Sum         float64      `json:"sum"`
+	Sum         model.Points `json:"sum"`
 	ProcessedAt api.Datetime `json:"processed_at"`
 }
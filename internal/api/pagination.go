@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const MaxPageLimit = 1000
+
+var (
+	ErrInvalidLimit  = errors.New("invalid limit")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidDate   = errors.New("invalid date")
+)
+
+// ParseLimit returns zero for an empty value, which means no pagination.
+func ParseLimit(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(s)
+	if err != nil || limit <= 0 || limit > MaxPageLimit {
+		return 0, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidLimit, MaxPageLimit)
+	}
+
+	return limit, nil
+}
+
+// ParseDate accepts RFC3339 timestamps and plain dates. An empty value gives zero time.
+func ParseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", ErrInvalidDate, s)
+	}
+
+	return t, nil
+}
+
+// ParseDateTo parses the exclusive upper bound of a period. A plain date includes the whole day, so the bound is
+// the start of the next one.
+func ParseDateTo(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t.AddDate(0, 0, 1), nil
+	}
+
+	return ParseDate(s)
+}
+
+// EncodeCursor packs the sort position of the last row on a page: its timestamp and a unique key.
+func EncodeCursor(t time.Time, key string) string {
+	raw := t.UTC().Format(time.RFC3339Nano) + "|" + key
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(s string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	ts, key, ok := strings.Cut(string(raw), "|")
+	if !ok || key == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return t, key, nil
+}
+
+// NextPageURL returns the request URI with the cursor replaced, keeping the other query parameters.
+func NextPageURL(u *url.URL, cursor string) string {
+	q := u.Query()
+	q.Set("cursor", cursor)
+
+	next := *u
+	next.RawQuery = q.Encode()
+
+	return next.RequestURI()
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2Prefix = "$argon2id$"
+
+var errInvalidArgon2Hash = errors.New("invalid argon2id hash")
+
+type Argon2Params struct {
+	// Memory in KiB.
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+	SaltLen uint32
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params the OWASP minimum for Argon2id.
+var DefaultArgon2Params = Argon2Params{
+	Memory:  19 * 1024,
+	Time:    2,
+	Threads: 1,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// Argon2Hasher hashes passwords with Argon2id. Hashes are in the PHC string format:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+type Argon2Hasher struct {
+	params Argon2Params
+}
+
+func NewArgon2Hasher(params Argon2Params) *Argon2Hasher {
+	return &Argon2Hasher{
+		params: params,
+	}
+}
+
+func (h Argon2Hasher) HashPassword(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Time, h.params.Memory, h.params.Threads, h.params.KeyLen)
+
+	return encodeArgon2(h.params, salt, key), nil
+}
+
+func (h Argon2Hasher) ComparePasswords(hashedPassword, password string) bool {
+	return comparePasswords(hashedPassword, password)
+}
+
+func (h Argon2Hasher) NeedsRehash(hashedPassword string) bool {
+	params, _, _, err := decodeArgon2(hashedPassword)
+	if err != nil {
+		return true
+	}
+
+	return params != h.params
+}
+
+func compareArgon2(hashedPassword, password string) bool {
+	params, salt, key, err := decodeArgon2(hashedPassword)
+	if err != nil {
+		return false
+	}
+
+	got := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+
+	return subtle.ConstantTimeCompare(got, key) == 1
+}
+
+func encodeArgon2(params Argon2Params, salt, key []byte) string {
+	return fmt.Sprintf(
+		"%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2Prefix,
+		argon2.Version,
+		params.Memory,
+		params.Time,
+		params.Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func decodeArgon2(hashedPassword string) (params Argon2Params, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=19456,t=2,p=1", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, errInvalidArgon2Hash
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: version %q", errInvalidArgon2Hash, parts[2])
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil || params.Time == 0 || params.Threads == 0 {
+		return params, nil, nil, fmt.Errorf("%w: parameters %q", errInvalidArgon2Hash, parts[3])
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: salt: %w", errInvalidArgon2Hash, err)
+	}
+
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("%w: key", errInvalidArgon2Hash)
+	}
+
+	params.SaltLen = uint32(len(salt))
+	params.KeyLen = uint32(len(key))
+
+	return params, salt, key, nil
+}
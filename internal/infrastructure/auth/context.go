@@ -9,7 +9,15 @@ type ctxKeyUserGUID int
 
 const UserGUIDKey ctxKeyUserGUID = 0
 
-var ErrUserGUIDNotFound = errors.New("user GUID not found in context")
+type ctxKeyClaims int
+
+// ClaimsKey the claims of the access token the request is authenticated with.
+const ClaimsKey ctxKeyClaims = 0
+
+var (
+	ErrUserGUIDNotFound = errors.New("user GUID not found in context")
+	ErrClaimsNotFound   = errors.New("token claims not found in context")
+)
 
 func UserGUIDFromContext(ctx context.Context) (string, error) {
 	switch v := ctx.Value(UserGUIDKey).(type) {
@@ -19,3 +27,12 @@ func UserGUIDFromContext(ctx context.Context) (string, error) {
 
 	return "", ErrUserGUIDNotFound
 }
+
+func ClaimsFromContext(ctx context.Context) (*Claims, error) {
+	switch v := ctx.Value(ClaimsKey).(type) {
+	case *Claims:
+		return v, nil
+	}
+
+	return nil, ErrClaimsNotFound
+}
@@ -2,13 +2,28 @@
 
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptMaxPasswordLen bcrypt uses only the first 72 bytes of a password.
+const bcryptMaxPasswordLen = 72
+
+var ErrPasswordTooLong = errors.New("password is too long")
 
 type IHasher interface {
 	HashPassword(password string) (string, error)
+	// ComparePasswords checks the password against a hash of any supported algorithm, not only the one new hashes
+	// are made with, so that users keep logging in while their hashes are upgraded.
 	ComparePasswords(hashedPassword, password string) bool
+	// NeedsRehash reports that the hash was made with another algorithm or other parameters than new hashes.
+	NeedsRehash(hashedPassword string) bool
 }
 
+// Hasher hashes passwords with bcrypt.
 type Hasher struct {
 	cost int
 }
@@ -23,7 +38,7 @@ func WithCost(cost int) Option {
 
 func NewHasher(opts ...Option) *Hasher {
 	h := &Hasher{
-		cost: bcrypt.MinCost,
+		cost: bcrypt.DefaultCost,
 	}
 
 	for _, opt := range opts {
@@ -34,11 +49,28 @@ func NewHasher(opts ...Option) *Hasher {
 }
 
 func (h Hasher) HashPassword(password string) (string, error) {
+	if len(password) > bcryptMaxPasswordLen {
+		return "", ErrPasswordTooLong
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
 	return string(hashedPassword), err
 }
 
 func (h Hasher) ComparePasswords(hashedPassword, password string) bool {
+	return comparePasswords(hashedPassword, password)
+}
+
+func (h Hasher) NeedsRehash(hashedPassword string) bool {
+	cost, err := bcrypt.Cost([]byte(hashedPassword))
+	return err != nil || cost != h.cost
+}
+
+func comparePasswords(hashedPassword, password string) bool {
+	if strings.HasPrefix(hashedPassword, argon2Prefix) {
+		return compareArgon2(hashedPassword, password)
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
 	return err == nil
 }
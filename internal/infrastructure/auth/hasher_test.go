@@ -1,10 +1,14 @@
 package auth_test
 
 import (
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
 )
@@ -27,3 +31,128 @@ func TestHasher_HashPassword(t *testing.T) {
 		assert.False(t, h.ComparePasswords(got, "654321"))
 	})
 }
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	h := auth.NewHasher(auth.WithCost(bcrypt.MinCost))
+	hashed, err := h.HashPassword("123456")
+	require.NoError(t, err)
+
+	assert.False(t, h.NeedsRehash(hashed))
+	assert.True(t, auth.NewHasher(auth.WithCost(bcrypt.MinCost+1)).NeedsRehash(hashed))
+	assert.True(t, h.NeedsRehash(hashArgon2(t, "123456")))
+}
+
+func TestHasher_HashPassword_TooLong(t *testing.T) {
+	_, err := auth.NewHasher().HashPassword(strings.Repeat("a", 73))
+	assert.ErrorIs(t, err, auth.ErrPasswordTooLong)
+}
+
+func TestArgon2Hasher_HashPassword(t *testing.T) {
+	h := auth.NewArgon2Hasher(testArgon2Params)
+
+	hashed, err := h.HashPassword("123456")
+	require.NoError(t, err)
+
+	assert.Regexp(t, `^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`, hashed)
+	assert.True(t, h.ComparePasswords(hashed, "123456"))
+	assert.False(t, h.ComparePasswords(hashed, "654321"))
+
+	again, err := h.HashPassword("123456")
+	require.NoError(t, err)
+	assert.NotEqual(t, hashed, again, "salt is not random")
+}
+
+func TestArgon2Hasher_ComparePasswords(t *testing.T) {
+	h := auth.NewArgon2Hasher(testArgon2Params)
+
+	t.Run("bcrypt", func(t *testing.T) {
+		hashed, err := auth.NewHasher(auth.WithCost(bcrypt.MinCost)).HashPassword("123456")
+		require.NoError(t, err)
+
+		assert.True(t, h.ComparePasswords(hashed, "123456"))
+		assert.False(t, h.ComparePasswords(hashed, "654321"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, hashed := range []string{
+			"",
+			"$argon2id$v=19$m=64,t=1,p=1$c2FsdA",
+			"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5",
+			"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHRzYWx0$a2V5",
+			"$argon2id$v=19$m=64,t=0,p=1$c2FsdHNhbHRzYWx0$a2V5",
+			"$argon2id$v=19$m=64,t=1,p=1$!$a2V5",
+		} {
+			assert.False(t, h.ComparePasswords(hashed, "123456"), hashed)
+			assert.True(t, h.NeedsRehash(hashed), hashed)
+		}
+	})
+}
+
+func TestArgon2Hasher_NeedsRehash(t *testing.T) {
+	h := auth.NewArgon2Hasher(testArgon2Params)
+	hashed := hashArgon2(t, "123456")
+
+	assert.False(t, h.NeedsRehash(hashed))
+
+	stronger := testArgon2Params
+	stronger.Time = 2
+	assert.True(t, auth.NewArgon2Hasher(stronger).NeedsRehash(hashed))
+
+	bcryptHash, err := auth.NewHasher(auth.WithCost(bcrypt.MinCost)).HashPassword("123456")
+	require.NoError(t, err)
+	assert.True(t, h.NeedsRehash(bcryptHash))
+}
+
+// slowHasher counts the calls in progress.
+type slowHasher struct {
+	auth.IHasher
+	mu            sync.Mutex
+	running, peak int
+}
+
+func (h *slowHasher) ComparePasswords(hashedPassword, password string) bool {
+	h.mu.Lock()
+	h.running++
+	h.peak = max(h.peak, h.running)
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		h.running--
+		h.mu.Unlock()
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+
+	return h.IHasher.ComparePasswords(hashedPassword, password)
+}
+
+func TestLimitedHasher_ComparePasswords(t *testing.T) {
+	slow := &slowHasher{IHasher: auth.NewArgon2Hasher(testArgon2Params)}
+	h := auth.NewLimitedHasher(slow, 2)
+	hashed := hashArgon2(t, "123456")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.True(t, h.ComparePasswords(hashed, "123456"))
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 2, slow.peak)
+}
+
+// testArgon2Params cheap parameters, the hashes are only checked for their format
+var testArgon2Params = auth.Argon2Params{Memory: 64, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32}
+
+func hashArgon2(t *testing.T, password string) string {
+	t.Helper()
+
+	hashed, err := auth.NewArgon2Hasher(testArgon2Params).HashPassword(password)
+	require.NoError(t, err)
+
+	return hashed
+}
@@ -8,53 +8,127 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var ErrInvalidToken = errors.New("invalid token")
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is matched by the error of ParseAccessToken for an expired token.
+	ErrTokenExpired = jwt.ErrTokenExpired
+)
 
 type claims struct {
 	jwt.RegisteredClaims
 	UserGUID string `json:"guid"`
 }
 
+// Claims of a valid access token.
+type Claims struct {
+	UserGUID string
+	// ID the jti claim, the token is revoked by it.
+	ID        string
+	ExpiresAt time.Time
+}
+
+// JWTBuilder signs access tokens with HS256 and the shared secret, or with the keys of a KeySet if it is given.
 type JWTBuilder struct {
 	secretKey string
 	tokenExp  time.Duration
+	keys      *KeySet
+}
+
+type JWTOption func(*JWTBuilder)
+
+// WithKeySet signs tokens with the asymmetric keys instead of the secret. Tokens signed with the secret are not
+// accepted then, their clients get a new access token with the refresh token.
+func WithKeySet(keys *KeySet) JWTOption {
+	return func(b *JWTBuilder) {
+		b.keys = keys
+	}
 }
 
-func NewJWTBuilder(secretKey string, tokenExp time.Duration) *JWTBuilder {
-	return &JWTBuilder{
+func NewJWTBuilder(secretKey string, tokenExp time.Duration, opts ...JWTOption) *JWTBuilder {
+	b := &JWTBuilder{
 		secretKey: secretKey,
 		tokenExp:  tokenExp,
 	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
 }
 
-func (b JWTBuilder) BuildJWTString(userGUID string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+// BuildAccessToken signs an access token of the user identified by jti. It returns the token and its expiration time.
+func (b JWTBuilder) BuildAccessToken(userGUID, jti string) (string, time.Time, error) {
+	expiresAt := time.Now().Add(b.tokenExp)
+
+	c := claims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(b.tokenExp)),
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 
 		UserGUID: userGUID,
-	})
+	}
+
+	var (
+		signed string
+		err    error
+	)
 
-	return token.SignedString([]byte(b.secretKey))
+	if b.keys != nil {
+		key := b.keys.SigningKey()
+		token := jwt.NewWithClaims(key.Method, c)
+		token.Header["kid"] = key.ID
+		signed, err = token.SignedString(key.private)
+	} else {
+		signed, err = jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte(b.secretKey))
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return signed, expiresAt, nil
 }
 
-func (b JWTBuilder) GetUserGUID(tokenString string) (string, error) {
+// ParseAccessToken checks the signature and expiration of the token. A token without jti can't be revoked, so it
+// is invalid too.
+func (b JWTBuilder) ParseAccessToken(tokenString string) (*Claims, error) {
 	c := &claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, c, func(t *jwt.Token) (interface{}, error) {
+	token, err := jwt.ParseWithClaims(tokenString, c, b.verifyingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || c.ID == "" || c.ExpiresAt == nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &Claims{
+		UserGUID:  c.UserGUID,
+		ID:        c.ID,
+		ExpiresAt: c.ExpiresAt.Time,
+	}, nil
+}
+
+func (b JWTBuilder) verifyingKey(t *jwt.Token) (interface{}, error) {
+	if b.keys == nil {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", t.Header["alg"])
 		}
 		return []byte(b.secretKey), nil
-	})
-	if err != nil {
-		return "", err
 	}
 
-	if !token.Valid {
-		return "", ErrInvalidToken
+	kid, _ := t.Header["kid"].(string)
+	key := b.keys.VerifyingKey(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	// the alg header must match the key, otherwise a public key could be used as an HMAC secret
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", t.Header["alg"], kid)
 	}
 
-	return c.UserGUID, nil
+	return key.public, nil
 }
@@ -12,14 +12,14 @@ import (
 	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
 )
 
-func TestJWTBuilder_BuildJWTString(t *testing.T) {
+func TestJWTBuilder_BuildAccessToken(t *testing.T) {
 	secretKey := "secret"
-	userGUID := "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
 
 	t.Run("success", func(t *testing.T) {
 		b := auth.NewJWTBuilder(secretKey, time.Hour)
-		got, err := b.BuildJWTString(userGUID)
+		got, expiresAt, err := b.BuildAccessToken(userGUID, jti)
 		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
 
 		token, err := jwt.Parse(got, func(token *jwt.Token) (interface{}, error) {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -34,6 +34,49 @@ func TestJWTBuilder_BuildJWTString(t *testing.T) {
 		claims, ok := token.Claims.(jwt.MapClaims)
 		require.True(t, ok)
 		assert.Equal(t, userGUID, claims["guid"])
+		assert.Equal(t, jti, claims["jti"])
 		assert.True(t, claims.VerifyExpiresAt(time.Now().Unix(), true))
 	})
 }
+
+func TestJWTBuilder_ParseAccessToken(t *testing.T) {
+	b := auth.NewJWTBuilder("secret", time.Hour)
+
+	t.Run("success", func(t *testing.T) {
+		token, expiresAt, err := b.BuildAccessToken(userGUID, jti)
+		require.NoError(t, err)
+
+		got, err := b.ParseAccessToken(token)
+		require.NoError(t, err)
+		assert.Equal(t, userGUID, got.UserGUID)
+		assert.Equal(t, jti, got.ID)
+		assert.WithinDuration(t, expiresAt, got.ExpiresAt, time.Second)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		token, _, err := auth.NewJWTBuilder("secret", -time.Minute).BuildAccessToken(userGUID, jti)
+		require.NoError(t, err)
+
+		_, err = b.ParseAccessToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("other_secret", func(t *testing.T) {
+		token, _, err := auth.NewJWTBuilder("other", time.Hour).BuildAccessToken(userGUID, jti)
+		require.NoError(t, err)
+
+		_, err = b.ParseAccessToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("without_jti", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"guid": userGUID,
+			"exp":  time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("secret"))
+		require.NoError(t, err)
+
+		_, err = b.ParseAccessToken(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
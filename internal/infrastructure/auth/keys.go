@@ -0,0 +1,300 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported key")
+	ErrNoSigningKey   = errors.New("signing key has no private part")
+)
+
+// Key is an asymmetric key for access tokens: RS256 for RSA, EdDSA for Ed25519. A key loaded from a public key file
+// only verifies tokens.
+type Key struct {
+	// ID the kid header of the tokens signed with the key, the RFC 7638 thumbprint of the public key.
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// LoadKey reads a PEM file with a PKCS#8 or PKCS#1 private key or a PKIX public key.
+func LoadKey(path string) (*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	key, err := ParseKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("key file %s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+func ParseKey(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block found", ErrUnsupportedKey)
+	}
+
+	var (
+		parsed any
+		err    error
+	)
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%w: PEM block %q", ErrUnsupportedKey, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	key := &Key{}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, parsed)
+	}
+
+	key.ID, err = thumbprint(key.JWK())
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWK returns the public part of the key.
+func (k Key) JWK() JWK {
+	jwk := JWK{
+		Kid: k.ID,
+		Use: "sig",
+		Alg: k.Method.Alg(),
+	}
+
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+
+	return jwk
+}
+
+// thumbprint hashes the required members of the JWK in lexicographic order, see RFC 7638.
+func thumbprint(jwk JWK) (string, error) {
+	var members any
+	switch jwk.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	default:
+		return "", fmt.Errorf("%w: key type %q", ErrUnsupportedKey, jwk.Kty)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal thumbprint: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// KeySet signs tokens with one key and verifies them with any key it holds. Keys are told apart by kid.
+type KeySet struct {
+	grace   time.Duration
+	publish time.Duration
+
+	mu      sync.RWMutex
+	signing *Key
+	// next is in the JWKS already and replaces signing at nextAt
+	next   *Key
+	nextAt time.Time
+	// verify keys by kid with the time they stop verifying, zero for never
+	verify map[string]verifyKey
+}
+
+type verifyKey struct {
+	key   *Key
+	until time.Time
+}
+
+// NewKeySet signs with signing. Keys from verify, e.g. the previous signing key after a restart, verify tokens
+// until they are removed from the configuration. A key passed to Rotate is published for publish before it signs,
+// the key it replaces verifies for grace after that.
+func NewKeySet(signing *Key, verify []*Key, grace, publish time.Duration) (*KeySet, error) {
+	if signing.private == nil {
+		return nil, ErrNoSigningKey
+	}
+
+	s := &KeySet{
+		grace:   grace,
+		publish: publish,
+		signing: signing,
+		verify:  make(map[string]verifyKey, len(verify)+1),
+	}
+
+	for _, k := range verify {
+		s.verify[k.ID] = verifyKey{key: k}
+	}
+	s.verify[signing.ID] = verifyKey{key: signing}
+
+	return s, nil
+}
+
+// Rotate publishes next in the JWKS and signs new tokens with it once the publish period is over, so the services
+// caching the JWKS know the key before they see a token signed with it. Tokens signed with the previous key verify
+// until the grace period after the switch is over, which should be at least the lifetime of a token. Rotating back
+// to the signing key cancels a pending rotation. It returns the time next signs from.
+func (s *KeySet) Rotate(next *Key) (time.Time, error) {
+	if next.private == nil {
+		return time.Time{}, ErrNoSigningKey
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	if s.next != nil && !now.Before(s.nextAt) {
+		s.signing, s.next = s.next, nil
+	}
+
+	if s.next != nil && next.ID == s.next.ID {
+		return s.nextAt, nil
+	}
+
+	// a pending key has signed nothing, so it stops verifying at once
+	if s.next != nil {
+		s.verify[s.next.ID] = verifyKey{key: s.next, until: now}
+		s.verify[s.signing.ID] = verifyKey{key: s.signing}
+		s.next = nil
+	}
+
+	if next.ID == s.signing.ID {
+		return now, nil
+	}
+
+	s.next, s.nextAt = next, now.Add(s.publish)
+	s.verify[next.ID] = verifyKey{key: next}
+	s.verify[s.signing.ID] = verifyKey{key: s.signing, until: s.nextAt.Add(s.grace)}
+
+	return s.nextAt, nil
+}
+
+// SigningKey returns the key new tokens are signed with.
+func (s *KeySet) SigningKey() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.current(time.Now())
+}
+
+func (s *KeySet) current(now time.Time) *Key {
+	if s.next != nil && !now.Before(s.nextAt) {
+		return s.next
+	}
+
+	return s.signing
+}
+
+// VerifyingKey returns the key with the kid, nil if there is no such key or its grace period is over.
+func (s *KeySet) VerifyingKey(kid string) *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.verify[kid]
+	if !ok || (!v.until.IsZero() && time.Now().After(v.until)) {
+		return nil
+	}
+
+	return v.key
+}
+
+// JWKS returns the public keys that verify tokens, for the services that check our tokens themselves.
+func (s *KeySet) JWKS() []JWK {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	signing := s.current(now)
+	keys := make([]JWK, 0, len(s.verify))
+
+	for kid, v := range s.verify {
+		if kid == signing.ID || (!v.until.IsZero() && now.After(v.until)) {
+			continue
+		}
+		keys = append(keys, v.key.JWK())
+	}
+	slices.SortFunc(keys, func(a, b JWK) int {
+		return strings.Compare(a.Kid, b.Kid)
+	})
+
+	// the signing key first, so a client that takes the first key gets the current one
+	return append([]JWK{signing.JWK()}, keys...)
+}
@@ -0,0 +1,257 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+)
+
+const (
+	userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+	jti      = "6b0f8d57-1c3f-4a43-9a4f-0f2b0bb4b1a1"
+)
+
+func rsaKeyPEM(t *testing.T) ([]byte, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), key
+}
+
+func ed25519KeyPEM(t *testing.T) []byte {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func publicKeyPEM(t *testing.T, pub any) []byte {
+	t.Helper()
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func parseKey(t *testing.T, data []byte) *auth.Key {
+	t.Helper()
+
+	key, err := auth.ParseKey(data)
+	require.NoError(t, err)
+
+	return key
+}
+
+func TestParseKey(t *testing.T) {
+	t.Run("rfc7638_thumbprint", func(t *testing.T) {
+		n, err := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+		require.NoError(t, err)
+
+		key := parseKey(t, publicKeyPEM(t, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}))
+
+		assert.Equal(t, "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", key.ID)
+		assert.Equal(t, "RS256", key.Method.Alg())
+
+		jwk := key.JWK()
+		assert.Equal(t, "RSA", jwk.Kty)
+		assert.Equal(t, "AQAB", jwk.E)
+	})
+
+	t.Run("ed25519", func(t *testing.T) {
+		key := parseKey(t, ed25519KeyPEM(t))
+
+		assert.Equal(t, "EdDSA", key.Method.Alg())
+		assert.NotEmpty(t, key.ID)
+
+		jwk := key.JWK()
+		assert.Equal(t, "OKP", jwk.Kty)
+		assert.Equal(t, "Ed25519", jwk.Crv)
+		assert.Equal(t, key.ID, jwk.Kid)
+	})
+
+	t.Run("private_and_public_have_same_kid", func(t *testing.T) {
+		data, private := rsaKeyPEM(t)
+
+		assert.Equal(t, parseKey(t, data).ID, parseKey(t, publicKeyPEM(t, &private.PublicKey)).ID)
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		_, err := auth.ParseKey([]byte("not a key"))
+		assert.ErrorIs(t, err, auth.ErrUnsupportedKey)
+
+		_, err = auth.ParseKey(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{1}}))
+		assert.ErrorIs(t, err, auth.ErrUnsupportedKey)
+	})
+
+	t.Run("from_file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "key.pem")
+		require.NoError(t, os.WriteFile(path, ed25519KeyPEM(t), 0o600))
+
+		key, err := auth.LoadKey(path)
+		require.NoError(t, err)
+		assert.Equal(t, "EdDSA", key.Method.Alg())
+	})
+}
+
+func TestJWTBuilder_KeySet(t *testing.T) {
+	rsaData, rsaPrivate := rsaKeyPEM(t)
+	rsaKey := parseKey(t, rsaData)
+	edKey := parseKey(t, ed25519KeyPEM(t))
+
+	t.Run("signs_with_kid", func(t *testing.T) {
+		for _, key := range []*auth.Key{rsaKey, edKey} {
+			keys, err := auth.NewKeySet(key, nil, time.Hour, 0)
+			require.NoError(t, err)
+			b := auth.NewJWTBuilder("secret", time.Hour, auth.WithKeySet(keys))
+
+			token, _, err := b.BuildAccessToken(userGUID, jti)
+			require.NoError(t, err)
+
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			require.NoError(t, err)
+			assert.Equal(t, key.ID, parsed.Header["kid"])
+			assert.Equal(t, key.Method.Alg(), parsed.Header["alg"])
+
+			claims, err := b.ParseAccessToken(token)
+			require.NoError(t, err)
+			assert.Equal(t, userGUID, claims.UserGUID)
+		}
+	})
+
+	t.Run("public_key_verifies_only", func(t *testing.T) {
+		public := parseKey(t, publicKeyPEM(t, &rsaPrivate.PublicKey))
+
+		_, err := auth.NewKeySet(public, nil, time.Hour, 0)
+		assert.ErrorIs(t, err, auth.ErrNoSigningKey)
+
+		signing, err := auth.NewKeySet(rsaKey, nil, time.Hour, 0)
+		require.NoError(t, err)
+		token, _, err := auth.NewJWTBuilder("secret", time.Hour, auth.WithKeySet(signing)).BuildAccessToken(userGUID, jti)
+		require.NoError(t, err)
+
+		// another service holds only the public key
+		verifying, err := auth.NewKeySet(edKey, []*auth.Key{public}, time.Hour, 0)
+		require.NoError(t, err)
+		_, err = auth.NewJWTBuilder("", time.Hour, auth.WithKeySet(verifying)).ParseAccessToken(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("rotation_grace", func(t *testing.T) {
+		keys, err := auth.NewKeySet(rsaKey, nil, 50*time.Millisecond, 0)
+		require.NoError(t, err)
+		b := auth.NewJWTBuilder("secret", time.Hour, auth.WithKeySet(keys))
+
+		old, _, err := b.BuildAccessToken(userGUID, jti)
+		require.NoError(t, err)
+
+		_, err = keys.Rotate(edKey)
+		require.NoError(t, err)
+		assert.Equal(t, edKey.ID, keys.SigningKey().ID)
+
+		jwks := keys.JWKS()
+		require.Len(t, jwks, 2)
+		assert.Equal(t, edKey.ID, jwks[0].Kid)
+
+		_, err = b.ParseAccessToken(old)
+		require.NoError(t, err, "the previous key verifies during the grace period")
+
+		time.Sleep(60 * time.Millisecond)
+
+		_, err = b.ParseAccessToken(old)
+		assert.Error(t, err)
+		assert.Len(t, keys.JWKS(), 1)
+
+		current, _, err := b.BuildAccessToken(userGUID, jti)
+		require.NoError(t, err)
+		_, err = b.ParseAccessToken(current)
+		assert.NoError(t, err)
+	})
+
+	t.Run("published_before_signing", func(t *testing.T) {
+		keys, err := auth.NewKeySet(rsaKey, nil, time.Hour, 50*time.Millisecond)
+		require.NoError(t, err)
+
+		signsAt, err := keys.Rotate(edKey)
+		require.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), signsAt, 10*time.Millisecond)
+
+		// the new key is in the JWKS, but the current one still signs
+		assert.Equal(t, rsaKey.ID, keys.SigningKey().ID)
+		jwks := keys.JWKS()
+		require.Len(t, jwks, 2)
+		assert.Equal(t, rsaKey.ID, jwks[0].Kid)
+		assert.Equal(t, edKey.ID, jwks[1].Kid)
+
+		time.Sleep(60 * time.Millisecond)
+
+		assert.Equal(t, edKey.ID, keys.SigningKey().ID)
+		jwks = keys.JWKS()
+		require.Len(t, jwks, 2)
+		assert.Equal(t, edKey.ID, jwks[0].Kid)
+	})
+
+	t.Run("pending_rotation_cancelled", func(t *testing.T) {
+		keys, err := auth.NewKeySet(rsaKey, nil, time.Hour, time.Hour)
+		require.NoError(t, err)
+
+		_, err = keys.Rotate(edKey)
+		require.NoError(t, err)
+
+		_, err = keys.Rotate(rsaKey)
+		require.NoError(t, err)
+
+		assert.Equal(t, rsaKey.ID, keys.SigningKey().ID)
+		assert.Nil(t, keys.VerifyingKey(edKey.ID))
+		assert.Len(t, keys.JWKS(), 1)
+	})
+
+	t.Run("rejects_secret_tokens", func(t *testing.T) {
+		keys, err := auth.NewKeySet(rsaKey, nil, time.Hour, 0)
+		require.NoError(t, err)
+
+		token, _, err := auth.NewJWTBuilder("secret", time.Hour).BuildAccessToken(userGUID, jti)
+		require.NoError(t, err)
+
+		_, err = auth.NewJWTBuilder("secret", time.Hour, auth.WithKeySet(keys)).ParseAccessToken(token)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects_public_key_as_hmac_secret", func(t *testing.T) {
+		keys, err := auth.NewKeySet(rsaKey, nil, time.Hour, 0)
+		require.NoError(t, err)
+
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"guid": userGUID,
+			"jti":  jti,
+			"exp":  time.Now().Add(time.Hour).Unix(),
+		})
+		token.Header["kid"] = rsaKey.ID
+		forged, err := token.SignedString(publicKeyPEM(t, &rsaPrivate.PublicKey))
+		require.NoError(t, err)
+
+		_, err = auth.NewJWTBuilder("secret", time.Hour, auth.WithKeySet(keys)).ParseAccessToken(forged)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,33 @@
+package auth
+
+// LimitedHasher bounds the number of passwords hashed and checked at once. Each of them takes the memory of the
+// algorithm, so without a bound a burst of logins or registrations could exhaust it.
+type LimitedHasher struct {
+	hasher IHasher
+	slots  chan struct{}
+}
+
+func NewLimitedHasher(hasher IHasher, limit int) *LimitedHasher {
+	return &LimitedHasher{
+		hasher: hasher,
+		slots:  make(chan struct{}, max(limit, 1)),
+	}
+}
+
+func (h LimitedHasher) HashPassword(password string) (string, error) {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	return h.hasher.HashPassword(password)
+}
+
+func (h LimitedHasher) ComparePasswords(hashedPassword, password string) bool {
+	h.slots <- struct{}{}
+	defer func() { <-h.slots }()
+
+	return h.hasher.ComparePasswords(hashedPassword, password)
+}
+
+func (h LimitedHasher) NeedsRehash(hashedPassword string) bool {
+	return h.hasher.NeedsRehash(hashedPassword)
+}
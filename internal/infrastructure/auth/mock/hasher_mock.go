@@ -61,3 +61,17 @@ func (mr *MockIHasherMockRecorder) HashPassword(password interface{}) *gomock.Ca
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HashPassword", reflect.TypeOf((*MockIHasher)(nil).HashPassword), password)
 }
+
+// NeedsRehash mocks base method.
+func (m *MockIHasher) NeedsRehash(hashedPassword string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NeedsRehash", hashedPassword)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// NeedsRehash indicates an expected call of NeedsRehash.
+func (mr *MockIHasherMockRecorder) NeedsRehash(hashedPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NeedsRehash", reflect.TypeOf((*MockIHasher)(nil).NeedsRehash), hashedPassword)
+}
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const opaqueTokenSize = 32
+
+// NewRefreshToken returns an opaque random token. Only its hash is stored, see HashRefreshToken.
+func NewRefreshToken() (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	return token, nil
+}
+
+// HashRefreshToken returns the hex SHA-256 of the token. The token is random, so a plain hash is enough to look it
+// up without storing it.
+func HashRefreshToken(token string) string {
+	return hashOpaqueToken(token)
+}
+
+// NewResetToken returns an opaque random token of a password reset. Only its hash is stored, see HashResetToken.
+func NewResetToken() (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+// HashResetToken returns the hex SHA-256 of the token, like HashRefreshToken.
+func HashResetToken(token string) string {
+	return hashOpaqueToken(token)
+}
+
+func newOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationChecker tells whether the access token with the jti is revoked.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// RevocationCache keeps answers of the revocation list in memory for ttl, so an authenticated request doesn't
+// query the database every time. A token revoked by another replica works here until its answer expires; a token
+// revoked by this replica stops at once, see Revoke.
+type RevocationCache struct {
+	next RevocationChecker
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]revocationEntry
+	sweptAt time.Time
+}
+
+type revocationEntry struct {
+	revoked   bool
+	expiresAt time.Time
+}
+
+func NewRevocationCache(next RevocationChecker, ttl time.Duration) *RevocationCache {
+	return &RevocationCache{
+		next:    next,
+		ttl:     ttl,
+		entries: make(map[string]revocationEntry),
+		sweptAt: time.Now(),
+	}
+}
+
+func (c *RevocationCache) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[jti]
+	c.mu.Unlock()
+
+	if ok && now.Before(entry.expiresAt) {
+		return entry.revoked, nil
+	}
+
+	revoked, err := c.next.IsRevoked(ctx, jti)
+	if err != nil {
+		return false, err
+	}
+
+	c.set(jti, revoked, now)
+
+	return revoked, nil
+}
+
+// Revoke marks the token revoked in the cache. It is called after the token has been put on the revocation list.
+func (c *RevocationCache) Revoke(jti string) {
+	c.set(jti, true, time.Now())
+}
+
+func (c *RevocationCache) set(jti string, revoked bool, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// a revoked answer is never overwritten by a stale one read concurrently
+	if entry, ok := c.entries[jti]; ok && entry.revoked && now.Before(entry.expiresAt) {
+		return
+	}
+
+	c.entries[jti] = revocationEntry{
+		revoked:   revoked,
+		expiresAt: now.Add(c.ttl),
+	}
+
+	if now.Sub(c.sweptAt) >= c.ttl {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		c.sweptAt = now
+	}
+}
@@ -0,0 +1,89 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+)
+
+type revocationList struct {
+	revoked map[string]bool
+	err     error
+	calls   int
+}
+
+func (l *revocationList) IsRevoked(_ context.Context, jti string) (bool, error) {
+	l.calls++
+	return l.revoked[jti], l.err
+}
+
+func TestRevocationCache(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("caches_answers", func(t *testing.T) {
+		list := &revocationList{revoked: map[string]bool{"a": true}}
+		c := auth.NewRevocationCache(list, time.Minute)
+
+		for i := 0; i < 3; i++ {
+			revoked, err := c.IsRevoked(ctx, "a")
+			require.NoError(t, err)
+			assert.True(t, revoked)
+
+			revoked, err = c.IsRevoked(ctx, "b")
+			require.NoError(t, err)
+			assert.False(t, revoked)
+		}
+		assert.Equal(t, 2, list.calls)
+	})
+
+	t.Run("asks_again_after_ttl", func(t *testing.T) {
+		list := &revocationList{revoked: map[string]bool{}}
+		c := auth.NewRevocationCache(list, 20*time.Millisecond)
+
+		revoked, err := c.IsRevoked(ctx, "a")
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		// revoked by another replica
+		list.revoked["a"] = true
+		time.Sleep(30 * time.Millisecond)
+
+		revoked, err = c.IsRevoked(ctx, "a")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("revoke_overrides_cached_answer", func(t *testing.T) {
+		list := &revocationList{revoked: map[string]bool{}}
+		c := auth.NewRevocationCache(list, time.Minute)
+
+		_, err := c.IsRevoked(ctx, "a")
+		require.NoError(t, err)
+
+		c.Revoke("a")
+
+		revoked, err := c.IsRevoked(ctx, "a")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+		assert.Equal(t, 1, list.calls)
+	})
+
+	t.Run("error_is_not_cached", func(t *testing.T) {
+		list := &revocationList{err: errors.New("db is down")}
+		c := auth.NewRevocationCache(list, time.Minute)
+
+		_, err := c.IsRevoked(ctx, "a")
+		require.Error(t, err)
+
+		list.err = nil
+		_, err = c.IsRevoked(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, 2, list.calls)
+	})
+}
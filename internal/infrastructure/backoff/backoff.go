@@ -0,0 +1,19 @@
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Exponential returns the delay before the next try after attempts failed ones: base doubles with every attempt up to
+// maxDelay. The delay is random between a half and the whole of it, which spreads out tries that failed together.
+func Exponential(base, maxDelay time.Duration, attempts int) time.Duration {
+	d := base
+	for i := 1; i < attempts && d < maxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, maxDelay)
+
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
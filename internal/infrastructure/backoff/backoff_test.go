@@ -0,0 +1,32 @@
+package backoff_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/backoff"
+)
+
+func TestExponential(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{name: "first", attempts: 1, want: time.Second},
+		{name: "doubles", attempts: 3, want: 4 * time.Second},
+		{name: "capped", attempts: 100, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				d := backoff.Exponential(time.Second, time.Minute, tt.attempts)
+				assert.GreaterOrEqual(t, d, tt.want/2)
+				assert.LessOrEqual(t, d, tt.want)
+			}
+		})
+	}
+}
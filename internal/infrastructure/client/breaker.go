@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type State uint
+
+const (
+	StateClosed   State = iota // Requests go through, failures are counted
+	StateOpen                  // Requests are rejected without being sent
+	StateHalfOpen              // A few probe requests decide whether the circuit closes or opens again
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+type BreakerSettings struct {
+	// FailureThreshold consecutive failures that open the circuit.
+	FailureThreshold int
+	// OpenTimeout how long the circuit stays open before probe requests are let through.
+	OpenTimeout time.Duration
+	// HalfOpenRequests probe requests let through in the half-open state. The circuit closes when all of them
+	// succeed and opens again on the first failure.
+	HalfOpenRequests int
+	// OnStateChange is called after every transition, e.g. to log it. It must not call the breaker.
+	OnStateChange func(from, to State)
+}
+
+// BreakerStats is a snapshot of the breaker for monitoring.
+type BreakerStats struct {
+	State    State
+	Failures int
+	// OpenedAt time the circuit was opened last, zero if it has never been open.
+	OpenedAt time.Time
+	LastErr  error
+}
+
+// Breaker is a circuit breaker around a Client. A transport error and a 5xx response are failures; any other
+// response, 429 included, means the service is up.
+type Breaker struct {
+	next     Client
+	settings BreakerSettings
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	lastErr   error
+	probes    int
+	successes int
+	// generation changes with every transition, so a request started in one state doesn't count in the next one
+	generation uint64
+}
+
+func NewBreaker(next Client, settings BreakerSettings) *Breaker {
+	return &Breaker{
+		next:     next,
+		settings: settings,
+	}
+}
+
+func (b *Breaker) Do(ctx context.Context, method, url string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	generation, err := b.before()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := b.next.Do(ctx, method, url, body, opts...)
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// the caller gave up, which says nothing about the service
+		b.cancel(generation)
+	case err != nil:
+		b.after(generation, err)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		b.after(generation, errors.New(resp.Status))
+	default:
+		b.after(generation, nil)
+	}
+
+	return resp, err
+}
+
+func (b *Breaker) Get(ctx context.Context, url string, opts ...RequestOption) (*http.Response, error) {
+	return b.Do(ctx, http.MethodGet, url, nil, opts...)
+}
+
+func (b *Breaker) Post(ctx context.Context, url string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return b.Do(ctx, http.MethodPost, url, body, opts...)
+}
+
+// State returns the current state. An open circuit whose timeout is over is reported as half-open, although it
+// switches only with the next request.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && time.Since(b.openedAt) >= b.settings.OpenTimeout {
+		return StateHalfOpen
+	}
+
+	return b.state
+}
+
+func (b *Breaker) Stats() BreakerStats {
+	state := b.State()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return BreakerStats{
+		State:    state,
+		Failures: b.failures,
+		OpenedAt: b.openedAt,
+		LastErr:  b.lastErr,
+	}
+}
+
+func (b *Breaker) before() (uint64, error) {
+	b.mu.Lock()
+
+	var from State
+	changed := false
+
+	if b.state == StateOpen {
+		if time.Since(b.openedAt) < b.settings.OpenTimeout {
+			b.mu.Unlock()
+			return 0, ErrCircuitOpen
+		}
+
+		from, changed = b.state, true
+		b.setState(StateHalfOpen)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.probes >= b.settings.HalfOpenRequests {
+			b.mu.Unlock()
+			return 0, ErrCircuitOpen
+		}
+		b.probes++
+	}
+
+	generation := b.generation
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(from, StateHalfOpen)
+	}
+
+	return generation, nil
+}
+
+func (b *Breaker) after(generation uint64, err error) {
+	b.mu.Lock()
+
+	if generation != b.generation {
+		b.mu.Unlock()
+		return
+	}
+
+	from := b.state
+
+	if err != nil {
+		b.failures++
+		b.lastErr = err
+	}
+
+	switch b.state {
+	case StateClosed:
+		if err == nil {
+			b.failures = 0
+		} else if b.failures >= b.settings.FailureThreshold {
+			b.setState(StateOpen)
+		}
+	case StateHalfOpen:
+		if err != nil {
+			b.setState(StateOpen)
+			break
+		}
+
+		b.successes++
+		if b.successes >= b.settings.HalfOpenRequests {
+			b.failures = 0
+			b.setState(StateClosed)
+		}
+	}
+
+	to := b.state
+	b.mu.Unlock()
+
+	if from != to {
+		b.notify(from, to)
+	}
+}
+
+// cancel frees the probe slot of a request without an outcome.
+func (b *Breaker) cancel(generation uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation == b.generation && b.state == StateHalfOpen {
+		b.probes--
+	}
+}
+
+// setState must be called under the lock.
+func (b *Breaker) setState(state State) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.successes = 0
+
+	if state == StateOpen {
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *Breaker) notify(from, to State) {
+	if b.settings.OnStateChange != nil {
+		b.settings.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,198 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+)
+
+// stub answers with code, or fails with err if it is set. The breaker calls only Do of the wrapped client.
+type stub struct {
+	client.Client
+
+	mu    sync.Mutex
+	code  int
+	err   error
+	calls int
+}
+
+func (s *stub) Do(context.Context, string, string, io.Reader, ...client.RequestOption) (*http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	return &http.Response{StatusCode: s.code, Status: http.StatusText(s.code), Body: http.NoBody}, nil
+}
+
+func (s *stub) set(code int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.code, s.err = code, err
+}
+
+type transitions struct {
+	mu  sync.Mutex
+	got []string
+}
+
+func (t *transitions) record(from, to client.State) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.got = append(t.got, from.String()+"->"+to.String())
+}
+
+func TestBreaker(t *testing.T) {
+	errDown := errors.New("connection refused")
+
+	t.Run("opens_after_consecutive_failures", func(t *testing.T) {
+		next := &stub{code: http.StatusOK}
+		tr := &transitions{}
+		b := client.NewBreaker(next, client.BreakerSettings{
+			FailureThreshold: 3,
+			OpenTimeout:      time.Minute,
+			HalfOpenRequests: 1,
+			OnStateChange:    tr.record,
+		})
+
+		next.set(0, errDown)
+		for i := 0; i < 2; i++ {
+			_, err := b.Get(context.Background(), "/")
+			assert.ErrorIs(t, err, errDown)
+		}
+
+		// a success in between resets the count
+		next.set(http.StatusOK, nil)
+		_, err := b.Get(context.Background(), "/")
+		require.NoError(t, err)
+		assert.Equal(t, client.StateClosed, b.State())
+
+		next.set(http.StatusBadGateway, nil)
+		for i := 0; i < 3; i++ {
+			_, _ = b.Get(context.Background(), "/")
+		}
+		assert.Equal(t, client.StateOpen, b.State())
+
+		_, err = b.Get(context.Background(), "/")
+		assert.ErrorIs(t, err, client.ErrCircuitOpen)
+		assert.Equal(t, 6, next.calls)
+
+		stats := b.Stats()
+		assert.Equal(t, 3, stats.Failures)
+		assert.False(t, stats.OpenedAt.IsZero())
+		assert.EqualError(t, stats.LastErr, "Bad Gateway")
+		assert.Equal(t, []string{"closed->open"}, tr.got)
+	})
+
+	t.Run("client_errors_are_not_failures", func(t *testing.T) {
+		next := &stub{code: http.StatusTooManyRequests}
+		b := client.NewBreaker(next, client.BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute, HalfOpenRequests: 1})
+
+		for i := 0; i < 3; i++ {
+			_, err := b.Get(context.Background(), "/")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, client.StateClosed, b.State())
+	})
+
+	t.Run("half_open_probe_closes", func(t *testing.T) {
+		next := &stub{}
+		tr := &transitions{}
+		b := client.NewBreaker(next, client.BreakerSettings{
+			FailureThreshold: 1,
+			OpenTimeout:      50 * time.Millisecond,
+			HalfOpenRequests: 2,
+			OnStateChange:    tr.record,
+		})
+
+		next.set(0, errDown)
+		_, _ = b.Get(context.Background(), "/")
+		require.Equal(t, client.StateOpen, b.State())
+
+		time.Sleep(60 * time.Millisecond)
+		assert.Equal(t, client.StateHalfOpen, b.State())
+
+		next.set(http.StatusOK, nil)
+		for i := 0; i < 2; i++ {
+			_, err := b.Get(context.Background(), "/")
+			require.NoError(t, err)
+		}
+
+		stats := b.Stats()
+		assert.Equal(t, client.StateClosed, stats.State)
+		assert.Zero(t, stats.Failures)
+		assert.Equal(t, []string{"closed->open", "open->half-open", "half-open->closed"}, tr.got)
+	})
+
+	t.Run("half_open_probe_fails", func(t *testing.T) {
+		next := &stub{}
+		b := client.NewBreaker(next, client.BreakerSettings{FailureThreshold: 1, OpenTimeout: 50 * time.Millisecond, HalfOpenRequests: 1})
+
+		next.set(0, errDown)
+		_, _ = b.Get(context.Background(), "/")
+		time.Sleep(60 * time.Millisecond)
+
+		_, err := b.Get(context.Background(), "/")
+		assert.ErrorIs(t, err, errDown)
+		assert.Equal(t, client.StateOpen, b.State())
+	})
+
+	t.Run("half_open_limits_probes", func(t *testing.T) {
+		release := make(chan struct{})
+		next := &blocking{release: release}
+		b := client.NewBreaker(next, client.BreakerSettings{FailureThreshold: 1, OpenTimeout: 50 * time.Millisecond, HalfOpenRequests: 1})
+
+		next.fail = true
+		_, _ = b.Get(context.Background(), "/")
+		time.Sleep(60 * time.Millisecond)
+		next.fail = false
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = b.Get(context.Background(), "/")
+		}()
+
+		// the probe is in flight, everything else is rejected until it answers
+		require.Eventually(t, func() bool {
+			_, err := b.Get(context.Background(), "/")
+			return errors.Is(err, client.ErrCircuitOpen)
+		}, time.Second, 5*time.Millisecond)
+
+		close(release)
+		<-done
+		assert.Equal(t, client.StateClosed, b.State())
+	})
+}
+
+// blocking fails at once if fail is set, otherwise answers 200 when release is closed.
+type blocking struct {
+	client.Client
+
+	fail    bool
+	release chan struct{}
+}
+
+func (s *blocking) Do(_ context.Context, _, _ string, _ io.Reader, _ ...client.RequestOption) (*http.Response, error) {
+	if s.fail {
+		return nil, errors.New("connection refused")
+	}
+
+	<-s.release
+
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
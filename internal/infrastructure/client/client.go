@@ -1,15 +1,61 @@
 package client
 
 import (
-	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"time"
 
+	chiMiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-resty/resty/v2"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
 )
 
+const HeaderRequestID = "X-Request-Id"
+
+// Client sends HTTP requests. The context bounds the whole call, retries included. The caller closes the body of
+// the response.
 type Client interface {
-	Get(url string) (*http.Response, error)
+	Do(ctx context.Context, method, url string, body io.Reader, opts ...RequestOption) (*http.Response, error)
+	Get(ctx context.Context, url string, opts ...RequestOption) (*http.Response, error)
+	Post(ctx context.Context, url string, body io.Reader, opts ...RequestOption) (*http.Response, error)
+}
+
+// RequestOption sets up a single request.
+type RequestOption func(header http.Header)
+
+func WithHeader(key, value string) RequestOption {
+	return func(header http.Header) {
+		header.Set(key, value)
+	}
+}
+
+// WithRequestID forwards the ID of the request or job the call is made for, if the context has one.
+func WithRequestID(ctx context.Context) RequestOption {
+	return func(header http.Header) {
+		if id := RequestIDFromContext(ctx); id != "" {
+			header.Set(HeaderRequestID, id)
+		}
+	}
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID marks calls made with the context by id, e.g. for a background job that isn't served by
+// the RequestID middleware.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the ID set by ContextWithRequestID or by chi's RequestID middleware.
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+
+	return chiMiddleware.GetReqID(ctx)
 }
 
 type Option func(*resty.Client)
@@ -53,11 +99,38 @@ func NewRestyClient(opts ...Option) *RestyClient {
 	}
 }
 
-func (c RestyClient) Get(url string) (*http.Response, error) {
-	resp, err := c.client.R().SetDoNotParseResponse(true).Get(url)
+func (c RestyClient) Do(ctx context.Context, method, url string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	header := make(http.Header)
+	for _, opt := range opts {
+		opt(header)
+	}
+
+	req := c.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		SetHeaderMultiValues(header)
+
+	// read at once, so a retry sends the same body
+	if body != nil {
+		b, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.SetBody(b)
+	}
+
+	resp, err := req.Execute(method, url)
 	if err != nil {
 		return nil, err
 	}
 
 	return resp.RawResponse, nil
 }
+
+func (c RestyClient) Get(ctx context.Context, url string, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(ctx, http.MethodGet, url, nil, opts...)
+}
+
+func (c RestyClient) Post(ctx context.Context, url string, body io.Reader, opts ...RequestOption) (*http.Response, error) {
+	return c.Do(ctx, http.MethodPost, url, body, opts...)
+}
@@ -0,0 +1,62 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+)
+
+func TestRestyClient_Post(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "req-1", r.Header.Get(client.HeaderRequestID))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":1}`, string(body))
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+
+	c := client.NewRestyClient()
+
+	ctx := client.ContextWithRequestID(context.Background(), "req-1")
+	resp, err := c.Post(ctx, server.URL, strings.NewReader(`{"a":1}`),
+		client.WithHeader("Content-Type", "application/json"),
+		client.WithRequestID(ctx),
+	)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
+
+func TestRestyClient_Get_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		cancel()
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	_, err := client.NewRestyClient().Get(ctx, server.URL)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	assert.Empty(t, client.RequestIDFromContext(context.Background()))
+	assert.Equal(t, "job-1", client.RequestIDFromContext(client.ContextWithRequestID(context.Background(), "job-1")))
+}
@@ -10,7 +10,8 @@ import (
 	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
 )
 
-func CheckAuth(jwt *auth.JWTBuilder, log logger.Logger) func(next http.Handler) http.Handler {
+// CheckAuth lets through requests with a valid access token that is not on the revocation list.
+func CheckAuth(jwt *auth.JWTBuilder, revocations auth.RevocationChecker, log logger.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
@@ -21,9 +22,14 @@ func CheckAuth(jwt *auth.JWTBuilder, log logger.Logger) func(next http.Handler)
 
 			token := strings.Replace(authHeader, "Bearer ", "", 1)
 
-			userGUID, err := jwt.GetUserGUID(token)
+			claims, err := jwt.ParseAccessToken(token)
 			if err != nil {
-				if !errors.Is(err, auth.ErrInvalidToken) {
+				// access tokens are short-lived, an expired one is a routine reason to refresh
+				switch {
+				case errors.Is(err, auth.ErrInvalidToken):
+				case errors.Is(err, auth.ErrTokenExpired):
+					log.WithError(err).Debug("Access token expired")
+				default:
 					log.WithError(err).Error("Failed to validate token")
 				}
 
@@ -31,7 +37,19 @@ func CheckAuth(jwt *auth.JWTBuilder, log logger.Logger) func(next http.Handler)
 				return
 			}
 
-			ctx := context.WithValue(r.Context(), auth.UserGUIDKey, userGUID)
+			revoked, err := revocations.IsRevoked(r.Context(), claims.ID)
+			if err != nil {
+				log.WithError(err).Error("Failed to check token revocation")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), auth.UserGUIDKey, claims.UserGUID)
+			ctx = context.WithValue(ctx, auth.ClaimsKey, claims)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
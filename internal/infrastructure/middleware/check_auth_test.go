@@ -0,0 +1,95 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/middleware"
+)
+
+func TestCheckAuth(t *testing.T) {
+	const (
+		secret   = "secret"
+		userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+	)
+
+	tests := []struct {
+		name   string
+		token  func(t *testing.T) string
+		log    func(log *mock.MockLogger)
+		status int
+	}{
+		{
+			name: "valid",
+			token: func(t *testing.T) string {
+				token, _, err := auth.NewJWTBuilder(secret, time.Minute).BuildAccessToken(userGUID, "jti")
+				require.NoError(t, err)
+				return token
+			},
+			log:    func(*mock.MockLogger) {},
+			status: http.StatusOK,
+		},
+		{
+			name: "expired_is_not_an_error",
+			token: func(t *testing.T) string {
+				token, _, err := auth.NewJWTBuilder(secret, -time.Minute).BuildAccessToken(userGUID, "jti")
+				require.NoError(t, err)
+				return token
+			},
+			log: func(log *mock.MockLogger) {
+				log.EXPECT().WithError(gomock.Any()).Return(log)
+				log.EXPECT().Debug("Access token expired")
+			},
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "wrong_signature",
+			token: func(t *testing.T) string {
+				token, _, err := auth.NewJWTBuilder("another", time.Minute).BuildAccessToken(userGUID, "jti")
+				require.NoError(t, err)
+				return token
+			},
+			log: func(log *mock.MockLogger) {
+				log.EXPECT().WithError(gomock.Any()).Return(log)
+				log.EXPECT().Error("Failed to validate token")
+			},
+			status: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			log := mock.NewMockLogger(ctrl)
+			tt.log(log)
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/user/balance", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token(t))
+
+			middleware.CheckAuth(auth.NewJWTBuilder(secret, time.Minute), noRevocations{}, log)(next).ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+type noRevocations struct{}
+
+func (noRevocations) IsRevoked(context.Context, string) (bool, error) {
+	return false, nil
+}
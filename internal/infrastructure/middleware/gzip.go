@@ -106,6 +106,17 @@ func (w *gzipWriter) Write(b []byte) (int, error) {
 	return w.zw.Write(b)
 }
 
+// FlushError sends compressed data written so far to the client, so streaming handlers work behind the middleware.
+// It is used by http.ResponseController.
+func (w *gzipWriter) FlushError() error {
+	err := w.zw.Flush()
+	if err != nil {
+		return err
+	}
+
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
 func (w *gzipWriter) Close() error {
 	return w.zw.Close()
 }
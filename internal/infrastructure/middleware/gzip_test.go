@@ -108,6 +108,32 @@ func TestGzip(t *testing.T) {
 		assert.Equal(t, http.StatusOK, response.StatusCode)
 		assert.Equal(t, 2, respBody.Value)
 	})
+
+	t.Run("flush_streamed_response", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		w := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/url", nil)
+		request.Header.Set("Accept-Encoding", "gzip")
+
+		var flushed int
+		h := middleware.Gzip(mock.NewMockLogger(ctrl))(http.HandlerFunc(func(zw http.ResponseWriter, _ *http.Request) {
+			_, _ = zw.Write([]byte(`{"value":1}`))
+			require.NoError(t, http.NewResponseController(zw).Flush())
+			flushed = w.Body.Len()
+		}))
+		h.ServeHTTP(w, request)
+
+		assert.True(t, w.Flushed)
+		assert.Positive(t, flushed)
+
+		response := w.Result()
+		defer func() {
+			_ = response.Body.Close()
+		}()
+
+		assert.Equal(t, 1, decompressBody(t, response.Body).Value)
+	})
 }
 
 func handlerGzip(w http.ResponseWriter, r *http.Request) {
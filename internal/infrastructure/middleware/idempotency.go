@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotencyReplayed = "Idempotency-Replayed"
+
+	maxLenIdempotencyKey = 255
+)
+
+// Idempotency replays the stored response when a request is retried with the same Idempotency-Key header.
+// It must be placed after CheckAuth, since keys are scoped by user. A request that hasn't completed within lockTTL,
+// e.g. because the instance crashed, no longer holds the key and can be retried.
+func Idempotency(repo repository.IdempotencyRepo, lockTTL time.Duration, log logger.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(headerIdempotencyKey)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxLenIdempotencyKey {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+
+			ctx := r.Context()
+			userGUID, err := auth.UserGUIDFromContext(ctx)
+			if err != nil {
+				log.WithError(err).Error("Could not get user GUID from context")
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				log.WithError(err).Error("Error reading body")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			idempotencyKey := model.NewIdempotencyKey(key, userGUID, requestHash(r, body), lockTTL)
+
+			stored, err := repo.Reserve(ctx, idempotencyKey)
+			if err != nil {
+				log.WithError(err).Error("Failed to reserve idempotency key")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			if stored != nil {
+				switch {
+				case stored.RequestHash != idempotencyKey.RequestHash:
+					http.Error(w, http.StatusText(http.StatusUnprocessableEntity), http.StatusUnprocessableEntity)
+				case !stored.Completed():
+					http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+				default:
+					replayResponse(w, stored, log)
+				}
+
+				return
+			}
+
+			rw := newResponseRecorder(w)
+			next.ServeHTTP(rw, r)
+
+			// the response must be saved even if the client has already gone away
+			saveCtx := context.WithoutCancel(ctx)
+
+			if rw.status >= http.StatusInternalServerError {
+				if err := repo.Release(saveCtx, idempotencyKey); err != nil {
+					log.WithError(err).Error("Failed to release idempotency key")
+				}
+				return
+			}
+
+			idempotencyKey.ResponseStatus = rw.status
+			idempotencyKey.ResponseContentType = rw.Header().Get("Content-Type")
+			idempotencyKey.ResponseBody = rw.body.Bytes()
+
+			if err := repo.SaveResponse(saveCtx, idempotencyKey); err != nil {
+				log.WithError(err).Error("Failed to save idempotent response")
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte("\n"))
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte("\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replayResponse(w http.ResponseWriter, stored *model.IdempotencyKey, log logger.Logger) {
+	if stored.ResponseContentType != "" {
+		w.Header().Set("Content-Type", stored.ResponseContentType)
+	}
+	w.Header().Set(headerIdempotencyReplayed, "true")
+	w.WriteHeader(stored.ResponseStatus)
+
+	if _, err := w.Write(stored.ResponseBody); err != nil {
+		log.WithError(err).Error("Could not write replayed response")
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+	}
+}
+
+func (w *responseRecorder) Write(buf []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	w.body.Write(buf)
+	return w.ResponseWriter.Write(buf)
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+
+	w.wroteHeader = true
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
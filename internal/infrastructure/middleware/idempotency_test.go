@@ -0,0 +1,197 @@
+package middleware_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/middleware"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestIdempotency(t *testing.T) {
+	const (
+		userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+		key      = "b5a6c1f4-retry"
+		body     = `{"order": "2377225624", "sum": 751}`
+	)
+
+	type want struct {
+		status   int
+		body     string
+		called   bool
+		replayed bool
+	}
+
+	tests := []struct {
+		name        string
+		key         string
+		handlerCode int
+		repo        func(ctrl *gomock.Controller) *mockRep.MockIdempotencyRepo
+		want        want
+	}{
+		{
+			name:        "without_key",
+			key:         "",
+			handlerCode: http.StatusOK,
+			repo:        mockRep.NewMockIdempotencyRepo,
+			want: want{
+				status: http.StatusOK,
+				body:   "handler",
+				called: true,
+			},
+		},
+		{
+			name:        "first_request",
+			key:         key,
+			handlerCode: http.StatusOK,
+			repo: func(ctrl *gomock.Controller) *mockRep.MockIdempotencyRepo {
+				repoMock := mockRep.NewMockIdempotencyRepo(ctrl)
+				gomock.InOrder(
+					repoMock.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(nil, nil),
+					repoMock.EXPECT().
+						SaveResponse(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(_ context.Context, k *model.IdempotencyKey) error {
+							assert.Equal(t, key, k.Key)
+							assert.Equal(t, userGUID, k.UserGUID)
+							assert.Equal(t, http.StatusOK, k.ResponseStatus)
+							assert.Equal(t, "handler", string(k.ResponseBody))
+							return nil
+						}),
+				)
+				return repoMock
+			},
+			want: want{
+				status: http.StatusOK,
+				body:   "handler",
+				called: true,
+			},
+		},
+		{
+			name:        "replay",
+			key:         key,
+			handlerCode: http.StatusOK,
+			repo: func(ctrl *gomock.Controller) *mockRep.MockIdempotencyRepo {
+				repoMock := mockRep.NewMockIdempotencyRepo(ctrl)
+				repoMock.EXPECT().
+					Reserve(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, k *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+						stored := *k
+						stored.ResponseStatus = http.StatusOK
+						stored.ResponseBody = []byte("stored")
+						return &stored, nil
+					})
+				return repoMock
+			},
+			want: want{
+				status:   http.StatusOK,
+				body:     "stored",
+				replayed: true,
+			},
+		},
+		{
+			name:        "same_key_other_body",
+			key:         key,
+			handlerCode: http.StatusOK,
+			repo: func(ctrl *gomock.Controller) *mockRep.MockIdempotencyRepo {
+				repoMock := mockRep.NewMockIdempotencyRepo(ctrl)
+				repoMock.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(&model.IdempotencyKey{
+					Key:            key,
+					UserGUID:       userGUID,
+					RequestHash:    "other",
+					ResponseStatus: http.StatusOK,
+				}, nil)
+				return repoMock
+			},
+			want: want{
+				status: http.StatusUnprocessableEntity,
+			},
+		},
+		{
+			name:        "in_progress",
+			key:         key,
+			handlerCode: http.StatusOK,
+			repo: func(ctrl *gomock.Controller) *mockRep.MockIdempotencyRepo {
+				repoMock := mockRep.NewMockIdempotencyRepo(ctrl)
+				repoMock.EXPECT().
+					Reserve(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, k *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+						stored := *k
+						return &stored, nil
+					})
+				return repoMock
+			},
+			want: want{
+				status: http.StatusConflict,
+			},
+		},
+		{
+			name:        "server_error_releases_key",
+			key:         key,
+			handlerCode: http.StatusInternalServerError,
+			repo: func(ctrl *gomock.Controller) *mockRep.MockIdempotencyRepo {
+				repoMock := mockRep.NewMockIdempotencyRepo(ctrl)
+				gomock.InOrder(
+					repoMock.EXPECT().Reserve(gomock.Any(), gomock.Any()).Return(nil, nil),
+					repoMock.EXPECT().
+						Release(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(_ context.Context, k *model.IdempotencyKey) error {
+							assert.Equal(t, key, k.Key)
+							assert.Equal(t, userGUID, k.UserGUID)
+							return nil
+						}),
+				)
+				return repoMock
+			},
+			want: want{
+				status: http.StatusInternalServerError,
+				body:   "handler",
+				called: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			var called bool
+			handler := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				called = true
+				w.WriteHeader(tt.handlerCode)
+				_, _ = w.Write([]byte("handler"))
+			})
+
+			w := httptest.NewRecorder()
+			request := httptest.NewRequest(http.MethodPost, "/api/user/balance/withdraw", strings.NewReader(body))
+			request = request.WithContext(context.WithValue(request.Context(), auth.UserGUIDKey, userGUID))
+			if tt.key != "" {
+				request.Header.Set("Idempotency-Key", tt.key)
+			}
+
+			h := middleware.Idempotency(tt.repo(ctrl), time.Minute, mockLog.NewMockLogger(ctrl))(handler)
+			h.ServeHTTP(w, request)
+
+			response := w.Result()
+			defer func() {
+				_ = response.Body.Close()
+			}()
+
+			assert.Equal(t, tt.want.status, response.StatusCode)
+			assert.Equal(t, tt.want.called, called)
+			assert.Equal(t, tt.want.replayed, response.Header.Get("Idempotency-Replayed") == "true")
+			if tt.want.body != "" {
+				assert.Equal(t, tt.want.body, w.Body.String())
+			}
+		})
+	}
+}
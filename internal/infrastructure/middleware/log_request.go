@@ -65,3 +65,8 @@ func (w *responseDataWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 	w.data.status = status
 }
+
+// Unwrap gives http.ResponseController access to the underlying writer, e.g. to flush a streamed response.
+func (w *responseDataWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,67 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// FileNotifier appends messages to a file, one JSON object per line. It is meant for local use, e.g. for scripts
+// that go through the password reset.
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{
+		path: path,
+	}
+}
+
+type message struct {
+	Type      string    `json:"type"`
+	UserGUID  string    `json:"user_guid"`
+	Login     string    `json:"login"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (n *FileNotifier) PasswordReset(_ context.Context, user *model.User, token string, expiresAt time.Time) error {
+	return n.write(message{
+		Type:      "password_reset",
+		UserGUID:  user.GUID,
+		Login:     user.Login,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+func (n *FileNotifier) write(m message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// the file holds tokens, so only the owner may read it
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open notification file: %w", err)
+	}
+
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write notification: %w", err)
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,58 @@
+package notifier_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/notifier"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestFileNotifier_PasswordReset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	n := notifier.NewFileNotifier(path)
+
+	user := &model.User{GUID: "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7", Login: "abcd"}
+	expiresAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, n.PasswordReset(context.Background(), user, "first", expiresAt))
+	require.NoError(t, n.PasswordReset(context.Background(), user, "second", expiresAt))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var got struct {
+			Type      string    `json:"type"`
+			UserGUID  string    `json:"user_guid"`
+			Login     string    `json:"login"`
+			Token     string    `json:"token"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &got))
+
+		assert.Equal(t, "password_reset", got.Type)
+		assert.Equal(t, user.GUID, got.UserGUID)
+		assert.Equal(t, user.Login, got.Login)
+		assert.True(t, expiresAt.Equal(got.ExpiresAt))
+		tokens = append(tokens, got.Token)
+	}
+	require.NoError(t, scanner.Err())
+
+	assert.Equal(t, []string{"first", "second"}, tokens)
+}
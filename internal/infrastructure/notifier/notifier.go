@@ -0,0 +1,16 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/notifier_mock.go
+
+package notifier
+
+import (
+	"context"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// Notifier delivers messages to users. Whoever receives a reset token can set the password, so the channel must
+// reach the owner of the account only.
+type Notifier interface {
+	PasswordReset(ctx context.Context, user *model.User, token string, expiresAt time.Time) error
+}
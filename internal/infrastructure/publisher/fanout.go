@@ -0,0 +1,27 @@
+package publisher
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// Fanout publishes every event to all publishers. If any of them fails, the event is published to all of them
+// again later, so each publisher must tolerate repeats.
+type Fanout []Publisher
+
+func NewFanout(publishers ...Publisher) Fanout {
+	return publishers
+}
+
+func (f Fanout) Publish(ctx context.Context, event model.Event) error {
+	var errs []error
+	for _, p := range f {
+		if err := p.Publish(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
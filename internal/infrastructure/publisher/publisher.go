@@ -0,0 +1,38 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/publisher_mock.go
+
+package publisher
+
+import (
+	"context"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// Publisher delivers domain events to downstream systems. Publish returns nil only when the event is accepted: the
+// relay retries it otherwise. The same event may be published more than once.
+type Publisher interface {
+	Publish(ctx context.Context, event model.Event) error
+}
+
+// LogPublisher writes events to the log. It is used when no downstream system is configured.
+type LogPublisher struct {
+	log logger.Logger
+}
+
+func NewLogPublisher(log logger.Logger) *LogPublisher {
+	return &LogPublisher{
+		log: log,
+	}
+}
+
+func (p LogPublisher) Publish(_ context.Context, event model.Event) error {
+	p.log.
+		WithField("event", event.GUID).
+		WithField("type", string(event.Type)).
+		WithField("user", event.UserGUID).
+		WithField("payload", string(event.Payload)).
+		Info("Event published")
+
+	return nil
+}
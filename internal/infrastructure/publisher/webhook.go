@@ -0,0 +1,48 @@
+package publisher
+
+import (
+	"context"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// WebhookPublisher queues the event for every webhook of the user subscribed to it. The requests are sent later by
+// the webhook sender, so a slow subscriber doesn't hold back the outbox.
+type WebhookPublisher struct {
+	repo repository.WebhookRepo
+}
+
+func NewWebhookPublisher(repo repository.WebhookRepo) *WebhookPublisher {
+	return &WebhookPublisher{
+		repo: repo,
+	}
+}
+
+func (p WebhookPublisher) Publish(ctx context.Context, event model.Event) error {
+	webhooks, err := p.repo.WebhooksByUser(ctx, event.UserGUID)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, w := range webhooks {
+		if !w.Accepts(event.Type) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = webhook.NewPayload(event); err != nil {
+				return err
+			}
+		}
+
+		// the event is published again if a later webhook fails, the ones already queued ignore the repeat
+		if err = p.repo.AddDelivery(ctx, w.GUID, event, payload); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package publisher_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/publisher"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+
+func TestWebhookPublisher_Publish(t *testing.T) {
+	event := model.Event{
+		GUID:      "8f0f3b9e-3c4a-4b1e-9d55-2f7b0b5f5e01",
+		Type:      model.EventAccrualInvalid,
+		UserGUID:  userGUID,
+		Payload:   []byte(`{"order":"2377225624","status":"INVALID"}`),
+		CreatedAt: time.Now(),
+	}
+
+	t.Run("queued_for_subscribed_webhooks", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().WebhooksByUser(gomock.Any(), userGUID).Return([]model.Webhook{
+			{GUID: "all"},
+			{GUID: "invalid", Events: []model.EventType{model.EventAccrualInvalid}},
+			{GUID: "processed", Events: []model.EventType{model.EventAccrualProcessed}},
+		}, nil)
+
+		var queued []string
+		repo.EXPECT().AddDelivery(gomock.Any(), gomock.Any(), event, gomock.Any()).
+			DoAndReturn(func(_ context.Context, webhookGUID string, _ model.Event, payload []byte) error {
+				queued = append(queued, webhookGUID)
+
+				var body webhook.Payload
+				require.NoError(t, json.Unmarshal(payload, &body))
+				assert.Equal(t, event.GUID, body.ID)
+				assert.Equal(t, event.Type, body.Type)
+				assert.JSONEq(t, string(event.Payload), string(body.Data))
+				return nil
+			}).Times(2)
+
+		require.NoError(t, publisher.NewWebhookPublisher(repo).Publish(context.Background(), event))
+		assert.Equal(t, []string{"all", "invalid"}, queued)
+	})
+
+	t.Run("withdrawals_are_not_sent", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().WebhooksByUser(gomock.Any(), userGUID).Return([]model.Webhook{{GUID: "all"}}, nil)
+
+		withdrawn := event
+		withdrawn.Type = model.EventBalanceWithdrawn
+		require.NoError(t, publisher.NewWebhookPublisher(repo).Publish(context.Background(), withdrawn))
+	})
+}
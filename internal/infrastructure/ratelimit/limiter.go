@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limiter is a token bucket shared by all goroutines calling an external service. Besides the steady rate it can
+// be paused, e.g. for the Retry-After period the service asked for.
+type Limiter struct {
+	limiter *rate.Limiter
+
+	mu          sync.Mutex
+	perMinute   int
+	pausedUntil time.Time
+}
+
+// NewLimiter creates a limiter allowing perMinute requests per minute. Zero means no limit.
+func NewLimiter(perMinute int) *Limiter {
+	l := &Limiter{
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+	l.SetPerMinute(perMinute)
+
+	return l
+}
+
+// Wait blocks until the pause is over and a token is available, or the context is done. A pause that starts while
+// waiting for a token holds the waiter too.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		if err := l.waitPause(ctx); err != nil {
+			return err
+		}
+
+		if err := l.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		if !l.paused() {
+			return nil
+		}
+	}
+}
+
+func (l *Limiter) waitPause(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		d := time.Until(l.pausedUntil)
+		l.mu.Unlock()
+
+		if d <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (l *Limiter) paused() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return time.Now().Before(l.pausedUntil)
+}
+
+// Pause stops all waiters for d. A shorter pause doesn't cut an earlier, longer one.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	until := time.Now().Add(d)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// SetPerMinute changes the rate. Requests are spread evenly over the minute rather than sent in bursts.
+func (l *Limiter) SetPerMinute(perMinute int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if perMinute == l.perMinute {
+		return
+	}
+	l.perMinute = perMinute
+
+	if perMinute <= 0 {
+		l.limiter.SetLimit(rate.Inf)
+		return
+	}
+
+	l.limiter.SetLimit(rate.Every(time.Minute / time.Duration(perMinute)))
+}
+
+func (l *Limiter) PerMinute() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.perMinute
+}
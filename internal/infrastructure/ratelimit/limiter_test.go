@@ -0,0 +1,56 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/ratelimit"
+)
+
+func TestLimiter(t *testing.T) {
+	t.Run("unlimited", func(t *testing.T) {
+		l := ratelimit.NewLimiter(0)
+
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			require.NoError(t, l.Wait(context.Background()))
+		}
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("pause_blocks_waiters", func(t *testing.T) {
+		l := ratelimit.NewLimiter(0)
+		l.Pause(100 * time.Millisecond)
+		l.Pause(time.Millisecond) // doesn't shorten the pause
+
+		start := time.Now()
+		require.NoError(t, l.Wait(context.Background()))
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+
+	t.Run("pause_respects_context", func(t *testing.T) {
+		l := ratelimit.NewLimiter(0)
+		l.Pause(time.Minute)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, l.Wait(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("rate_per_minute", func(t *testing.T) {
+		l := ratelimit.NewLimiter(0)
+		l.SetPerMinute(1200) // one request every 50ms
+		assert.Equal(t, 1200, l.PerMinute())
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, l.Wait(context.Background()))
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+	})
+}
@@ -9,24 +9,37 @@ import (
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
 )
 
 type AccountRepo interface {
-	Balance(ctx context.Context, accountGUID string) (float64, float64, error)
+	Balance(ctx context.Context, accountGUID string) (model.Points, model.Points, error)
 }
 
 type AccountPG struct {
 	db *sqlx.DB
 }
 
-func NewAccountPG(db *sqlx.DB) *AccrualPG {
-	return &AccrualPG{
+func NewAccountPG(db *sqlx.DB) *AccountPG {
+	return &AccountPG{
 		db: db,
 	}
 }
 
-func (r AccrualPG) Balance(ctx context.Context, accountGUID string) (float64, float64, error) {
-	query := `SELECT balance, withdraw_sum FROM accounts WHERE guid = $1`
+// Balance returns the current balance and the withdrawn total of the user ledger account.
+func (r AccountPG) Balance(ctx context.Context, accountGUID string) (model.Points, model.Points, error) {
+	query := `
+		SELECT a.balance,
+		       COALESCE((
+		           SELECT SUM(-p.amount)
+		           FROM ledger_postings p
+		           JOIN ledger_entries e ON e.guid = p.entry_guid
+		           WHERE p.account_guid = a.guid AND e.type = $2
+		       ), 0)
+		FROM ledger_accounts a
+		WHERE a.guid = $1 AND a.type = $3
+	`
 	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return 0, 0, fmt.Errorf("failed to prepare query: %w", err)
@@ -35,8 +48,8 @@ func (r AccrualPG) Balance(ctx context.Context, accountGUID string) (float64, fl
 		_ = stmt.Close()
 	}()
 
-	var balance, withdraw float64
-	row := stmt.QueryRowContext(ctx, accountGUID)
+	var balance, withdraw model.Points
+	row := stmt.QueryRowContext(ctx, accountGUID, model.WithdrawEntry, model.UserLedgerAccount)
 	if row.Err() != nil {
 		return 0, 0, fmt.Errorf("failed to query account: %w", row.Err())
 	}
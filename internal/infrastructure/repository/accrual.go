@@ -7,21 +7,37 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 
 	"github.com/bjlag/go-loyalty/internal/model"
 )
 
+const withdrawOrderNumberUniqIdx = "transactions_withdraw_order_number_uniq_idx"
+
+var (
+	ErrInsufficientBalanceOnAccount = errors.New("insufficient balance on account")
+	ErrOrderAlreadyPaid             = errors.New("order already paid")
+	ErrAccrualAlreadyFinal          = errors.New("accrual already in final status")
+)
+
 type AccrualRepo interface {
 	AccrualByOrderNumber(ctx context.Context, orderNumber string) (*model.Accrual, error)
 	AccrualsByUser(ctx context.Context, userGUID string) ([]model.Accrual, error)
-	AccrualsInWork(ctx context.Context) ([]model.Accrual, error)
+	AccrualsByUserFiltered(ctx context.Context, userGUID string, filter model.AccrualFilter) ([]model.Accrual, error)
+	LeaseInWork(ctx context.Context, owner string, limit int, ttl time.Duration) ([]model.Accrual, error)
+	ReleaseLease(ctx context.Context, orderNumber, owner string) error
+	ScheduleCheck(ctx context.Context, orderNumber, owner string, attempts int, nextCheckAt time.Time) error
+	GiveUp(ctx context.Context, orderNumber, reason string) error
 
 	Create(ctx context.Context, accrual *model.Accrual) error
 	UpdateStatus(ctx context.Context, orderNumber string, newStatus model.AccrualStatus) error
-	AddBalance(ctx context.Context, accrual model.Accrual, account model.Account, transaction model.Transaction) error
+	AddBalance(ctx context.Context, accrual model.Accrual, transaction model.Transaction) error
 	WithdrawBalance(ctx context.Context, transaction model.Transaction) error
 }
 
@@ -37,7 +53,7 @@ func NewAccrualPG(db *sqlx.DB) *AccrualPG {
 
 func (r AccrualPG) AccrualByOrderNumber(ctx context.Context, orderNumber string) (*model.Accrual, error) {
 	query := `
-		SELECT order_number, user_guid, status, accrual, uploaded_at 
+		SELECT order_number, user_guid, status, accrual, uploaded_at, attempts, last_error, gave_up_at IS NOT NULL 
 		FROM accruals 
 		WHERE order_number = $1
 	`
@@ -51,7 +67,7 @@ func (r AccrualPG) AccrualByOrderNumber(ctx context.Context, orderNumber string)
 
 	var m accrual
 	row := stmt.QueryRowContext(ctx, orderNumber)
-	err = row.Scan(&m.OrderNumber, &m.UserGUID, &m.Status, &m.Accrual, &m.UploadedAt)
+	err = row.Scan(&m.OrderNumber, &m.UserGUID, &m.Status, &m.Accrual, &m.UploadedAt, &m.Attempts, &m.LastError, &m.GaveUp)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -65,7 +81,7 @@ func (r AccrualPG) AccrualByOrderNumber(ctx context.Context, orderNumber string)
 
 func (r AccrualPG) AccrualsByUser(ctx context.Context, orderNumber string) ([]model.Accrual, error) {
 	query := `
-		SELECT order_number, user_guid, status, accrual, uploaded_at 
+		SELECT order_number, user_guid, status, accrual, uploaded_at, attempts, last_error, gave_up_at IS NOT NULL 
 		FROM accruals 
 		WHERE user_guid = $1
 		ORDER BY uploaded_at DESC
@@ -93,7 +109,7 @@ func (r AccrualPG) AccrualsByUser(ctx context.Context, orderNumber string) ([]mo
 	var models []accrual
 	for rows.Next() {
 		var m accrual
-		err = rows.Scan(&m.OrderNumber, &m.UserGUID, &m.Status, &m.Accrual, &m.UploadedAt)
+		err = rows.Scan(&m.OrderNumber, &m.UserGUID, &m.Status, &m.Accrual, &m.UploadedAt, &m.Attempts, &m.LastError, &m.GaveUp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan: %w", err)
 		}
@@ -113,13 +129,48 @@ func (r AccrualPG) AccrualsByUser(ctx context.Context, orderNumber string) ([]mo
 	return result, nil
 }
 
-func (r AccrualPG) AccrualsInWork(ctx context.Context) ([]model.Accrual, error) {
-	query := `
-		SELECT order_number, user_guid, status, accrual, uploaded_at 
-		FROM accruals 
-		WHERE status = $1 OR status = $2
-	`
-	stmt, err := r.db.PrepareContext(ctx, query)
+// AccrualsByUserFiltered uses keyset pagination on (uploaded_at, order_number), so a page costs the same
+// regardless of how deep the cursor is.
+func (r AccrualPG) AccrualsByUserFiltered(ctx context.Context, userGUID string, filter model.AccrualFilter) ([]model.Accrual, error) {
+	var query strings.Builder
+	query.WriteString(`
+		SELECT order_number, user_guid, status, accrual, uploaded_at, attempts, last_error, gave_up_at IS NOT NULL
+		FROM accruals
+		WHERE user_guid = $1`)
+
+	args := []any{userGUID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if len(filter.Statuses) > 0 {
+		placeholders := make([]string, 0, len(filter.Statuses))
+		for _, s := range filter.Statuses {
+			placeholders = append(placeholders, arg(s))
+		}
+		query.WriteString(" AND status IN (" + strings.Join(placeholders, ", ") + ")")
+	}
+
+	if !filter.UploadedFrom.IsZero() {
+		query.WriteString(" AND uploaded_at >= " + arg(filter.UploadedFrom))
+	}
+
+	if !filter.UploadedTo.IsZero() {
+		query.WriteString(" AND uploaded_at < " + arg(filter.UploadedTo))
+	}
+
+	if filter.After != nil {
+		query.WriteString(" AND (uploaded_at, order_number) < (" + arg(filter.After.UploadedAt) + ", " + arg(filter.After.OrderNumber) + ")")
+	}
+
+	query.WriteString(" ORDER BY uploaded_at DESC, order_number DESC")
+
+	if filter.Limit > 0 {
+		query.WriteString(" LIMIT " + arg(filter.Limit))
+	}
+
+	stmt, err := r.db.PrepareContext(ctx, query.String())
 	if err != nil {
 		return nil, fmt.Errorf("failed to prepare query: %w", err)
 	}
@@ -127,7 +178,7 @@ func (r AccrualPG) AccrualsInWork(ctx context.Context) ([]model.Accrual, error)
 		_ = stmt.Close()
 	}()
 
-	rows, err := stmt.QueryContext(ctx, model.New, model.Processing)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
 	}
@@ -135,31 +186,94 @@ func (r AccrualPG) AccrualsInWork(ctx context.Context) ([]model.Accrual, error)
 		_ = rows.Close()
 	}()
 
+	var result []model.Accrual
+	for rows.Next() {
+		var m accrual
+		err = rows.Scan(&m.OrderNumber, &m.UserGUID, &m.Status, &m.Accrual, &m.UploadedAt, &m.Attempts, &m.LastError, &m.GaveUp)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		result = append(result, *m.export())
+	}
+
 	if rows.Err() != nil {
 		return nil, rows.Err()
 	}
 
-	var models []accrual
+	return result, nil
+}
+
+// LeaseInWork takes at most limit orders that are due to be checked in the accrual system, longest waiting first,
+// and leases them to owner for ttl. Orders leased by another live owner and rows locked by a concurrent lease are skipped, so replicas
+// never get the same order. An expired lease can be taken over.
+func (r AccrualPG) LeaseInWork(ctx context.Context, owner string, limit int, ttl time.Duration) ([]model.Accrual, error) {
+	query := `
+		UPDATE accruals a
+		SET locked_by = $1, locked_until = now() + $2 * interval '1 millisecond'
+		FROM (
+			SELECT order_number
+			FROM accruals
+			WHERE status IN ($3, $4) AND gave_up_at IS NULL AND next_check_at <= now()
+			  AND (locked_until IS NULL OR locked_until < now())
+			ORDER BY next_check_at, order_number
+			LIMIT $5
+			FOR UPDATE SKIP LOCKED
+		) c
+		WHERE a.order_number = c.order_number
+		RETURNING a.order_number, a.user_guid, a.status, a.accrual, a.uploaded_at, a.attempts, a.last_error, a.gave_up_at IS NOT NULL
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, owner, ttl.Milliseconds(), model.New, model.Processing, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.Accrual
 	for rows.Next() {
 		var m accrual
-		err = rows.Scan(&m.OrderNumber, &m.UserGUID, &m.Status, &m.Accrual, &m.UploadedAt)
+		err = rows.Scan(&m.OrderNumber, &m.UserGUID, &m.Status, &m.Accrual, &m.UploadedAt, &m.Attempts, &m.LastError, &m.GaveUp)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan: %w", err)
 		}
 
-		models = append(models, m)
+		result = append(result, *m.export())
 	}
 
-	if len(models) == 0 {
-		return nil, nil
+	if rows.Err() != nil {
+		return nil, rows.Err()
 	}
 
-	result := make([]model.Accrual, 0, len(models))
-	for _, m := range models {
-		result = append(result, *m.export())
+	return result, nil
+}
+
+// ReleaseLease returns the order to the queue if owner still holds it.
+func (r AccrualPG) ReleaseLease(ctx context.Context, orderNumber, owner string) error {
+	query := `UPDATE accruals SET locked_by = NULL, locked_until = NULL WHERE order_number = $1 AND locked_by = $2`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, orderNumber, owner)
+	if err != nil {
+		return fmt.Errorf("failed to release accrual lease: %w", err)
 	}
 
-	return result, nil
+	return nil
 }
 
 func (r AccrualPG) Create(ctx context.Context, accrual *model.Accrual) error {
@@ -183,8 +297,14 @@ func (r AccrualPG) Create(ctx context.Context, accrual *model.Accrual) error {
 	return nil
 }
 
-func (r AccrualPG) UpdateStatus(ctx context.Context, orderNumber string, newStatus model.AccrualStatus) error {
-	query := `UPDATE accruals SET status = $1 WHERE order_number = $2`
+// ScheduleCheck saves the number of checks without progress and releases the order until nextCheckAt. It does
+// nothing if owner has lost the lease.
+func (r AccrualPG) ScheduleCheck(ctx context.Context, orderNumber, owner string, attempts int, nextCheckAt time.Time) error {
+	query := `
+		UPDATE accruals
+		SET attempts = $1, next_check_at = $2, locked_by = NULL, locked_until = NULL
+		WHERE order_number = $3 AND locked_by = $4
+	`
 	stmt, err := r.db.PrepareContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to prepare query: %w", err)
@@ -193,15 +313,106 @@ func (r AccrualPG) UpdateStatus(ctx context.Context, orderNumber string, newStat
 		_ = stmt.Close()
 	}()
 
-	_, err = stmt.ExecContext(ctx, newStatus, orderNumber)
+	_, err = stmt.ExecContext(ctx, attempts, nextCheckAt, orderNumber, owner)
 	if err != nil {
+		return fmt.Errorf("failed to schedule accrual check: %w", err)
+	}
+
+	return nil
+}
+
+// GiveUp stops checking the order. Its status is kept, so a status pushed by the accrual system still finishes it,
+// and the reason is shown to the user.
+func (r AccrualPG) GiveUp(ctx context.Context, orderNumber, reason string) error {
+	query := `
+		UPDATE accruals
+		SET gave_up_at = now(), last_error = $1, locked_by = NULL, locked_until = NULL
+		WHERE order_number = $2 AND status IN ($3, $4) AND gave_up_at IS NULL
+		RETURNING user_guid, status
+	`
+
+	return r.updateInWork(ctx, orderNumber, func(tx *sql.Tx, userGUID string, status model.AccrualStatus) error {
+		return addGaveUpEventTx(tx, userGUID, orderNumber, status, reason)
+	}, query, reason, orderNumber, model.New, model.Processing)
+}
+
+// UpdateStatus moves an order in work forward to newStatus and releases its lease. The change is progress, so the
+// backoff starts over and a given up order is checked again. It returns ErrAccrualAlreadyFinal if the order has
+// already been finished or moved past newStatus, e.g. by another replica.
+func (r AccrualPG) UpdateStatus(ctx context.Context, orderNumber string, newStatus model.AccrualStatus) error {
+	query := `
+		UPDATE accruals
+		SET status = $1, attempts = 0, next_check_at = now(), last_error = '', gave_up_at = NULL,
+		    locked_by = NULL, locked_until = NULL
+		WHERE order_number = $2 AND status IN ($3, $4) AND status < $1
+		RETURNING user_guid, status
+	`
+
+	return r.updateInWork(ctx, orderNumber, func(tx *sql.Tx, userGUID string, status model.AccrualStatus) error {
+		return addAccrualEventTx(tx, userGUID, orderNumber, status, 0, "")
+	}, query, newStatus, orderNumber, model.New, model.Processing)
+}
+
+// updateInWork runs query, which updates an order in work and returns its user and status, and records the event
+// of the change. Both are saved in one transaction.
+func (r AccrualPG) updateInWork(
+	ctx context.Context,
+	orderNumber string,
+	addEvent func(tx *sql.Tx, userGUID string, status model.AccrualStatus) error,
+	query string,
+	args ...any,
+) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var (
+		userGUID string
+		status   model.AccrualStatus
+	)
+	err = stmt.QueryRowContext(ctx, args...).Scan(&userGUID, &status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrAccrualAlreadyFinal, orderNumber)
+		}
+
 		return fmt.Errorf("failed to update accrual: %w", err)
 	}
 
+	err = addEvent(tx, userGUID, status)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
 	return nil
 }
 
-func (r AccrualPG) AddBalance(ctx context.Context, accrual model.Accrual, account model.Account, transaction model.Transaction) error {
+// AddBalance finishes the order and credits the user. The credit is made only if this call moves the order out of
+// work: when two replicas process the same order, the second one gets ErrAccrualAlreadyFinal and credits nothing.
+// The outbox event is written in the same transaction, so it is published exactly for the credits that are saved.
+// Only a PROCESSED order is credited.
+func (r AccrualPG) AddBalance(ctx context.Context, accrual model.Accrual, transaction model.Transaction) error {
+	if accrual.Status != model.Processed {
+		return fmt.Errorf("failed to credit order %s in status %s", accrual.OrderNumber, accrual.Status)
+	}
+
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -215,7 +426,12 @@ func (r AccrualPG) AddBalance(ctx context.Context, accrual model.Accrual, accoun
 		return err
 	}
 
-	err = addAccountTx(tx, account.GUID, account.Balance, account.UpdatedAt)
+	err = addAccrualEventTx(tx, accrual.UserGUID, accrual.OrderNumber, accrual.Status, accrual.Accrual, "")
+	if err != nil {
+		return err
+	}
+
+	err = openUserLedgerAccountTx(tx, transaction.AccountGUID, transaction.ProcessedAt)
 	if err != nil {
 		return err
 	}
@@ -233,6 +449,11 @@ func (r AccrualPG) AddBalance(ctx context.Context, accrual model.Accrual, accoun
 		return err
 	}
 
+	err = postEntryTx(tx, model.NewLedgerEntry(transaction))
+	if err != nil {
+		return err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
@@ -241,6 +462,8 @@ func (r AccrualPG) AddBalance(ctx context.Context, accrual model.Accrual, accoun
 	return nil
 }
 
+// WithdrawBalance locks the user ledger account, so the balance check and the debit cannot interleave with
+// another withdrawal. The non-negative balance constraint on ledger_accounts is the last line of defence.
 func (r AccrualPG) WithdrawBalance(ctx context.Context, transaction model.Transaction) error {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -250,11 +473,15 @@ func (r AccrualPG) WithdrawBalance(ctx context.Context, transaction model.Transa
 		_ = tx.Rollback()
 	}()
 
-	err = withdrawAccountTx(tx, transaction.AccountGUID, transaction.Sum, transaction.ProcessedAt)
+	balance, found, err := lockUserLedgerAccountTx(tx, transaction.AccountGUID)
 	if err != nil {
 		return err
 	}
 
+	if !found || balance < transaction.Sum {
+		return ErrInsufficientBalanceOnAccount
+	}
+
 	err = addTransaction(
 		tx,
 		transaction.GUID,
@@ -265,85 +492,74 @@ func (r AccrualPG) WithdrawBalance(ctx context.Context, transaction model.Transa
 		transaction.ProcessedAt,
 	)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation && pgErr.ConstraintName == withdrawOrderNumberUniqIdx {
+			return fmt.Errorf("%w: %s", ErrOrderAlreadyPaid, transaction.OrderNumber)
+		}
+
 		return err
 	}
 
-	err = tx.Commit()
+	err = postEntryTx(tx, model.NewLedgerEntry(transaction))
 	if err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.CheckViolation {
+			return ErrInsufficientBalanceOnAccount
+		}
 
-	return nil
-}
+		return err
+	}
 
-func updateAccrualTx(tx *sql.Tx, status model.AccrualStatus, accrual float64, orderNumber string) error {
-	query := `UPDATE accruals SET status = $1, accrual = $2 WHERE order_number = $3`
-	stmt, err := tx.Prepare(query)
+	err = addWithdrawalEventTx(tx, transaction)
 	if err != nil {
-		return fmt.Errorf("failed to prepare update accrual query: %w", err)
+		return err
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
 
-	_, err = stmt.Exec(status, accrual, orderNumber)
+	err = tx.Commit()
 	if err != nil {
-		return fmt.Errorf("failed to update accrual: %w", err)
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
 
-func addAccountTx(tx *sql.Tx, guid string, balance float64, updatedAt time.Time) error {
+// updateAccrualTx updates only an order in work, and only forward. The row lock it takes makes a concurrent update
+// wait and then find the order already final.
+func updateAccrualTx(tx *sql.Tx, status model.AccrualStatus, accrual model.Points, orderNumber string) error {
 	query := `
-		INSERT INTO accounts (guid, balance, updated_at)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (guid) DO UPDATE
-    		SET balance    = accounts.balance + excluded.balance,
-        		updated_at = excluded.updated_at;
+		UPDATE accruals SET status = $1, accrual = $2, last_error = '', gave_up_at = NULL, locked_by = NULL, locked_until = NULL
+		WHERE order_number = $3 AND status IN ($4, $5) AND status < $1
 	`
 	stmt, err := tx.Prepare(query)
 	if err != nil {
-		return fmt.Errorf("failed to prepare insert account query: %w", err)
+		return fmt.Errorf("failed to prepare update accrual query: %w", err)
 	}
 	defer func() {
 		_ = stmt.Close()
 	}()
 
-	_, err = stmt.Exec(guid, balance, updatedAt)
+	res, err := stmt.Exec(status, accrual, orderNumber, model.New, model.Processing)
 	if err != nil {
-		return fmt.Errorf("failed to update account: %w", err)
+		return fmt.Errorf("failed to update accrual: %w", err)
 	}
 
-	return nil
+	return checkAccrualUpdated(res, orderNumber)
 }
 
-func withdrawAccountTx(tx *sql.Tx, guid string, sum float64, updatedAt time.Time) error {
-	query := `
-		INSERT INTO accounts (guid, balance, withdraw_sum, updated_at)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT (guid) DO UPDATE
-    		SET balance      = accounts.balance - excluded.balance,
-        		withdraw_sum = accounts.withdraw_sum + excluded.withdraw_sum,
-        		updated_at   = excluded.updated_at;
-	`
-	stmt, err := tx.Prepare(query)
+func checkAccrualUpdated(res sql.Result, orderNumber string) error {
+	affected, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to prepare insert account query: %w", err)
+		return fmt.Errorf("failed to get affected rows: %w", err)
 	}
-	defer func() {
-		_ = stmt.Close()
-	}()
 
-	_, err = stmt.Exec(guid, sum, sum, updatedAt)
-	if err != nil {
-		return fmt.Errorf("failed to update account: %w", err)
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrAccrualAlreadyFinal, orderNumber)
 	}
 
 	return nil
 }
 
-func addTransaction(tx *sql.Tx, guid, accountGUID, orderNumber string, tType model.TransactionType, sum float64, processedAt time.Time) error {
+func addTransaction(tx *sql.Tx, guid, accountGUID, orderNumber string, tType model.TransactionType, sum model.Points, processedAt time.Time) error {
 	query := `
 		INSERT INTO transactions (guid, account_guid, order_number, type, sum, processed_at)
 		VALUES ($1, $2, $3, $4, $5, $6)
@@ -0,0 +1,333 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestAccrualPG_WithdrawBalance_Concurrent(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	fundAccount(t, db, userGUID, model.NewPoints(100, 0))
+
+	const workers = 50
+
+	repo := repository.NewAccrualPG(db)
+
+	var (
+		wg           sync.WaitGroup
+		succeeded    atomic.Int32
+		insufficient atomic.Int32
+	)
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			transaction := model.NewWithdrawTransaction(
+				uuid.NewString(),
+				userGUID,
+				orderNumber(),
+				model.NewPoints(10, 0),
+				time.Now(),
+			)
+
+			err := repo.WithdrawBalance(ctx, transaction)
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case errors.Is(err, repository.ErrInsufficientBalanceOnAccount):
+				insufficient.Add(1)
+			default:
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.EqualValues(t, 10, succeeded.Load())
+	assert.EqualValues(t, workers-10, insufficient.Load())
+
+	balance, withdrawn, err := repository.NewAccountPG(db).Balance(ctx, userGUID)
+	require.NoError(t, err)
+	assert.Equal(t, model.Points(0), balance)
+	assert.Equal(t, model.NewPoints(100, 0), withdrawn)
+}
+
+func TestAccrualPG_WithdrawBalance_NoAccount(t *testing.T) {
+	db := testDB(t)
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	transaction := model.NewWithdrawTransaction(uuid.NewString(), userGUID, "2377225624", model.NewPoints(1, 0), time.Now())
+
+	err := repository.NewAccrualPG(db).WithdrawBalance(context.Background(), transaction)
+	assert.ErrorIs(t, err, repository.ErrInsufficientBalanceOnAccount)
+}
+
+func TestAccrualPG_WithdrawBalance_OrderAlreadyPaid(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	fundAccount(t, db, userGUID, model.NewPoints(100, 0))
+
+	repo := repository.NewAccrualPG(db)
+	number := orderNumber()
+
+	first := model.NewWithdrawTransaction(uuid.NewString(), userGUID, number, model.NewPoints(10, 0), time.Now())
+	require.NoError(t, repo.WithdrawBalance(ctx, first))
+
+	second := model.NewWithdrawTransaction(uuid.NewString(), userGUID, number, model.NewPoints(10, 0), time.Now())
+	err := repo.WithdrawBalance(ctx, second)
+	assert.ErrorIs(t, err, repository.ErrOrderAlreadyPaid)
+
+	balance, _, err := repository.NewAccountPG(db).Balance(ctx, userGUID)
+	require.NoError(t, err)
+	assert.Equal(t, model.NewPoints(90, 0), balance)
+}
+
+func TestAccrualPG_LeaseInWork(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewAccrualPG(db)
+
+	orders := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		accrual := model.NewAccrual(uuid.NewString()[:20], userGUID)
+		require.NoError(t, repo.Create(ctx, accrual))
+		orders[accrual.OrderNumber] = true
+	}
+
+	ours := func(leased []model.Accrual) int {
+		var n int
+		for _, a := range leased {
+			if orders[a.OrderNumber] {
+				n++
+			}
+		}
+		return n
+	}
+
+	ownerA, ownerB := uuid.NewString(), uuid.NewString()
+
+	leased, err := repo.LeaseInWork(ctx, ownerA, 10000, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, ours(leased))
+
+	t.Run("leased_orders_are_skipped", func(t *testing.T) {
+		leased, err := repo.LeaseInWork(ctx, ownerB, 10000, time.Minute)
+		require.NoError(t, err)
+		assert.Zero(t, ours(leased))
+	})
+
+	t.Run("released_order_is_leased_again", func(t *testing.T) {
+		for orderNumber := range orders {
+			require.NoError(t, repo.ReleaseLease(ctx, orderNumber, ownerB)) // not the owner, no effect
+			require.NoError(t, repo.ReleaseLease(ctx, orderNumber, ownerA))
+		}
+
+		leased, err := repo.LeaseInWork(ctx, ownerB, 10000, time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, 2, ours(leased))
+	})
+
+	t.Run("expired_lease_is_taken_over", func(t *testing.T) {
+		time.Sleep(10 * time.Millisecond)
+
+		leased, err := repo.LeaseInWork(ctx, ownerA, 10000, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, 2, ours(leased))
+	})
+}
+
+func TestAccrualPG_ScheduleCheck(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewAccrualPG(db)
+
+	accrual := model.NewAccrual(uuid.NewString()[:20], userGUID)
+	require.NoError(t, repo.Create(ctx, accrual))
+
+	find := func(leased []model.Accrual) *model.Accrual {
+		for _, a := range leased {
+			if a.OrderNumber == accrual.OrderNumber {
+				return &a
+			}
+		}
+		return nil
+	}
+
+	owner := uuid.NewString()
+	_, err := repo.LeaseInWork(ctx, owner, 10000, time.Minute)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.ScheduleCheck(ctx, accrual.OrderNumber, owner, 1, time.Now().Add(50*time.Millisecond)))
+
+	t.Run("not_due_yet", func(t *testing.T) {
+		leased, err := repo.LeaseInWork(ctx, owner, 10000, time.Minute)
+		require.NoError(t, err)
+		assert.Nil(t, find(leased))
+	})
+
+	t.Run("due", func(t *testing.T) {
+		time.Sleep(100 * time.Millisecond)
+
+		leased, err := repo.LeaseInWork(ctx, owner, 10000, time.Minute)
+		require.NoError(t, err)
+
+		a := find(leased)
+		require.NotNil(t, a)
+		assert.Equal(t, 1, a.Attempts)
+	})
+
+	t.Run("give_up", func(t *testing.T) {
+		require.NoError(t, repo.GiveUp(ctx, accrual.OrderNumber, "stuck"))
+		assert.ErrorIs(t, repo.GiveUp(ctx, accrual.OrderNumber, "stuck"), repository.ErrAccrualAlreadyFinal)
+
+		got, err := repo.AccrualByOrderNumber(ctx, accrual.OrderNumber)
+		require.NoError(t, err)
+		assert.Equal(t, model.New, got.Status)
+		assert.True(t, got.GaveUp)
+		assert.Equal(t, "stuck", got.LastError)
+
+		time.Sleep(100 * time.Millisecond)
+
+		leased, err := repo.LeaseInWork(ctx, owner, 10000, time.Minute)
+		require.NoError(t, err)
+		assert.Nil(t, find(leased))
+	})
+
+	t.Run("push_after_give_up", func(t *testing.T) {
+		require.NoError(t, repo.UpdateStatus(ctx, accrual.OrderNumber, model.Processing))
+
+		got, err := repo.AccrualByOrderNumber(ctx, accrual.OrderNumber)
+		require.NoError(t, err)
+		assert.Equal(t, model.Processing, got.Status)
+		assert.False(t, got.GaveUp)
+		assert.Empty(t, got.LastError)
+	})
+
+	t.Run("status_does_not_go_back", func(t *testing.T) {
+		err := repo.UpdateStatus(ctx, accrual.OrderNumber, model.New)
+		assert.ErrorIs(t, err, repository.ErrAccrualAlreadyFinal)
+
+		got, err := repo.AccrualByOrderNumber(ctx, accrual.OrderNumber)
+		require.NoError(t, err)
+		assert.Equal(t, model.Processing, got.Status)
+	})
+}
+
+func TestAccrualPG_AddBalance_CreditsOnce(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewAccrualPG(db)
+
+	accrual := model.NewAccrual(uuid.NewString()[:20], userGUID)
+	require.NoError(t, repo.Create(ctx, accrual))
+
+	accrual.Status = model.Processed
+	accrual.Accrual = model.NewPoints(500, 0)
+
+	const replicas = 10
+
+	var (
+		wg       sync.WaitGroup
+		credited atomic.Int32
+		final    atomic.Int32
+	)
+
+	for i := 0; i < replicas; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			transaction := model.NewAddTransaction(uuid.NewString(), userGUID, accrual.OrderNumber, accrual.Accrual, time.Now())
+			err := repo.AddBalance(ctx, *accrual, transaction)
+			switch {
+			case err == nil:
+				credited.Add(1)
+			case errors.Is(err, repository.ErrAccrualAlreadyFinal):
+				final.Add(1)
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.EqualValues(t, 1, credited.Load())
+	assert.EqualValues(t, replicas-1, final.Load())
+
+	balance, _, err := repository.NewAccountPG(db).Balance(ctx, userGUID)
+	require.NoError(t, err)
+	assert.Equal(t, model.NewPoints(500, 0), balance)
+
+	err = repo.UpdateStatus(ctx, accrual.OrderNumber, model.Invalid)
+	assert.ErrorIs(t, err, repository.ErrAccrualAlreadyFinal)
+}
+
+func TestAccrualPG_AddBalance_OnlyProcessed(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewAccrualPG(db)
+
+	accrual := model.NewAccrual(uuid.NewString()[:20], userGUID)
+	require.NoError(t, repo.Create(ctx, accrual))
+
+	accrual.Status = model.Processing
+	accrual.Accrual = model.NewPoints(500, 0)
+
+	transaction := model.NewAddTransaction(uuid.NewString(), userGUID, accrual.OrderNumber, accrual.Accrual, time.Now())
+	assert.Error(t, repo.AddBalance(ctx, *accrual, transaction))
+
+	got, err := repo.AccrualByOrderNumber(ctx, accrual.OrderNumber)
+	require.NoError(t, err)
+	assert.Equal(t, model.New, got.Status)
+
+	accrual.Status = model.Processed
+
+	transaction = model.NewAddTransaction(uuid.NewString(), userGUID, accrual.OrderNumber, accrual.Accrual, time.Now())
+	require.NoError(t, repo.AddBalance(ctx, *accrual, transaction))
+
+	balance, _, err := repository.NewAccountPG(db).Balance(ctx, userGUID)
+	require.NoError(t, err)
+	assert.Equal(t, model.NewPoints(500, 0), balance)
+}
@@ -0,0 +1,99 @@
+package repository_test
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/db/migrator"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/db/pg"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const envTestDatabaseURI = "TEST_DATABASE_URI"
+
+// testDB connects to the database from TEST_DATABASE_URI and applies migrations.
+// Tests that need a real PostgreSQL are skipped when the variable is not set.
+func testDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+
+	dsn := os.Getenv(envTestDatabaseURI)
+	if dsn == "" {
+		t.Skipf("%s is not set", envTestDatabaseURI)
+	}
+
+	db, err := pg.Connect(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+
+	driver, err := pgx.WithInstance(db.DB, &pgx.Config{})
+	require.NoError(t, err)
+
+	m, err := migrator.NewMigrator("../../../migrations", driver)
+	require.NoError(t, err)
+
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	return db
+}
+
+// createUser inserts a user with a random login. Ledger tables are append-only, so test data is not cleaned up
+// and the test database is expected to be disposable.
+func createUser(t *testing.T, db *sqlx.DB, guid string) {
+	t.Helper()
+
+	_, err := db.Exec(`INSERT INTO users (guid, login, password) VALUES ($1, $2, '')`, guid, guid[:20])
+	require.NoError(t, err)
+}
+
+// fundAccount credits the user ledger account through a processed accrual, the same way the accrual worker does.
+func fundAccount(t *testing.T, db *sqlx.DB, userGUID string, sum model.Points) {
+	t.Helper()
+
+	ctx := context.Background()
+	repo := repository.NewAccrualPG(db)
+
+	accrual := model.NewAccrual(uuid.NewString()[:20], userGUID)
+	require.NoError(t, repo.Create(ctx, accrual))
+
+	accrual.Status = model.Processed
+	accrual.Accrual = sum
+
+	transaction := model.NewAddTransaction(uuid.NewString(), userGUID, accrual.OrderNumber, sum, time.Now())
+	require.NoError(t, repo.AddBalance(ctx, *accrual, transaction))
+}
+
+// orderNumber returns a random order number that passes the Luhn check. Withdrawals are unique per order across
+// all users, so tests can't share a fixed number.
+func orderNumber() string {
+	digits := make([]byte, 15)
+	for i := range digits {
+		digits[i] = byte('0' + rand.IntN(10))
+	}
+
+	var sum int
+	for i := len(digits) - 1; i >= 0; i -= 2 {
+		digit := int(digits[i]-'0') * 2
+		if digit > 9 {
+			digit -= 9
+		}
+		sum += digit
+		if i > 0 {
+			sum += int(digits[i-1] - '0')
+		}
+	}
+
+	return string(digits) + strconv.Itoa((10-sum%10)%10)
+}
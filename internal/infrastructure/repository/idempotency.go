@@ -0,0 +1,185 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/idempotency_mock.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var ErrIdempotencyKeyTakenOver = errors.New("idempotency key taken over")
+
+type IdempotencyRepo interface {
+	// Reserve stores the key if it is not used yet, or takes over a reservation of the same request whose lock has
+	// expired, sets key.LockToken and returns nil. Otherwise the stored record is returned.
+	Reserve(ctx context.Context, key *model.IdempotencyKey) (*model.IdempotencyKey, error)
+	// SaveResponse and Release apply only while the key is held with key.LockToken.
+	SaveResponse(ctx context.Context, key *model.IdempotencyKey) error
+	Release(ctx context.Context, key *model.IdempotencyKey) error
+	// DeleteExpired deletes completed keys created before the time and abandoned reservations.
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type IdempotencyPG struct {
+	db *sqlx.DB
+}
+
+func NewIdempotencyPG(db *sqlx.DB) *IdempotencyPG {
+	return &IdempotencyPG{
+		db: db,
+	}
+}
+
+func (r IdempotencyPG) Reserve(ctx context.Context, key *model.IdempotencyKey) (*model.IdempotencyKey, error) {
+	query := `
+		INSERT INTO idempotency_keys (key, user_guid, request_hash, created_at, locked_until, lock_token)
+		VALUES ($1, $2, $3, $4, $5, gen_random_uuid())
+		ON CONFLICT (user_guid, key) DO UPDATE
+		SET created_at = EXCLUDED.created_at,
+			locked_until = EXCLUDED.locked_until,
+			lock_token = EXCLUDED.lock_token
+		WHERE idempotency_keys.response_status IS NULL
+			AND idempotency_keys.request_hash = EXCLUDED.request_hash
+			AND (idempotency_keys.locked_until IS NULL OR idempotency_keys.locked_until <= now())
+		RETURNING lock_token
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	err = stmt.QueryRowContext(ctx, key.Key, key.UserGUID, key.RequestHash, key.CreatedAt, key.LockedUntil).Scan(&key.LockToken)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to save idempotency key: %w", err)
+	}
+
+	return r.find(ctx, key.UserGUID, key.Key)
+}
+
+func (r IdempotencyPG) SaveResponse(ctx context.Context, key *model.IdempotencyKey) error {
+	query := `
+		UPDATE idempotency_keys
+		SET response_status = $1, response_content_type = $2, response_body = $3
+		WHERE user_guid = $4 AND key = $5 AND lock_token = $6
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	res, err := stmt.ExecContext(ctx, key.ResponseStatus, key.ResponseContentType, key.ResponseBody, key.UserGUID, key.Key, key.LockToken)
+	if err != nil {
+		return fmt.Errorf("failed to save idempotency response: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrIdempotencyKeyTakenOver, key.Key)
+	}
+
+	return nil
+}
+
+func (r IdempotencyPG) Release(ctx context.Context, key *model.IdempotencyKey) error {
+	query := `DELETE FROM idempotency_keys WHERE user_guid = $1 AND key = $2 AND lock_token = $3`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, key.UserGUID, key.Key, key.LockToken)
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (r IdempotencyPG) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM idempotency_keys
+		WHERE (response_status IS NOT NULL AND created_at < $1)
+			OR (response_status IS NULL AND (locked_until IS NULL OR locked_until < now()))
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	res, err := stmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete idempotency keys: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return deleted, nil
+}
+
+func (r IdempotencyPG) find(ctx context.Context, userGUID, key string) (*model.IdempotencyKey, error) {
+	query := `
+		SELECT key, user_guid, request_hash, response_status, response_content_type, response_body, created_at,
+			locked_until, lock_token
+		FROM idempotency_keys
+		WHERE user_guid = $1 AND key = $2
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var m idempotencyKey
+	row := stmt.QueryRowContext(ctx, userGUID, key)
+	err = row.Scan(
+		&m.Key,
+		&m.UserGUID,
+		&m.RequestHash,
+		&m.ResponseStatus,
+		&m.ResponseContentType,
+		&m.ResponseBody,
+		&m.CreatedAt,
+		&m.LockedUntil,
+		&m.LockToken,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	return m.export(), nil
+}
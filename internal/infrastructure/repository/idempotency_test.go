@@ -0,0 +1,116 @@
+package repository_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestIdempotencyPG_Reserve(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewIdempotencyPG(db)
+
+	t.Run("in_progress", func(t *testing.T) {
+		key := uuid.NewString()
+		require.NoError(t, reserve(ctx, repo, model.NewIdempotencyKey(key, userGUID, "hash", time.Minute)))
+
+		stored, err := repo.Reserve(ctx, model.NewIdempotencyKey(key, userGUID, "hash", time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.False(t, stored.Completed())
+	})
+
+	t.Run("lock_expired", func(t *testing.T) {
+		key := uuid.NewString()
+		require.NoError(t, reserve(ctx, repo, model.NewIdempotencyKey(key, userGUID, "hash", -time.Second)))
+
+		stored, err := repo.Reserve(ctx, model.NewIdempotencyKey(key, userGUID, "hash", time.Minute))
+		require.NoError(t, err)
+		assert.Nil(t, stored, "expired reservation is not taken over")
+	})
+
+	t.Run("lock_expired_other_request", func(t *testing.T) {
+		key := uuid.NewString()
+		require.NoError(t, reserve(ctx, repo, model.NewIdempotencyKey(key, userGUID, "hash", -time.Second)))
+
+		stored, err := repo.Reserve(ctx, model.NewIdempotencyKey(key, userGUID, "other", time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, stored, "expired reservation is taken over by another request")
+		assert.Equal(t, "hash", stored.RequestHash)
+	})
+
+	t.Run("slow_request_after_take_over", func(t *testing.T) {
+		slow := model.NewIdempotencyKey(uuid.NewString(), userGUID, "hash", -time.Second)
+		require.NoError(t, reserve(ctx, repo, slow))
+
+		retry := model.NewIdempotencyKey(slow.Key, userGUID, "hash", time.Minute)
+		require.NoError(t, reserve(ctx, repo, retry))
+		assert.NotEqual(t, slow.LockToken, retry.LockToken)
+
+		require.NoError(t, repo.Release(ctx, slow))
+
+		slow.ResponseStatus = http.StatusInternalServerError
+		assert.ErrorIs(t, repo.SaveResponse(ctx, slow), repository.ErrIdempotencyKeyTakenOver)
+
+		retry.ResponseStatus = http.StatusOK
+		require.NoError(t, repo.SaveResponse(ctx, retry))
+
+		stored, err := repo.Reserve(ctx, model.NewIdempotencyKey(slow.Key, userGUID, "hash", time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, http.StatusOK, stored.ResponseStatus)
+	})
+
+	t.Run("completed_not_taken_over", func(t *testing.T) {
+		key := model.NewIdempotencyKey(uuid.NewString(), userGUID, "hash", -time.Second)
+		require.NoError(t, reserve(ctx, repo, key))
+
+		key.ResponseStatus = http.StatusOK
+		require.NoError(t, repo.SaveResponse(ctx, key))
+
+		stored, err := repo.Reserve(ctx, model.NewIdempotencyKey(key.Key, userGUID, "hash", time.Minute))
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.True(t, stored.Completed())
+	})
+
+	t.Run("delete_expired", func(t *testing.T) {
+		key := model.NewIdempotencyKey(uuid.NewString(), userGUID, "hash", time.Minute)
+		require.NoError(t, reserve(ctx, repo, key))
+
+		key.ResponseStatus = http.StatusOK
+		require.NoError(t, repo.SaveResponse(ctx, key))
+
+		_, err := repo.DeleteExpired(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+
+		stored, err := repo.Reserve(ctx, model.NewIdempotencyKey(key.Key, userGUID, "hash", time.Minute))
+		require.NoError(t, err)
+		assert.Nil(t, stored, "completed key is not deleted")
+	})
+}
+
+func reserve(ctx context.Context, repo *repository.IdempotencyPG, key *model.IdempotencyKey) error {
+	stored, err := repo.Reserve(ctx, key)
+	if err != nil {
+		return err
+	}
+	if stored != nil {
+		return assert.AnError
+	}
+
+	return nil
+}
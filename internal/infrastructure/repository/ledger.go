@@ -0,0 +1,338 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/ledger_mock.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var ErrUnbalancedLedgerEntry = errors.New("unbalanced ledger entry")
+
+type LedgerRepo interface {
+	AccountTotals(ctx context.Context) ([]model.AccountTotals, error)
+	AccrualMismatches(ctx context.Context) ([]model.AccrualTotals, error)
+
+	PostEntry(ctx context.Context, entry model.LedgerEntry) error
+	Rematerialise(ctx context.Context, accountGUID string) error
+}
+
+func openUserLedgerAccountTx(tx *sql.Tx, userGUID string, openedAt time.Time) error {
+	query := `
+		INSERT INTO ledger_accounts (guid, type, user_guid, created_at, updated_at)
+		VALUES ($1, $2, $1, $3, $3)
+		ON CONFLICT (guid) DO NOTHING
+	`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare open ledger account query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.Exec(userGUID, model.UserLedgerAccount, openedAt)
+	if err != nil {
+		return fmt.Errorf("failed to open ledger account: %w", err)
+	}
+
+	return nil
+}
+
+// lockUserLedgerAccountTx locks the user account row until the end of the transaction and returns its balance.
+func lockUserLedgerAccountTx(tx *sql.Tx, userGUID string) (model.Points, bool, error) {
+	query := `SELECT balance FROM ledger_accounts WHERE guid = $1 AND type = $2 FOR UPDATE`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to prepare lock ledger account query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var balance model.Points
+	err = stmt.QueryRow(userGUID, model.UserLedgerAccount).Scan(&balance)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+
+		return 0, false, fmt.Errorf("failed to lock ledger account: %w", err)
+	}
+
+	return balance, true, nil
+}
+
+// postEntryTx writes the entry and its postings. User account balances are materialised from postings by a trigger,
+// system accounts are not updated, so concurrent entries don't queue on their rows.
+func postEntryTx(tx *sql.Tx, entry model.LedgerEntry) error {
+	if !entry.Balanced() {
+		return fmt.Errorf("%w: %s", ErrUnbalancedLedgerEntry, entry.GUID)
+	}
+
+	query := `
+		INSERT INTO ledger_entries (guid, type, user_guid, order_number, description, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert ledger entry query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.Exec(entry.GUID, entry.Type, entry.UserGUID, entry.OrderNumber, entry.Description, entry.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert ledger entry: %w", err)
+	}
+
+	query = `
+		INSERT INTO ledger_postings (entry_guid, account_guid, amount, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	postingStmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert ledger posting query: %w", err)
+	}
+	defer func() {
+		_ = postingStmt.Close()
+	}()
+
+	for _, p := range entry.Postings {
+		_, err = postingStmt.Exec(entry.GUID, p.AccountGUID, p.Amount, entry.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to insert ledger posting: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type LedgerPG struct {
+	db *sqlx.DB
+}
+
+func NewLedgerPG(db *sqlx.DB) *LedgerPG {
+	return &LedgerPG{
+		db: db,
+	}
+}
+
+func (r LedgerPG) AccountTotals(ctx context.Context) ([]model.AccountTotals, error) {
+	query := `
+		WITH postings AS (
+			SELECT p.account_guid,
+			       SUM(p.amount)                             AS balance,
+			       SUM(-p.amount) FILTER (WHERE e.type = $2) AS withdrawn
+			FROM ledger_postings p
+			JOIN ledger_entries e ON e.guid = p.entry_guid
+			GROUP BY p.account_guid
+		), txs AS (
+			SELECT account_guid,
+			       SUM(sum) FILTER (WHERE type = $3) AS accrued,
+			       SUM(sum) FILTER (WHERE type = $4) AS withdrawn
+			FROM transactions
+			GROUP BY account_guid
+		)
+		SELECT u.guid,
+		       COALESCE(a.balance, 0),
+		       COALESCE(p.balance, 0),
+		       COALESCE(p.withdrawn, 0),
+		       COALESCE(t.accrued, 0),
+		       COALESCE(t.withdrawn, 0)
+		FROM (
+			SELECT guid FROM ledger_accounts WHERE type = $1
+			UNION
+			SELECT account_guid FROM transactions
+		) u
+		LEFT JOIN ledger_accounts a ON a.guid = u.guid
+		LEFT JOIN postings p ON p.account_guid = u.guid
+		LEFT JOIN txs t ON t.account_guid = u.guid
+		ORDER BY u.guid
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, model.UserLedgerAccount, model.WithdrawEntry, model.Add, model.Withdraw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.AccountTotals
+	for rows.Next() {
+		var m model.AccountTotals
+		err = rows.Scan(
+			&m.AccountGUID,
+			&m.MaterialisedBalance,
+			&m.LedgerBalance,
+			&m.LedgerWithdrawn,
+			&m.TransactionsAccrued,
+			&m.TransactionsWithdrawn,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		result = append(result, m)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+// AccrualMismatches returns processed accruals whose credited sum differs from the accrual, and credits without
+// a processed accrual.
+func (r LedgerPG) AccrualMismatches(ctx context.Context) ([]model.AccrualTotals, error) {
+	query := `
+		SELECT COALESCE(a.order_number, t.order_number),
+		       COALESCE(a.user_guid, t.account_guid),
+		       COALESCE(a.accrual, 0),
+		       COALESCE(t.credited, 0)
+		FROM (
+			SELECT order_number, user_guid, accrual
+			FROM accruals
+			WHERE status = $1 AND accrual <> 0
+		) a
+		FULL JOIN (
+			SELECT order_number, account_guid, SUM(sum) AS credited
+			FROM transactions
+			WHERE type = $2
+			GROUP BY order_number, account_guid
+		) t ON t.order_number = a.order_number AND t.account_guid = a.user_guid
+		WHERE a.order_number IS NULL OR t.order_number IS NULL OR a.accrual <> t.credited
+		ORDER BY 1
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, model.Processed, model.Add)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.AccrualTotals
+	for rows.Next() {
+		var m model.AccrualTotals
+		err = rows.Scan(&m.OrderNumber, &m.UserGUID, &m.Accrual, &m.Credited)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		result = append(result, m)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+func (r LedgerPG) PostEntry(ctx context.Context, entry model.LedgerEntry) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = openUserLedgerAccountTx(tx, entry.UserGUID, entry.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = postEntryTx(tx, entry)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Rematerialise recomputes the stored balance and turnovers of the user account from its postings. The account row
+// is locked first, so a posting committed in the meantime is not lost.
+func (r LedgerPG) Rematerialise(ctx context.Context, accountGUID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, found, err := lockUserLedgerAccountTx(tx, accountGUID)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return nil
+	}
+
+	query := `
+		UPDATE ledger_accounts a
+		SET balance      = p.balance,
+		    total_debit  = p.total_debit,
+		    total_credit = p.total_credit
+		FROM (
+			SELECT COALESCE(SUM(amount), 0)                             AS balance,
+			       COALESCE(SUM(-amount) FILTER (WHERE amount < 0), 0) AS total_debit,
+			       COALESCE(SUM(amount) FILTER (WHERE amount > 0), 0)  AS total_credit
+			FROM ledger_postings
+			WHERE account_guid = $1
+		) p
+		WHERE a.guid = $1 AND a.type = $2
+	`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, accountGUID, model.UserLedgerAccount)
+	if err != nil {
+		return fmt.Errorf("failed to rematerialise ledger account: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestLedger_Postings(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	fundAccount(t, db, userGUID, model.NewPoints(729, 98))
+
+	withdraw := model.NewWithdrawTransaction(uuid.NewString(), userGUID, uuid.NewString()[:20], model.NewPoints(29, 98), time.Now())
+	require.NoError(t, repository.NewAccrualPG(db).WithdrawBalance(ctx, withdraw))
+
+	t.Run("balance", func(t *testing.T) {
+		balance, withdrawn, err := repository.NewAccountPG(db).Balance(ctx, userGUID)
+		require.NoError(t, err)
+		assert.Equal(t, model.NewPoints(700, 0), balance)
+		assert.Equal(t, model.NewPoints(29, 98), withdrawn)
+	})
+
+	t.Run("materialised_balance_matches_postings", func(t *testing.T) {
+		var materialised, derived model.Points
+		err := db.QueryRow(`
+			SELECT a.balance, (SELECT SUM(amount) FROM ledger_postings WHERE account_guid = a.guid)
+			FROM ledger_accounts a
+			WHERE a.guid = $1
+		`, userGUID).Scan(&materialised, &derived)
+		require.NoError(t, err)
+		assert.Equal(t, derived, materialised)
+	})
+
+	t.Run("system_accounts_are_not_materialised", func(t *testing.T) {
+		var stored, derived model.Points
+		err := db.QueryRow(`
+			SELECT a.balance, b.balance
+			FROM ledger_accounts a
+			JOIN ledger_system_balances b ON b.guid = a.guid
+			WHERE a.guid = $1
+		`, model.RedemptionAccountGUID).Scan(&stored, &derived)
+		require.NoError(t, err)
+		assert.Zero(t, stored)
+		assert.GreaterOrEqual(t, derived, model.NewPoints(29, 98))
+	})
+
+	t.Run("entries_are_balanced", func(t *testing.T) {
+		var unbalanced int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM (
+				SELECT p.entry_guid
+				FROM ledger_postings p
+				JOIN ledger_entries e ON e.guid = p.entry_guid
+				WHERE e.user_guid = $1
+				GROUP BY p.entry_guid
+				HAVING SUM(p.amount) <> 0
+			) t
+		`, userGUID).Scan(&unbalanced)
+		require.NoError(t, err)
+		assert.Zero(t, unbalanced)
+	})
+
+	t.Run("append_only", func(t *testing.T) {
+		_, err := db.Exec(`UPDATE ledger_postings SET amount = amount * 2 WHERE account_guid = $1`, userGUID)
+		assert.Error(t, err)
+
+		_, err = db.Exec(`DELETE FROM ledger_entries WHERE user_guid = $1`, userGUID)
+		assert.Error(t, err)
+	})
+}
+
+func TestLedgerPG_Rematerialise_Concurrent(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	fundAccount(t, db, userGUID, model.NewPoints(1, 0))
+
+	accrualRepo := repository.NewAccrualPG(db)
+	repo := repository.NewLedgerPG(db)
+
+	const credits = 20
+
+	accruals := make([]*model.Accrual, 0, credits)
+	for i := 0; i < credits; i++ {
+		accrual := model.NewAccrual(uuid.NewString()[:20], userGUID)
+		require.NoError(t, accrualRepo.Create(ctx, accrual))
+
+		accrual.Status = model.Processed
+		accrual.Accrual = model.NewPoints(1, 0)
+		accruals = append(accruals, accrual)
+	}
+
+	var wg sync.WaitGroup
+	for _, accrual := range accruals {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			transaction := model.NewAddTransaction(uuid.NewString(), userGUID, accrual.OrderNumber, accrual.Accrual, time.Now())
+			assert.NoError(t, accrualRepo.AddBalance(ctx, *accrual, transaction))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, repo.Rematerialise(ctx, userGUID))
+		}()
+	}
+
+	wg.Wait()
+
+	balance, _, err := repository.NewAccountPG(db).Balance(ctx, userGUID)
+	require.NoError(t, err)
+	assert.Equal(t, model.NewPoints(credits+1, 0), balance)
+}
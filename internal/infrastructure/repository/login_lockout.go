@@ -0,0 +1,56 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/login_lockout_mock.go
+
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// LoginLockoutRepo is the audit log of login lockouts. It is kept in Postgres whatever store counts the failures.
+type LoginLockoutRepo interface {
+	Add(ctx context.Context, lockout *model.LoginLockout) error
+}
+
+type LoginLockoutPG struct {
+	db *sqlx.DB
+}
+
+func NewLoginLockoutPG(db *sqlx.DB) *LoginLockoutPG {
+	return &LoginLockoutPG{
+		db: db,
+	}
+}
+
+func (r LoginLockoutPG) Add(ctx context.Context, lockout *model.LoginLockout) error {
+	query := `
+		INSERT INTO login_lockouts (guid, subject, value, failures, locked_until, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(
+		ctx,
+		lockout.GUID,
+		lockout.Subject,
+		lockout.Value,
+		lockout.Failures,
+		lockout.LockedUntil,
+		lockout.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save login lockout: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,213 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/login_throttle_mock.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// LoginThrottleRepo counts failed logins by key. LoginThrottlePG shares the counts between replicas,
+// LoginThrottleMemory keeps them in the process for a single instance.
+type LoginThrottleRepo interface {
+	// Throttle returns the failures of the key, nil if there are none.
+	Throttle(ctx context.Context, key string) (*model.LoginThrottle, error)
+	// Reserve counts an attempt of the key as a failure before it is checked and blocks the key for hold(failures),
+	// so that parallel attempts see it at once. A blocked key is not counted, false is returned then. The count
+	// starts over when the window that began with the first failure is over.
+	Reserve(ctx context.Context, key string, window time.Duration, hold func(failures int) time.Duration) (*model.LoginThrottle, bool, error)
+	// Release takes back a reserved attempt that has succeeded.
+	Release(ctx context.Context, key string) error
+	// Block rejects attempts of the key until the time.
+	Block(ctx context.Context, key string, until time.Time) error
+	// Reset forgets the failures of the key.
+	Reset(ctx context.Context, key string) error
+	// DeleteExpired deletes the keys whose window has started before the time and that are no longer blocked.
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+type LoginThrottlePG struct {
+	db *sqlx.DB
+}
+
+func NewLoginThrottlePG(db *sqlx.DB) *LoginThrottlePG {
+	return &LoginThrottlePG{
+		db: db,
+	}
+}
+
+func (r LoginThrottlePG) Throttle(ctx context.Context, key string) (*model.LoginThrottle, error) {
+	query := "SELECT key, failures, window_started_at, blocked_until FROM login_throttles WHERE key = $1"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var m loginThrottle
+	err = stmt.QueryRowContext(ctx, key).Scan(&m.Key, &m.Failures, &m.WindowStartedAt, &m.BlockedUntil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	return m.export(), nil
+}
+
+// Reserve locks the row of the key, so that parallel attempts are counted one after another.
+func (r LoginThrottlePG) Reserve(
+	ctx context.Context,
+	key string,
+	window time.Duration,
+	hold func(failures int) time.Duration,
+) (*model.LoginThrottle, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+		INSERT INTO login_throttles (key, failures, window_started_at)
+		VALUES ($1, 0, now())
+		ON CONFLICT (key) DO NOTHING
+	`
+	_, err = tx.ExecContext(ctx, query, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to add login throttle: %w", err)
+	}
+
+	query = `
+		SELECT key, failures, window_started_at, blocked_until, now()
+		FROM login_throttles
+		WHERE key = $1
+		FOR UPDATE
+	`
+	var (
+		m   loginThrottle
+		now time.Time
+	)
+	err = tx.QueryRowContext(ctx, query, key).Scan(&m.Key, &m.Failures, &m.WindowStartedAt, &m.BlockedUntil, &now)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	t := m.export()
+	if t.RetryAfter(now) > 0 {
+		return t, false, tx.Commit()
+	}
+
+	if !now.Before(t.WindowStartedAt.Add(window)) {
+		t.Failures = 0
+		t.WindowStartedAt = now
+	}
+	t.Failures++
+	t.BlockedUntil = now.Add(hold(t.Failures))
+
+	query = "UPDATE login_throttles SET failures = $1, window_started_at = $2, blocked_until = $3 WHERE key = $4"
+	_, err = tx.ExecContext(ctx, query, t.Failures, t.WindowStartedAt, t.BlockedUntil, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to reserve login attempt: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return t, true, nil
+}
+
+func (r LoginThrottlePG) Release(ctx context.Context, key string) error {
+	query := "UPDATE login_throttles SET failures = GREATEST(failures - 1, 0) WHERE key = $1"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to release login attempt: %w", err)
+	}
+
+	return nil
+}
+
+func (r LoginThrottlePG) Block(ctx context.Context, key string, until time.Time) error {
+	query := "UPDATE login_throttles SET blocked_until = $1 WHERE key = $2"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, until, key)
+	if err != nil {
+		return fmt.Errorf("failed to block login: %w", err)
+	}
+
+	return nil
+}
+
+func (r LoginThrottlePG) Reset(ctx context.Context, key string) error {
+	query := "DELETE FROM login_throttles WHERE key = $1"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+
+	return nil
+}
+
+func (r LoginThrottlePG) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM login_throttles
+		WHERE window_started_at < $1 AND (blocked_until IS NULL OR blocked_until < now())
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	res, err := stmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete login throttles: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return deleted, nil
+}
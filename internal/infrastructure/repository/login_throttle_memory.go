@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// LoginThrottleMemory keeps failed logins in the process. Counts are lost on restart and are not seen by other
+// replicas, so it is meant for a single instance.
+type LoginThrottleMemory struct {
+	mu        sync.Mutex
+	throttles map[string]*model.LoginThrottle
+}
+
+func NewLoginThrottleMemory() *LoginThrottleMemory {
+	return &LoginThrottleMemory{
+		throttles: make(map[string]*model.LoginThrottle),
+	}
+}
+
+func (r *LoginThrottleMemory) Throttle(_ context.Context, key string) (*model.LoginThrottle, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.throttles[key]
+	if !ok {
+		return nil, nil
+	}
+
+	c := *t
+	return &c, nil
+}
+
+func (r *LoginThrottleMemory) Reserve(
+	_ context.Context,
+	key string,
+	window time.Duration,
+	hold func(failures int) time.Duration,
+) (*model.LoginThrottle, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	t, ok := r.throttles[key]
+	if !ok {
+		t = &model.LoginThrottle{Key: key}
+		r.throttles[key] = t
+	}
+
+	if t.RetryAfter(now) > 0 {
+		c := *t
+		return &c, false, nil
+	}
+
+	if !now.Before(t.WindowStartedAt.Add(window)) {
+		t.Failures = 0
+		t.WindowStartedAt = now
+	}
+	t.Failures++
+	t.BlockedUntil = now.Add(hold(t.Failures))
+
+	c := *t
+	return &c, true, nil
+}
+
+func (r *LoginThrottleMemory) Release(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.throttles[key]; ok && t.Failures > 0 {
+		t.Failures--
+	}
+
+	return nil
+}
+
+func (r *LoginThrottleMemory) Block(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if t, ok := r.throttles[key]; ok {
+		t.BlockedUntil = until
+	}
+
+	return nil
+}
+
+func (r *LoginThrottleMemory) Reset(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.throttles, key)
+
+	return nil
+}
+
+func (r *LoginThrottleMemory) DeleteExpired(_ context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+
+	var deleted int64
+	for key, t := range r.throttles {
+		if t.WindowStartedAt.Before(before) && !now.Before(t.BlockedUntil) {
+			delete(r.throttles, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,164 @@
+package repository_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestLoginThrottlePG(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	repo := repository.NewLoginThrottlePG(db)
+	key := model.ThrottleKey(model.ThrottleLogin, uuid.NewString())
+	noHold := func(int) time.Duration { return 0 }
+
+	got, err := repo.Throttle(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	for want := 1; want <= 3; want++ {
+		got, ok, err := repo.Reserve(ctx, key, time.Hour, noHold)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, want, got.Failures)
+	}
+
+	t.Run("release", func(t *testing.T) {
+		require.NoError(t, repo.Release(ctx, key))
+
+		got, err := repo.Throttle(ctx, key)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, 2, got.Failures)
+	})
+
+	t.Run("held", func(t *testing.T) {
+		got, ok, err := repo.Reserve(ctx, key, time.Hour, func(failures int) time.Duration {
+			assert.Equal(t, 3, failures)
+			return time.Minute
+		})
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Positive(t, got.RetryAfter(time.Now()))
+
+		// a blocked key is not counted
+		got, ok, err = repo.Reserve(ctx, key, time.Hour, noHold)
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 3, got.Failures)
+	})
+
+	t.Run("block", func(t *testing.T) {
+		until := time.Now().Add(time.Hour)
+		require.NoError(t, repo.Block(ctx, key, until))
+
+		got, err := repo.Throttle(ctx, key)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.WithinDuration(t, until, got.BlockedUntil, time.Millisecond)
+	})
+
+	t.Run("window_over", func(t *testing.T) {
+		query := "UPDATE login_throttles SET window_started_at = now() - interval '2 hours', blocked_until = NULL WHERE key = $1"
+		_, err := db.ExecContext(ctx, query, key)
+		require.NoError(t, err)
+
+		got, ok, err := repo.Reserve(ctx, key, time.Hour, noHold)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, 1, got.Failures)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		require.NoError(t, repo.Reset(ctx, key))
+
+		got, err := repo.Throttle(ctx, key)
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+}
+
+func TestLoginThrottlePG_Reserve_Concurrent(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	repo := repository.NewLoginThrottlePG(db)
+	key := model.ThrottleKey(model.ThrottleLogin, uuid.NewString())
+
+	const attempts = 20
+
+	var (
+		wg       sync.WaitGroup
+		reserved atomic.Int32
+	)
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, ok, err := repo.Reserve(ctx, key, time.Hour, func(int) time.Duration { return time.Minute })
+			assert.NoError(t, err)
+			if ok {
+				reserved.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// the first attempt holds the key, the others are not let through
+	assert.EqualValues(t, 1, reserved.Load())
+}
+
+func TestLoginThrottlePG_DeleteExpired(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	repo := repository.NewLoginThrottlePG(db)
+	expired := model.ThrottleKey(model.ThrottleIP, uuid.NewString())
+	blocked := model.ThrottleKey(model.ThrottleIP, uuid.NewString())
+
+	for _, key := range []string{expired, blocked} {
+		_, _, err := repo.Reserve(ctx, key, time.Hour, func(int) time.Duration { return 0 })
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Block(ctx, blocked, time.Now().Add(time.Hour)))
+
+	deleted, err := repo.DeleteExpired(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Positive(t, deleted)
+
+	got, err := repo.Throttle(ctx, expired)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = repo.Throttle(ctx, blocked)
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+}
+
+func TestLoginLockoutPG_Add(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	repo := repository.NewLoginLockoutPG(db)
+	lockout := model.NewLoginLockout(uuid.NewString(), model.ThrottleIP, "192.0.2.1", 20, time.Now().Add(15*time.Minute))
+	require.NoError(t, repo.Add(ctx, lockout))
+
+	var failures int
+	err := db.GetContext(ctx, &failures, "SELECT failures FROM login_lockouts WHERE guid = $1", lockout.GUID)
+	require.NoError(t, err)
+	assert.Equal(t, 20, failures)
+}
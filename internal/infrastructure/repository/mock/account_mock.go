@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	model "github.com/bjlag/go-loyalty/internal/model"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,11 +36,11 @@ func (m *MockAccountRepo) EXPECT() *MockAccountRepoMockRecorder {
 }
 
 // Balance mocks base method.
-func (m *MockAccountRepo) Balance(ctx context.Context, accountGUID string) (float64, float64, error) {
+func (m *MockAccountRepo) Balance(ctx context.Context, accountGUID string) (model.Points, model.Points, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Balance", ctx, accountGUID)
-	ret0, _ := ret[0].(float64)
-	ret1, _ := ret[1].(float64)
+	ret0, _ := ret[0].(model.Points)
+	ret1, _ := ret[1].(model.Points)
 	ret2, _ := ret[2].(error)
 	return ret0, ret1, ret2
 }
@@ -7,6 +7,7 @@ package mock
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	model "github.com/bjlag/go-loyalty/internal/model"
 	gomock "github.com/golang/mock/gomock"
@@ -65,33 +66,33 @@ func (mr *MockAccrualRepoMockRecorder) AccrualsByUser(ctx, userGUID interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualsByUser", reflect.TypeOf((*MockAccrualRepo)(nil).AccrualsByUser), ctx, userGUID)
 }
 
-// AccrualsInWork mocks base method.
-func (m *MockAccrualRepo) AccrualsInWork(ctx context.Context) ([]model.Accrual, error) {
+// AccrualsByUserFiltered mocks base method.
+func (m *MockAccrualRepo) AccrualsByUserFiltered(ctx context.Context, userGUID string, filter model.AccrualFilter) ([]model.Accrual, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AccrualsInWork", ctx)
+	ret := m.ctrl.Call(m, "AccrualsByUserFiltered", ctx, userGUID, filter)
 	ret0, _ := ret[0].([]model.Accrual)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// AccrualsInWork indicates an expected call of AccrualsInWork.
-func (mr *MockAccrualRepoMockRecorder) AccrualsInWork(ctx interface{}) *gomock.Call {
+// AccrualsByUserFiltered indicates an expected call of AccrualsByUserFiltered.
+func (mr *MockAccrualRepoMockRecorder) AccrualsByUserFiltered(ctx, userGUID, filter interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualsInWork", reflect.TypeOf((*MockAccrualRepo)(nil).AccrualsInWork), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualsByUserFiltered", reflect.TypeOf((*MockAccrualRepo)(nil).AccrualsByUserFiltered), ctx, userGUID, filter)
 }
 
 // AddBalance mocks base method.
-func (m *MockAccrualRepo) AddBalance(ctx context.Context, accrual model.Accrual, account model.Account, transaction model.Transaction) error {
+func (m *MockAccrualRepo) AddBalance(ctx context.Context, accrual model.Accrual, transaction model.Transaction) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddBalance", ctx, accrual, account, transaction)
+	ret := m.ctrl.Call(m, "AddBalance", ctx, accrual, transaction)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AddBalance indicates an expected call of AddBalance.
-func (mr *MockAccrualRepoMockRecorder) AddBalance(ctx, accrual, account, transaction interface{}) *gomock.Call {
+func (mr *MockAccrualRepoMockRecorder) AddBalance(ctx, accrual, transaction interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBalance", reflect.TypeOf((*MockAccrualRepo)(nil).AddBalance), ctx, accrual, account, transaction)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddBalance", reflect.TypeOf((*MockAccrualRepo)(nil).AddBalance), ctx, accrual, transaction)
 }
 
 // Create mocks base method.
@@ -108,6 +109,63 @@ func (mr *MockAccrualRepoMockRecorder) Create(ctx, accrual interface{}) *gomock.
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockAccrualRepo)(nil).Create), ctx, accrual)
 }
 
+// GiveUp mocks base method.
+func (m *MockAccrualRepo) GiveUp(ctx context.Context, orderNumber, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GiveUp", ctx, orderNumber, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// GiveUp indicates an expected call of GiveUp.
+func (mr *MockAccrualRepoMockRecorder) GiveUp(ctx, orderNumber, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GiveUp", reflect.TypeOf((*MockAccrualRepo)(nil).GiveUp), ctx, orderNumber, reason)
+}
+
+// LeaseInWork mocks base method.
+func (m *MockAccrualRepo) LeaseInWork(ctx context.Context, owner string, limit int, ttl time.Duration) ([]model.Accrual, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LeaseInWork", ctx, owner, limit, ttl)
+	ret0, _ := ret[0].([]model.Accrual)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LeaseInWork indicates an expected call of LeaseInWork.
+func (mr *MockAccrualRepoMockRecorder) LeaseInWork(ctx, owner, limit, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LeaseInWork", reflect.TypeOf((*MockAccrualRepo)(nil).LeaseInWork), ctx, owner, limit, ttl)
+}
+
+// ReleaseLease mocks base method.
+func (m *MockAccrualRepo) ReleaseLease(ctx context.Context, orderNumber, owner string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseLease", ctx, orderNumber, owner)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseLease indicates an expected call of ReleaseLease.
+func (mr *MockAccrualRepoMockRecorder) ReleaseLease(ctx, orderNumber, owner interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseLease", reflect.TypeOf((*MockAccrualRepo)(nil).ReleaseLease), ctx, orderNumber, owner)
+}
+
+// ScheduleCheck mocks base method.
+func (m *MockAccrualRepo) ScheduleCheck(ctx context.Context, orderNumber, owner string, attempts int, nextCheckAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleCheck", ctx, orderNumber, owner, attempts, nextCheckAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleCheck indicates an expected call of ScheduleCheck.
+func (mr *MockAccrualRepoMockRecorder) ScheduleCheck(ctx, orderNumber, owner, attempts, nextCheckAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleCheck", reflect.TypeOf((*MockAccrualRepo)(nil).ScheduleCheck), ctx, orderNumber, owner, attempts, nextCheckAt)
+}
+
 // UpdateStatus mocks base method.
 func (m *MockAccrualRepo) UpdateStatus(ctx context.Context, orderNumber string, newStatus model.AccrualStatus) error {
 	m.ctrl.T.Helper()
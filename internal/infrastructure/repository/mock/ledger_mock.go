@@ -0,0 +1,94 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ledger.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/bjlag/go-loyalty/internal/model"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLedgerRepo is a mock of LedgerRepo interface.
+type MockLedgerRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockLedgerRepoMockRecorder
+}
+
+// MockLedgerRepoMockRecorder is the mock recorder for MockLedgerRepo.
+type MockLedgerRepoMockRecorder struct {
+	mock *MockLedgerRepo
+}
+
+// NewMockLedgerRepo creates a new mock instance.
+func NewMockLedgerRepo(ctrl *gomock.Controller) *MockLedgerRepo {
+	mock := &MockLedgerRepo{ctrl: ctrl}
+	mock.recorder = &MockLedgerRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLedgerRepo) EXPECT() *MockLedgerRepoMockRecorder {
+	return m.recorder
+}
+
+// AccountTotals mocks base method.
+func (m *MockLedgerRepo) AccountTotals(ctx context.Context) ([]model.AccountTotals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccountTotals", ctx)
+	ret0, _ := ret[0].([]model.AccountTotals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccountTotals indicates an expected call of AccountTotals.
+func (mr *MockLedgerRepoMockRecorder) AccountTotals(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccountTotals", reflect.TypeOf((*MockLedgerRepo)(nil).AccountTotals), ctx)
+}
+
+// AccrualMismatches mocks base method.
+func (m *MockLedgerRepo) AccrualMismatches(ctx context.Context) ([]model.AccrualTotals, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AccrualMismatches", ctx)
+	ret0, _ := ret[0].([]model.AccrualTotals)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AccrualMismatches indicates an expected call of AccrualMismatches.
+func (mr *MockLedgerRepoMockRecorder) AccrualMismatches(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AccrualMismatches", reflect.TypeOf((*MockLedgerRepo)(nil).AccrualMismatches), ctx)
+}
+
+// PostEntry mocks base method.
+func (m *MockLedgerRepo) PostEntry(ctx context.Context, entry model.LedgerEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostEntry", ctx, entry)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PostEntry indicates an expected call of PostEntry.
+func (mr *MockLedgerRepoMockRecorder) PostEntry(ctx, entry interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostEntry", reflect.TypeOf((*MockLedgerRepo)(nil).PostEntry), ctx, entry)
+}
+
+// Rematerialise mocks base method.
+func (m *MockLedgerRepo) Rematerialise(ctx context.Context, accountGUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rematerialise", ctx, accountGUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rematerialise indicates an expected call of Rematerialise.
+func (mr *MockLedgerRepoMockRecorder) Rematerialise(ctx, accountGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rematerialise", reflect.TypeOf((*MockLedgerRepo)(nil).Rematerialise), ctx, accountGUID)
+}
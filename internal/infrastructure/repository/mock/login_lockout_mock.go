@@ -0,0 +1,50 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: login_lockout.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/bjlag/go-loyalty/internal/model"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLoginLockoutRepo is a mock of LoginLockoutRepo interface.
+type MockLoginLockoutRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginLockoutRepoMockRecorder
+}
+
+// MockLoginLockoutRepoMockRecorder is the mock recorder for MockLoginLockoutRepo.
+type MockLoginLockoutRepoMockRecorder struct {
+	mock *MockLoginLockoutRepo
+}
+
+// NewMockLoginLockoutRepo creates a new mock instance.
+func NewMockLoginLockoutRepo(ctrl *gomock.Controller) *MockLoginLockoutRepo {
+	mock := &MockLoginLockoutRepo{ctrl: ctrl}
+	mock.recorder = &MockLoginLockoutRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginLockoutRepo) EXPECT() *MockLoginLockoutRepoMockRecorder {
+	return m.recorder
+}
+
+// Add mocks base method.
+func (m *MockLoginLockoutRepo) Add(ctx context.Context, lockout *model.LoginLockout) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Add", ctx, lockout)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Add indicates an expected call of Add.
+func (mr *MockLoginLockoutRepoMockRecorder) Add(ctx, lockout interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockLoginLockoutRepo)(nil).Add), ctx, lockout)
+}
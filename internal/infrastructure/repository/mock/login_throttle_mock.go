@@ -0,0 +1,125 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: login_throttle.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	model "github.com/bjlag/go-loyalty/internal/model"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockLoginThrottleRepo is a mock of LoginThrottleRepo interface.
+type MockLoginThrottleRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockLoginThrottleRepoMockRecorder
+}
+
+// MockLoginThrottleRepoMockRecorder is the mock recorder for MockLoginThrottleRepo.
+type MockLoginThrottleRepoMockRecorder struct {
+	mock *MockLoginThrottleRepo
+}
+
+// NewMockLoginThrottleRepo creates a new mock instance.
+func NewMockLoginThrottleRepo(ctrl *gomock.Controller) *MockLoginThrottleRepo {
+	mock := &MockLoginThrottleRepo{ctrl: ctrl}
+	mock.recorder = &MockLoginThrottleRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockLoginThrottleRepo) EXPECT() *MockLoginThrottleRepoMockRecorder {
+	return m.recorder
+}
+
+// Block mocks base method.
+func (m *MockLoginThrottleRepo) Block(ctx context.Context, key string, until time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Block", ctx, key, until)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Block indicates an expected call of Block.
+func (mr *MockLoginThrottleRepoMockRecorder) Block(ctx, key, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Block", reflect.TypeOf((*MockLoginThrottleRepo)(nil).Block), ctx, key, until)
+}
+
+// DeleteExpired mocks base method.
+func (m *MockLoginThrottleRepo) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpired", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpired indicates an expected call of DeleteExpired.
+func (mr *MockLoginThrottleRepoMockRecorder) DeleteExpired(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpired", reflect.TypeOf((*MockLoginThrottleRepo)(nil).DeleteExpired), ctx, before)
+}
+
+// Release mocks base method.
+func (m *MockLoginThrottleRepo) Release(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Release", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Release indicates an expected call of Release.
+func (mr *MockLoginThrottleRepoMockRecorder) Release(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Release", reflect.TypeOf((*MockLoginThrottleRepo)(nil).Release), ctx, key)
+}
+
+// Reserve mocks base method.
+func (m *MockLoginThrottleRepo) Reserve(ctx context.Context, key string, window time.Duration, hold func(int) time.Duration) (*model.LoginThrottle, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reserve", ctx, key, window, hold)
+	ret0, _ := ret[0].(*model.LoginThrottle)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Reserve indicates an expected call of Reserve.
+func (mr *MockLoginThrottleRepoMockRecorder) Reserve(ctx, key, window, hold interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reserve", reflect.TypeOf((*MockLoginThrottleRepo)(nil).Reserve), ctx, key, window, hold)
+}
+
+// Reset mocks base method.
+func (m *MockLoginThrottleRepo) Reset(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reset", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLoginThrottleRepoMockRecorder) Reset(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLoginThrottleRepo)(nil).Reset), ctx, key)
+}
+
+// Throttle mocks base method.
+func (m *MockLoginThrottleRepo) Throttle(ctx context.Context, key string) (*model.LoginThrottle, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Throttle", ctx, key)
+	ret0, _ := ret[0].(*model.LoginThrottle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Throttle indicates an expected call of Throttle.
+func (mr *MockLoginThrottleRepoMockRecorder) Throttle(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Throttle", reflect.TypeOf((*MockLoginThrottleRepo)(nil).Throttle), ctx, key)
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: password_reset.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/bjlag/go-loyalty/internal/model"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockPasswordResetRepo is a mock of PasswordResetRepo interface.
+type MockPasswordResetRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockPasswordResetRepoMockRecorder
+}
+
+// MockPasswordResetRepoMockRecorder is the mock recorder for MockPasswordResetRepo.
+type MockPasswordResetRepoMockRecorder struct {
+	mock *MockPasswordResetRepo
+}
+
+// NewMockPasswordResetRepo creates a new mock instance.
+func NewMockPasswordResetRepo(ctrl *gomock.Controller) *MockPasswordResetRepo {
+	mock := &MockPasswordResetRepo{ctrl: ctrl}
+	mock.recorder = &MockPasswordResetRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPasswordResetRepo) EXPECT() *MockPasswordResetRepoMockRecorder {
+	return m.recorder
+}
+
+// Create mocks base method.
+func (m *MockPasswordResetRepo) Create(ctx context.Context, reset *model.PasswordReset) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, reset)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockPasswordResetRepoMockRecorder) Create(ctx, reset interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockPasswordResetRepo)(nil).Create), ctx, reset)
+}
+
+// Use mocks base method.
+func (m *MockPasswordResetRepo) Use(ctx context.Context, tokenHash string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Use", ctx, tokenHash)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Use indicates an expected call of Use.
+func (mr *MockPasswordResetRepoMockRecorder) Use(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Use", reflect.TypeOf((*MockPasswordResetRepo)(nil).Use), ctx, tokenHash)
+}
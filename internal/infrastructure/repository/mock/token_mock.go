@@ -0,0 +1,168 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: token.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	model "github.com/bjlag/go-loyalty/internal/model"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTokenRepo is a mock of TokenRepo interface.
+type MockTokenRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenRepoMockRecorder
+}
+
+// MockTokenRepoMockRecorder is the mock recorder for MockTokenRepo.
+type MockTokenRepoMockRecorder struct {
+	mock *MockTokenRepo
+}
+
+// NewMockTokenRepo creates a new mock instance.
+func NewMockTokenRepo(ctrl *gomock.Controller) *MockTokenRepo {
+	mock := &MockTokenRepo{ctrl: ctrl}
+	mock.recorder = &MockTokenRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenRepo) EXPECT() *MockTokenRepoMockRecorder {
+	return m.recorder
+}
+
+// CreateRefreshToken mocks base method.
+func (m *MockTokenRepo) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateRefreshToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateRefreshToken indicates an expected call of CreateRefreshToken.
+func (mr *MockTokenRepoMockRecorder) CreateRefreshToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateRefreshToken", reflect.TypeOf((*MockTokenRepo)(nil).CreateRefreshToken), ctx, token)
+}
+
+// DeleteExpiredRefreshTokens mocks base method.
+func (m *MockTokenRepo) DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRefreshTokens", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredRefreshTokens indicates an expected call of DeleteExpiredRefreshTokens.
+func (mr *MockTokenRepoMockRecorder) DeleteExpiredRefreshTokens(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRefreshTokens", reflect.TypeOf((*MockTokenRepo)(nil).DeleteExpiredRefreshTokens), ctx, before)
+}
+
+// DeleteExpiredRevocations mocks base method.
+func (m *MockTokenRepo) DeleteExpiredRevocations(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRevocations", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredRevocations indicates an expected call of DeleteExpiredRevocations.
+func (mr *MockTokenRepoMockRecorder) DeleteExpiredRevocations(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRevocations", reflect.TypeOf((*MockTokenRepo)(nil).DeleteExpiredRevocations), ctx, before)
+}
+
+// IsRevoked mocks base method.
+func (m *MockTokenRepo) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsRevoked", ctx, jti)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsRevoked indicates an expected call of IsRevoked.
+func (mr *MockTokenRepoMockRecorder) IsRevoked(ctx, jti interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsRevoked", reflect.TypeOf((*MockTokenRepo)(nil).IsRevoked), ctx, jti)
+}
+
+// RefreshTokenByHash mocks base method.
+func (m *MockTokenRepo) RefreshTokenByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokenByHash", ctx, hash)
+	ret0, _ := ret[0].(*model.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokenByHash indicates an expected call of RefreshTokenByHash.
+func (mr *MockTokenRepoMockRecorder) RefreshTokenByHash(ctx, hash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokenByHash", reflect.TypeOf((*MockTokenRepo)(nil).RefreshTokenByHash), ctx, hash)
+}
+
+// RevokeByAccessJTI mocks base method.
+func (m *MockTokenRepo) RevokeByAccessJTI(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeByAccessJTI", ctx, jti, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeByAccessJTI indicates an expected call of RevokeByAccessJTI.
+func (mr *MockTokenRepoMockRecorder) RevokeByAccessJTI(ctx, jti, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeByAccessJTI", reflect.TypeOf((*MockTokenRepo)(nil).RevokeByAccessJTI), ctx, jti, expiresAt)
+}
+
+// RevokeFamily mocks base method.
+func (m *MockTokenRepo) RevokeFamily(ctx context.Context, familyGUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeFamily", ctx, familyGUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeFamily indicates an expected call of RevokeFamily.
+func (mr *MockTokenRepoMockRecorder) RevokeFamily(ctx, familyGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeFamily", reflect.TypeOf((*MockTokenRepo)(nil).RevokeFamily), ctx, familyGUID)
+}
+
+// RevokeUser mocks base method.
+func (m *MockTokenRepo) RevokeUser(ctx context.Context, userGUID string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUser", ctx, userGUID)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RevokeUser indicates an expected call of RevokeUser.
+func (mr *MockTokenRepoMockRecorder) RevokeUser(ctx, userGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUser", reflect.TypeOf((*MockTokenRepo)(nil).RevokeUser), ctx, userGUID)
+}
+
+// Rotate mocks base method.
+func (m *MockTokenRepo) Rotate(ctx context.Context, usedGUID string, next *model.RefreshToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", ctx, usedGUID, next)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockTokenRepoMockRecorder) Rotate(ctx, usedGUID, next interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockTokenRepo)(nil).Rotate), ctx, usedGUID, next)
+}
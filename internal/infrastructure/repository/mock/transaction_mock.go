@@ -0,0 +1,80 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: transaction.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	model "github.com/bjlag/go-loyalty/internal/model"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTransactionRepo is a mock of TransactionRepo interface.
+type MockTransactionRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockTransactionRepoMockRecorder
+}
+
+// MockTransactionRepoMockRecorder is the mock recorder for MockTransactionRepo.
+type MockTransactionRepoMockRecorder struct {
+	mock *MockTransactionRepo
+}
+
+// NewMockTransactionRepo creates a new mock instance.
+func NewMockTransactionRepo(ctrl *gomock.Controller) *MockTransactionRepo {
+	mock := &MockTransactionRepo{ctrl: ctrl}
+	mock.recorder = &MockTransactionRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTransactionRepo) EXPECT() *MockTransactionRepoMockRecorder {
+	return m.recorder
+}
+
+// Statement mocks base method.
+func (m *MockTransactionRepo) Statement(ctx context.Context, accountGUID string, filter model.StatementFilter) ([]model.StatementEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Statement", ctx, accountGUID, filter)
+	ret0, _ := ret[0].([]model.StatementEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Statement indicates an expected call of Statement.
+func (mr *MockTransactionRepoMockRecorder) Statement(ctx, accountGUID, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Statement", reflect.TypeOf((*MockTransactionRepo)(nil).Statement), ctx, accountGUID, filter)
+}
+
+// StreamStatement mocks base method.
+func (m *MockTransactionRepo) StreamStatement(ctx context.Context, accountGUID string, filter model.StatementFilter, fn func(model.StatementEntry) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StreamStatement", ctx, accountGUID, filter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// StreamStatement indicates an expected call of StreamStatement.
+func (mr *MockTransactionRepoMockRecorder) StreamStatement(ctx, accountGUID, filter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StreamStatement", reflect.TypeOf((*MockTransactionRepo)(nil).StreamStatement), ctx, accountGUID, filter, fn)
+}
+
+// Withdrawals mocks base method.
+func (m *MockTransactionRepo) Withdrawals(ctx context.Context, accountGUID string) ([]model.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Withdrawals", ctx, accountGUID)
+	ret0, _ := ret[0].([]model.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Withdrawals indicates an expected call of Withdrawals.
+func (mr *MockTransactionRepoMockRecorder) Withdrawals(ctx, accountGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Withdrawals", reflect.TypeOf((*MockTransactionRepo)(nil).Withdrawals), ctx, accountGUID)
+}
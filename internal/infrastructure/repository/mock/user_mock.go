@@ -35,6 +35,36 @@ func (m *MockUserRepository) EXPECT() *MockUserRepositoryMockRecorder {
 	return m.recorder
 }
 
+// ChangePassword mocks base method.
+func (m *MockUserRepository) ChangePassword(ctx context.Context, guid, password string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, guid, password)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockUserRepositoryMockRecorder) ChangePassword(ctx, guid, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockUserRepository)(nil).ChangePassword), ctx, guid, password)
+}
+
+// FindByGUID mocks base method.
+func (m *MockUserRepository) FindByGUID(ctx context.Context, guid string) (*model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindByGUID", ctx, guid)
+	ret0, _ := ret[0].(*model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindByGUID indicates an expected call of FindByGUID.
+func (mr *MockUserRepositoryMockRecorder) FindByGUID(ctx, guid interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindByGUID", reflect.TypeOf((*MockUserRepository)(nil).FindByGUID), ctx, guid)
+}
+
 // FindByLogin mocks base method.
 func (m *MockUserRepository) FindByLogin(ctx context.Context, login string) (*model.User, error) {
 	m.ctrl.T.Helper()
@@ -53,7 +83,7 @@ func (mr *MockUserRepositoryMockRecorder) FindByLogin(ctx, login interface{}) *g
 // Insert mocks base method.
 func (m *MockUserRepository) Insert(ctx context.Context, user *model.User) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", ctx, user)
+	ret := m.ctrl.Call(m, "Insert", ctx, user)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
@@ -61,5 +91,19 @@ func (m *MockUserRepository) Insert(ctx context.Context, user *model.User) error
 // Insert indicates an expected call of Insert.
 func (mr *MockUserRepositoryMockRecorder) Insert(ctx, user interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserRepository)(nil).Insert), ctx, user)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Insert", reflect.TypeOf((*MockUserRepository)(nil).Insert), ctx, user)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, guid, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, guid, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockUserRepositoryMockRecorder) UpdatePassword(ctx, guid, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockUserRepository)(nil).UpdatePassword), ctx, guid, password)
 }
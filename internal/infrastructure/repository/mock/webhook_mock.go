@@ -0,0 +1,236 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webhook.go
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	model "github.com/bjlag/go-loyalty/internal/model"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockWebhookRepo is a mock of WebhookRepo interface.
+type MockWebhookRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepoMockRecorder
+}
+
+// MockWebhookRepoMockRecorder is the mock recorder for MockWebhookRepo.
+type MockWebhookRepoMockRecorder struct {
+	mock *MockWebhookRepo
+}
+
+// NewMockWebhookRepo creates a new mock instance.
+func NewMockWebhookRepo(ctrl *gomock.Controller) *MockWebhookRepo {
+	mock := &MockWebhookRepo{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepo) EXPECT() *MockWebhookRepoMockRecorder {
+	return m.recorder
+}
+
+// AddDelivery mocks base method.
+func (m *MockWebhookRepo) AddDelivery(ctx context.Context, webhookGUID string, event model.Event, payload []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddDelivery", ctx, webhookGUID, event, payload)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddDelivery indicates an expected call of AddDelivery.
+func (mr *MockWebhookRepoMockRecorder) AddDelivery(ctx, webhookGUID, event, payload interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddDelivery", reflect.TypeOf((*MockWebhookRepo)(nil).AddDelivery), ctx, webhookGUID, event, payload)
+}
+
+// Create mocks base method.
+func (m *MockWebhookRepo) Create(ctx context.Context, webhook *model.Webhook) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Create", ctx, webhook)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockWebhookRepoMockRecorder) Create(ctx, webhook interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockWebhookRepo)(nil).Create), ctx, webhook)
+}
+
+// Delete mocks base method.
+func (m *MockWebhookRepo) Delete(ctx context.Context, guid, userGUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Delete", ctx, guid, userGUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Delete indicates an expected call of Delete.
+func (mr *MockWebhookRepoMockRecorder) Delete(ctx, guid, userGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockWebhookRepo)(nil).Delete), ctx, guid, userGUID)
+}
+
+// Deliveries mocks base method.
+func (m *MockWebhookRepo) Deliveries(ctx context.Context, webhookGUID string, limit int) ([]model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Deliveries", ctx, webhookGUID, limit)
+	ret0, _ := ret[0].([]model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Deliveries indicates an expected call of Deliveries.
+func (mr *MockWebhookRepoMockRecorder) Deliveries(ctx, webhookGUID, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Deliveries", reflect.TypeOf((*MockWebhookRepo)(nil).Deliveries), ctx, webhookGUID, limit)
+}
+
+// LeaseDue mocks base method.
+func (m *MockWebhookRepo) LeaseDue(ctx context.Context, limit int, ttl time.Duration) ([]model.WebhookDispatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LeaseDue", ctx, limit, ttl)
+	ret0, _ := ret[0].([]model.WebhookDispatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LeaseDue indicates an expected call of LeaseDue.
+func (mr *MockWebhookRepoMockRecorder) LeaseDue(ctx, limit, ttl interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LeaseDue", reflect.TypeOf((*MockWebhookRepo)(nil).LeaseDue), ctx, limit, ttl)
+}
+
+// MarkDelivered mocks base method.
+func (m *MockWebhookRepo) MarkDelivered(ctx context.Context, guid string, statusCode int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDelivered", ctx, guid, statusCode)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkDelivered indicates an expected call of MarkDelivered.
+func (mr *MockWebhookRepoMockRecorder) MarkDelivered(ctx, guid, statusCode interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDelivered", reflect.TypeOf((*MockWebhookRepo)(nil).MarkDelivered), ctx, guid, statusCode)
+}
+
+// MarkFailed mocks base method.
+func (m *MockWebhookRepo) MarkFailed(ctx context.Context, guid string, statusCode int, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkFailed", ctx, guid, statusCode, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkFailed indicates an expected call of MarkFailed.
+func (mr *MockWebhookRepoMockRecorder) MarkFailed(ctx, guid, statusCode, lastError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkFailed", reflect.TypeOf((*MockWebhookRepo)(nil).MarkFailed), ctx, guid, statusCode, lastError)
+}
+
+// Redeliver mocks base method.
+func (m *MockWebhookRepo) Redeliver(ctx context.Context, guid, userGUID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", ctx, guid, userGUID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockWebhookRepoMockRecorder) Redeliver(ctx, guid, userGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockWebhookRepo)(nil).Redeliver), ctx, guid, userGUID)
+}
+
+// ScheduleRetry mocks base method.
+func (m *MockWebhookRepo) ScheduleRetry(ctx context.Context, guid string, nextAttemptAt time.Time, statusCode int, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ScheduleRetry", ctx, guid, nextAttemptAt, statusCode, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ScheduleRetry indicates an expected call of ScheduleRetry.
+func (mr *MockWebhookRepoMockRecorder) ScheduleRetry(ctx, guid, nextAttemptAt, statusCode, lastError interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ScheduleRetry", reflect.TypeOf((*MockWebhookRepo)(nil).ScheduleRetry), ctx, guid, nextAttemptAt, statusCode, lastError)
+}
+
+// WebhookByGUID mocks base method.
+func (m *MockWebhookRepo) WebhookByGUID(ctx context.Context, guid, userGUID string) (*model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhookByGUID", ctx, guid, userGUID)
+	ret0, _ := ret[0].(*model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhookByGUID indicates an expected call of WebhookByGUID.
+func (mr *MockWebhookRepoMockRecorder) WebhookByGUID(ctx, guid, userGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhookByGUID", reflect.TypeOf((*MockWebhookRepo)(nil).WebhookByGUID), ctx, guid, userGUID)
+}
+
+// WebhooksByUser mocks base method.
+func (m *MockWebhookRepo) WebhooksByUser(ctx context.Context, userGUID string) ([]model.Webhook, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebhooksByUser", ctx, userGUID)
+	ret0, _ := ret[0].([]model.Webhook)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebhooksByUser indicates an expected call of WebhooksByUser.
+func (mr *MockWebhookRepoMockRecorder) WebhooksByUser(ctx, userGUID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebhooksByUser", reflect.TypeOf((*MockWebhookRepo)(nil).WebhooksByUser), ctx, userGUID)
+}
+
+// MockrowScanner is a mock of rowScanner interface.
+type MockrowScanner struct {
+	ctrl     *gomock.Controller
+	recorder *MockrowScannerMockRecorder
+}
+
+// MockrowScannerMockRecorder is the mock recorder for MockrowScanner.
+type MockrowScannerMockRecorder struct {
+	mock *MockrowScanner
+}
+
+// NewMockrowScanner creates a new mock instance.
+func NewMockrowScanner(ctrl *gomock.Controller) *MockrowScanner {
+	mock := &MockrowScanner{ctrl: ctrl}
+	mock.recorder = &MockrowScannerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockrowScanner) EXPECT() *MockrowScannerMockRecorder {
+	return m.recorder
+}
+
+// Scan mocks base method.
+func (m *MockrowScanner) Scan(dest ...any) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range dest {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Scan", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Scan indicates an expected call of Scan.
+func (mr *MockrowScannerMockRecorder) Scan(dest ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Scan", reflect.TypeOf((*MockrowScanner)(nil).Scan), dest...)
+}
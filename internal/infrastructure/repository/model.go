@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"database/sql"
 	"time"
 
 	"github.com/bjlag/go-loyalty/internal/model"
@@ -29,11 +30,14 @@ func (u user) export() *model.User {
 }
 
 type accrual struct {
-	OrderNumber string    `db:"order_number"`
-	UserGUID    string    `db:"user_guid"`
-	Status      float64   `db:"status"`
-	Accrual     float64   `db:"accrual"`
-	UploadedAt  time.Time `db:"uploaded_at"`
+	OrderNumber string       `db:"order_number"`
+	UserGUID    string       `db:"user_guid"`
+	Status      float64      `db:"status"`
+	Accrual     model.Points `db:"accrual"`
+	UploadedAt  time.Time    `db:"uploaded_at"`
+	Attempts    int          `db:"attempts"`
+	LastError   string       `db:"last_error"`
+	GaveUp      bool         `db:"gave_up"`
 }
 
 func (a accrual) export() *model.Accrual {
@@ -43,6 +47,9 @@ func (a accrual) export() *model.Accrual {
 		Status:      model.AccrualStatus(a.Status),
 		Accrual:     a.Accrual,
 		UploadedAt:  a.UploadedAt,
+		Attempts:    a.Attempts,
+		LastError:   a.LastError,
+		GaveUp:      a.GaveUp,
 	}
 }
 
@@ -51,7 +58,7 @@ type transaction struct {
 	AccountGUID string                `db:"account_guid"`
 	OrderNumber string                `db:"order_number"`
 	Type        model.TransactionType `db:"type"`
-	Sum         float64               `db:"sum"`
+	Sum         model.Points          `db:"sum"`
 	ProcessedAt time.Time             `db:"processed_at"`
 }
 
@@ -65,3 +72,122 @@ func (t transaction) export() *model.Transaction {
 		ProcessedAt: t.ProcessedAt,
 	}
 }
+
+type idempotencyKey struct {
+	Key                 string         `db:"key"`
+	UserGUID            string         `db:"user_guid"`
+	RequestHash         string         `db:"request_hash"`
+	ResponseStatus      sql.NullInt32  `db:"response_status"`
+	ResponseContentType sql.NullString `db:"response_content_type"`
+	ResponseBody        []byte         `db:"response_body"`
+	CreatedAt           time.Time      `db:"created_at"`
+	LockedUntil         sql.NullTime   `db:"locked_until"`
+	LockToken           sql.NullString `db:"lock_token"`
+}
+
+func (k idempotencyKey) export() *model.IdempotencyKey {
+	return &model.IdempotencyKey{
+		Key:                 k.Key,
+		UserGUID:            k.UserGUID,
+		RequestHash:         k.RequestHash,
+		ResponseStatus:      int(k.ResponseStatus.Int32),
+		ResponseContentType: k.ResponseContentType.String,
+		ResponseBody:        k.ResponseBody,
+		CreatedAt:           k.CreatedAt,
+		LockedUntil:         k.LockedUntil.Time,
+		LockToken:           k.LockToken.String,
+	}
+}
+
+type webhookDelivery struct {
+	GUID           string               `db:"guid"`
+	WebhookGUID    string               `db:"webhook_guid"`
+	EventGUID      string               `db:"event_guid"`
+	EventType      model.EventType      `db:"event_type"`
+	Payload        []byte               `db:"payload"`
+	Status         model.DeliveryStatus `db:"status"`
+	Attempts       int                  `db:"attempts"`
+	NextAttemptAt  time.Time            `db:"next_attempt_at"`
+	LastStatusCode int                  `db:"last_status_code"`
+	LastError      string               `db:"last_error"`
+	CreatedAt      time.Time            `db:"created_at"`
+	DeliveredAt    sql.NullTime         `db:"delivered_at"`
+}
+
+func (d webhookDelivery) export() *model.WebhookDelivery {
+	m := &model.WebhookDelivery{
+		GUID:           d.GUID,
+		WebhookGUID:    d.WebhookGUID,
+		EventGUID:      d.EventGUID,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		NextAttemptAt:  d.NextAttemptAt,
+		LastStatusCode: d.LastStatusCode,
+		LastError:      d.LastError,
+		CreatedAt:      d.CreatedAt,
+	}
+
+	if d.DeliveredAt.Valid {
+		m.DeliveredAt = &d.DeliveredAt.Time
+	}
+
+	return m
+}
+
+type refreshToken struct {
+	GUID            string       `db:"guid"`
+	FamilyGUID      string       `db:"family_guid"`
+	UserGUID        string       `db:"user_guid"`
+	TokenHash       string       `db:"token_hash"`
+	AccessJTI       string       `db:"access_jti"`
+	AccessExpiresAt time.Time    `db:"access_expires_at"`
+	CreatedAt       time.Time    `db:"created_at"`
+	ExpiresAt       time.Time    `db:"expires_at"`
+	UsedAt          sql.NullTime `db:"used_at"`
+	RevokedAt       sql.NullTime `db:"revoked_at"`
+}
+
+func (t refreshToken) export() *model.RefreshToken {
+	m := &model.RefreshToken{
+		GUID:            t.GUID,
+		FamilyGUID:      t.FamilyGUID,
+		UserGUID:        t.UserGUID,
+		TokenHash:       t.TokenHash,
+		AccessJTI:       t.AccessJTI,
+		AccessExpiresAt: t.AccessExpiresAt,
+		CreatedAt:       t.CreatedAt,
+		ExpiresAt:       t.ExpiresAt,
+	}
+
+	if t.UsedAt.Valid {
+		m.UsedAt = &t.UsedAt.Time
+	}
+	if t.RevokedAt.Valid {
+		m.RevokedAt = &t.RevokedAt.Time
+	}
+
+	return m
+}
+
+type loginThrottle struct {
+	Key             string       `db:"key"`
+	Failures        int          `db:"failures"`
+	WindowStartedAt time.Time    `db:"window_started_at"`
+	BlockedUntil    sql.NullTime `db:"blocked_until"`
+}
+
+func (t loginThrottle) export() *model.LoginThrottle {
+	m := &model.LoginThrottle{
+		Key:             t.Key,
+		Failures:        t.Failures,
+		WindowStartedAt: t.WindowStartedAt,
+	}
+
+	if t.BlockedUntil.Valid {
+		m.BlockedUntil = t.BlockedUntil.Time
+	}
+
+	return m
+}
@@ -0,0 +1,264 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/outbox_mock.go
+
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// outboxClaimLockKey is the advisory lock that serialises claims of replicas, so two of them never claim events of
+// the same user.
+const outboxClaimLockKey = 7_316_001
+
+// outboxUserLockClass is the first key of the per-user advisory lock taken before an event is written. Writers of
+// one user's events queue on it, so their ids grow in commit order.
+const outboxUserLockClass = 7_316_002
+
+type OutboxRepo interface {
+	Claim(ctx context.Context, limit int, ttl time.Duration) ([]model.Event, error)
+	MarkPublished(ctx context.Context, ids []int64) error
+	Fail(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time, park bool) error
+	Release(ctx context.Context, ids []int64) error
+}
+
+type OutboxPG struct {
+	db *sqlx.DB
+}
+
+func NewOutboxPG(db *sqlx.DB) *OutboxPG {
+	return &OutboxPG{
+		db: db,
+	}
+}
+
+// Claim leases up to limit unpublished events to the caller for ttl, in the order they were written. Events of a
+// user are skipped while another claim of that user is alive or while an earlier event of the user waits for its
+// next attempt, so every user gets their events in order and a failing user doesn't hold back the others. Parked
+// events are never claimed. An expired claim is taken over, so delivery is at least once.
+func (r OutboxPG) Claim(ctx context.Context, limit int, ttl time.Duration) ([]model.Event, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, outboxClaimLockKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take outbox claim lock: %w", err)
+	}
+
+	query := `
+		UPDATE outbox
+		SET claimed_until = now() + $1 * interval '1 millisecond'
+		WHERE id IN (
+			SELECT o.id
+			FROM outbox o
+			WHERE o.published_at IS NULL AND o.parked_at IS NULL
+			  AND NOT EXISTS (
+				SELECT 1
+				FROM outbox b
+				WHERE b.user_guid = o.user_guid AND b.published_at IS NULL AND b.parked_at IS NULL
+				  AND (b.claimed_until > now() OR (b.id <= o.id AND b.next_attempt_at > now()))
+			  )
+			ORDER BY o.id
+			LIMIT $2
+		)
+		RETURNING id, guid, type, user_guid, payload, created_at, attempts
+	`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, ttl.Milliseconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.Event
+	for rows.Next() {
+		var e model.Event
+		err = rows.Scan(&e.ID, &e.GUID, &e.Type, &e.UserGUID, &e.Payload, &e.CreatedAt, &e.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		result = append(result, e)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	// RETURNING doesn't keep the order of the subquery
+	slices.SortFunc(result, func(a, b model.Event) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	return result, nil
+}
+
+func (r OutboxPG) MarkPublished(ctx context.Context, ids []int64) error {
+	return r.updateClaimed(ctx, `UPDATE outbox SET published_at = now(), claimed_until = NULL`, ids)
+}
+
+// Release returns claimed events that were not tried to the outbox.
+func (r OutboxPG) Release(ctx context.Context, ids []int64) error {
+	return r.updateClaimed(ctx, `UPDATE outbox SET claimed_until = NULL`, ids)
+}
+
+func (r OutboxPG) updateClaimed(ctx context.Context, update string, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(update + ` WHERE id IN (`)
+
+	args := make([]any, 0, len(ids))
+	for i, id := range ids {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		args = append(args, id)
+		query.WriteString(fmt.Sprintf("$%d", len(args)))
+	}
+	query.WriteString(`)`)
+
+	_, err := r.db.ExecContext(ctx, query.String(), args...)
+	if err != nil {
+		return fmt.Errorf("failed to update events: %w", err)
+	}
+
+	return nil
+}
+
+// Fail records a failed attempt to publish the event. The event and the later events of its user wait until
+// nextAttemptAt; a parked event is not tried again and doesn't hold back the others.
+func (r OutboxPG) Fail(ctx context.Context, id int64, lastError string, nextAttemptAt time.Time, park bool) error {
+	query := `
+		UPDATE outbox
+		SET attempts = attempts + 1, last_error = $1, next_attempt_at = $2, claimed_until = NULL,
+		    parked_at = CASE WHEN $3 THEN now() END
+		WHERE id = $4
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, lastError, nextAttemptAt, park, id)
+	if err != nil {
+		return fmt.Errorf("failed to record event error: %w", err)
+	}
+
+	return nil
+}
+
+type accrualEventPayload struct {
+	Order   string        `json:"order"`
+	Status  string        `json:"status"`
+	Accrual *model.Points `json:"accrual,omitempty"`
+	Reason  string        `json:"reason,omitempty"`
+}
+
+type withdrawalEventPayload struct {
+	Order       string       `json:"order"`
+	Sum         model.Points `json:"sum"`
+	Transaction string       `json:"transaction"`
+	ProcessedAt time.Time    `json:"processed_at"`
+}
+
+// addAccrualEventTx records that the order has left work. Orders still in work produce no event.
+func addAccrualEventTx(tx *sql.Tx, userGUID, orderNumber string, status model.AccrualStatus, accrual model.Points, reason string) error {
+	payload := accrualEventPayload{
+		Order:  orderNumber,
+		Status: strings.ToUpper(status.String()),
+	}
+
+	var eventType model.EventType
+	switch status {
+	case model.Processed:
+		eventType = model.EventAccrualProcessed
+		payload.Accrual = &accrual
+	case model.Invalid:
+		eventType = model.EventAccrualInvalid
+		payload.Reason = reason
+	default:
+		return nil
+	}
+
+	return addEventTx(tx, eventType, userGUID, payload)
+}
+
+func addGaveUpEventTx(tx *sql.Tx, userGUID, orderNumber string, status model.AccrualStatus, reason string) error {
+	return addEventTx(tx, model.EventAccrualGaveUp, userGUID, accrualEventPayload{
+		Order:  orderNumber,
+		Status: strings.ToUpper(status.String()),
+		Reason: reason,
+	})
+}
+
+func addWithdrawalEventTx(tx *sql.Tx, transaction model.Transaction) error {
+	return addEventTx(tx, model.EventBalanceWithdrawn, transaction.AccountGUID, withdrawalEventPayload{
+		Order:       transaction.OrderNumber,
+		Sum:         transaction.Sum,
+		Transaction: transaction.GUID,
+		ProcessedAt: transaction.ProcessedAt,
+	})
+}
+
+func addEventTx(tx *sql.Tx, eventType model.EventType, userGUID string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event payload: %w", err)
+	}
+
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext($2))`, outboxUserLockClass, userGUID)
+	if err != nil {
+		return fmt.Errorf("failed to take outbox user lock: %w", err)
+	}
+
+	query := `INSERT INTO outbox (type, user_guid, payload) VALUES ($1, $2, $3)`
+	stmt, err := tx.Prepare(query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert event query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.Exec(eventType, userGUID, data)
+	if err != nil {
+		return fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	return nil
+}
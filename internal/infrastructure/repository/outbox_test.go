@@ -0,0 +1,135 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestOutboxPG_Relay(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userA, userB := uuid.NewString(), uuid.NewString()
+	createUser(t, db, userA)
+	createUser(t, db, userB)
+
+	accrualRepo := repository.NewAccrualPG(db)
+
+	fundAccount(t, db, userA, model.NewPoints(100, 0))
+	withdraw := model.NewWithdrawTransaction(uuid.NewString(), userA, uuid.NewString()[:20], model.NewPoints(40, 0), time.Now())
+	require.NoError(t, accrualRepo.WithdrawBalance(ctx, withdraw))
+
+	invalid := model.NewAccrual(uuid.NewString()[:20], userB)
+	require.NoError(t, accrualRepo.Create(ctx, invalid))
+	require.NoError(t, accrualRepo.UpdateStatus(ctx, invalid.OrderNumber, model.Processing)) // still in work, no event
+	require.NoError(t, accrualRepo.UpdateStatus(ctx, invalid.OrderNumber, model.Invalid))
+
+	repo := repository.NewOutboxPG(db)
+
+	// events of other tests share the database, so only the ones of these users are checked
+	ours := map[string]bool{userA: true, userB: true}
+	relay := func(fail func(model.Event) bool, park bool) []model.Event {
+		var published []model.Event
+		for {
+			events, err := repo.Claim(ctx, 10000, time.Minute)
+			require.NoError(t, err)
+
+			if len(events) == 0 {
+				return published
+			}
+
+			var ids []int64
+			for _, e := range events {
+				if ours[e.UserGUID] && fail != nil && fail(e) {
+					require.NoError(t, repo.Fail(ctx, e.ID, "publish error", time.Now().Add(time.Hour), park))
+					continue
+				}
+				if ours[e.UserGUID] {
+					published = append(published, e)
+				}
+				ids = append(ids, e.ID)
+			}
+			require.NoError(t, repo.MarkPublished(ctx, ids))
+		}
+	}
+
+	t.Run("failure_holds_back_user_events", func(t *testing.T) {
+		published := relay(func(e model.Event) bool {
+			return e.UserGUID == userA && e.Type == model.EventAccrualProcessed
+		}, false)
+
+		require.Len(t, published, 1)
+		assert.Equal(t, model.EventAccrualInvalid, published[0].Type)
+		assert.Equal(t, userB, published[0].UserGUID)
+	})
+
+	t.Run("retried_in_order", func(t *testing.T) {
+		_, err := db.ExecContext(ctx, "UPDATE outbox SET next_attempt_at = now() WHERE user_guid = $1", userA)
+		require.NoError(t, err)
+
+		published := relay(nil, false)
+
+		require.Len(t, published, 2)
+		assert.Equal(t, model.EventAccrualProcessed, published[0].Type)
+		assert.Equal(t, 1, published[0].Attempts)
+		assert.Equal(t, model.EventBalanceWithdrawn, published[1].Type)
+
+		var payload struct {
+			Order string       `json:"order"`
+			Sum   model.Points `json:"sum"`
+		}
+		require.NoError(t, json.Unmarshal(published[1].Payload, &payload))
+		assert.Equal(t, withdraw.OrderNumber, payload.Order)
+		assert.Equal(t, model.NewPoints(40, 0), payload.Sum)
+	})
+
+	t.Run("published_once", func(t *testing.T) {
+		assert.Empty(t, relay(nil, false))
+	})
+
+	t.Run("claimed_events_are_not_claimed_again", func(t *testing.T) {
+		accrual := model.NewAccrual(uuid.NewString()[:20], userB)
+		require.NoError(t, accrualRepo.Create(ctx, accrual))
+		require.NoError(t, accrualRepo.UpdateStatus(ctx, accrual.OrderNumber, model.Invalid))
+
+		first, err := repo.Claim(ctx, 10000, time.Minute)
+		require.NoError(t, err)
+		require.True(t, slices.ContainsFunc(first, func(e model.Event) bool { return e.UserGUID == userB }))
+
+		second, err := repo.Claim(ctx, 10000, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, slices.ContainsFunc(second, func(e model.Event) bool { return e.UserGUID == userB }))
+
+		var ids []int64
+		for _, e := range first {
+			ids = append(ids, e.ID)
+		}
+		require.NoError(t, repo.Release(ctx, ids))
+	})
+
+	t.Run("parked_event_lets_later_ones_go", func(t *testing.T) {
+		accrual := model.NewAccrual(uuid.NewString()[:20], userB)
+		require.NoError(t, accrualRepo.Create(ctx, accrual))
+		require.NoError(t, accrualRepo.UpdateStatus(ctx, accrual.OrderNumber, model.Invalid))
+
+		published := relay(func(e model.Event) bool {
+			return e.Attempts == 0 && e.UserGUID == userB
+		}, true)
+		assert.Empty(t, published)
+
+		// the first event of userB is parked, the second one is not held back by it
+		published = relay(nil, false)
+		require.Len(t, published, 1)
+		assert.Equal(t, userB, published[0].UserGUID)
+	})
+}
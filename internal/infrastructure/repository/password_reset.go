@@ -0,0 +1,92 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/password_reset_mock.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type PasswordResetRepo interface {
+	Create(ctx context.Context, reset *model.PasswordReset) error
+	// Use marks the reset with the token hash as used and returns the GUID of its user, empty if there is no such
+	// reset, or it is used or expired. Other resets of the user are used up too, so only one of them works.
+	Use(ctx context.Context, tokenHash string) (string, error)
+}
+
+type PasswordResetPG struct {
+	db *sqlx.DB
+}
+
+func NewPasswordResetPG(db *sqlx.DB) *PasswordResetPG {
+	return &PasswordResetPG{
+		db: db,
+	}
+}
+
+func (r PasswordResetPG) Create(ctx context.Context, reset *model.PasswordReset) error {
+	query := `
+		INSERT INTO password_resets (guid, user_guid, token_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, reset.GUID, reset.UserGUID, reset.TokenHash, reset.CreatedAt, reset.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save password reset: %w", err)
+	}
+
+	return nil
+}
+
+func (r PasswordResetPG) Use(ctx context.Context, tokenHash string) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	// the condition on used_at makes a concurrent use of the same token wait and then find nothing
+	query := `
+		UPDATE password_resets
+		SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_guid
+	`
+	var userGUID string
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&userGUID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("failed to use password reset: %w", err)
+	}
+
+	query = "UPDATE password_resets SET used_at = now() WHERE user_guid = $1 AND used_at IS NULL"
+	_, err = tx.ExecContext(ctx, query, userGUID)
+	if err != nil {
+		return "", fmt.Errorf("failed to use other password resets: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userGUID, nil
+}
@@ -0,0 +1,63 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestPasswordResetPG_Use(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewPasswordResetPG(db)
+
+	newReset := func(ttl time.Duration) *model.PasswordReset {
+		reset := model.NewPasswordReset(uuid.NewString(), userGUID, uuid.NewString()+uuid.NewString()[:28], ttl)
+		require.NoError(t, repo.Create(ctx, reset))
+		return reset
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		reset := newReset(-time.Minute)
+
+		got, err := repo.Use(ctx, reset.TokenHash)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	first := newReset(time.Hour)
+	second := newReset(time.Hour)
+
+	got, err := repo.Use(ctx, first.TokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, userGUID, got)
+
+	t.Run("used_once", func(t *testing.T) {
+		got, err := repo.Use(ctx, first.TokenHash)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("others_used_up", func(t *testing.T) {
+		got, err := repo.Use(ctx, second.TokenHash)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		got, err := repo.Use(ctx, uuid.NewString())
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	})
+}
@@ -0,0 +1,395 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/token_mock.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// ErrRefreshTokenUsed the token has been used or revoked by a concurrent request.
+var ErrRefreshTokenUsed = errors.New("refresh token already used")
+
+type TokenRepo interface {
+	RefreshTokenByHash(ctx context.Context, hash string) (*model.RefreshToken, error)
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+
+	CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	Rotate(ctx context.Context, usedGUID string, next *model.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyGUID string) error
+	RevokeByAccessJTI(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeUser returns the jti of the access tokens that were still valid.
+	RevokeUser(ctx context.Context, userGUID string) ([]string, error)
+
+	// DeleteExpiredRefreshTokens deletes the families whose every token has expired before the time.
+	DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error)
+	// DeleteExpiredRevocations deletes revoked access tokens that have expired before the time.
+	DeleteExpiredRevocations(ctx context.Context, before time.Time) (int64, error)
+}
+
+type TokenPG struct {
+	db *sqlx.DB
+}
+
+func NewTokenPG(db *sqlx.DB) *TokenPG {
+	return &TokenPG{
+		db: db,
+	}
+}
+
+// RefreshTokenByHash returns the token with the hash, nil if there is no such token.
+func (r TokenPG) RefreshTokenByHash(ctx context.Context, hash string) (*model.RefreshToken, error) {
+	query := `
+		SELECT guid, family_guid, user_guid, token_hash, access_jti, access_expires_at, created_at, expires_at, used_at, revoked_at
+		FROM refresh_tokens
+		WHERE token_hash = $1
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var m refreshToken
+	err = stmt.QueryRowContext(ctx, hash).Scan(
+		&m.GUID, &m.FamilyGUID, &m.UserGUID, &m.TokenHash, &m.AccessJTI, &m.AccessExpiresAt,
+		&m.CreatedAt, &m.ExpiresAt, &m.UsedAt, &m.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	return m.export(), nil
+}
+
+func (r TokenPG) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := "SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var revoked bool
+	err = stmt.QueryRowContext(ctx, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// CreateRefreshToken saves the first token of a new family.
+func (r TokenPG) CreateRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = addRefreshTokenTx(ctx, tx, token)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// Rotate marks the token used and saves the next one of the family. Only one of concurrent rotations of the same
+// token succeeds, the others get ErrRefreshTokenUsed.
+func (r TokenPG) Rotate(ctx context.Context, usedGUID string, next *model.RefreshToken) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+		UPDATE refresh_tokens
+		SET used_at = now()
+		WHERE guid = $1 AND used_at IS NULL AND revoked_at IS NULL
+	`
+	stmt, err := tx.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	res, err := stmt.ExecContext(ctx, usedGUID)
+	if err != nil {
+		return fmt.Errorf("failed to mark refresh token used: %w", err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", ErrRefreshTokenUsed, usedGUID)
+	}
+
+	err = addRefreshTokenTx(ctx, tx, next)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes all refresh tokens of the family and the access tokens issued with them.
+func (r TokenPG) RevokeFamily(ctx context.Context, familyGUID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = revokeFamilyTx(ctx, tx, familyGUID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeByAccessJTI revokes the access token and the family of the refresh token issued with it, i.e. ends the
+// session the access token belongs to.
+func (r TokenPG) RevokeByAccessJTI(ctx context.Context, jti string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	query := `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`
+	_, err = tx.ExecContext(ctx, query, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
+	}
+
+	var familyGUID string
+	err = tx.QueryRowContext(ctx, "SELECT family_guid FROM refresh_tokens WHERE access_jti = $1", jti).Scan(&familyGUID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to scan: %w", err)
+	}
+
+	if familyGUID != "" {
+		err = revokeFamilyTx(ctx, tx, familyGUID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUser revokes all refresh tokens of the user and the access tokens issued with them, i.e. ends all sessions.
+func (r TokenPG) RevokeUser(ctx context.Context, userGUID string) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	jtis, err := revokeUserTx(ctx, tx, userGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return jtis, nil
+}
+
+// DeleteExpiredRefreshTokens keeps a family as long as one of its tokens is valid: a used token of a live family is
+// needed to detect its reuse.
+func (r TokenPG) DeleteExpiredRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM refresh_tokens t
+		WHERE t.expires_at < $1
+			AND NOT EXISTS (SELECT 1 FROM refresh_tokens f WHERE f.family_guid = t.family_guid AND f.expires_at >= $1)
+	`
+
+	return r.deleteExpired(ctx, query, before)
+}
+
+func (r TokenPG) DeleteExpiredRevocations(ctx context.Context, before time.Time) (int64, error) {
+	return r.deleteExpired(ctx, "DELETE FROM revoked_tokens WHERE expires_at < $1", before)
+}
+
+func (r TokenPG) deleteExpired(ctx context.Context, query string, before time.Time) (int64, error) {
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	res, err := stmt.ExecContext(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired tokens: %w", err)
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return deleted, nil
+}
+
+func addRefreshTokenTx(ctx context.Context, tx *sql.Tx, token *model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (guid, family_guid, user_guid, token_hash, access_jti, access_expires_at, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := tx.ExecContext(
+		ctx,
+		query,
+		token.GUID,
+		token.FamilyGUID,
+		token.UserGUID,
+		token.TokenHash,
+		token.AccessJTI,
+		token.AccessExpiresAt,
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// revokeFamilyTx puts the access tokens of the family that are still valid on the revocation list, so they stop
+// working before they expire.
+func revokeFamilyTx(ctx context.Context, tx *sql.Tx, familyGUID string) error {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE family_guid = $1 AND revoked_at IS NULL
+	`
+	_, err := tx.ExecContext(ctx, query, familyGUID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	query = `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		SELECT access_jti, access_expires_at
+		FROM refresh_tokens
+		WHERE family_guid = $1 AND access_expires_at > now()
+		ON CONFLICT (jti) DO NOTHING
+	`
+	_, err = tx.ExecContext(ctx, query, familyGUID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+
+	return nil
+}
+
+// revokeUserTx is revokeFamilyTx for all families of the user. It returns the jti of the access tokens that were
+// still valid.
+func revokeUserTx(ctx context.Context, tx *sql.Tx, userGUID string) ([]string, error) {
+	query := `
+		UPDATE refresh_tokens
+		SET revoked_at = now()
+		WHERE user_guid = $1 AND revoked_at IS NULL
+	`
+	_, err := tx.ExecContext(ctx, query, userGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	query = `
+		WITH valid AS (
+			SELECT access_jti, access_expires_at
+			FROM refresh_tokens
+			WHERE user_guid = $1 AND access_expires_at > now()
+		), revoked AS (
+			INSERT INTO revoked_tokens (jti, expires_at)
+			SELECT access_jti, access_expires_at FROM valid
+			ON CONFLICT (jti) DO NOTHING
+		)
+		SELECT access_jti FROM valid
+	`
+	rows, err := tx.QueryContext(ctx, query, userGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var jtis []string
+	for rows.Next() {
+		var jti string
+		if err = rows.Scan(&jti); err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		jtis = append(jtis, jti)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read access tokens: %w", err)
+	}
+
+	return jtis, nil
+}
@@ -0,0 +1,196 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestTokenPG_Rotate(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewTokenPG(db)
+
+	newToken := func(familyGUID string) *model.RefreshToken {
+		now := time.Now()
+		return &model.RefreshToken{
+			GUID:            uuid.NewString(),
+			FamilyGUID:      familyGUID,
+			UserGUID:        userGUID,
+			TokenHash:       uuid.NewString() + uuid.NewString()[:28],
+			AccessJTI:       uuid.NewString(),
+			AccessExpiresAt: now.Add(time.Hour),
+			CreatedAt:       now,
+			ExpiresAt:       now.Add(24 * time.Hour),
+		}
+	}
+
+	familyGUID := uuid.NewString()
+	first := newToken(familyGUID)
+	require.NoError(t, repo.CreateRefreshToken(ctx, first))
+
+	got, err := repo.RefreshTokenByHash(ctx, first.TokenHash)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, familyGUID, got.FamilyGUID)
+	assert.True(t, got.Active(time.Now()))
+
+	second := newToken(familyGUID)
+	require.NoError(t, repo.Rotate(ctx, first.GUID, second))
+
+	t.Run("used_once", func(t *testing.T) {
+		err := repo.Rotate(ctx, first.GUID, newToken(familyGUID))
+		assert.ErrorIs(t, err, repository.ErrRefreshTokenUsed)
+
+		got, err := repo.RefreshTokenByHash(ctx, first.TokenHash)
+		require.NoError(t, err)
+		assert.NotNil(t, got.UsedAt)
+	})
+
+	t.Run("family_revoked", func(t *testing.T) {
+		require.NoError(t, repo.RevokeFamily(ctx, familyGUID))
+
+		got, err := repo.RefreshTokenByHash(ctx, second.TokenHash)
+		require.NoError(t, err)
+		assert.NotNil(t, got.RevokedAt)
+
+		for _, jti := range []string{first.AccessJTI, second.AccessJTI} {
+			revoked, err := repo.IsRevoked(ctx, jti)
+			require.NoError(t, err)
+			assert.True(t, revoked)
+		}
+
+		err = repo.Rotate(ctx, second.GUID, newToken(familyGUID))
+		assert.ErrorIs(t, err, repository.ErrRefreshTokenUsed)
+	})
+
+	t.Run("revoked_by_access_jti", func(t *testing.T) {
+		other := newToken(uuid.NewString())
+		require.NoError(t, repo.CreateRefreshToken(ctx, other))
+
+		revoked, err := repo.IsRevoked(ctx, other.AccessJTI)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		require.NoError(t, repo.RevokeByAccessJTI(ctx, other.AccessJTI, other.AccessExpiresAt))
+
+		revoked, err = repo.IsRevoked(ctx, other.AccessJTI)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+
+		got, err := repo.RefreshTokenByHash(ctx, other.TokenHash)
+		require.NoError(t, err)
+		assert.NotNil(t, got.RevokedAt)
+	})
+}
+
+func TestTokenPG_RevokeUser(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewTokenPG(db)
+
+	var tokens []*model.RefreshToken
+	for i := 0; i < 2; i++ {
+		now := time.Now()
+		rt := &model.RefreshToken{
+			GUID:            uuid.NewString(),
+			FamilyGUID:      uuid.NewString(),
+			UserGUID:        userGUID,
+			TokenHash:       uuid.NewString() + uuid.NewString()[:28],
+			AccessJTI:       uuid.NewString(),
+			AccessExpiresAt: now.Add(time.Hour),
+			CreatedAt:       now,
+			ExpiresAt:       now.Add(24 * time.Hour),
+		}
+		require.NoError(t, repo.CreateRefreshToken(ctx, rt))
+		tokens = append(tokens, rt)
+	}
+
+	jtis, err := repo.RevokeUser(ctx, userGUID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{tokens[0].AccessJTI, tokens[1].AccessJTI}, jtis)
+
+	for _, rt := range tokens {
+		got, err := repo.RefreshTokenByHash(ctx, rt.TokenHash)
+		require.NoError(t, err)
+		assert.False(t, got.Active(time.Now()))
+
+		revoked, err := repo.IsRevoked(ctx, rt.AccessJTI)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	}
+}
+
+func TestTokenPG_DeleteExpired(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewTokenPG(db)
+
+	newToken := func(familyGUID string, expiresAt time.Time) *model.RefreshToken {
+		return &model.RefreshToken{
+			GUID:            uuid.NewString(),
+			FamilyGUID:      familyGUID,
+			UserGUID:        userGUID,
+			TokenHash:       uuid.NewString() + uuid.NewString()[:28],
+			AccessJTI:       uuid.NewString(),
+			AccessExpiresAt: expiresAt,
+			CreatedAt:       expiresAt.Add(-24 * time.Hour),
+			ExpiresAt:       expiresAt,
+		}
+	}
+
+	now := time.Now()
+
+	expired := newToken(uuid.NewString(), now.Add(-time.Hour))
+	require.NoError(t, repo.CreateRefreshToken(ctx, expired))
+
+	// the used token of a live family has expired too, but is kept to detect its reuse
+	liveFamily := uuid.NewString()
+	used := newToken(liveFamily, now.Add(-time.Hour))
+	require.NoError(t, repo.CreateRefreshToken(ctx, used))
+	require.NoError(t, repo.Rotate(ctx, used.GUID, newToken(liveFamily, now.Add(time.Hour))))
+
+	revoked := newToken(uuid.NewString(), now.Add(time.Hour))
+	require.NoError(t, repo.CreateRefreshToken(ctx, revoked))
+	require.NoError(t, repo.RevokeByAccessJTI(ctx, uuid.NewString(), now.Add(-time.Minute)))
+	require.NoError(t, repo.RevokeByAccessJTI(ctx, revoked.AccessJTI, now.Add(time.Hour)))
+
+	deleted, err := repo.DeleteExpiredRefreshTokens(ctx, now)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+
+	got, err := repo.RefreshTokenByHash(ctx, expired.TokenHash)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	got, err = repo.RefreshTokenByHash(ctx, used.TokenHash)
+	require.NoError(t, err)
+	assert.NotNil(t, got)
+
+	deleted, err = repo.DeleteExpiredRevocations(ctx, now)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+
+	isRevoked, err := repo.IsRevoked(ctx, revoked.AccessJTI)
+	require.NoError(t, err)
+	assert.True(t, isRevoked)
+}
@@ -1,10 +1,13 @@
-//go:generate mockgen -source ${GOFILE} -package mock -destination mock/accrual_mock.go
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/transaction_mock.go
 
 package repository
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 
@@ -13,6 +16,8 @@ import (
 
 type TransactionRepo interface {
 	Withdrawals(ctx context.Context, accountGUID string) ([]model.Transaction, error)
+	Statement(ctx context.Context, accountGUID string, filter model.StatementFilter) ([]model.StatementEntry, error)
+	StreamStatement(ctx context.Context, accountGUID string, filter model.StatementFilter, fn func(model.StatementEntry) error) error
 }
 
 type TransactionPG struct {
@@ -74,3 +79,207 @@ func (r TransactionPG) Withdrawals(ctx context.Context, accountGUID string) ([]m
 
 	return result, nil
 }
+
+// Statement returns account transactions with the balance of the ledger account after each of them. Filters don't
+// change the balance.
+func (r TransactionPG) Statement(ctx context.Context, accountGUID string, filter model.StatementFilter) ([]model.StatementEntry, error) {
+	query, args := statementQuery(accountGUID, filter)
+
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.StatementEntry
+	for rows.Next() {
+		entry, err := scanStatementEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, entry)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+// StreamStatement passes the statement to fn row by row. Rows are read from a server-side cursor in batches,
+// so the whole statement is never held in memory. An error returned by fn stops the stream and is returned as is.
+func (r TransactionPG) StreamStatement(ctx context.Context, accountGUID string, filter model.StatementFilter, fn func(model.StatementEntry) error) error {
+	query, args := statementQuery(accountGUID, filter)
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "DECLARE statement_cursor NO SCROLL CURSOR FOR "+query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to declare cursor: %w", err)
+	}
+
+	for {
+		n, err := fetchStatementTx(ctx, tx, fn)
+		if err != nil {
+			return err
+		}
+
+		if n < statementFetchSize {
+			break
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+const statementFetchSize = 500
+
+func fetchStatementTx(ctx context.Context, tx *sql.Tx, fn func(model.StatementEntry) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, "FETCH "+strconv.Itoa(statementFetchSize)+" FROM statement_cursor")
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch from cursor: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var n int
+	for rows.Next() {
+		entry, err := scanStatementEntry(rows)
+		if err != nil {
+			return n, err
+		}
+
+		if err = fn(entry); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	if rows.Err() != nil {
+		return n, rows.Err()
+	}
+
+	return n, nil
+}
+
+// statementQuery selects the page of transactions first and then computes the balance after each of them from
+// the postings of the user ledger account, so adjustments are counted too. The running total starts from the
+// balance before the oldest row of the page: the stored balance less the postings since that row.
+func statementQuery(accountGUID string, filter model.StatementFilter) (string, []any) {
+	args := []any{accountGUID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	var page strings.Builder
+	page.WriteString(`
+			SELECT guid, account_guid, order_number, type, sum, processed_at
+			FROM transactions
+			WHERE account_guid = $1`)
+
+	if len(filter.Types) > 0 {
+		placeholders := make([]string, 0, len(filter.Types))
+		for _, t := range filter.Types {
+			placeholders = append(placeholders, arg(t))
+		}
+		page.WriteString(" AND type IN (" + strings.Join(placeholders, ", ") + ")")
+	}
+
+	if !filter.ProcessedFrom.IsZero() {
+		page.WriteString(" AND processed_at >= " + arg(filter.ProcessedFrom))
+	}
+
+	if !filter.ProcessedTo.IsZero() {
+		page.WriteString(" AND processed_at < " + arg(filter.ProcessedTo))
+	}
+
+	order, cmp := "DESC", "<"
+	if filter.OldestFirst {
+		order, cmp = "ASC", ">"
+	}
+
+	if filter.After != nil {
+		page.WriteString(" AND (processed_at, guid) " + cmp + " (" + arg(filter.After.ProcessedAt) + ", " + arg(filter.After.GUID) + ")")
+	}
+
+	page.WriteString(" ORDER BY processed_at " + order + ", guid " + order)
+
+	if filter.Limit > 0 {
+		page.WriteString(" LIMIT " + arg(filter.Limit))
+	}
+
+	query := `
+		WITH page AS (` + page.String() + `
+		), oldest AS (
+			SELECT processed_at, guid FROM page ORDER BY processed_at, guid LIMIT 1
+		), newest AS (
+			SELECT processed_at, guid FROM page ORDER BY processed_at DESC, guid DESC LIMIT 1
+		), postings AS (
+			SELECT p.created_at, p.entry_guid, p.amount
+			FROM ledger_postings p, oldest
+			WHERE p.account_guid = $1 AND (p.created_at, p.entry_guid) >= (oldest.processed_at, oldest.guid)
+		), seed AS (
+			SELECT COALESCE((SELECT balance FROM ledger_accounts WHERE guid = $1), 0)
+			       - COALESCE((SELECT SUM(amount) FROM postings), 0) AS balance
+		), running AS (
+			SELECT e.*, seed.balance + SUM(e.amount) OVER (ORDER BY e.at, e.key, e.listed) AS balance
+			FROM (
+				SELECT guid, account_guid, order_number, type, sum, processed_at,
+				       processed_at AS at, guid AS key, 0::numeric AS amount, true AS listed
+				FROM page
+				UNION ALL
+				SELECT NULL, NULL, NULL, NULL, NULL, NULL, p.created_at, p.entry_guid, p.amount, false
+				FROM postings p, newest
+				WHERE (p.created_at, p.entry_guid) <= (newest.processed_at, newest.guid)
+			) e, seed
+		)
+		SELECT guid, account_guid, order_number, type, sum, processed_at, balance
+		FROM running
+		WHERE listed
+		ORDER BY processed_at ` + order + `, guid ` + order + `
+	`
+
+	return query, args
+}
+
+func scanStatementEntry(rows *sql.Rows) (model.StatementEntry, error) {
+	var (
+		m       transaction
+		balance model.Points
+	)
+
+	err := rows.Scan(&m.GUID, &m.AccountGUID, &m.OrderNumber, &m.Type, &m.Sum, &m.ProcessedAt, &balance)
+	if err != nil {
+		return model.StatementEntry{}, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	return model.StatementEntry{
+		Transaction: *m.export(),
+		Balance:     balance,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestTransactionPG_Statement(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	fundAccount(t, db, userGUID, model.NewPoints(500, 0))
+	fundAccount(t, db, userGUID, model.NewPoints(250, 50))
+
+	withdraw := model.NewWithdrawTransaction(uuid.NewString(), userGUID, uuid.NewString()[:20], model.NewPoints(100, 25), time.Now())
+	require.NoError(t, repository.NewAccrualPG(db).WithdrawBalance(ctx, withdraw))
+
+	repo := repository.NewTransactionPG(db)
+
+	t.Run("running_balance", func(t *testing.T) {
+		rows, err := repo.Statement(ctx, userGUID, model.StatementFilter{})
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+
+		assert.Equal(t, model.Withdraw, rows[0].Type)
+		assert.Equal(t, model.NewPoints(650, 25), rows[0].Balance)
+		assert.Equal(t, model.NewPoints(750, 50), rows[1].Balance)
+		assert.Equal(t, model.NewPoints(500, 0), rows[2].Balance)
+	})
+
+	t.Run("filter_keeps_balance", func(t *testing.T) {
+		rows, err := repo.Statement(ctx, userGUID, model.StatementFilter{Types: []model.TransactionType{model.Add}})
+		require.NoError(t, err)
+		require.Len(t, rows, 2)
+		assert.Equal(t, model.NewPoints(750, 50), rows[0].Balance)
+	})
+
+	t.Run("pages", func(t *testing.T) {
+		first, err := repo.Statement(ctx, userGUID, model.StatementFilter{Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, first, 2)
+
+		last := first[len(first)-1]
+		second, err := repo.Statement(ctx, userGUID, model.StatementFilter{
+			After: &model.StatementCursor{ProcessedAt: last.ProcessedAt, GUID: last.GUID},
+			Limit: 2,
+		})
+		require.NoError(t, err)
+		require.Len(t, second, 1)
+		assert.Equal(t, model.NewPoints(500, 0), second[0].Balance)
+	})
+
+	t.Run("stream_oldest_first", func(t *testing.T) {
+		var balances []model.Points
+		err := repo.StreamStatement(ctx, userGUID, model.StatementFilter{OldestFirst: true}, func(entry model.StatementEntry) error {
+			balances = append(balances, entry.Balance)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []model.Points{model.NewPoints(500, 0), model.NewPoints(750, 50), model.NewPoints(650, 25)}, balances)
+	})
+
+	t.Run("adjustments_count", func(t *testing.T) {
+		adjustment := model.NewAdjustmentEntry(uuid.NewString(), userGUID, model.NewPoints(10, 0), "test", time.Now())
+		require.NoError(t, repository.NewLedgerPG(db).PostEntry(ctx, adjustment))
+
+		fundAccount(t, db, userGUID, model.NewPoints(1, 0))
+
+		rows, err := repo.Statement(ctx, userGUID, model.StatementFilter{Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, rows, 1)
+		assert.Equal(t, model.NewPoints(661, 25), rows[0].Balance)
+	})
+}
@@ -15,7 +15,12 @@ import (
 
 type UserRepository interface {
 	FindByLogin(ctx context.Context, login string) (*model.User, error)
+	FindByGUID(ctx context.Context, guid string) (*model.User, error)
 	Insert(ctx context.Context, user *model.User) error
+	UpdatePassword(ctx context.Context, guid, password string) error
+	// ChangePassword sets the password and ends all sessions of the user in one transaction, see
+	// TokenRepo.RevokeUser.
+	ChangePassword(ctx context.Context, guid, password string) ([]string, error)
 }
 
 type UserPG struct {
@@ -52,6 +57,30 @@ func (r UserPG) FindByLogin(ctx context.Context, login string) (*model.User, err
 	return m.export(), nil
 }
 
+func (r UserPG) FindByGUID(ctx context.Context, guid string) (*model.User, error) {
+	query := "SELECT guid, login, password FROM users WHERE guid = $1"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	var m user
+	row := stmt.QueryRowContext(ctx, guid)
+	err = row.Scan(&m.GUID, &m.Login, &m.Password)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	return m.export(), nil
+}
+
 func (r UserPG) Insert(ctx context.Context, user *model.User) error {
 	query := `INSERT INTO users (guid, login, password) VALUES ($1, $2, $3)`
 	stmt, err := r.db.PrepareContext(ctx, query)
@@ -70,3 +99,48 @@ func (r UserPG) Insert(ctx context.Context, user *model.User) error {
 
 	return nil
 }
+
+func (r UserPG) UpdatePassword(ctx context.Context, guid, password string) error {
+	query := "UPDATE users SET password = $1 WHERE guid = $2"
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, password, guid)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	return nil
+}
+
+func (r UserPG) ChangePassword(ctx context.Context, guid, password string) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET password = $1 WHERE guid = $2", password, guid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update password: %w", err)
+	}
+
+	jtis, err := revokeUserTx(ctx, tx, guid)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return jtis, nil
+}
@@ -0,0 +1,51 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestUserPG_ChangePassword(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	tokenRepo := repository.NewTokenPG(db)
+
+	now := time.Now()
+	rt := &model.RefreshToken{
+		GUID:            uuid.NewString(),
+		FamilyGUID:      uuid.NewString(),
+		UserGUID:        userGUID,
+		TokenHash:       uuid.NewString() + uuid.NewString()[:28],
+		AccessJTI:       uuid.NewString(),
+		AccessExpiresAt: now.Add(time.Hour),
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(24 * time.Hour),
+	}
+	require.NoError(t, tokenRepo.CreateRefreshToken(ctx, rt))
+
+	repo := repository.NewUserPG(db)
+
+	jtis, err := repo.ChangePassword(ctx, userGUID, "new-hash")
+	require.NoError(t, err)
+	assert.Equal(t, []string{rt.AccessJTI}, jtis)
+
+	user, err := repo.FindByGUID(ctx, userGUID)
+	require.NoError(t, err)
+	assert.Equal(t, "new-hash", user.Password)
+
+	revoked, err := tokenRepo.IsRevoked(ctx, rt.AccessJTI)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,400 @@
+//go:generate mockgen -source ${GOFILE} -package mock -destination mock/webhook_mock.go
+
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+type WebhookRepo interface {
+	WebhookByGUID(ctx context.Context, guid, userGUID string) (*model.Webhook, error)
+	WebhooksByUser(ctx context.Context, userGUID string) ([]model.Webhook, error)
+	Deliveries(ctx context.Context, webhookGUID string, limit int) ([]model.WebhookDelivery, error)
+	LeaseDue(ctx context.Context, limit int, ttl time.Duration) ([]model.WebhookDispatch, error)
+
+	Create(ctx context.Context, webhook *model.Webhook) error
+	Delete(ctx context.Context, guid, userGUID string) error
+	AddDelivery(ctx context.Context, webhookGUID string, event model.Event, payload []byte) error
+	MarkDelivered(ctx context.Context, guid string, statusCode int) error
+	ScheduleRetry(ctx context.Context, guid string, nextAttemptAt time.Time, statusCode int, lastError string) error
+	MarkFailed(ctx context.Context, guid string, statusCode int, lastError string) error
+	Redeliver(ctx context.Context, guid, userGUID string) error
+}
+
+type WebhookPG struct {
+	db *sqlx.DB
+}
+
+func NewWebhookPG(db *sqlx.DB) *WebhookPG {
+	return &WebhookPG{
+		db: db,
+	}
+}
+
+// WebhookByGUID returns the webhook of the user, nil if the user has no such webhook.
+func (r WebhookPG) WebhookByGUID(ctx context.Context, guid, userGUID string) (*model.Webhook, error) {
+	query := `
+		SELECT guid, user_guid, url, secret, events, created_at
+		FROM webhooks
+		WHERE guid = $1 AND user_guid = $2
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	m, err := scanWebhook(stmt.QueryRowContext(ctx, guid, userGUID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return m, nil
+}
+
+func (r WebhookPG) WebhooksByUser(ctx context.Context, userGUID string) ([]model.Webhook, error) {
+	query := `
+		SELECT guid, user_guid, url, secret, events, created_at
+		FROM webhooks
+		WHERE user_guid = $1
+		ORDER BY created_at
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, userGUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.Webhook
+	for rows.Next() {
+		m, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, *m)
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+// Deliveries returns the delivery log of the webhook, newest first.
+func (r WebhookPG) Deliveries(ctx context.Context, webhookGUID string, limit int) ([]model.WebhookDelivery, error) {
+	query := `
+		SELECT guid, webhook_guid, event_guid, event_type, payload, status, attempts, next_attempt_at,
+			last_status_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_guid = $1
+		ORDER BY created_at DESC, guid DESC
+		LIMIT $2
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, webhookGUID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.WebhookDelivery
+	for rows.Next() {
+		var m webhookDelivery
+		err = rows.Scan(&m.GUID, &m.WebhookGUID, &m.EventGUID, &m.EventType, &m.Payload, &m.Status, &m.Attempts,
+			&m.NextAttemptAt, &m.LastStatusCode, &m.LastError, &m.CreatedAt, &m.DeliveredAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		result = append(result, *m.export())
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+// LeaseDue takes pending deliveries whose time has come and hides them from other senders for ttl. A delivery whose
+// result is not recorded within ttl, e.g. because the sender crashed, is sent again.
+func (r WebhookPG) LeaseDue(ctx context.Context, limit int, ttl time.Duration) ([]model.WebhookDispatch, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = now() + $1 * interval '1 millisecond'
+		FROM (
+			SELECT guid
+			FROM webhook_deliveries
+			WHERE status = $2 AND next_attempt_at <= now()
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		) c, webhooks w
+		WHERE d.guid = c.guid AND w.guid = d.webhook_guid
+		RETURNING d.guid, d.webhook_guid, d.event_guid, d.event_type, d.payload, d.status, d.attempts,
+			d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at, w.url, w.secret
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	rows, err := stmt.QueryContext(ctx, ttl.Milliseconds(), model.DeliveryPending, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute a prepared query: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var result []model.WebhookDispatch
+	for rows.Next() {
+		var (
+			m           webhookDelivery
+			url, secret string
+		)
+		err = rows.Scan(&m.GUID, &m.WebhookGUID, &m.EventGUID, &m.EventType, &m.Payload, &m.Status, &m.Attempts,
+			&m.NextAttemptAt, &m.LastStatusCode, &m.LastError, &m.CreatedAt, &m.DeliveredAt, &url, &secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan: %w", err)
+		}
+
+		result = append(result, model.WebhookDispatch{
+			Delivery: *m.export(),
+			URL:      url,
+			Secret:   secret,
+		})
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return result, nil
+}
+
+func (r WebhookPG) Create(ctx context.Context, webhook *model.Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook events: %w", err)
+	}
+
+	query := `
+		INSERT INTO webhooks (guid, user_guid, url, secret, events, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, webhook.GUID, webhook.UserGUID, webhook.URL, webhook.Secret, events, webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save webhook: %w", err)
+	}
+
+	return nil
+}
+
+// Delete removes the webhook of the user together with its delivery log.
+func (r WebhookPG) Delete(ctx context.Context, guid, userGUID string) error {
+	query := `DELETE FROM webhooks WHERE guid = $1 AND user_guid = $2`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	res, err := stmt.ExecContext(ctx, guid, userGUID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	return checkAffected(res, ErrWebhookNotFound, guid)
+}
+
+// AddDelivery queues the event for the webhook. The outbox may publish an event more than once, the webhook gets it
+// once.
+func (r WebhookPG) AddDelivery(ctx context.Context, webhookGUID string, event model.Event, payload []byte) error {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_guid, event_guid, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (webhook_guid, event_guid) DO NOTHING
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, webhookGUID, event.GUID, event.Type, payload)
+	if err != nil {
+		return fmt.Errorf("failed to add webhook delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (r WebhookPG) MarkDelivered(ctx context.Context, guid string, statusCode int) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, last_status_code = $2, last_error = '', delivered_at = now()
+		WHERE guid = $3
+	`
+
+	return r.exec(ctx, query, "failed to mark webhook delivered", model.DeliveryDelivered, statusCode, guid)
+}
+
+// ScheduleRetry records a failed attempt and hides the delivery until nextAttemptAt.
+func (r WebhookPG) ScheduleRetry(ctx context.Context, guid string, nextAttemptAt time.Time, statusCode int, lastError string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1, next_attempt_at = $1, last_status_code = $2, last_error = $3
+		WHERE guid = $4
+	`
+
+	return r.exec(ctx, query, "failed to schedule webhook retry", nextAttemptAt, statusCode, lastError, guid)
+}
+
+// MarkFailed records the last failed attempt. The delivery is not sent again unless it is redelivered manually.
+func (r WebhookPG) MarkFailed(ctx context.Context, guid string, statusCode int, lastError string) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = attempts + 1, last_status_code = $2, last_error = $3
+		WHERE guid = $4
+	`
+
+	return r.exec(ctx, query, "failed to mark webhook failed", model.DeliveryFailed, statusCode, lastError, guid)
+}
+
+// Redeliver queues a delivery of the user's webhook to be sent right away with a fresh set of attempts, whatever
+// its status.
+func (r WebhookPG) Redeliver(ctx context.Context, guid, userGUID string) error {
+	query := `
+		UPDATE webhook_deliveries d
+		SET status = $1, attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		FROM webhooks w
+		WHERE d.guid = $2 AND w.guid = d.webhook_guid AND w.user_guid = $3
+	`
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	res, err := stmt.ExecContext(ctx, model.DeliveryPending, guid, userGUID)
+	if err != nil {
+		return fmt.Errorf("failed to redeliver webhook: %w", err)
+	}
+
+	return checkAffected(res, ErrWebhookDeliveryNotFound, guid)
+}
+
+func (r WebhookPG) exec(ctx context.Context, query, errMsg string, args ...any) error {
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to prepare query: %w", err)
+	}
+	defer func() {
+		_ = stmt.Close()
+	}()
+
+	_, err = stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", errMsg, err)
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanWebhook(row rowScanner) (*model.Webhook, error) {
+	var (
+		m      model.Webhook
+		events []byte
+	)
+	err := row.Scan(&m.GUID, &m.UserGUID, &m.URL, &m.Secret, &events, &m.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+
+		return nil, fmt.Errorf("failed to scan: %w", err)
+	}
+
+	err = json.Unmarshal(events, &m.Events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook events: %w", err)
+	}
+
+	return &m, nil
+}
+
+func checkAffected(res sql.Result, notFound error, guid string) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%w: %s", notFound, guid)
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestWebhookPG_Deliveries(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	userGUID := uuid.NewString()
+	createUser(t, db, userGUID)
+
+	repo := repository.NewWebhookPG(db)
+
+	webhook := model.NewWebhook(uuid.NewString(), userGUID, "https://partner.example/hooks", "secret", []model.EventType{model.EventAccrualInvalid})
+	require.NoError(t, repo.Create(ctx, webhook))
+
+	got, err := repo.WebhookByGUID(ctx, webhook.GUID, userGUID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, webhook.Events, got.Events)
+
+	event := model.Event{GUID: uuid.NewString(), Type: model.EventAccrualInvalid, UserGUID: userGUID}
+	payload := []byte(`{"id":"` + event.GUID + `"}`)
+
+	// the outbox may publish the event again
+	require.NoError(t, repo.AddDelivery(ctx, webhook.GUID, event, payload))
+	require.NoError(t, repo.AddDelivery(ctx, webhook.GUID, event, payload))
+
+	deliveries, err := repo.Deliveries(ctx, webhook.GUID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+
+	ours := func(dispatches []model.WebhookDispatch) *model.WebhookDispatch {
+		for _, d := range dispatches {
+			if d.Delivery.GUID == delivery.GUID {
+				return &d
+			}
+		}
+		return nil
+	}
+
+	t.Run("leased_once", func(t *testing.T) {
+		leased, err := repo.LeaseDue(ctx, 10000, time.Minute)
+		require.NoError(t, err)
+
+		d := ours(leased)
+		require.NotNil(t, d)
+		assert.Equal(t, webhook.URL, d.URL)
+		assert.Equal(t, webhook.Secret, d.Secret)
+
+		leased, err = repo.LeaseDue(ctx, 10000, time.Minute)
+		require.NoError(t, err)
+		assert.Nil(t, ours(leased))
+	})
+
+	t.Run("failed_then_redelivered", func(t *testing.T) {
+		require.NoError(t, repo.MarkFailed(ctx, delivery.GUID, 500, "unexpected status code: 500"))
+
+		deliveries, err := repo.Deliveries(ctx, webhook.GUID, 10)
+		require.NoError(t, err)
+		assert.Equal(t, model.DeliveryFailed, deliveries[0].Status)
+		assert.Equal(t, 1, deliveries[0].Attempts)
+		assert.Equal(t, 500, deliveries[0].LastStatusCode)
+
+		assert.ErrorIs(t, repo.Redeliver(ctx, delivery.GUID, uuid.NewString()), repository.ErrWebhookDeliveryNotFound)
+		require.NoError(t, repo.Redeliver(ctx, delivery.GUID, userGUID))
+
+		leased, err := repo.LeaseDue(ctx, 10000, time.Minute)
+		require.NoError(t, err)
+		assert.NotNil(t, ours(leased))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		assert.ErrorIs(t, repo.Delete(ctx, webhook.GUID, uuid.NewString()), repository.ErrWebhookNotFound)
+		require.NoError(t, repo.Delete(ctx, webhook.GUID, userGUID))
+
+		deliveries, err := repo.Deliveries(ctx, webhook.GUID, 10)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+}
@@ -1,17 +1,44 @@
 package accrual
 
 import (
+	"sync/atomic"
+	"time"
+
 	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
 )
 
+// defaultBatchRecheck is how long the batch endpoint is not tried after the service answered that it has none.
+const defaultBatchRecheck = 10 * time.Minute
+
 type Client struct {
 	client     client.Client
 	serviceURL string
+	// batchUnsupportedAt is the unix time in nanoseconds the service last answered that it has no batch endpoint
+	batchUnsupportedAt *atomic.Int64
+	batchRecheck       time.Duration
 }
 
-func NewAccrualClient(client client.Client, serviceURL string) *Client {
-	return &Client{
-		client:     client,
-		serviceURL: serviceURL,
+type Option func(*Client)
+
+// WithBatchRecheck sets how long the orders are looked up one by one before the batch endpoint is tried again,
+// e.g. after the service has been upgraded.
+func WithBatchRecheck(d time.Duration) Option {
+	return func(c *Client) {
+		c.batchRecheck = d
 	}
 }
+
+func NewAccrualClient(client client.Client, serviceURL string, opts ...Option) *Client {
+	c := &Client{
+		client:             client,
+		serviceURL:         serviceURL,
+		batchUnsupportedAt: new(atomic.Int64),
+		batchRecheck:       defaultBatchRecheck,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
@@ -1,26 +1,58 @@
 package accrual
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+	"github.com/bjlag/go-loyalty/internal/model"
 )
 
 var (
 	ErrOrderNotRegistered = errors.New("order not registered")
 	ErrTooManyRequests    = errors.New("too many requests")
 	ErrUnknownStatus      = errors.New("unknown status")
+	ErrServiceUnavailable = errors.New("service unavailable")
 )
 
+// DefaultRetryAfter is used when the service answers 429 without a usable Retry-After header.
+const DefaultRetryAfter = time.Minute
+
+var reRequestsPerMinute = regexp.MustCompile(`(\d+) requests per minute`)
+
+// RateLimitError is returned on 429 Too Many Requests. It matches ErrTooManyRequests with errors.Is.
+type RateLimitError struct {
+	OrderNumber string
+	RetryAfter  time.Duration
+	// PerMinute is the limit advertised in the response body, zero if the body doesn't mention it.
+	PerMinute int
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s: %s, retry after %s", ErrTooManyRequests, e.OrderNumber, e.RetryAfter)
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
+
 type Response struct {
-	Order   string   `json:"order"`
-	Status  string   `json:"status"`
-	Accrual *float64 `json:"accrual,omitempty"`
+	Order   string        `json:"order"`
+	Status  string        `json:"status"`
+	Accrual *model.Points `json:"accrual,omitempty"`
 }
 
-func (c Client) OrderStatus(orderNumber string) (*Response, error) {
-	resp, err := c.client.Get(c.serviceURL + "/api/orders/" + orderNumber)
+// OrderStatus asks the accrual system for the status of the order. The ID of the request or job in the context is
+// forwarded in the X-Request-Id header.
+func (c Client) OrderStatus(ctx context.Context, orderNumber string) (*Response, error) {
+	resp, err := c.client.Get(ctx, c.serviceURL+"/api/orders/"+orderNumber, client.WithRequestID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -28,15 +60,16 @@ func (c Client) OrderStatus(orderNumber string) (*Response, error) {
 		_ = resp.Body.Close()
 	}()
 
-	if resp.StatusCode != http.StatusOK {
-		switch resp.StatusCode {
-		case http.StatusNoContent:
-			return nil, fmt.Errorf("%w: %s", ErrOrderNotRegistered, orderNumber)
-		case http.StatusTooManyRequests:
-			return nil, fmt.Errorf("%w: %s", ErrTooManyRequests, orderNumber)
-		default:
-			return nil, fmt.Errorf("%w: %s", ErrUnknownStatus, orderNumber)
-		}
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNoContent:
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotRegistered, orderNumber)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, newRateLimitError(orderNumber, resp)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: %s: %d", ErrServiceUnavailable, orderNumber, resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStatus, orderNumber)
 	}
 
 	var result *Response
@@ -47,3 +80,37 @@ func (c Client) OrderStatus(orderNumber string) (*Response, error) {
 
 	return result, nil
 }
+
+func newRateLimitError(orderNumber string, resp *http.Response) *RateLimitError {
+	err := &RateLimitError{
+		OrderNumber: orderNumber,
+		RetryAfter:  parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if m := reRequestsPerMinute.FindSubmatch(body); m != nil {
+		err.PerMinute, _ = strconv.Atoi(string(m[1]))
+	}
+
+	return err
+}
+
+// parseRetryAfter accepts both forms of the header: delay in seconds and HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return DefaultRetryAfter
+	}
+
+	if seconds, err := strconv.Atoi(v); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+		return 0
+	}
+
+	return DefaultRetryAfter
+}
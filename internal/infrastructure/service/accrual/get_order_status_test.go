@@ -0,0 +1,122 @@
+package accrual_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestClient_OrderStatus(t *testing.T) {
+	serve := func(t *testing.T, h http.HandlerFunc) *accrual.Client {
+		server := httptest.NewServer(h)
+		t.Cleanup(server.Close)
+
+		return accrual.NewAccrualClient(client.NewRestyClient(), server.URL)
+	}
+
+	t.Run("processed", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/orders/2377225624", r.URL.Path)
+			_, _ = w.Write([]byte(`{"order":"2377225624","status":"PROCESSED","accrual":500.5}`))
+		})
+
+		resp, err := c.OrderStatus(context.Background(), "2377225624")
+		require.NoError(t, err)
+
+		accrualSum := model.NewPoints(500, 50)
+		assert.Equal(t, &accrual.Response{Order: "2377225624", Status: "PROCESSED", Accrual: &accrualSum}, resp)
+	})
+
+	t.Run("forwards_request_id", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "5d8a3b1e-7f2c-4e9a-b6d0-1c3e5f7a9b2d", r.Header.Get(client.HeaderRequestID))
+			_, _ = w.Write([]byte(`{"order":"2377225624","status":"PROCESSING"}`))
+		})
+
+		ctx := client.ContextWithRequestID(context.Background(), "5d8a3b1e-7f2c-4e9a-b6d0-1c3e5f7a9b2d")
+		_, err := c.OrderStatus(ctx, "2377225624")
+		require.NoError(t, err)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		c := serve(t, func(w http.ResponseWriter, r *http.Request) {
+			cancel()
+			<-r.Context().Done()
+		})
+
+		_, err := c.OrderStatus(ctx, "2377225624")
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("not_registered", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+
+		_, err := c.OrderStatus(context.Background(), "2377225624")
+		assert.ErrorIs(t, err, accrual.ErrOrderNotRegistered)
+	})
+
+	t.Run("service_unavailable", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+
+		_, err := c.OrderStatus(context.Background(), "2377225624")
+		assert.ErrorIs(t, err, accrual.ErrServiceUnavailable)
+	})
+
+	t.Run("too_many_requests", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("No more than 30 requests per minute allowed"))
+		})
+
+		_, err := c.OrderStatus(context.Background(), "2377225624")
+		assert.ErrorIs(t, err, accrual.ErrTooManyRequests)
+
+		var rateErr *accrual.RateLimitError
+		require.True(t, errors.As(err, &rateErr))
+		assert.Equal(t, time.Minute, rateErr.RetryAfter)
+		assert.Equal(t, 30, rateErr.PerMinute)
+	})
+
+	t.Run("too_many_requests_http_date", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", time.Now().Add(2*time.Minute).UTC().Format(http.TimeFormat))
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		_, err := c.OrderStatus(context.Background(), "2377225624")
+
+		var rateErr *accrual.RateLimitError
+		require.True(t, errors.As(err, &rateErr))
+		assert.InDelta(t, 2*time.Minute, rateErr.RetryAfter, float64(2*time.Second))
+		assert.Zero(t, rateErr.PerMinute)
+	})
+
+	t.Run("too_many_requests_without_header", func(t *testing.T) {
+		c := serve(t, func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		})
+
+		_, err := c.OrderStatus(context.Background(), "2377225624")
+
+		var rateErr *accrual.RateLimitError
+		require.True(t, errors.As(err, &rateErr))
+		assert.Equal(t, accrual.DefaultRetryAfter, rateErr.RetryAfter)
+	})
+}
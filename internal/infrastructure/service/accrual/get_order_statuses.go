@@ -0,0 +1,165 @@
+package accrual
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+)
+
+var errBatchUnsupported = errors.New("batch lookup is not supported")
+
+// singleLookupWorkers number of concurrent single lookups when the service has no batch endpoint.
+const singleLookupWorkers = 4
+
+// Limiter paces the requests to the service, e.g. a rate limiter shared by all pollers. It is paused as soon as the
+// service answers 429, so the other requests wait too.
+type Limiter interface {
+	Wait(ctx context.Context) error
+	Pause(d time.Duration)
+	SetPerMinute(perMinute int)
+}
+
+// BatchRequest is the body of POST /api/orders/batch. The service answers 200 with the list of Response for the
+// orders it knows, unknown ones are left out. A service without the endpoint answers 404 or 405.
+type BatchRequest struct {
+	Orders []string `json:"orders"`
+}
+
+// StatusResult is the answer for one order of a batch.
+type StatusResult struct {
+	OrderNumber string
+	Response    *Response
+	// Err is ErrOrderNotRegistered for an order unknown to the service, or the error of its single lookup.
+	Err error
+}
+
+// OrderStatuses asks the accrual system for the statuses of many orders in one request. If the service has no
+// batch endpoint, the orders are looked up one by one with a few concurrent requests, and the batch endpoint is not
+// tried again for a while. Every request waits for limiter first. The results are in the order of orderNumbers. An
+// error is returned if the whole batch failed.
+func (c Client) OrderStatuses(ctx context.Context, orderNumbers []string, limiter Limiter) ([]StatusResult, error) {
+	if len(orderNumbers) == 1 || c.batchUnsupported() {
+		return c.singleStatuses(ctx, orderNumbers, limiter), nil
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+
+	results, err := c.batchStatuses(ctx, orderNumbers)
+	if errors.Is(err, errBatchUnsupported) {
+		c.batchUnsupportedAt.Store(time.Now().UnixNano())
+		return c.singleStatuses(ctx, orderNumbers, limiter), nil
+	}
+	throttle(limiter, err)
+
+	return results, err
+}
+
+// throttle pauses the limiter for the period the service asked for on 429.
+func throttle(limiter Limiter, err error) {
+	var rateErr *RateLimitError
+	if !errors.As(err, &rateErr) {
+		return
+	}
+
+	limiter.Pause(rateErr.RetryAfter)
+	if rateErr.PerMinute > 0 {
+		limiter.SetPerMinute(rateErr.PerMinute)
+	}
+}
+
+func (c Client) batchUnsupported() bool {
+	at := c.batchUnsupportedAt.Load()
+	return at != 0 && time.Since(time.Unix(0, at)) < c.batchRecheck
+}
+
+func (c Client) batchStatuses(ctx context.Context, orderNumbers []string) ([]StatusResult, error) {
+	body, err := json.Marshal(BatchRequest{Orders: orderNumbers})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Post(
+		ctx,
+		c.serviceURL+"/api/orders/batch",
+		bytes.NewReader(body),
+		client.WithHeader("Content-Type", "application/json"),
+		client.WithRequestID(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode == http.StatusOK:
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusMethodNotAllowed:
+		return nil, errBatchUnsupported
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return nil, newRateLimitError(fmt.Sprintf("batch of %d orders", len(orderNumbers)), resp)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return nil, fmt.Errorf("%w: batch of %d orders: %d", ErrServiceUnavailable, len(orderNumbers), resp.StatusCode)
+	default:
+		return nil, fmt.Errorf("%w: batch of %d orders: %d", ErrUnknownStatus, len(orderNumbers), resp.StatusCode)
+	}
+
+	var found []*Response
+	if err = json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		return nil, err
+	}
+
+	byNumber := make(map[string]*Response, len(found))
+	for _, r := range found {
+		if r != nil {
+			byNumber[r.Order] = r
+		}
+	}
+
+	results := make([]StatusResult, len(orderNumbers))
+	for i, number := range orderNumbers {
+		results[i].OrderNumber = number
+		if r, ok := byNumber[number]; ok {
+			results[i].Response = r
+		} else {
+			results[i].Err = fmt.Errorf("%w: %s", ErrOrderNotRegistered, number)
+		}
+	}
+
+	return results, nil
+}
+
+func (c Client) singleStatuses(ctx context.Context, orderNumbers []string, limiter Limiter) []StatusResult {
+	results := make([]StatusResult, len(orderNumbers))
+
+	g := new(errgroup.Group)
+	g.SetLimit(singleLookupWorkers)
+
+	for i, number := range orderNumbers {
+		g.Go(func() error {
+			if err := limiter.Wait(ctx); err != nil {
+				results[i] = StatusResult{OrderNumber: number, Err: err}
+				return nil
+			}
+
+			resp, err := c.OrderStatus(ctx, number)
+			throttle(limiter, err)
+			results[i] = StatusResult{OrderNumber: number, Response: resp, Err: err}
+			return nil
+		})
+	}
+
+	_ = g.Wait()
+
+	return results
+}
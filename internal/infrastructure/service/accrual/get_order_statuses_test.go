@@ -0,0 +1,192 @@
+package accrual_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/ratelimit"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+// waits counts the requests paced by it.
+type waits struct {
+	n atomic.Int32
+}
+
+func (w *waits) Wait(context.Context) error {
+	w.n.Add(1)
+	return nil
+}
+
+func (w *waits) Pause(time.Duration) {}
+
+func (w *waits) SetPerMinute(int) {}
+
+func TestClient_OrderStatuses(t *testing.T) {
+	numbers := []string{"2377225624", "12345678903", "79927398713"}
+
+	t.Run("batch", func(t *testing.T) {
+		var hits atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "/api/orders/batch", r.URL.Path)
+
+			var req accrual.BatchRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, numbers, req.Orders)
+
+			// unknown orders are left out, the order of the answer doesn't matter
+			_, _ = w.Write([]byte(`[
+				{"order":"79927398713","status":"PROCESSING"},
+				{"order":"2377225624","status":"PROCESSED","accrual":500}
+			]`))
+		}))
+		t.Cleanup(server.Close)
+
+		c := accrual.NewAccrualClient(client.NewRestyClient(), server.URL)
+
+		results, err := c.OrderStatuses(context.Background(), numbers, ratelimit.NewLimiter(0))
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+
+		sum := model.NewPoints(500, 0)
+		assert.Equal(t, &accrual.Response{Order: "2377225624", Status: "PROCESSED", Accrual: &sum}, results[0].Response)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "12345678903", results[1].OrderNumber)
+		assert.ErrorIs(t, results[1].Err, accrual.ErrOrderNotRegistered)
+		assert.Equal(t, "PROCESSING", results[2].Response.Status)
+		assert.EqualValues(t, 1, hits.Load())
+	})
+
+	noBatch := func(batchHits, singleHits *atomic.Int32) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				batchHits.Add(1)
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			singleHits.Add(1)
+			if r.URL.Path == "/api/orders/12345678903" {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			_, _ = w.Write([]byte(`{"order":"` + r.URL.Path[len("/api/orders/"):] + `","status":"REGISTERED"}`))
+		}))
+		t.Cleanup(server.Close)
+
+		return server.URL
+	}
+
+	t.Run("falls_back_to_single_lookups", func(t *testing.T) {
+		var batchHits, singleHits atomic.Int32
+
+		c := accrual.NewAccrualClient(client.NewRestyClient(), noBatch(&batchHits, &singleHits))
+		waiter := new(waits)
+
+		for i := 0; i < 2; i++ {
+			results, err := c.OrderStatuses(context.Background(), numbers, waiter)
+			require.NoError(t, err)
+			require.Len(t, results, 3)
+
+			assert.Equal(t, "2377225624", results[0].Response.Order)
+			assert.ErrorIs(t, results[1].Err, accrual.ErrOrderNotRegistered)
+			assert.Equal(t, "79927398713", results[2].Response.Order)
+		}
+
+		// the batch endpoint is not tried again once it is known to be missing
+		assert.EqualValues(t, 1, batchHits.Load())
+		assert.EqualValues(t, 6, singleHits.Load())
+		// every request waits for its turn, the failed batch one too
+		assert.EqualValues(t, 7, waiter.n.Load())
+	})
+
+	t.Run("batch_is_tried_again", func(t *testing.T) {
+		var batchHits, singleHits atomic.Int32
+
+		c := accrual.NewAccrualClient(client.NewRestyClient(), noBatch(&batchHits, &singleHits), accrual.WithBatchRecheck(50*time.Millisecond))
+
+		_, err := c.OrderStatuses(context.Background(), numbers, ratelimit.NewLimiter(0))
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, batchHits.Load())
+
+		time.Sleep(60 * time.Millisecond)
+
+		_, err = c.OrderStatuses(context.Background(), numbers, ratelimit.NewLimiter(0))
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, batchHits.Load())
+	})
+
+	t.Run("rate_limited", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("No more than 10 requests per minute allowed"))
+		}))
+		t.Cleanup(server.Close)
+
+		c := accrual.NewAccrualClient(client.NewRestyClient(), server.URL)
+		limiter := ratelimit.NewLimiter(0)
+
+		_, err := c.OrderStatuses(context.Background(), numbers, limiter)
+
+		var rateErr *accrual.RateLimitError
+		require.ErrorAs(t, err, &rateErr)
+		assert.Equal(t, 10, rateErr.PerMinute)
+		assert.Equal(t, 10, limiter.PerMinute())
+	})
+
+	t.Run("rate_limited_single_lookup_pauses_the_others", func(t *testing.T) {
+		var hits atomic.Int32
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			hits.Add(1)
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		t.Cleanup(server.Close)
+
+		c := accrual.NewAccrualClient(client.NewRestyClient(), server.URL)
+
+		// requests are spaced out, so the others are still waiting when the first one gets 429
+		limiter := ratelimit.NewLimiter(600)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+
+		results, err := c.OrderStatuses(ctx, []string{"2377225624", "12345678903", "79927398713", "4561261212345467"}, limiter)
+		require.NoError(t, err)
+
+		assert.EqualValues(t, 1, hits.Load())
+
+		var limited, cancelled int
+		for _, r := range results {
+			switch {
+			case errors.Is(r.Err, accrual.ErrTooManyRequests):
+				limited++
+			case errors.Is(r.Err, context.DeadlineExceeded):
+				cancelled++
+			}
+		}
+		assert.Equal(t, 1, limited)
+		assert.Equal(t, 3, cancelled)
+	})
+}
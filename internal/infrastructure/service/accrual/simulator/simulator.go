@@ -0,0 +1,337 @@
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	serviceAccrual "github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/validator"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusInvalid    = "INVALID"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+)
+
+var (
+	ErrInvalidOrder      = errors.New("invalid order")
+	ErrInvalidReward     = errors.New("invalid reward")
+	ErrUnknownRewardType = errors.New("unknown reward type")
+	ErrAlreadyRegistered = errors.New("already registered")
+)
+
+// Rules shape the answers of the status endpoint.
+type Rules struct {
+	// Latency added to every answer of the status endpoint.
+	Latency time.Duration
+	// ProcessingTime from the registration of an order to its final status. The order is REGISTERED for the first
+	// half of it and PROCESSING for the second.
+	ProcessingTime time.Duration
+	// InvalidProbability share of registered orders that end INVALID, from 0 to 1.
+	InvalidProbability float64
+	// PerMinute requests to the status endpoints allowed per minute, zero is unlimited. A batch is one request.
+	PerMinute int
+	// BatchLookup serves POST /api/orders/batch. The reference accrual system has no such endpoint, so it is off by
+	// default and the client falls back to single lookups.
+	BatchLookup bool
+}
+
+type RewardType string
+
+const (
+	RewardPercent RewardType = "%"
+	RewardPoints  RewardType = "pt"
+)
+
+// Reward is given for goods whose description contains Match, case-insensitively.
+type Reward struct {
+	Match  string       `json:"match"`
+	Reward model.Points `json:"reward"`
+	Type   RewardType   `json:"reward_type"`
+}
+
+// accrual returns the reward for one good.
+func (r Reward) accrual(price model.Points) model.Points {
+	if r.Type == RewardPoints {
+		return r.Reward
+	}
+
+	// both values are in hundredths, the result is rounded down to hundredths of a point
+	return price * r.Reward / 100 / 100
+}
+
+type Good struct {
+	Description string       `json:"description"`
+	Price       model.Points `json:"price"`
+}
+
+type Order struct {
+	Order string `json:"order"`
+	Goods []Good `json:"goods"`
+}
+
+type order struct {
+	registeredAt time.Time
+	invalid      bool
+	accrual      model.Points
+}
+
+// Simulator stands in for the accrual system: it keeps registered orders and rewards in memory and answers
+// GET /api/orders/{number} the way the specification describes.
+type Simulator struct {
+	rules Rules
+
+	mu      sync.Mutex
+	rewards []Reward
+	orders  map[string]order
+	// the rate limit window starts with the first request after the previous one is over
+	windowStart time.Time
+	requests    int
+}
+
+func New(rules Rules) *Simulator {
+	return &Simulator{
+		rules:  rules,
+		orders: make(map[string]order),
+	}
+}
+
+// AddReward registers a reward. Orders registered earlier are not recalculated.
+func (s *Simulator) AddReward(reward Reward) error {
+	reward.Match = strings.TrimSpace(reward.Match)
+	if reward.Match == "" || reward.Reward <= 0 {
+		return ErrInvalidReward
+	}
+	if reward.Type != RewardPercent && reward.Type != RewardPoints {
+		return fmt.Errorf("%w: %q", ErrUnknownRewardType, reward.Type)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range s.rewards {
+		if strings.EqualFold(r.Match, reward.Match) {
+			return fmt.Errorf("%w: %s", ErrAlreadyRegistered, reward.Match)
+		}
+	}
+
+	s.rewards = append(s.rewards, reward)
+
+	return nil
+}
+
+// Register takes the order into calculation. Its accrual is the sum of the first matching reward of every good.
+func (s *Simulator) Register(o Order) error {
+	if !validator.CheckLuhn(o.Order) {
+		return fmt.Errorf("%w: %s", ErrInvalidOrder, o.Order)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.orders[o.Order]; ok {
+		return fmt.Errorf("%w: %s", ErrAlreadyRegistered, o.Order)
+	}
+
+	var accrual model.Points
+	for _, good := range o.Goods {
+		for _, r := range s.rewards {
+			if strings.Contains(strings.ToLower(good.Description), strings.ToLower(r.Match)) {
+				accrual += r.accrual(good.Price)
+				break
+			}
+		}
+	}
+
+	s.orders[o.Order] = order{
+		registeredAt: time.Now(),
+		invalid:      rand.Float64() < s.rules.InvalidProbability,
+		accrual:      accrual,
+	}
+
+	return nil
+}
+
+// Status returns the current status of the order, or nil if it is not registered.
+func (s *Simulator) Status(orderNumber string) *serviceAccrual.Response {
+	s.mu.Lock()
+	o, ok := s.orders[orderNumber]
+	s.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	resp := &serviceAccrual.Response{Order: orderNumber}
+
+	elapsed := time.Now().Sub(o.registeredAt)
+	switch {
+	case elapsed < s.rules.ProcessingTime/2:
+		resp.Status = StatusRegistered
+	case elapsed < s.rules.ProcessingTime:
+		resp.Status = StatusProcessing
+	case o.invalid:
+		resp.Status = StatusInvalid
+	default:
+		resp.Status = StatusProcessed
+		if o.accrual > 0 {
+			accrual := o.accrual
+			resp.Accrual = &accrual
+		}
+	}
+
+	return resp
+}
+
+// allow counts the request and returns how long to wait if the limit per minute is exceeded.
+func (s *Simulator) allow() (time.Duration, bool) {
+	if s.rules.PerMinute <= 0 {
+		return 0, true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.windowStart) >= time.Minute {
+		s.windowStart = now
+		s.requests = 0
+	}
+
+	if s.requests >= s.rules.PerMinute {
+		return s.windowStart.Add(time.Minute).Sub(now), false
+	}
+	s.requests++
+
+	return 0, true
+}
+
+// Handler serves the status endpoint of the specification, the batch one if Rules.BatchLookup is set, and the admin
+// endpoints:
+//
+//	POST /api/orders {"order": "<number>", "goods": [{"description": "...", "price": 7000}]}
+//	POST /api/goods {"match": "Bork", "reward": 10, "reward_type": "%"}
+func (s *Simulator) Handler() http.Handler {
+	r := chi.NewRouter()
+
+	r.Get("/api/orders/{number}", s.handleStatus)
+	if s.rules.BatchLookup {
+		r.Post("/api/orders/batch", s.handleBatchStatus)
+	}
+	r.Post("/api/orders", s.handleRegister)
+	r.Post("/api/goods", s.handleAddReward)
+
+	return r
+}
+
+func (s *Simulator) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.admit(w, r) {
+		return
+	}
+
+	resp := s.Status(chi.URLParam(r, "number"))
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+func (s *Simulator) handleBatchStatus(w http.ResponseWriter, r *http.Request) {
+	var req serviceAccrual.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	if !s.admit(w, r) {
+		return
+	}
+
+	found := make([]*serviceAccrual.Response, 0, len(req.Orders))
+	for _, number := range req.Orders {
+		if resp := s.Status(number); resp != nil {
+			found = append(found, resp)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(found); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// admit applies the rate limit and the latency to a status request. It reports false if the request is answered
+// already or gone.
+func (s *Simulator) admit(w http.ResponseWriter, r *http.Request) bool {
+	if retryAfter, ok := s.allow(); !ok {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = fmt.Fprintf(w, "No more than %d requests per minute allowed", s.rules.PerMinute)
+		return false
+	}
+
+	if s.rules.Latency > 0 {
+		select {
+		case <-time.After(s.rules.Latency):
+		case <-r.Context().Done():
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s *Simulator) handleRegister(w http.ResponseWriter, r *http.Request) {
+	var req Order
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err := s.Register(req)
+	switch {
+	case errors.Is(err, ErrAlreadyRegistered):
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+	case err != nil:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (s *Simulator) handleAddReward(w http.ResponseWriter, r *http.Request) {
+	var req Reward
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	err := s.AddReward(req)
+	switch {
+	case errors.Is(err, ErrAlreadyRegistered):
+		http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+	case err != nil:
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
@@ -0,0 +1,127 @@
+package simulator_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual/simulator"
+)
+
+func do(t *testing.T, h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	return w
+}
+
+func TestSimulator(t *testing.T) {
+	t.Run("processed_with_rewards", func(t *testing.T) {
+		h := simulator.New(simulator.Rules{}).Handler()
+
+		assert.Equal(t, http.StatusOK, do(t, h, http.MethodPost, "/api/goods", `{"match":"Bork","reward":10,"reward_type":"%"}`).Code)
+		assert.Equal(t, http.StatusOK, do(t, h, http.MethodPost, "/api/goods", `{"match":"Cup","reward":2.5,"reward_type":"pt"}`).Code)
+		assert.Equal(t, http.StatusConflict, do(t, h, http.MethodPost, "/api/goods", `{"match":"bork","reward":5,"reward_type":"%"}`).Code)
+
+		order := `{"order":"12345678903","goods":[
+			{"description":"Чайник Bork","price":7000.55},
+			{"description":"cup","price":100},
+			{"description":"Spoon","price":50}
+		]}`
+		assert.Equal(t, http.StatusAccepted, do(t, h, http.MethodPost, "/api/orders", order).Code)
+		assert.Equal(t, http.StatusConflict, do(t, h, http.MethodPost, "/api/orders", order).Code)
+
+		w := do(t, h, http.MethodGet, "/api/orders/12345678903", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"order":"12345678903","status":"PROCESSED","accrual":702.55}`, w.Body.String())
+	})
+
+	t.Run("without_rewards_accrual_is_omitted", func(t *testing.T) {
+		h := simulator.New(simulator.Rules{}).Handler()
+
+		require.Equal(t, http.StatusAccepted, do(t, h, http.MethodPost, "/api/orders", `{"order":"12345678903","goods":[]}`).Code)
+
+		w := do(t, h, http.MethodGet, "/api/orders/12345678903", "")
+		assert.JSONEq(t, `{"order":"12345678903","status":"PROCESSED"}`, w.Body.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		h := simulator.New(simulator.Rules{InvalidProbability: 1}).Handler()
+
+		require.Equal(t, http.StatusAccepted, do(t, h, http.MethodPost, "/api/orders", `{"order":"12345678903"}`).Code)
+
+		w := do(t, h, http.MethodGet, "/api/orders/12345678903", "")
+		assert.JSONEq(t, `{"order":"12345678903","status":"INVALID"}`, w.Body.String())
+	})
+
+	t.Run("processing_time", func(t *testing.T) {
+		sim := simulator.New(simulator.Rules{ProcessingTime: 200 * time.Millisecond})
+		require.NoError(t, sim.Register(simulator.Order{Order: "12345678903"}))
+
+		assert.Equal(t, simulator.StatusRegistered, sim.Status("12345678903").Status)
+
+		time.Sleep(250 * time.Millisecond)
+		assert.Equal(t, simulator.StatusProcessed, sim.Status("12345678903").Status)
+	})
+
+	t.Run("unknown_order", func(t *testing.T) {
+		h := simulator.New(simulator.Rules{}).Handler()
+
+		assert.Equal(t, http.StatusNoContent, do(t, h, http.MethodGet, "/api/orders/12345678903", "").Code)
+	})
+
+	t.Run("rate_limited", func(t *testing.T) {
+		h := simulator.New(simulator.Rules{PerMinute: 2}).Handler()
+
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusNoContent, do(t, h, http.MethodGet, "/api/orders/12345678903", "").Code)
+		}
+
+		w := do(t, h, http.MethodGet, "/api/orders/12345678903", "")
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+		body, err := io.ReadAll(w.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "No more than 2 requests per minute allowed", string(body))
+	})
+
+	t.Run("batch_lookup", func(t *testing.T) {
+		body := `{"orders":["12345678903","79927398713"]}`
+
+		h := simulator.New(simulator.Rules{}).Handler()
+		assert.Equal(t, http.StatusMethodNotAllowed, do(t, h, http.MethodPost, "/api/orders/batch", body).Code)
+
+		sim := simulator.New(simulator.Rules{BatchLookup: true})
+		require.NoError(t, sim.Register(simulator.Order{Order: "12345678903"}))
+
+		w := do(t, sim.Handler(), http.MethodPost, "/api/orders/batch", body)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"order":"12345678903","status":"PROCESSED"}]`, w.Body.String())
+	})
+
+	t.Run("bad_requests", func(t *testing.T) {
+		h := simulator.New(simulator.Rules{}).Handler()
+
+		for _, tc := range []struct {
+			target string
+			body   string
+		}{
+			{"/api/orders", `{"order":"12345678900"}`},
+			{"/api/orders", `{`},
+			{"/api/goods", `{"match":"","reward":10,"reward_type":"%"}`},
+			{"/api/goods", `{"match":"Bork","reward":10,"reward_type":"x"}`},
+			{"/api/goods", `{"match":"Bork","reward":0,"reward_type":"pt"}`},
+		} {
+			assert.Equal(t, http.StatusBadRequest, do(t, h, http.MethodPost, tc.target, tc.body).Code, tc.body)
+		}
+	})
+}
@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var (
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	ErrForbiddenAddress = errors.New("address is not public")
+
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+// Payload is the body of a webhook request.
+type Payload struct {
+	ID        string          `json:"id"`
+	Type      model.EventType `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// NewPayload wraps the outbox event into the webhook body. The event GUID lets the subscriber drop repeated deliveries.
+func NewPayload(event model.Event) ([]byte, error) {
+	return json.Marshal(Payload{
+		ID:        event.GUID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      event.Payload,
+	})
+}
+
+type Client struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+type ClientOption func(*Client)
+
+// WithPrivateAddresses lets the client send requests to loopback and private addresses, e.g. to a local receiver in
+// tests.
+func WithPrivateAddresses() ClientOption {
+	return func(c *Client) {
+		c.allowPrivate = true
+	}
+}
+
+func NewClient(timeout time.Duration, opts ...ClientOption) *Client {
+	c := new(Client)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	// the address is checked after DNS resolution, so a subscriber's host name can't point into the internal network
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			if c.allowPrivate {
+				return nil
+			}
+
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil || !IsPublicAddr(addr.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+			}
+
+			return nil
+		},
+	}
+
+	c.client = &http.Client{
+		Timeout: timeout,
+		// a proxy would dial the subscriber itself, bypassing the address check
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			ForceAttemptHTTP2:   true,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: timeout,
+		},
+		// a subscriber must answer itself, a redirect would send the signed body somewhere else
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return c
+}
+
+// IsPublicAddr reports whether addr may be a webhook receiver: loopback, private (RFC 1918, RFC 4193), link-local
+// (including the cloud metadata address 169.254.169.254), shared (RFC 6598), multicast and unspecified addresses
+// are not.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsValid() &&
+		!addr.IsLoopback() &&
+		!addr.IsPrivate() &&
+		!addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() &&
+		!addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast() &&
+		!addr.IsUnspecified() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// Send posts the signed delivery to the subscriber. It returns the response status code, zero if there was no
+// response, and an error unless the subscriber answered 2xx.
+func (c Client) Send(ctx context.Context, dispatch model.WebhookDispatch) (int, error) {
+	body := dispatch.Delivery.Payload
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dispatch.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(dispatch.Delivery.EventType))
+	req.Header.Set(HeaderDelivery, dispatch.Delivery.GUID)
+	req.Header.Set(HeaderSignature, Sign(dispatch.Secret, time.Now(), body))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	// drain the body, so the connection is reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("%w: %d", ErrUnexpectedStatus, resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,154 @@
+package webhook_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+const secret = "0123456789abcdef"
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, webhook.Verify(secret, webhook.Sign(secret, time.Now(), body), body, time.Minute))
+	})
+
+	t.Run("tampered_body", func(t *testing.T) {
+		err := webhook.Verify(secret, webhook.Sign(secret, time.Now(), body), []byte(`{"id":"2"}`), time.Minute)
+		assert.ErrorIs(t, err, webhook.ErrInvalidSignature)
+	})
+
+	t.Run("wrong_secret", func(t *testing.T) {
+		err := webhook.Verify("another", webhook.Sign(secret, time.Now(), body), body, time.Minute)
+		assert.ErrorIs(t, err, webhook.ErrInvalidSignature)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		err := webhook.Verify(secret, webhook.Sign(secret, time.Now().Add(-time.Hour), body), body, time.Minute)
+		assert.ErrorIs(t, err, webhook.ErrExpiredSignature)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		assert.ErrorIs(t, webhook.Verify(secret, "v1=abc", body, 0), webhook.ErrInvalidSignature)
+	})
+}
+
+func TestClient_Send(t *testing.T) {
+	event := model.Event{
+		GUID:      "8f0f3b9e-3c4a-4b1e-9d55-2f7b0b5f5e01",
+		Type:      model.EventAccrualProcessed,
+		Payload:   []byte(`{"order":"2377225624","status":"PROCESSED","accrual":500}`),
+		CreatedAt: time.Now(),
+	}
+	payload, err := webhook.NewPayload(event)
+	require.NoError(t, err)
+
+	dispatch := func(url string) model.WebhookDispatch {
+		return model.WebhookDispatch{
+			Delivery: model.WebhookDelivery{
+				GUID:      "d1",
+				EventGUID: event.GUID,
+				EventType: event.Type,
+				Payload:   payload,
+			},
+			URL:    url,
+			Secret: secret,
+		}
+	}
+
+	t.Run("signed_request", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.MethodPost, r.Method)
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, "accrual.processed", r.Header.Get(webhook.HeaderEvent))
+			assert.Equal(t, "d1", r.Header.Get(webhook.HeaderDelivery))
+			assert.NoError(t, webhook.Verify(secret, r.Header.Get(webhook.HeaderSignature), body, time.Minute))
+			assert.JSONEq(t, string(payload), string(body))
+
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(receiver.Close)
+
+		code, err := webhook.NewClient(time.Second, webhook.WithPrivateAddresses()).Send(context.Background(), dispatch(receiver.URL))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNoContent, code)
+	})
+
+	t.Run("error_status", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		t.Cleanup(receiver.Close)
+
+		code, err := webhook.NewClient(time.Second, webhook.WithPrivateAddresses()).Send(context.Background(), dispatch(receiver.URL))
+		assert.ErrorIs(t, err, webhook.ErrUnexpectedStatus)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+	})
+
+	t.Run("redirect_is_not_followed", func(t *testing.T) {
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "https://example.com/", http.StatusFound)
+		}))
+		t.Cleanup(receiver.Close)
+
+		code, err := webhook.NewClient(time.Second, webhook.WithPrivateAddresses()).Send(context.Background(), dispatch(receiver.URL))
+		assert.ErrorIs(t, err, webhook.ErrUnexpectedStatus)
+		assert.Equal(t, http.StatusFound, code)
+	})
+
+	t.Run("private_address_is_rejected", func(t *testing.T) {
+		var called atomic.Bool
+		receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			called.Store(true)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(receiver.Close)
+
+		code, err := webhook.NewClient(time.Second).Send(context.Background(), dispatch(receiver.URL))
+		assert.ErrorIs(t, err, webhook.ErrForbiddenAddress)
+		assert.Zero(t, code)
+		assert.False(t, called.Load())
+	})
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			assert.Equal(t, tt.want, webhook.IsPublicAddr(netip.MustParseAddr(tt.addr)))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderSignature = "X-Gophermart-Signature"
+	HeaderEvent     = "X-Gophermart-Event"
+	HeaderDelivery  = "X-Gophermart-Delivery"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrExpiredSignature = errors.New("expired webhook signature")
+)
+
+// NewSecret generates a signing key for a new webhook.
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">". The time is
+// signed too, so a receiver can reject a replayed request.
+func Sign(secret string, at time.Time, body []byte) string {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts, body))
+}
+
+// Verify checks the signature header of a received webhook, as a subscriber does. A signature older than tolerance
+// is rejected, zero tolerance disables the check.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: bad timestamp", ErrInvalidSignature)
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, mac(secret, ts, body)) {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 && time.Since(time.Unix(unix, 0)) > tolerance {
+		return ErrExpiredSignature
+	}
+
+	return nil
+}
+
+func mac(secret, ts string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+
+	return h.Sum(nil)
+}
@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type AccrualStatus uint
 
@@ -25,12 +28,29 @@ func (s AccrualStatus) String() string {
 	return "Unknown"
 }
 
+func (s AccrualStatus) IsFinal() bool {
+	return s == Invalid || s == Processed
+}
+
+func ParseAccrualStatus(s string) (AccrualStatus, bool) {
+	for _, status := range []AccrualStatus{New, Processing, Invalid, Processed} {
+		if strings.EqualFold(status.String(), s) {
+			return status, true
+		}
+	}
+
+	return 0, false
+}
+
 type Accrual struct {
 	OrderNumber string
 	UserGUID    string
 	Status      AccrualStatus
-	Accrual     float64
+	Accrual     Points
 	UploadedAt  time.Time
+	Attempts    int // Ответы без смены статуса
+	LastError   string
+	GaveUp      bool
 }
 
 func NewAccrual(orderNumber, userGUID string) *Accrual {
@@ -41,3 +61,16 @@ func NewAccrual(orderNumber, userGUID string) *Accrual {
 		UploadedAt:  time.Now(),
 	}
 }
+
+type AccrualCursor struct {
+	UploadedAt  time.Time
+	OrderNumber string
+}
+
+type AccrualFilter struct {
+	Statuses     []AccrualStatus
+	UploadedFrom time.Time
+	UploadedTo   time.Time
+	After        *AccrualCursor
+	Limit        int
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type EventType string
+
+const (
+	EventAccrualProcessed EventType = "accrual.processed"
+	EventAccrualInvalid   EventType = "accrual.invalid"
+	EventAccrualGaveUp    EventType = "accrual.gave_up"
+	EventBalanceWithdrawn EventType = "balance.withdrawn"
+)
+
+// Event доставляется не реже одного раза, повторы отбрасываются по GUID.
+type Event struct {
+	ID        int64
+	GUID      string
+	Type      EventType
+	UserGUID  string
+	Payload   []byte
+	CreatedAt time.Time
+	Attempts  int
+}
@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type IdempotencyKey struct {
+	Key                 string
+	UserGUID            string
+	RequestHash         string
+	ResponseStatus      int
+	ResponseContentType string
+	ResponseBody        []byte
+	CreatedAt           time.Time
+	LockedUntil         time.Time // После него ключ можно занять повторно
+	LockToken           string
+}
+
+func NewIdempotencyKey(key, userGUID, requestHash string, lockTTL time.Duration) *IdempotencyKey {
+	now := time.Now()
+
+	return &IdempotencyKey{
+		Key:         key,
+		UserGUID:    userGUID,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		LockedUntil: now.Add(lockTTL),
+	}
+}
+
+func (k IdempotencyKey) Completed() bool {
+	return k.ResponseStatus != 0
+}
@@ -0,0 +1,97 @@
+package model
+
+import "time"
+
+type LedgerAccountType uint
+
+const (
+	UserLedgerAccount LedgerAccountType = iota
+	AccrualFundingLedgerAccount
+	RedemptionLedgerAccount
+)
+
+// Системные счета создаются миграцией.
+const (
+	AccrualFundingAccountGUID = "00000000-0000-0000-0000-000000000001"
+	RedemptionAccountGUID     = "00000000-0000-0000-0000-000000000002"
+)
+
+type LedgerEntryType uint
+
+const (
+	AccrualEntry LedgerEntryType = iota
+	WithdrawEntry
+	AdjustmentEntry
+)
+
+// Posting сумма больше нуля - кредит, меньше нуля - дебет.
+type Posting struct {
+	AccountGUID string
+	Amount      Points
+}
+
+type LedgerEntry struct {
+	GUID        string
+	Type        LedgerEntryType
+	UserGUID    string
+	OrderNumber string
+	Description string
+	Postings    []Posting
+	CreatedAt   time.Time
+}
+
+func NewLedgerEntry(transaction Transaction) LedgerEntry {
+	entry := LedgerEntry{
+		GUID:        transaction.GUID,
+		UserGUID:    transaction.AccountGUID,
+		OrderNumber: transaction.OrderNumber,
+		CreatedAt:   transaction.ProcessedAt,
+	}
+
+	switch transaction.Type {
+	case Add:
+		entry.Type = AccrualEntry
+		entry.Postings = []Posting{
+			{AccountGUID: transaction.AccountGUID, Amount: transaction.Sum},
+			{AccountGUID: AccrualFundingAccountGUID, Amount: -transaction.Sum},
+		}
+	case Withdraw:
+		entry.Type = WithdrawEntry
+		entry.Postings = []Posting{
+			{AccountGUID: transaction.AccountGUID, Amount: -transaction.Sum},
+			{AccountGUID: RedemptionAccountGUID, Amount: transaction.Sum},
+		}
+	}
+
+	return entry
+}
+
+func NewAdjustmentEntry(guid, userGUID string, amount Points, description string, createdAt time.Time) LedgerEntry {
+	return LedgerEntry{
+		GUID:        guid,
+		Type:        AdjustmentEntry,
+		UserGUID:    userGUID,
+		Description: description,
+		Postings: []Posting{
+			{AccountGUID: userGUID, Amount: amount},
+			{AccountGUID: AccrualFundingAccountGUID, Amount: -amount},
+		},
+		CreatedAt: createdAt,
+	}
+}
+
+func (e LedgerEntry) Balanced() bool {
+	if len(e.Postings) < 2 {
+		return false
+	}
+
+	var total Points
+	for _, p := range e.Postings {
+		if p.Amount == 0 {
+			return false
+		}
+		total += p.Amount
+	}
+
+	return total == 0
+}
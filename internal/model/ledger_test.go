@@ -0,0 +1,100 @@
+package model_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestNewLedgerEntry(t *testing.T) {
+	const (
+		userGUID    = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+		orderNumber = "2377225624"
+	)
+
+	now := time.Now()
+	sum := model.NewPoints(729, 98)
+
+	t.Run("accrual", func(t *testing.T) {
+		entry := model.NewLedgerEntry(model.NewAddTransaction("guid", userGUID, orderNumber, sum, now))
+
+		assert.Equal(t, model.AccrualEntry, entry.Type)
+		assert.True(t, entry.Balanced())
+		assert.Equal(t, []model.Posting{
+			{AccountGUID: userGUID, Amount: sum},
+			{AccountGUID: model.AccrualFundingAccountGUID, Amount: -sum},
+		}, entry.Postings)
+	})
+
+	t.Run("withdraw", func(t *testing.T) {
+		entry := model.NewLedgerEntry(model.NewWithdrawTransaction("guid", userGUID, orderNumber, sum, now))
+
+		assert.Equal(t, model.WithdrawEntry, entry.Type)
+		assert.True(t, entry.Balanced())
+		assert.Equal(t, []model.Posting{
+			{AccountGUID: userGUID, Amount: -sum},
+			{AccountGUID: model.RedemptionAccountGUID, Amount: sum},
+		}, entry.Postings)
+	})
+
+	t.Run("adjustment", func(t *testing.T) {
+		entry := model.NewAdjustmentEntry("guid", userGUID, -sum, "drift", now)
+
+		assert.Equal(t, model.AdjustmentEntry, entry.Type)
+		assert.True(t, entry.Balanced())
+	})
+}
+
+func TestLedgerEntry_Balanced(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []model.Posting
+		want     bool
+	}{
+		{
+			name:     "no_postings",
+			postings: nil,
+			want:     false,
+		},
+		{
+			name:     "single_posting",
+			postings: []model.Posting{{AccountGUID: "a", Amount: 0}},
+			want:     false,
+		},
+		{
+			name: "unbalanced",
+			postings: []model.Posting{
+				{AccountGUID: "a", Amount: 100},
+				{AccountGUID: "b", Amount: -99},
+			},
+			want: false,
+		},
+		{
+			name: "zero_amount",
+			postings: []model.Posting{
+				{AccountGUID: "a", Amount: 0},
+				{AccountGUID: "b", Amount: 0},
+			},
+			want: false,
+		},
+		{
+			name: "balanced",
+			postings: []model.Posting{
+				{AccountGUID: "a", Amount: 100},
+				{AccountGUID: "b", Amount: -60},
+				{AccountGUID: "c", Amount: -40},
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := model.LedgerEntry{Postings: tt.postings}
+			assert.Equal(t, tt.want, entry.Balanced())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+type ThrottleSubject string
+
+const (
+	ThrottleLogin ThrottleSubject = "login"
+	ThrottleIP    ThrottleSubject = "ip"
+)
+
+func ThrottleKey(subject ThrottleSubject, value string) string {
+	return string(subject) + ":" + value
+}
+
+type LoginThrottle struct {
+	Key             string
+	Failures        int
+	WindowStartedAt time.Time
+	BlockedUntil    time.Time
+}
+
+func (t LoginThrottle) RetryAfter(now time.Time) time.Duration {
+	if !now.Before(t.BlockedUntil) {
+		return 0
+	}
+
+	return t.BlockedUntil.Sub(now)
+}
+
+type LoginLockout struct {
+	GUID        string
+	Subject     ThrottleSubject
+	Value       string
+	Failures    int
+	LockedUntil time.Time
+	CreatedAt   time.Time
+}
+
+func NewLoginLockout(guid string, subject ThrottleSubject, value string, failures int, lockedUntil time.Time) *LoginLockout {
+	return &LoginLockout{
+		GUID:        guid,
+		Subject:     subject,
+		Value:       value,
+		Failures:    failures,
+		LockedUntil: lockedUntil,
+		CreatedAt:   time.Now(),
+	}
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+type PasswordReset struct {
+	GUID      string
+	UserGUID  string
+	TokenHash string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func NewPasswordReset(guid, userGUID, tokenHash string, ttl time.Duration) *PasswordReset {
+	now := time.Now()
+
+	return &PasswordReset{
+		GUID:      guid,
+		UserGUID:  userGUID,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+}
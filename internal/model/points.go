@@ -0,0 +1,119 @@
+package model
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const pointsScale = 100
+
+var (
+	ErrInvalidPoints  = errors.New("invalid points value")
+	ErrPointsOverflow = errors.New("points value overflow")
+)
+
+// Points сумма в сотых долях балла.
+type Points int64
+
+func NewPoints(units, cents int64) Points {
+	return Points(units*pointsScale + cents)
+}
+
+func ParsePoints(s string) (Points, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPoints, s)
+	}
+
+	r.Mul(r, big.NewRat(pointsScale, 1))
+
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("%w: %q", ErrPointsOverflow, s)
+	}
+
+	return Points(quo.Int64()), nil
+}
+
+func (p Points) String() string {
+	sign := ""
+	v := int64(p)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	units, cents := v/pointsScale, v%pointsScale
+	if cents == 0 {
+		return sign + strconv.FormatInt(units, 10)
+	}
+
+	return strings.TrimRight(fmt.Sprintf("%s%d.%02d", sign, units, cents), "0")
+}
+
+func (p Points) MarshalJSON() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+func (p *Points) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+
+	v, err := ParsePoints(s)
+	if err != nil {
+		return err
+	}
+
+	*p = v
+	return nil
+}
+
+func (p *Points) Scan(src any) error {
+	var (
+		v   Points
+		err error
+	)
+
+	switch s := src.(type) {
+	case nil:
+		v = 0
+	case string:
+		v, err = ParsePoints(s)
+	case []byte:
+		v, err = ParsePoints(string(s))
+	case int64:
+		v = Points(s * pointsScale)
+	case float64:
+		v, err = ParsePoints(strconv.FormatFloat(s, 'f', -1, 64))
+	default:
+		return fmt.Errorf("%w: unsupported type %T", ErrInvalidPoints, src)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*p = v
+	return nil
+}
+
+func (p Points) Value() (driver.Value, error) {
+	return p.String(), nil
+}
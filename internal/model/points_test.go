@@ -0,0 +1,107 @@
+package model_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+func TestParsePoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    model.Points
+		wantErr bool
+	}{
+		{name: "integer", value: "500", want: model.NewPoints(500, 0)},
+		{name: "fraction", value: "729.98", want: model.NewPoints(729, 98)},
+		{name: "one_digit_fraction", value: "0.5", want: model.NewPoints(0, 50)},
+		{name: "exponent", value: "1e2", want: model.NewPoints(100, 0)},
+		{name: "round_half_up", value: "0.005", want: model.NewPoints(0, 1)},
+		{name: "round_down", value: "0.004", want: 0},
+		{name: "negative", value: "-1.25", want: -model.NewPoints(1, 25)},
+		{name: "invalid", value: "abc", wantErr: true},
+		{name: "overflow", value: "1e100", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := model.ParsePoints(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPoints_String(t *testing.T) {
+	assert.Equal(t, "729.98", model.NewPoints(729, 98).String())
+	assert.Equal(t, "729.9", model.NewPoints(729, 90).String())
+	assert.Equal(t, "500", model.NewPoints(500, 0).String())
+	assert.Equal(t, "0.01", model.NewPoints(0, 1).String())
+	assert.Equal(t, "-0.5", (-model.NewPoints(0, 50)).String())
+	assert.Equal(t, "0", model.Points(0).String())
+}
+
+func TestPoints_JSON(t *testing.T) {
+	t.Run("no_float_drift", func(t *testing.T) {
+		var sum model.Points
+		step := model.NewPoints(0, 1)
+		for i := 0; i < 72998; i++ {
+			sum += step
+		}
+
+		data, err := json.Marshal(sum)
+		require.NoError(t, err)
+		assert.Equal(t, "729.98", string(data))
+	})
+
+	t.Run("unmarshal", func(t *testing.T) {
+		var v struct {
+			Sum     model.Points  `json:"sum"`
+			Accrual *model.Points `json:"accrual,omitempty"`
+		}
+
+		err := json.Unmarshal([]byte(`{"sum": 751.1, "accrual": 500}`), &v)
+		require.NoError(t, err)
+		assert.Equal(t, model.NewPoints(751, 10), v.Sum)
+		require.NotNil(t, v.Accrual)
+		assert.Equal(t, model.NewPoints(500, 0), *v.Accrual)
+	})
+
+	t.Run("unmarshal_string", func(t *testing.T) {
+		var p model.Points
+		assert.Error(t, json.Unmarshal([]byte(`"500"`), &p))
+	})
+}
+
+func TestPoints_Scan(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  model.Points
+	}{
+		{name: "string", value: "729.98", want: model.NewPoints(729, 98)},
+		{name: "bytes", value: []byte("0.10"), want: model.NewPoints(0, 10)},
+		{name: "int64", value: int64(3), want: model.NewPoints(3, 0)},
+		{name: "float64", value: 729.98, want: model.NewPoints(729, 98)},
+		{name: "nil", value: nil, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := model.Points(42)
+			err := p.Scan(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, p)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package model
+
+type AccountTotals struct {
+	AccountGUID           string
+	MaterialisedBalance   Points
+	LedgerBalance         Points
+	LedgerWithdrawn       Points
+	TransactionsAccrued   Points
+	TransactionsWithdrawn Points
+}
+
+// ExpectedBalance корректировки не входят: они есть только в главной книге.
+func (t AccountTotals) ExpectedBalance() Points {
+	return t.TransactionsAccrued - t.TransactionsWithdrawn
+}
+
+type AccrualTotals struct {
+	OrderNumber string
+	UserGUID    string
+	Accrual     Points
+	Credited    Points
+}
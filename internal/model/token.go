@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+type RefreshToken struct {
+	GUID            string
+	FamilyGUID      string // Цепочка обновлений от одного входа
+	UserGUID        string
+	TokenHash       string
+	AccessJTI       string
+	AccessExpiresAt time.Time
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	UsedAt          *time.Time // Повторный обмен - признак кражи
+	RevokedAt       *time.Time
+}
+
+func (t RefreshToken) Active(now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
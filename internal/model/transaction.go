@@ -1,6 +1,9 @@
 package model
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type TransactionType uint
 
@@ -9,16 +12,36 @@ const (
 	Withdraw                        // Сняли со счета
 )
 
+func (t TransactionType) String() string {
+	switch t {
+	case Add:
+		return "Accrual"
+	case Withdraw:
+		return "Withdrawal"
+	}
+	return "Unknown"
+}
+
+func ParseTransactionType(s string) (TransactionType, bool) {
+	for _, t := range []TransactionType{Add, Withdraw} {
+		if strings.EqualFold(t.String(), s) {
+			return t, true
+		}
+	}
+
+	return 0, false
+}
+
 type Transaction struct {
 	GUID        string
 	AccountGUID string
 	OrderNumber string
 	Type        TransactionType
-	Sum         float64
+	Sum         Points
 	ProcessedAt time.Time
 }
 
-func NewAddTransaction(guid, accountGUID, orderNumber string, sum float64, processedAt time.Time) Transaction {
+func NewAddTransaction(guid, accountGUID, orderNumber string, sum Points, processedAt time.Time) Transaction {
 	return Transaction{
 		GUID:        guid,
 		AccountGUID: accountGUID,
@@ -29,7 +52,7 @@ func NewAddTransaction(guid, accountGUID, orderNumber string, sum float64, proce
 	}
 }
 
-func NewWithdrawTransaction(guid, accountGUID, orderNumber string, sum float64, processedAt time.Time) Transaction {
+func NewWithdrawTransaction(guid, accountGUID, orderNumber string, sum Points, processedAt time.Time) Transaction {
 	return Transaction{
 		GUID:        guid,
 		AccountGUID: accountGUID,
@@ -39,3 +62,23 @@ func NewWithdrawTransaction(guid, accountGUID, orderNumber string, sum float64,
 		ProcessedAt: processedAt,
 	}
 }
+
+type StatementEntry struct {
+	Transaction
+	Balance Points
+}
+
+type StatementCursor struct {
+	ProcessedAt time.Time
+	GUID        string
+}
+
+// StatementFilter не влияет на остаток: он считается по всем проводкам счета.
+type StatementFilter struct {
+	Types         []TransactionType
+	ProcessedFrom time.Time
+	ProcessedTo   time.Time
+	After         *StatementCursor
+	Limit         int
+	OldestFirst   bool
+}
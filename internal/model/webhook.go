@@ -0,0 +1,85 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+var WebhookEventTypes = []EventType{EventAccrualProcessed, EventAccrualInvalid}
+
+type Webhook struct {
+	GUID      string
+	UserGUID  string
+	URL       string
+	Secret    string
+	Events    []EventType // Пустой список - все события из WebhookEventTypes
+	CreatedAt time.Time
+}
+
+func NewWebhook(guid, userGUID, url, secret string, events []EventType) *Webhook {
+	return &Webhook{
+		GUID:      guid,
+		UserGUID:  userGUID,
+		URL:       url,
+		Secret:    secret,
+		Events:    events,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (w Webhook) Accepts(eventType EventType) bool {
+	if !slices.Contains(WebhookEventTypes, eventType) {
+		return false
+	}
+
+	return len(w.Events) == 0 || slices.Contains(w.Events, eventType)
+}
+
+func (w Webhook) Subscribed() []EventType {
+	if len(w.Events) == 0 {
+		return WebhookEventTypes
+	}
+
+	return w.Events
+}
+
+type DeliveryStatus uint
+
+const (
+	DeliveryPending DeliveryStatus = iota
+	DeliveryDelivered
+	DeliveryFailed
+)
+
+func (s DeliveryStatus) String() string {
+	switch s {
+	case DeliveryPending:
+		return "Pending"
+	case DeliveryDelivered:
+		return "Delivered"
+	case DeliveryFailed:
+		return "Failed"
+	}
+	return "Unknown"
+}
+
+type WebhookDelivery struct {
+	GUID           string
+	WebhookGUID    string
+	EventGUID      string
+	EventType      EventType
+	Payload        []byte
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type WebhookDispatch struct {
+	Delivery WebhookDelivery
+	URL      string
+	Secret   string
+}
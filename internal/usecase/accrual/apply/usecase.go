@@ -0,0 +1,127 @@
+package apply
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	serviceAccrual "github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var (
+	ErrOrderNotFound   = errors.New("order not found")
+	ErrUnknownStatus   = errors.New("unknown status")
+	ErrStatusUnchanged = errors.New("status unchanged")
+	ErrStatusOutdated  = errors.New("status outdated")
+)
+
+var (
+	mapAccrualStatus = map[string]model.AccrualStatus{
+		"registered": model.New,
+		"processing": model.Processing,
+		"invalid":    model.Invalid,
+		"processed":  model.Processed,
+	}
+)
+
+// Change is a saved status change of an order.
+type Change struct {
+	Old        model.Accrual
+	NewStatus  model.AccrualStatus
+	NewAccrual model.Points
+}
+
+type Usecase struct {
+	repo    repository.AccrualRepo
+	guidGen guid.IGenerator
+}
+
+func NewUsecase(repo repository.AccrualRepo, guidGen guid.IGenerator) *Usecase {
+	return &Usecase{
+		repo:    repo,
+		guidGen: guidGen,
+	}
+}
+
+// Apply saves the status the accrual system reported for the order, polled or pushed, and credits the accrual.
+// It returns ErrStatusUnchanged if there is nothing to save, ErrStatusOutdated if the order has already moved past
+// the status, and repository.ErrAccrualAlreadyFinal if the order has already been finished, e.g. by a push that came
+// while the order was polled.
+func (u Usecase) Apply(ctx context.Context, accrual model.Accrual, resp serviceAccrual.Response) (*Change, error) {
+	newStatus, ok := mapAccrualStatus[strings.ToLower(resp.Status)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStatus, resp.Status)
+	}
+
+	if newStatus == accrual.Status {
+		return nil, ErrStatusUnchanged
+	}
+
+	if accrual.Status.IsFinal() {
+		return nil, fmt.Errorf("%w: %s", repository.ErrAccrualAlreadyFinal, accrual.OrderNumber)
+	}
+
+	// a push and a poll may come out of order, an order never goes back from PROCESSING to REGISTERED
+	if newStatus < accrual.Status {
+		return nil, fmt.Errorf("%w: %s after %s", ErrStatusOutdated, newStatus, accrual.Status)
+	}
+
+	// only PROCESSED is final and credited, an accrual sent with an interim status is ignored
+	var newAccrual model.Points
+	if newStatus == model.Processed && resp.Accrual != nil {
+		newAccrual = *resp.Accrual
+	}
+
+	var err error
+	if newAccrual > 0 {
+		mAccrual := model.Accrual{
+			OrderNumber: accrual.OrderNumber,
+			UserGUID:    accrual.UserGUID,
+			Status:      newStatus,
+			Accrual:     newAccrual,
+			UploadedAt:  accrual.UploadedAt,
+		}
+
+		mTransaction := model.NewAddTransaction(
+			u.guidGen.Generate(),
+			accrual.UserGUID,
+			accrual.OrderNumber,
+			newAccrual,
+			time.Now(),
+		)
+
+		err = u.repo.AddBalance(ctx, mAccrual, mTransaction)
+	} else {
+		err = u.repo.UpdateStatus(ctx, accrual.OrderNumber, newStatus)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Change{
+		Old:        accrual,
+		NewStatus:  newStatus,
+		NewAccrual: newAccrual,
+	}, nil
+}
+
+// ApplyPushed applies a status the accrual system pushed for an order it has been given. Besides the errors of
+// Apply it returns ErrOrderNotFound if no user has uploaded the order.
+func (u Usecase) ApplyPushed(ctx context.Context, resp serviceAccrual.Response) (*Change, error) {
+	accrual, err := u.repo.AccrualByOrderNumber(ctx, resp.Order)
+	if err != nil {
+		return nil, err
+	}
+
+	if accrual == nil {
+		return nil, fmt.Errorf("%w: %s", ErrOrderNotFound, resp.Order)
+	}
+
+	return u.Apply(ctx, *accrual, resp)
+}
@@ -4,49 +4,75 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
 	"time"
 
 	"golang.org/x/sync/errgroup"
 
+	"github.com/bjlag/go-loyalty/internal/infrastructure/backoff"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/ratelimit"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
 	serviceAccrual "github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
 	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/accrual/apply"
 )
 
-var (
-	mapAccrualStatus = map[string]model.AccrualStatus{
-		"registered": model.New,
-		"processing": model.Processing,
-		"invalid":    model.Invalid,
-		"processed":  model.Processed,
-	}
-)
+// ErrGaveUp is reported for an order that is no longer checked.
+var ErrGaveUp = errors.New("order given up")
+
+// Limits bound the work of one Update call.
+type Limits struct {
+	// Workers number of lookups sent concurrently.
+	Workers int
+	// BatchSize number of orders read from the database at once.
+	BatchSize int
+	// LookupSize number of orders asked from the accrual system in one request.
+	LookupSize int
+	// TickBudget maximum number of orders polled per call, the rest wait for the next one.
+	TickBudget int
+	// LeaseTTL how long an order stays with this instance. It must cover polling and saving, including a pause
+	// on Retry-After, otherwise another replica takes the order over.
+	LeaseTTL time.Duration
+	// BackoffBase and BackoffMax bound the delay before the next check of an order without progress. The delay
+	// doubles with every check.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// MaxAttempts answers without progress after which the order is no longer checked. Failed requests and outages
+	// don't count.
+	MaxAttempts int
+}
 
 type Usecase struct {
 	client  *serviceAccrual.Client
 	repo    repository.AccrualRepo
+	limiter *ratelimit.Limiter
+	applier *apply.Usecase
 	guidGen guid.IGenerator
+	limits  Limits
+	// instance identifies this replica as the owner of leased orders
+	instance string
 }
 
 type Result struct {
 	OrderNumber string
 	UserGUID    string
 	OldStatus   model.AccrualStatus
-	OldAccrual  float64
+	OldAccrual  model.Points
 	NewStatus   *model.AccrualStatus
-	NewAccrual  *float64
+	NewAccrual  *model.Points
 	Err         error
+	// RequestID is sent to the accrual system in the X-Request-Id header of the poll.
+	RequestID string
 }
 
 func NewResult(
 	orderNumber string,
 	userGUID string,
 	oldStatus model.AccrualStatus,
-	oldAccrual float64,
+	oldAccrual model.Points,
 	newStatus *model.AccrualStatus,
-	newAccrual *float64,
+	newAccrual *model.Points,
 	err error,
 ) *Result {
 	return &Result{
@@ -60,104 +86,203 @@ func NewResult(
 	}
 }
 
-func NewUsecase(client *serviceAccrual.Client, repo repository.AccrualRepo, guidGen guid.IGenerator) *Usecase {
+func NewUsecase(
+	client *serviceAccrual.Client,
+	repo repository.AccrualRepo,
+	guidGen guid.IGenerator,
+	limiter *ratelimit.Limiter,
+	limits Limits,
+	instance string,
+) *Usecase {
 	return &Usecase{
-		client:  client,
-		repo:    repo,
-		guidGen: guidGen,
+		client:   client,
+		repo:     repo,
+		limiter:  limiter,
+		applier:  apply.NewUsecase(repo, guidGen),
+		guidGen:  guidGen,
+		limits:   limits,
+		instance: instance,
 	}
 }
 
-func (u Usecase) Update(ctx context.Context, resultCh chan *Result) error {
-	var err error
+// Update leases orders in work and polls the accrual system for them in lookups of Limits.LookupSize orders with at
+// most Limits.Workers goroutines. When the context is done it releases the orders it hasn't started, aborts the
+// requests in flight and returns once their orders are released. Results are sent to resultCh, which is not closed.
+func (u Usecase) Update(ctx context.Context, resultCh chan<- *Result) error {
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(u.limits.Workers)
 
-	accrualsInWork, err := u.repo.AccrualsInWork(ctx)
-	if err != nil {
-		return err
-	}
+	lookupSize := max(u.limits.LookupSize, 1)
 
-	g, gCtx := errgroup.WithContext(ctx)
+	var (
+		scheduled int
+		err       error
+	)
 
-	for _, accrual := range accrualsInWork {
-		g.Go(func() error {
-			select {
-			case <-gCtx.Done():
-				close(resultCh)
-				return gCtx.Err()
-			default:
-			}
+schedule:
+	for scheduled < u.limits.TickBudget {
+		limit := min(u.limits.BatchSize, u.limits.TickBudget-scheduled)
 
-			resp, err := u.client.OrderStatus(accrual.OrderNumber)
-			if err != nil {
-				resultCh <- NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil, err)
-				return nil
-			}
+		var batch []model.Accrual
+		batch, err = u.repo.LeaseInWork(ctx, u.instance, limit, u.limits.LeaseTTL)
+		if err != nil {
+			break
+		}
 
-			newStatus, ok := mapAccrualStatus[strings.ToLower(resp.Status)]
-			if !ok {
-				resultCh <- NewResult(
-					accrual.OrderNumber,
-					accrual.UserGUID,
-					accrual.Status,
-					accrual.Accrual,
-					nil,
-					nil,
-					fmt.Errorf("unknown status: %s", resp.Status),
-				)
-				return nil
+		for i := 0; i < len(batch); i += lookupSize {
+			if ctx.Err() != nil {
+				for _, rest := range batch[i:] {
+					u.release(ctx, rest)
+				}
+				break schedule
 			}
 
-			if newStatus == accrual.Status {
+			lookup := batch[i:min(i+lookupSize, len(batch))]
+			g.Go(func() error {
+				u.lookup(gCtx, lookup, resultCh)
 				return nil
-			}
+			})
+			scheduled += len(lookup)
+		}
 
-			var newAccrual float64
-			if resp.Accrual != nil {
-				newAccrual = *resp.Accrual
-			}
+		// leased orders are not returned again, so a short batch means the queue is empty
+		if len(batch) < limit {
+			break
+		}
+	}
 
-			if newAccrual > 0 {
-				mAccrual := model.Accrual{
-					OrderNumber: accrual.OrderNumber,
-					UserGUID:    accrual.UserGUID,
-					Status:      newStatus,
-					Accrual:     newAccrual,
-					UploadedAt:  accrual.UploadedAt,
-				}
+	_ = g.Wait()
 
-				mAccount := model.Account{
-					GUID:      accrual.UserGUID,
-					Balance:   newAccrual,
-					UpdatedAt: time.Now(),
-				}
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
 
-				mTransaction := model.NewAddTransaction(
-					u.guidGen.Generate(),
-					mAccount.GUID,
-					accrual.OrderNumber,
-					newAccrual,
-					time.Now(),
-				)
-
-				err = u.repo.AddBalance(gCtx, mAccrual, mAccount, mTransaction)
-			} else {
-				err = u.repo.UpdateStatus(gCtx, accrual.OrderNumber, newStatus)
-			}
+	return nil
+}
 
-			if err != nil {
-				resultCh <- NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil, err)
-				return nil
-			}
+// lookup polls the accrual system for the orders with one request, or with a few single ones if the service has
+// no batch endpoint.
+func (u Usecase) lookup(ctx context.Context, accruals []model.Accrual, resultCh chan<- *Result) {
+	ctx = client.ContextWithRequestID(ctx, u.guidGen.Generate())
 
-			resultCh <- NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, &newStatus, &newAccrual, nil)
+	numbers := make([]string, 0, len(accruals))
+	for _, accrual := range accruals {
+		numbers = append(numbers, accrual.OrderNumber)
+	}
 
-			return nil
-		})
+	// all goroutines share the limiter, and the client pauses it on a 429, so the requests of the other lookups wait
+	// too. An order whose wait is cut by shutdown is released.
+	statuses, err := u.client.OrderStatuses(ctx, numbers, u.limiter)
+	for i, accrual := range accruals {
+		if err != nil {
+			u.update(ctx, accrual, nil, err, resultCh)
+			continue
+		}
+		u.update(ctx, accrual, statuses[i].Response, statuses[i].Err, resultCh)
 	}
+}
 
-	if err := g.Wait(); err != nil && !errors.Is(err, context.Canceled) {
-		return err
+// update saves the answer of the accrual system for one order.
+func (u Usecase) update(ctx context.Context, accrual model.Accrual, resp *serviceAccrual.Response, err error, resultCh chan<- *Result) {
+	saved := false
+	defer func() {
+		if !saved {
+			u.release(ctx, accrual)
+		}
+	}()
+
+	// the accrual system is down and the request wasn't sent, or the request is aborted by shutdown: the order is
+	// neither reported nor charged an attempt
+	if errors.Is(err, client.ErrCircuitOpen) || (err != nil && ctx.Err() != nil) {
+		return
 	}
+	if err != nil {
+		u.send(ctx, resultCh, NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil, err))
 
-	return nil
+		// throttling is not the order's fault, so it is checked again as soon as the pause is over
+		if errors.Is(err, serviceAccrual.ErrTooManyRequests) {
+			return
+		}
+
+		saved = u.retry(ctx, accrual, isAnswer(err), resultCh)
+		return
+	}
+
+	// the status is already received, so it is saved even if shutdown has started
+	change, err := u.applier.Apply(context.WithoutCancel(ctx), accrual, *resp)
+	switch {
+	case errors.Is(err, apply.ErrStatusUnchanged), errors.Is(err, apply.ErrStatusOutdated):
+		saved = u.retry(ctx, accrual, true, resultCh)
+		return
+	case errors.Is(err, apply.ErrUnknownStatus):
+		u.send(ctx, resultCh, NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil, err))
+		saved = u.retry(ctx, accrual, true, resultCh)
+		return
+	case errors.Is(err, repository.ErrAccrualAlreadyFinal):
+		// another replica or a push from the accrual system has already finished the order
+		saved = true
+		return
+	case err != nil:
+		u.send(ctx, resultCh, NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil, err))
+		return
+	}
+
+	saved = true
+	u.send(ctx, resultCh, NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, &change.NewStatus, &change.NewAccrual, nil))
+}
+
+// isAnswer reports whether the error is an answer of the accrual system about the order rather than a failed
+// request or an outage.
+func isAnswer(err error) bool {
+	return errors.Is(err, serviceAccrual.ErrOrderNotRegistered) || errors.Is(err, serviceAccrual.ErrUnknownStatus)
+}
+
+// retry schedules the next check of an order without progress, or gives the order up after Limits.MaxAttempts
+// answers. Only an answer is counted as an attempt. It reports whether the order has left this instance.
+func (u Usecase) retry(ctx context.Context, accrual model.Accrual, answered bool, resultCh chan<- *Result) bool {
+	saveCtx := context.WithoutCancel(ctx)
+
+	attempts := accrual.Attempts
+	if answered {
+		attempts++
+	}
+
+	if attempts < u.limits.MaxAttempts {
+		nextCheckAt := time.Now().Add(backoff.Exponential(u.limits.BackoffBase, u.limits.BackoffMax, max(attempts, 1)))
+
+		err := u.repo.ScheduleCheck(saveCtx, accrual.OrderNumber, u.instance, attempts, nextCheckAt)
+		if err != nil {
+			u.send(ctx, resultCh, NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil, err))
+			return false
+		}
+
+		return true
+	}
+
+	// the reason is shown to the user, the errors of the checks are only logged
+	reason := fmt.Sprintf("no final status from the accrual system after %d checks", attempts)
+
+	err := u.repo.GiveUp(saveCtx, accrual.OrderNumber, reason)
+	if errors.Is(err, repository.ErrAccrualAlreadyFinal) {
+		return true
+	}
+	if err != nil {
+		u.send(ctx, resultCh, NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil, err))
+		return false
+	}
+
+	u.send(ctx, resultCh, NewResult(accrual.OrderNumber, accrual.UserGUID, accrual.Status, accrual.Accrual, nil, nil,
+		fmt.Errorf("%w: %s: %s", ErrGaveUp, accrual.OrderNumber, reason)))
+
+	return true
+}
+
+func (u Usecase) send(ctx context.Context, resultCh chan<- *Result, result *Result) {
+	result.RequestID = client.RequestIDFromContext(ctx)
+	resultCh <- result
+}
+
+// release returns the order to the queue for the next tick or another replica. If it fails, the lease just expires.
+func (u Usecase) release(ctx context.Context, accrual model.Accrual) {
+	_ = u.repo.ReleaseLease(context.WithoutCancel(ctx), accrual.OrderNumber, u.instance)
 }
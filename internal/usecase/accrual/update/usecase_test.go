@@ -0,0 +1,583 @@
+package update_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/client"
+	mockGuid "github.com/bjlag/go-loyalty/internal/infrastructure/guid/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/ratelimit"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	serviceAccrual "github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/accrual/simulator"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/accrual/update"
+)
+
+const userGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+
+func accruals(n int) []model.Accrual {
+	start := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+	result := make([]model.Accrual, 0, n)
+	for i := 0; i < n; i++ {
+		result = append(result, model.Accrual{
+			OrderNumber: strconv.Itoa(1000 + i),
+			UserGUID:    userGUID,
+			Status:      model.New,
+			UploadedAt:  start.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	return result
+}
+
+const (
+	instance  = "c0d1b0d4-8c4d-4f0a-9f7e-0b7c1d8e4a11"
+	requestID = "5d8a3b1e-7f2c-4e9a-b6d0-1c3e5f7a9b2d"
+)
+
+// queue leases orders the same way the repository does: a leased order is not returned again.
+type queue struct {
+	mu        sync.Mutex
+	orders    []model.Accrual
+	released  []string
+	scheduled map[string]scheduledCheck
+}
+
+type scheduledCheck struct {
+	delay    time.Duration
+	attempts int
+}
+
+func (q *queue) lease(_ context.Context, owner string, limit int, _ time.Duration) ([]model.Accrual, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if owner != instance {
+		return nil, nil
+	}
+
+	n := min(limit, len(q.orders))
+	page := q.orders[:n]
+	q.orders = q.orders[n:]
+
+	return page, nil
+}
+
+func (q *queue) release(_ context.Context, orderNumber, owner string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if owner == instance {
+		q.released = append(q.released, orderNumber)
+	}
+
+	return nil
+}
+
+func (q *queue) schedule(_ context.Context, orderNumber, owner string, attempts int, nextCheckAt time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if owner == instance {
+		q.scheduled[orderNumber] = scheduledCheck{delay: time.Until(nextCheckAt), attempts: attempts}
+	}
+
+	return nil
+}
+
+func expectQueue(repo *mockRep.MockAccrualRepo, orders []model.Accrual) *queue {
+	q := &queue{orders: orders, scheduled: map[string]scheduledCheck{}}
+	repo.EXPECT().LeaseInWork(gomock.Any(), gomock.Any(), gomock.Any(), time.Minute).DoAndReturn(q.lease).AnyTimes()
+	repo.EXPECT().ReleaseLease(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(q.release).AnyTimes()
+	repo.EXPECT().ScheduleCheck(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(q.schedule).AnyTimes()
+
+	return q
+}
+
+// accrualServer answers status for every order after delay and tracks the peak number of concurrent requests.
+// A non-zero code is sent instead of the status.
+type accrualServer struct {
+	code     int
+	status   string
+	delay    time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	requests atomic.Int32
+	onHit    func()
+}
+
+func (s *accrualServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	s.requests.Add(1)
+	if s.onHit != nil {
+		s.onHit()
+	}
+
+	if s.code != 0 {
+		w.WriteHeader(s.code)
+		return
+	}
+
+	status := s.status
+	if status == "" {
+		status = "PROCESSING"
+	}
+
+	select {
+	case <-time.After(s.delay):
+	case <-r.Context().Done():
+		return
+	}
+	_, _ = w.Write([]byte(`{"order":"1","status":"` + status + `"}`))
+}
+
+func newUsecase(t *testing.T, ctrl *gomock.Controller, repo *mockRep.MockAccrualRepo, server *accrualServer, limits update.Limits) *update.Usecase {
+	limits.LeaseTTL = time.Minute
+	limits.BackoffBase = time.Second
+	limits.BackoffMax = time.Minute
+	if limits.MaxAttempts == 0 {
+		limits.MaxAttempts = 3
+	}
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	guidGen := mockGuid.NewMockIGenerator(ctrl)
+	guidGen.EXPECT().Generate().Return(requestID).AnyTimes()
+
+	return update.NewUsecase(
+		serviceAccrual.NewAccrualClient(client.NewRestyClient(), httpServer.URL),
+		repo,
+		guidGen,
+		ratelimit.NewLimiter(0),
+		limits,
+		instance,
+	)
+}
+
+func collect(resultCh <-chan *update.Result) (*[]*update.Result, *sync.WaitGroup) {
+	var (
+		results []*update.Result
+		wg      sync.WaitGroup
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for r := range resultCh {
+			results = append(results, r)
+		}
+	}()
+
+	return &results, &wg
+}
+
+func TestUsecase_Update(t *testing.T) {
+	t.Run("bounded_concurrency_in_batches", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, accruals(20))
+		repo.EXPECT().UpdateStatus(gomock.Any(), gomock.Any(), model.Processing).Return(nil).Times(20)
+
+		server := &accrualServer{delay: 20 * time.Millisecond}
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 3, BatchSize: 8, TickBudget: 100})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		assert.Len(t, *results, 20)
+		assert.EqualValues(t, 20, server.requests.Load())
+		assert.LessOrEqual(t, server.peak.Load(), int32(3))
+		assert.Empty(t, q.released)
+	})
+
+	t.Run("tick_budget", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, accruals(20))
+		repo.EXPECT().UpdateStatus(gomock.Any(), gomock.Any(), model.Processing).Return(nil).Times(5)
+
+		server := &accrualServer{}
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 2, BatchSize: 10, TickBudget: 5})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		assert.Len(t, *results, 5)
+		assert.Len(t, q.orders, 15)
+	})
+
+	t.Run("aborts_on_shutdown", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, accruals(10))
+
+		server := &accrualServer{delay: time.Minute}
+		server.onHit = cancel
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 2, BatchSize: 10, TickBudget: 100})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		start := time.Now()
+		require.NoError(t, uc.Update(ctx, resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		// requests in flight are aborted rather than waited for, and their orders are not charged an attempt
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Empty(t, *results)
+		assert.Empty(t, q.scheduled)
+		assert.Len(t, q.released, 10)
+	})
+
+	t.Run("unchanged_status_schedules_check", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		orders := accruals(3)
+		for i := range orders {
+			orders[i].Status = model.Processing
+			orders[i].Attempts = i
+		}
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, orders)
+
+		server := &accrualServer{}
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 2, BatchSize: 10, TickBudget: 100, MaxAttempts: 10})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		assert.Empty(t, *results)
+		assert.Empty(t, q.released)
+		require.Len(t, q.scheduled, 3)
+
+		// the delay doubles with every attempt, the random part is up to a half of it
+		assert.InDelta(t, 750*time.Millisecond, q.scheduled["1000"].delay, float64(260*time.Millisecond))
+		assert.InDelta(t, 1500*time.Millisecond, q.scheduled["1001"].delay, float64(510*time.Millisecond))
+		assert.Equal(t, 1, q.scheduled["1000"].attempts)
+	})
+
+	t.Run("gives_up_after_max_attempts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		orders := accruals(2)
+		orders[0].Attempts = 2
+		orders[1].Attempts = 1
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, orders)
+		repo.EXPECT().
+			GiveUp(gomock.Any(), "1000", "no final status from the accrual system after 3 checks").
+			Return(nil)
+
+		server := &accrualServer{code: http.StatusNoContent}
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 1, BatchSize: 10, TickBudget: 100, MaxAttempts: 3})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		assert.Equal(t, 2, q.scheduled["1001"].attempts)
+		assert.NotContains(t, q.scheduled, "1000")
+
+		var gaveUp bool
+		for _, r := range *results {
+			if r.OrderNumber == "1000" && errors.Is(r.Err, update.ErrGaveUp) {
+				gaveUp = true
+				assert.Nil(t, r.NewStatus)
+			}
+		}
+		assert.True(t, gaveUp)
+	})
+
+	t.Run("server_error_without_attempt", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		orders := accruals(1)
+		orders[0].Attempts = 2
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, orders)
+		repo.EXPECT().GiveUp(gomock.Any(), gomock.Any(), gomock.Any()).Times(0)
+
+		server := &accrualServer{code: http.StatusServiceUnavailable}
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 1, BatchSize: 10, TickBudget: 100, MaxAttempts: 3})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		require.Len(t, *results, 1)
+		assert.ErrorIs(t, (*results)[0].Err, serviceAccrual.ErrServiceUnavailable)
+		assert.Equal(t, 2, q.scheduled["1000"].attempts)
+	})
+
+	t.Run("rate_limited_without_attempt", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, accruals(1))
+
+		server := &accrualServer{code: http.StatusTooManyRequests}
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 1, BatchSize: 10, TickBudget: 100})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		require.Len(t, *results, 1)
+		assert.ErrorIs(t, (*results)[0].Err, serviceAccrual.ErrTooManyRequests)
+		assert.Empty(t, q.scheduled)
+		assert.Equal(t, []string{"1000"}, q.released)
+	})
+
+	t.Run("circuit_open_releases_without_attempt", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, accruals(3))
+
+		httpServer := httptest.NewServer(&accrualServer{code: http.StatusBadGateway})
+		t.Cleanup(httpServer.Close)
+
+		guidGen := mockGuid.NewMockIGenerator(ctrl)
+		guidGen.EXPECT().Generate().Return(requestID).AnyTimes()
+
+		breaker := client.NewBreaker(client.NewRestyClient(), client.BreakerSettings{
+			FailureThreshold: 1,
+			OpenTimeout:      time.Minute,
+			HalfOpenRequests: 1,
+		})
+
+		uc := update.NewUsecase(
+			serviceAccrual.NewAccrualClient(breaker, httpServer.URL),
+			repo,
+			guidGen,
+			ratelimit.NewLimiter(0),
+			update.Limits{Workers: 1, BatchSize: 10, TickBudget: 100, LeaseTTL: time.Minute, BackoffBase: time.Second, BackoffMax: time.Minute, MaxAttempts: 3},
+			instance,
+		)
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		// only the request that opened the circuit is reported and counted as an attempt
+		require.Len(t, *results, 1)
+		assert.Len(t, q.scheduled, 1)
+		assert.Equal(t, []string{"1001", "1002"}, q.released)
+		assert.Equal(t, client.StateOpen, breaker.State())
+	})
+
+	t.Run("finished_by_another_replica", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockAccrualRepo(ctrl)
+		q := expectQueue(repo, accruals(1))
+		repo.EXPECT().
+			UpdateStatus(gomock.Any(), "1000", model.Invalid).
+			Return(fmt.Errorf("%w: 1000", repository.ErrAccrualAlreadyFinal))
+
+		server := &accrualServer{status: "INVALID"}
+		uc := newUsecase(t, ctrl, repo, server, update.Limits{Workers: 1, BatchSize: 10, TickBudget: 100})
+
+		resultCh := make(chan *update.Result)
+		results, wg := collect(resultCh)
+
+		require.NoError(t, uc.Update(context.Background(), resultCh))
+		close(resultCh)
+		wg.Wait()
+
+		assert.Empty(t, *results)
+		assert.Empty(t, q.released)
+	})
+}
+
+func TestUsecase_Update_Simulator(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	sim := simulator.New(simulator.Rules{PerMinute: 2})
+	require.NoError(t, sim.AddReward(simulator.Reward{Match: "Bork", Reward: model.NewPoints(10, 0), Type: simulator.RewardPercent}))
+	require.NoError(t, sim.Register(simulator.Order{
+		Order: "12345678903",
+		Goods: []simulator.Good{{Description: "Чайник Bork", Price: model.NewPoints(7000, 0)}},
+	}))
+
+	httpServer := httptest.NewServer(sim.Handler())
+	t.Cleanup(httpServer.Close)
+
+	orders := []model.Accrual{
+		{OrderNumber: "12345678903", UserGUID: userGUID, Status: model.New},
+		{OrderNumber: "79927398713", UserGUID: userGUID, Status: model.New},
+		{OrderNumber: "4561261212345467", UserGUID: userGUID, Status: model.New},
+	}
+
+	repo := mockRep.NewMockAccrualRepo(ctrl)
+	q := expectQueue(repo, orders)
+	repo.EXPECT().
+		AddBalance(gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, accrual model.Accrual, transaction model.Transaction) error {
+			assert.Equal(t, "12345678903", accrual.OrderNumber)
+			assert.Equal(t, model.Processed, accrual.Status)
+			assert.Equal(t, model.NewPoints(700, 0), transaction.Sum)
+			return nil
+		})
+
+	guidGen := mockGuid.NewMockIGenerator(ctrl)
+	guidGen.EXPECT().Generate().Return("9a3f0b1c-3e2d-4c5b-8a7f-6e5d4c3b2a10").AnyTimes()
+
+	uc := update.NewUsecase(
+		serviceAccrual.NewAccrualClient(client.NewRestyClient(), httpServer.URL),
+		repo,
+		guidGen,
+		ratelimit.NewLimiter(0),
+		update.Limits{
+			Workers:     1,
+			BatchSize:   10,
+			TickBudget:  100,
+			LeaseTTL:    time.Minute,
+			BackoffBase: time.Second,
+			BackoffMax:  time.Minute,
+			MaxAttempts: 3,
+		},
+		instance,
+	)
+
+	resultCh := make(chan *update.Result)
+	results, wg := collect(resultCh)
+
+	require.NoError(t, uc.Update(context.Background(), resultCh))
+	close(resultCh)
+	wg.Wait()
+
+	require.Len(t, *results, 3)
+
+	// the registered order is processed with the reward for its goods
+	assert.NoError(t, (*results)[0].Err)
+	assert.Equal(t, "9a3f0b1c-3e2d-4c5b-8a7f-6e5d4c3b2a10", (*results)[0].RequestID)
+	assert.Equal(t, model.Processed, *(*results)[0].NewStatus)
+
+	// the order unknown to the accrual system is checked again later
+	assert.ErrorIs(t, (*results)[1].Err, serviceAccrual.ErrOrderNotRegistered)
+	assert.Equal(t, 1, q.scheduled["79927398713"].attempts)
+
+	// the third request is over the limit, the order waits for the pause without an attempt
+	var rateErr *serviceAccrual.RateLimitError
+	require.ErrorAs(t, (*results)[2].Err, &rateErr)
+	assert.Equal(t, 2, rateErr.PerMinute)
+	assert.Equal(t, []string{"4561261212345467"}, q.released)
+}
+
+func TestUsecase_Update_SimulatorBatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// one request a minute is enough only if the orders are asked in one batch
+	sim := simulator.New(simulator.Rules{PerMinute: 1, BatchLookup: true})
+	require.NoError(t, sim.Register(simulator.Order{Order: "12345678903"}))
+	require.NoError(t, sim.Register(simulator.Order{Order: "79927398713"}))
+
+	httpServer := httptest.NewServer(sim.Handler())
+	t.Cleanup(httpServer.Close)
+
+	orders := []model.Accrual{
+		{OrderNumber: "12345678903", UserGUID: userGUID, Status: model.New},
+		{OrderNumber: "79927398713", UserGUID: userGUID, Status: model.New},
+		{OrderNumber: "4561261212345467", UserGUID: userGUID, Status: model.New},
+	}
+
+	repo := mockRep.NewMockAccrualRepo(ctrl)
+	q := expectQueue(repo, orders)
+	repo.EXPECT().UpdateStatus(gomock.Any(), "12345678903", model.Processed).Return(nil)
+	repo.EXPECT().UpdateStatus(gomock.Any(), "79927398713", model.Processed).Return(nil)
+
+	guidGen := mockGuid.NewMockIGenerator(ctrl)
+	guidGen.EXPECT().Generate().Return(requestID).Times(1)
+
+	uc := update.NewUsecase(
+		serviceAccrual.NewAccrualClient(client.NewRestyClient(), httpServer.URL),
+		repo,
+		guidGen,
+		ratelimit.NewLimiter(0),
+		update.Limits{
+			Workers:     2,
+			BatchSize:   10,
+			LookupSize:  10,
+			TickBudget:  100,
+			LeaseTTL:    time.Minute,
+			BackoffBase: time.Second,
+			BackoffMax:  time.Minute,
+			MaxAttempts: 3,
+		},
+		instance,
+	)
+
+	resultCh := make(chan *update.Result)
+	results, wg := collect(resultCh)
+
+	require.NoError(t, uc.Update(context.Background(), resultCh))
+	close(resultCh)
+	wg.Wait()
+
+	require.Len(t, *results, 3)
+	for _, r := range *results {
+		assert.Equal(t, requestID, r.RequestID)
+	}
+
+	assert.Equal(t, 1, q.scheduled["4561261212345467"].attempts)
+	assert.Empty(t, q.released)
+}
@@ -0,0 +1,148 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type DriftKind string
+
+const (
+	// DriftBalance ledger balance differs from the balance recomputed from transactions.
+	DriftBalance DriftKind = "balance"
+	// DriftWithdrawn ledger withdrawals differ from withdraw transactions. Reported only: the balance is closed by
+	// DriftBalance, and which side is wrong is left to an operator.
+	DriftWithdrawn DriftKind = "withdrawn"
+	// DriftMaterialisedBalance stored account balance differs from the sum of its postings.
+	DriftMaterialisedBalance DriftKind = "materialised_balance"
+	// DriftAccrual processed accrual differs from the sum credited for the order. Reported only.
+	DriftAccrual DriftKind = "accrual"
+)
+
+type Drift struct {
+	Kind        DriftKind
+	AccountGUID string
+	OrderNumber string
+	Expected    model.Points
+	Actual      model.Points
+	Repaired    bool
+	RepairErr   error
+}
+
+type Report struct {
+	CheckedAt       time.Time
+	AccountsChecked int
+	Drifts          []Drift
+}
+
+// Unresolved returns the number of drifts that were not repaired.
+func (r Report) Unresolved() int {
+	var n int
+	for _, d := range r.Drifts {
+		if !d.Repaired {
+			n++
+		}
+	}
+
+	return n
+}
+
+type Usecase struct {
+	repo    repository.LedgerRepo
+	guidGen guid.IGenerator
+}
+
+func NewUsecase(repo repository.LedgerRepo, guidGen guid.IGenerator) *Usecase {
+	return &Usecase{
+		repo:    repo,
+		guidGen: guidGen,
+	}
+}
+
+// Reconcile compares the ledger with transactions and accruals. With repair enabled, stored balances are
+// rematerialised from postings and balance drift is closed by an adjustment entry, so every fix stays in the journal.
+// Withdrawn and accrual drifts are never repaired.
+func (u Usecase) Reconcile(ctx context.Context, repair bool) (*Report, error) {
+	totals, err := u.repo.AccountTotals(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		CheckedAt:       time.Now(),
+		AccountsChecked: len(totals),
+	}
+
+	for _, t := range totals {
+		if t.MaterialisedBalance != t.LedgerBalance {
+			d := Drift{
+				Kind:        DriftMaterialisedBalance,
+				AccountGUID: t.AccountGUID,
+				Expected:    t.LedgerBalance,
+				Actual:      t.MaterialisedBalance,
+			}
+
+			if repair {
+				d.RepairErr = u.repo.Rematerialise(ctx, t.AccountGUID)
+				d.Repaired = d.RepairErr == nil
+			}
+
+			report.Drifts = append(report.Drifts, d)
+		}
+
+		if expected := t.ExpectedBalance(); expected != t.LedgerBalance {
+			d := Drift{
+				Kind:        DriftBalance,
+				AccountGUID: t.AccountGUID,
+				Expected:    expected,
+				Actual:      t.LedgerBalance,
+			}
+
+			if repair {
+				entry := model.NewAdjustmentEntry(
+					u.guidGen.Generate(),
+					t.AccountGUID,
+					expected-t.LedgerBalance,
+					fmt.Sprintf("reconcile: transactions balance %s, ledger balance %s", expected, t.LedgerBalance),
+					time.Now(),
+				)
+
+				d.RepairErr = u.repo.PostEntry(ctx, entry)
+				d.Repaired = d.RepairErr == nil
+			}
+
+			report.Drifts = append(report.Drifts, d)
+		}
+
+		if t.TransactionsWithdrawn != t.LedgerWithdrawn {
+			report.Drifts = append(report.Drifts, Drift{
+				Kind:        DriftWithdrawn,
+				AccountGUID: t.AccountGUID,
+				Expected:    t.TransactionsWithdrawn,
+				Actual:      t.LedgerWithdrawn,
+			})
+		}
+	}
+
+	mismatches, err := u.repo.AccrualMismatches(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range mismatches {
+		report.Drifts = append(report.Drifts, Drift{
+			Kind:        DriftAccrual,
+			AccountGUID: m.UserGUID,
+			OrderNumber: m.OrderNumber,
+			Expected:    m.Accrual,
+			Actual:      m.Credited,
+		})
+	}
+
+	return report, nil
+}
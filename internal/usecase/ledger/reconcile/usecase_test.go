@@ -0,0 +1,208 @@
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockGuid "github.com/bjlag/go-loyalty/internal/infrastructure/guid/mock"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/ledger/reconcile"
+)
+
+func TestUsecase_Reconcile(t *testing.T) {
+	const (
+		accountGUID = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+		entryGUID   = "c0d1b0d4-8c4d-4f0a-9f7e-0b7c1d8e4a11"
+	)
+
+	errSomeError := errors.New("some error")
+
+	consistent := model.AccountTotals{
+		AccountGUID:           accountGUID,
+		MaterialisedBalance:   model.NewPoints(400, 0),
+		LedgerBalance:         model.NewPoints(400, 0),
+		LedgerWithdrawn:       model.NewPoints(100, 0),
+		TransactionsAccrued:   model.NewPoints(500, 0),
+		TransactionsWithdrawn: model.NewPoints(100, 0),
+	}
+
+	t.Run("no_drift", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).Return([]model.AccountTotals{consistent}, nil)
+		repo.EXPECT().AccrualMismatches(gomock.Any()).Return(nil, nil)
+
+		report, err := reconcile.NewUsecase(repo, mockGuid.NewMockIGenerator(ctrl)).Reconcile(context.Background(), true)
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, report.AccountsChecked)
+		assert.Empty(t, report.Drifts)
+		assert.Zero(t, report.Unresolved())
+	})
+
+	t.Run("balance_drift_report_only", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		totals := consistent
+		totals.MaterialisedBalance = model.NewPoints(399, 99)
+		totals.LedgerBalance = model.NewPoints(399, 99)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).Return([]model.AccountTotals{totals}, nil)
+		repo.EXPECT().AccrualMismatches(gomock.Any()).Return(nil, nil)
+		repo.EXPECT().PostEntry(gomock.Any(), gomock.Any()).Times(0)
+
+		report, err := reconcile.NewUsecase(repo, mockGuid.NewMockIGenerator(ctrl)).Reconcile(context.Background(), false)
+		require.NoError(t, err)
+
+		require.Len(t, report.Drifts, 1)
+		assert.Equal(t, reconcile.DriftBalance, report.Drifts[0].Kind)
+		assert.Equal(t, model.NewPoints(400, 0), report.Drifts[0].Expected)
+		assert.Equal(t, model.NewPoints(399, 99), report.Drifts[0].Actual)
+		assert.Equal(t, 1, report.Unresolved())
+	})
+
+	t.Run("balance_drift_repaired_with_adjustment", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		totals := consistent
+		totals.MaterialisedBalance = model.NewPoints(399, 99)
+		totals.LedgerBalance = model.NewPoints(399, 99)
+
+		guidGen := mockGuid.NewMockIGenerator(ctrl)
+		guidGen.EXPECT().Generate().Return(entryGUID)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).Return([]model.AccountTotals{totals}, nil)
+		repo.EXPECT().AccrualMismatches(gomock.Any()).Return(nil, nil)
+		repo.EXPECT().
+			PostEntry(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, entry model.LedgerEntry) error {
+				assert.Equal(t, entryGUID, entry.GUID)
+				assert.Equal(t, model.AdjustmentEntry, entry.Type)
+				assert.True(t, entry.Balanced())
+				assert.Equal(t, model.Posting{AccountGUID: accountGUID, Amount: model.NewPoints(0, 1)}, entry.Postings[0])
+				return nil
+			})
+
+		report, err := reconcile.NewUsecase(repo, guidGen).Reconcile(context.Background(), true)
+		require.NoError(t, err)
+
+		require.Len(t, report.Drifts, 1)
+		assert.True(t, report.Drifts[0].Repaired)
+		assert.Zero(t, report.Unresolved())
+	})
+
+	t.Run("repair_closes_balance_drift", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		totals := consistent
+		totals.MaterialisedBalance = model.NewPoints(390, 0)
+		totals.LedgerBalance = model.NewPoints(390, 0)
+
+		guidGen := mockGuid.NewMockIGenerator(ctrl)
+		guidGen.EXPECT().Generate().Return(entryGUID)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).DoAndReturn(func(context.Context) ([]model.AccountTotals, error) {
+			return []model.AccountTotals{totals}, nil
+		}).Times(2)
+		repo.EXPECT().AccrualMismatches(gomock.Any()).Return(nil, nil).Times(2)
+		repo.EXPECT().
+			PostEntry(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, entry model.LedgerEntry) error {
+				totals.LedgerBalance += entry.Postings[0].Amount
+				totals.MaterialisedBalance += entry.Postings[0].Amount
+				return nil
+			})
+
+		u := reconcile.NewUsecase(repo, guidGen)
+
+		report, err := u.Reconcile(context.Background(), true)
+		require.NoError(t, err)
+		require.Len(t, report.Drifts, 1)
+		assert.True(t, report.Drifts[0].Repaired)
+
+		report, err = u.Reconcile(context.Background(), true)
+		require.NoError(t, err)
+		assert.Empty(t, report.Drifts)
+	})
+
+	t.Run("materialised_balance_drift", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		totals := consistent
+		totals.MaterialisedBalance = model.NewPoints(1000, 0)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).Return([]model.AccountTotals{totals}, nil)
+		repo.EXPECT().AccrualMismatches(gomock.Any()).Return(nil, nil)
+		repo.EXPECT().Rematerialise(gomock.Any(), accountGUID).Return(nil)
+
+		report, err := reconcile.NewUsecase(repo, mockGuid.NewMockIGenerator(ctrl)).Reconcile(context.Background(), true)
+		require.NoError(t, err)
+
+		require.Len(t, report.Drifts, 1)
+		assert.Equal(t, reconcile.DriftMaterialisedBalance, report.Drifts[0].Kind)
+		assert.True(t, report.Drifts[0].Repaired)
+	})
+
+	t.Run("repair_failed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		totals := consistent
+		totals.MaterialisedBalance = model.NewPoints(1000, 0)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).Return([]model.AccountTotals{totals}, nil)
+		repo.EXPECT().AccrualMismatches(gomock.Any()).Return(nil, nil)
+		repo.EXPECT().Rematerialise(gomock.Any(), accountGUID).Return(errSomeError)
+
+		report, err := reconcile.NewUsecase(repo, mockGuid.NewMockIGenerator(ctrl)).Reconcile(context.Background(), true)
+		require.NoError(t, err)
+
+		require.Len(t, report.Drifts, 1)
+		assert.False(t, report.Drifts[0].Repaired)
+		assert.ErrorIs(t, report.Drifts[0].RepairErr, errSomeError)
+		assert.Equal(t, 1, report.Unresolved())
+	})
+
+	t.Run("withdrawn_and_accrual_drift", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		totals := consistent
+		totals.LedgerWithdrawn = model.NewPoints(50, 0)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).Return([]model.AccountTotals{totals}, nil)
+		repo.EXPECT().AccrualMismatches(gomock.Any()).Return([]model.AccrualTotals{
+			{OrderNumber: "2377225624", UserGUID: accountGUID, Accrual: model.NewPoints(500, 0)},
+		}, nil)
+
+		report, err := reconcile.NewUsecase(repo, mockGuid.NewMockIGenerator(ctrl)).Reconcile(context.Background(), true)
+		require.NoError(t, err)
+
+		require.Len(t, report.Drifts, 2)
+		assert.Equal(t, reconcile.DriftWithdrawn, report.Drifts[0].Kind)
+		assert.Equal(t, reconcile.DriftAccrual, report.Drifts[1].Kind)
+		assert.Equal(t, "2377225624", report.Drifts[1].OrderNumber)
+		assert.Equal(t, 2, report.Unresolved())
+	})
+
+	t.Run("repository_error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockLedgerRepo(ctrl)
+		repo.EXPECT().AccountTotals(gomock.Any()).Return(nil, errSomeError)
+
+		_, err := reconcile.NewUsecase(repo, mockGuid.NewMockIGenerator(ctrl)).Reconcile(context.Background(), true)
+		assert.ErrorIs(t, err, errSomeError)
+	})
+}
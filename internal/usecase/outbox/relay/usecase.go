@@ -0,0 +1,121 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/backoff"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/publisher"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+)
+
+type Result struct {
+	Published int
+	// Failed events stay in the outbox and are retried after a backoff, or are parked after Limits.MaxAttempts.
+	Failed []error
+}
+
+// Limits bound the work of one Relay call.
+type Limits struct {
+	// BatchSize number of events claimed at once.
+	BatchSize int
+	// ClaimTTL how long claimed events stay with this instance. It must cover publishing of a batch, otherwise
+	// another replica publishes them too.
+	ClaimTTL time.Duration
+	// BackoffBase and BackoffMax bound the delay before the next attempt to publish a failed event.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// MaxAttempts failed attempts after which the event is parked.
+	MaxAttempts int
+}
+
+type Usecase struct {
+	repo      repository.OutboxRepo
+	publisher publisher.Publisher
+	limits    Limits
+}
+
+func NewUsecase(repo repository.OutboxRepo, publisher publisher.Publisher, limits Limits) *Usecase {
+	return &Usecase{
+		repo:      repo,
+		publisher: publisher,
+		limits:    limits,
+	}
+}
+
+// Relay publishes outbox events until the outbox is drained, a batch has failures, or the context is done. Events of
+// one user are published in the order they were written. Publishing runs outside of a database transaction.
+func (u Usecase) Relay(ctx context.Context) (Result, error) {
+	var result Result
+
+	// the events already published must be marked even if the context is cancelled in the middle of the batch
+	saveCtx := context.WithoutCancel(ctx)
+
+	for ctx.Err() == nil {
+		events, err := u.repo.Claim(ctx, u.limits.BatchSize, u.limits.ClaimTTL)
+		if err != nil {
+			if ctx.Err() != nil {
+				return result, nil
+			}
+			return result, err
+		}
+
+		var (
+			published, untried []int64
+			failed             int
+			held               = make(map[string]bool)
+		)
+		for _, event := range events {
+			if ctx.Err() != nil || held[event.UserGUID] {
+				untried = append(untried, event.ID)
+				continue
+			}
+
+			err := u.publisher.Publish(ctx, event)
+			if err == nil {
+				published = append(published, event.ID)
+				continue
+			}
+
+			// an aborted publish is not the event's fault
+			if ctx.Err() != nil {
+				untried = append(untried, event.ID)
+				continue
+			}
+
+			held[event.UserGUID] = true
+			failed++
+
+			attempts := event.Attempts + 1
+			park := attempts >= u.limits.MaxAttempts
+			nextAttemptAt := time.Now().Add(backoff.Exponential(u.limits.BackoffBase, u.limits.BackoffMax, attempts))
+
+			if park {
+				err = fmt.Errorf("event %s (%s) parked after %d attempts: %w", event.GUID, event.Type, attempts, err)
+			} else {
+				err = fmt.Errorf("event %s (%s): %w", event.GUID, event.Type, err)
+			}
+			result.Failed = append(result.Failed, err)
+
+			if err = u.repo.Fail(saveCtx, event.ID, err.Error(), nextAttemptAt, park); err != nil {
+				return result, err
+			}
+		}
+
+		if err = u.repo.MarkPublished(saveCtx, published); err != nil {
+			return result, err
+		}
+		result.Published += len(published)
+
+		// if releasing fails, the claim just expires
+		_ = u.repo.Release(saveCtx, untried)
+
+		// a short batch means the outbox is drained; failed events wait for their next attempt
+		if len(events) < u.limits.BatchSize || failed > 0 {
+			break
+		}
+	}
+
+	return result, nil
+}
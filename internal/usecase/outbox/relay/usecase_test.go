@@ -0,0 +1,128 @@
+package relay_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockPub "github.com/bjlag/go-loyalty/internal/infrastructure/publisher/mock"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/outbox/relay"
+)
+
+var limits = relay.Limits{
+	BatchSize:   2,
+	ClaimTTL:    time.Minute,
+	BackoffBase: time.Second,
+	BackoffMax:  time.Minute,
+	MaxAttempts: 3,
+}
+
+func event(id int64, user string) model.Event {
+	return model.Event{ID: id, GUID: string(rune('a' + id)), Type: model.EventAccrualProcessed, UserGUID: user}
+}
+
+func TestUsecase_Relay(t *testing.T) {
+	errPublish := errors.New("publish error")
+
+	t.Run("drains_outbox_in_batches", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockOutboxRepo(ctrl)
+		gomock.InOrder(
+			repo.EXPECT().Claim(gomock.Any(), 2, time.Minute).Return([]model.Event{event(1, "a"), event(2, "a")}, nil),
+			repo.EXPECT().MarkPublished(gomock.Any(), []int64{1, 2}).Return(nil),
+			repo.EXPECT().Claim(gomock.Any(), 2, time.Minute).Return([]model.Event{event(3, "a")}, nil),
+			repo.EXPECT().MarkPublished(gomock.Any(), []int64{3}).Return(nil),
+		)
+		repo.EXPECT().Release(gomock.Any(), gomock.Len(0)).Return(nil).Times(2)
+
+		pub := mockPub.NewMockPublisher(ctrl)
+		pub.EXPECT().Publish(gomock.Any(), gomock.Any()).Return(nil).Times(3)
+
+		result, err := relay.NewUsecase(repo, pub, limits).Relay(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 3, result.Published)
+		assert.Empty(t, result.Failed)
+	})
+
+	t.Run("failure_holds_back_only_its_user", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockOutboxRepo(ctrl)
+		repo.EXPECT().
+			Claim(gomock.Any(), 2, time.Minute).
+			Return([]model.Event{event(1, "a"), event(2, "b"), event(3, "a")}, nil)
+		repo.EXPECT().
+			Fail(gomock.Any(), int64(1), gomock.Any(), gomock.Any(), false).
+			DoAndReturn(func(_ context.Context, _ int64, lastError string, nextAttemptAt time.Time, _ bool) error {
+				assert.Contains(t, lastError, errPublish.Error())
+				assert.True(t, nextAttemptAt.After(time.Now()))
+				return nil
+			})
+		repo.EXPECT().MarkPublished(gomock.Any(), []int64{2}).Return(nil)
+		repo.EXPECT().Release(gomock.Any(), []int64{3}).Return(nil)
+
+		pub := mockPub.NewMockPublisher(ctrl)
+		gomock.InOrder(
+			pub.EXPECT().Publish(gomock.Any(), event(1, "a")).Return(errPublish),
+			pub.EXPECT().Publish(gomock.Any(), event(2, "b")).Return(nil),
+		)
+
+		result, err := relay.NewUsecase(repo, pub, limits).Relay(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Published)
+		require.Len(t, result.Failed, 1)
+		assert.ErrorIs(t, result.Failed[0], errPublish)
+	})
+
+	t.Run("parks_after_max_attempts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		poison := event(1, "a")
+		poison.Attempts = 2
+
+		repo := mockRep.NewMockOutboxRepo(ctrl)
+		repo.EXPECT().Claim(gomock.Any(), 2, time.Minute).Return([]model.Event{poison}, nil)
+		repo.EXPECT().Fail(gomock.Any(), int64(1), gomock.Any(), gomock.Any(), true).Return(nil)
+		repo.EXPECT().MarkPublished(gomock.Any(), gomock.Len(0)).Return(nil)
+		repo.EXPECT().Release(gomock.Any(), gomock.Len(0)).Return(nil)
+
+		pub := mockPub.NewMockPublisher(ctrl)
+		pub.EXPECT().Publish(gomock.Any(), poison).Return(errPublish)
+
+		result, err := relay.NewUsecase(repo, pub, limits).Relay(context.Background())
+		require.NoError(t, err)
+		require.Len(t, result.Failed, 1)
+		assert.Contains(t, result.Failed[0].Error(), "parked")
+	})
+
+	t.Run("repository_error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		errRepo := errors.New("repo error")
+		repo := mockRep.NewMockOutboxRepo(ctrl)
+		repo.EXPECT().Claim(gomock.Any(), 2, time.Minute).Return(nil, errRepo)
+
+		result, err := relay.NewUsecase(repo, mockPub.NewMockPublisher(ctrl), limits).Relay(context.Background())
+		assert.ErrorIs(t, err, errRepo)
+		assert.Zero(t, result.Published)
+	})
+
+	t.Run("stops_when_context_is_done", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := relay.NewUsecase(mockRep.NewMockOutboxRepo(ctrl), mockPub.NewMockPublisher(ctrl), limits).Relay(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, result.Published)
+	})
+}
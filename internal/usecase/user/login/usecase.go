@@ -4,42 +4,304 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/logger"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
 )
 
 var (
-	ErrUserNotFound  = errors.New("user not found")
-	ErrWrongPassword = errors.New("wrong password")
+	ErrUserNotFound    = errors.New("user not found")
+	ErrWrongPassword   = errors.New("wrong password")
+	ErrTooManyAttempts = errors.New("too many login attempts")
 )
 
+// ThrottledError is returned while attempts are rejected after failures. It matches ErrTooManyAttempts with
+// errors.Is.
+type ThrottledError struct {
+	Subject    model.ThrottleSubject
+	Value      string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s: %s %q, retry after %s", ErrTooManyAttempts, e.Subject, e.Value, e.RetryAfter)
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+type Limits struct {
+	// MaxFailures failed attempts for one login within Window after which the login is locked for LockoutTime.
+	MaxFailures int
+	// MaxFailuresPerIP the same for attempts from one address under any login. It is higher than MaxFailures,
+	// since users behind NAT share an address.
+	MaxFailuresPerIP int
+	Window           time.Duration
+	LockoutTime      time.Duration
+	// DelayBase how long attempts are rejected after the first failure. The delay doubles with every next failure
+	// until the lockout.
+	DelayBase time.Duration
+}
+
 type Usecase struct {
 	userRepo repository.UserRepository
-	hasher   *auth.Hasher
-	jwt      *auth.JWTBuilder
+	hasher   auth.IHasher
+	tokens   *token.Usecase
+	throttle repository.LoginThrottleRepo
+	lockouts repository.LoginLockoutRepo
+	guidGen  guid.IGenerator
+	limits   Limits
+	log      logger.Logger
+
+	dummyMu   sync.Mutex
+	dummyHash string
 }
 
-func NewUsecase(userRepo repository.UserRepository, hasher *auth.Hasher, jwt *auth.JWTBuilder) *Usecase {
+func NewUsecase(
+	userRepo repository.UserRepository,
+	hasher auth.IHasher,
+	tokens *token.Usecase,
+	throttle repository.LoginThrottleRepo,
+	lockouts repository.LoginLockoutRepo,
+	guidGen guid.IGenerator,
+	limits Limits,
+	log logger.Logger,
+) *Usecase {
 	return &Usecase{
 		userRepo: userRepo,
 		hasher:   hasher,
-		jwt:      jwt,
+		tokens:   tokens,
+		throttle: throttle,
+		lockouts: lockouts,
+		guidGen:  guidGen,
+		limits:   limits,
+		log:      log,
 	}
 }
 
-func (u *Usecase) LoginUser(ctx context.Context, login, password string) (string, error) {
+// throttleKey a counter of failures the attempt is checked against.
+type throttleKey struct {
+	subject     model.ThrottleSubject
+	value       string
+	maxFailures int
+	// delayed the key is blocked for a growing delay after every failure, not only locked after maxFailures
+	delayed bool
+}
+
+func (k throttleKey) String() string {
+	return model.ThrottleKey(k.subject, k.value)
+}
+
+// reservation an attempt counted for the key before the password is checked.
+type reservation struct {
+	key      throttleKey
+	failures int
+}
+
+// LoginUser checks the password of the user logging in from ip. Failures are counted per login and per address,
+// an unknown login counts too, so that it can't be told from a wrong password. An empty ip is not counted.
+func (u *Usecase) LoginUser(ctx context.Context, login, password, ip string) (*token.Pair, error) {
+	// the address goes first: an attempt from a locked address must not delay the login
+	var keys []throttleKey
+	if ip != "" {
+		keys = append(keys, throttleKey{subject: model.ThrottleIP, value: ip, maxFailures: u.limits.MaxFailuresPerIP})
+	}
+	keys = append(keys, u.loginKey(login))
+
+	reserved, err := u.reserve(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// an attempt that fails for another reason than the password is taken back
 	user, err := u.userRepo.FindByLogin(ctx, login)
 	if err != nil {
-		return "", err
+		u.release(ctx, reserved)
+		return nil, err
+	}
+
+	// an unknown login is checked against a dummy hash, so that it takes as long as a wrong password
+	var hash string
+	if user != nil {
+		hash = user.Password
+	} else {
+		hash, err = u.dummy()
+		if err != nil {
+			u.release(ctx, reserved)
+			return nil, err
+		}
 	}
+	match := u.hasher.ComparePasswords(hash, password)
+
 	if user == nil {
-		return "", fmt.Errorf("%w: email %q", ErrUserNotFound, login)
+		return nil, u.fail(ctx, reserved, fmt.Errorf("%w: email %q", ErrUserNotFound, login))
+	}
+	if !match {
+		return nil, u.fail(ctx, reserved, fmt.Errorf("%w: email %q", ErrWrongPassword, login))
+	}
+
+	// the address is not reset: a valid login of one's own account must not clear the guesses at others
+	for _, r := range reserved {
+		if r.key.subject == model.ThrottleLogin {
+			err = u.throttle.Reset(ctx, r.key.String())
+		} else {
+			err = u.throttle.Release(ctx, r.key.String())
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the password is only known here, so an outdated hash is upgraded on login. A failed upgrade doesn't fail the
+	// login, it is tried again on the next one.
+	if u.hasher.NeedsRehash(user.Password) {
+		err = u.rehash(ctx, user, password)
+		if err != nil {
+			u.log.WithError(err).WithField("user", user.GUID).Error("Failed to rehash password")
+		}
+	}
+
+	return u.tokens.Issue(ctx, user.GUID)
+}
+
+// VerifyPassword checks the password of a signed-in user, e.g. before it is changed. Failures count against the
+// login of the user like failed logins, so that the check can't be used to guess the password past the limits.
+func (u *Usecase) VerifyPassword(ctx context.Context, user *model.User, password string) error {
+	key := u.loginKey(user.Login)
+
+	reserved, err := u.reserve(ctx, []throttleKey{key})
+	if err != nil {
+		return err
 	}
 
 	if !u.hasher.ComparePasswords(user.Password, password) {
-		return "", fmt.Errorf("%w: email %q", ErrWrongPassword, login)
+		return u.fail(ctx, reserved, fmt.Errorf("%w: email %q", ErrWrongPassword, user.Login))
+	}
+
+	return u.throttle.Reset(ctx, key.String())
+}
+
+func (u *Usecase) loginKey(login string) throttleKey {
+	return throttleKey{subject: model.ThrottleLogin, value: login, maxFailures: u.limits.MaxFailures, delayed: true}
+}
+
+func (u *Usecase) rehash(ctx context.Context, user *model.User, password string) error {
+	hashedPassword, err := u.hasher.HashPassword(password)
+	if err != nil {
+		// bcrypt can't take a long password set under Argon2id, the old hash is kept then
+		if errors.Is(err, auth.ErrPasswordTooLong) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to rehash password: %w", err)
+	}
+
+	return u.userRepo.UpdatePassword(ctx, user.GUID, hashedPassword)
+}
+
+// reserve counts the attempt for every key before the password is checked, so that parallel attempts can't get
+// past the delay and the limits. An attempt rejected by one key is taken back from the keys before it.
+func (u *Usecase) reserve(ctx context.Context, keys []throttleKey) ([]reservation, error) {
+	reserved := make([]reservation, 0, len(keys))
+
+	for _, key := range keys {
+		hold := func(failures int) time.Duration {
+			if !key.delayed {
+				return 0
+			}
+			return u.delay(failures)
+		}
+
+		t, ok, err := u.throttle.Reserve(ctx, key.String(), u.limits.Window, hold)
+		if err != nil {
+			return nil, err
+		}
+
+		// the block is set by the clock of the store, at least a second is asked to wait
+		retryAfter := time.Second
+		switch {
+		case !ok:
+			retryAfter = max(retryAfter, t.RetryAfter(time.Now()))
+		case t.Failures > key.maxFailures:
+			// the attempts in flight have used up the limit, they lock the key if they fail
+			reserved = append(reserved, reservation{key: key, failures: t.Failures})
+		default:
+			reserved = append(reserved, reservation{key: key, failures: t.Failures})
+			continue
+		}
+
+		u.release(ctx, reserved)
+
+		return nil, &ThrottledError{Subject: key.subject, Value: key.value, RetryAfter: retryAfter}
+	}
+
+	return reserved, nil
+}
+
+// fail locks the keys whose reserved attempt has reached the limit and returns cause, or the error of the store.
+func (u *Usecase) fail(ctx context.Context, reserved []reservation, cause error) error {
+	for _, r := range reserved {
+		if r.failures < r.key.maxFailures {
+			continue
+		}
+
+		err := u.lock(ctx, r.key, r.failures)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cause
+}
+
+// release takes back the reserved attempts. If it fails, they expire with the window.
+func (u *Usecase) release(ctx context.Context, reserved []reservation) {
+	for _, r := range reserved {
+		_ = u.throttle.Release(ctx, r.key.String())
+	}
+}
+
+// dummy a hash to check the password of an unknown login against. It is made by the hasher in use, so that the
+// check costs the same.
+func (u *Usecase) dummy() (string, error) {
+	u.dummyMu.Lock()
+	defer u.dummyMu.Unlock()
+
+	if u.dummyHash == "" {
+		hash, err := u.hasher.HashPassword("dummy password")
+		if err != nil {
+			return "", fmt.Errorf("failed to hash dummy password: %w", err)
+		}
+		u.dummyHash = hash
+	}
+
+	return u.dummyHash, nil
+}
+
+func (u *Usecase) lock(ctx context.Context, key throttleKey, failures int) error {
+	until := time.Now().Add(u.limits.LockoutTime)
+
+	err := u.throttle.Block(ctx, key.String(), until)
+	if err != nil {
+		return err
+	}
+
+	return u.lockouts.Add(ctx, model.NewLoginLockout(u.guidGen.Generate(), key.subject, key.value, failures, until))
+}
+
+// delay after the failures, capped by the lockout time.
+func (u *Usecase) delay(failures int) time.Duration {
+	delay := u.limits.DelayBase
+	for i := 1; i < failures && delay < u.limits.LockoutTime; i++ {
+		delay *= 2
 	}
 
-	return u.jwt.BuildJWTString(user.GUID)
+	return min(delay, u.limits.LockoutTime)
 }
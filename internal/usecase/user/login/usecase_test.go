@@ -0,0 +1,377 @@
+package login_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/login"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
+)
+
+const (
+	userLogin = "abcd"
+	ip        = "192.0.2.1"
+	// bcrypt hash of "123456"
+	passwordHash = "$2a$10$wEwL0jTt5ryuBRzCv56A3eq0odey9nSFrcuqqubJttyLjAw3SF2/."
+)
+
+func newUsecase(ctrl *gomock.Controller, throttle repository.LoginThrottleRepo, lockouts repository.LoginLockoutRepo, limits login.Limits) *login.Usecase {
+	userRepo := mockRep.NewMockUserRepository(ctrl)
+	userRepo.EXPECT().FindByLogin(gomock.Any(), userLogin).Return(&model.User{
+		GUID:     "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7",
+		Login:    userLogin,
+		Password: passwordHash,
+	}, nil).AnyTimes()
+	userRepo.EXPECT().FindByLogin(gomock.Any(), gomock.Any()).Return(nil, nil).AnyTimes()
+
+	tokenRepo := mockRep.NewMockTokenRepo(ctrl)
+	tokenRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+
+	guidGen := new(guid.Generator)
+	tokens := token.NewUsecase(tokenRepo, auth.NewJWTBuilder("secret", time.Hour), guidGen, nil, 24*time.Hour)
+
+	return login.NewUsecase(userRepo, auth.NewHasher(), tokens, throttle, lockouts, guidGen, limits, mockLog.NewMockLogger(ctrl))
+}
+
+func TestUsecase_LoginUser_Delay(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	u := newUsecase(ctrl, repository.NewLoginThrottleMemory(), mockRep.NewMockLoginLockoutRepo(ctrl), login.Limits{
+		MaxFailures:      5,
+		MaxFailuresPerIP: 20,
+		Window:           time.Hour,
+		LockoutTime:      time.Hour,
+		DelayBase:        time.Minute,
+	})
+
+	_, err := u.LoginUser(context.Background(), userLogin, "wrong", ip)
+	require.ErrorIs(t, err, login.ErrWrongPassword)
+
+	// the right password doesn't help until the delay is over
+	_, err = u.LoginUser(context.Background(), userLogin, "123456", ip)
+	require.ErrorIs(t, err, login.ErrTooManyAttempts)
+
+	var throttled *login.ThrottledError
+	require.True(t, errors.As(err, &throttled))
+	assert.Equal(t, model.ThrottleLogin, throttled.Subject)
+	// the delay runs from the start of the failed attempt, the password check is slow under the race detector
+	assert.InDelta(t, time.Minute, throttled.RetryAfter, float64(5*time.Second))
+}
+
+func TestUsecase_LoginUser_Lockout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var lockout *model.LoginLockout
+	lockouts := mockRep.NewMockLoginLockoutRepo(ctrl)
+	lockouts.EXPECT().
+		Add(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, l *model.LoginLockout) error {
+			lockout = l
+			return nil
+		})
+
+	// without a delay only the lockout rejects attempts
+	u := newUsecase(ctrl, repository.NewLoginThrottleMemory(), lockouts, login.Limits{
+		MaxFailures:      3,
+		MaxFailuresPerIP: 20,
+		Window:           time.Hour,
+		LockoutTime:      time.Hour,
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := u.LoginUser(context.Background(), userLogin, "wrong", ip)
+		require.ErrorIs(t, err, login.ErrWrongPassword)
+	}
+
+	_, err := u.LoginUser(context.Background(), userLogin, "123456", ip)
+	require.ErrorIs(t, err, login.ErrTooManyAttempts)
+
+	require.NotNil(t, lockout)
+	assert.NotEmpty(t, lockout.GUID)
+	assert.Equal(t, model.ThrottleLogin, lockout.Subject)
+	assert.Equal(t, userLogin, lockout.Value)
+	assert.Equal(t, 3, lockout.Failures)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), lockout.LockedUntil, time.Minute)
+
+	// other logins from the address are still allowed
+	_, err = u.LoginUser(context.Background(), "other", "123456", ip)
+	require.ErrorIs(t, err, login.ErrUserNotFound)
+}
+
+func TestUsecase_LoginUser_LockoutPerIP(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	lockouts := mockRep.NewMockLoginLockoutRepo(ctrl)
+	lockouts.EXPECT().
+		Add(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, l *model.LoginLockout) error {
+			assert.Equal(t, model.ThrottleIP, l.Subject)
+			assert.Equal(t, ip, l.Value)
+			return nil
+		})
+
+	u := newUsecase(ctrl, repository.NewLoginThrottleMemory(), lockouts, login.Limits{
+		MaxFailures:      5,
+		MaxFailuresPerIP: 3,
+		Window:           time.Hour,
+		LockoutTime:      time.Hour,
+	})
+
+	for _, l := range []string{"first", "second", "third"} {
+		_, err := u.LoginUser(context.Background(), l, "123456", ip)
+		require.ErrorIs(t, err, login.ErrUserNotFound)
+	}
+
+	_, err := u.LoginUser(context.Background(), userLogin, "123456", ip)
+	require.ErrorIs(t, err, login.ErrTooManyAttempts)
+
+	// the same login from another address is allowed
+	_, err = u.LoginUser(context.Background(), userLogin, "123456", "192.0.2.2")
+	require.NoError(t, err)
+}
+
+func TestUsecase_LoginUser_SuccessResetsLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	throttle := repository.NewLoginThrottleMemory()
+	u := newUsecase(ctrl, throttle, mockRep.NewMockLoginLockoutRepo(ctrl), login.Limits{
+		MaxFailures:      5,
+		MaxFailuresPerIP: 20,
+		Window:           time.Hour,
+		LockoutTime:      time.Hour,
+	})
+
+	_, err := u.LoginUser(context.Background(), userLogin, "wrong", ip)
+	require.ErrorIs(t, err, login.ErrWrongPassword)
+
+	pair, err := u.LoginUser(context.Background(), userLogin, "123456", ip)
+	require.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+
+	byLogin, err := throttle.Throttle(context.Background(), model.ThrottleKey(model.ThrottleLogin, userLogin))
+	require.NoError(t, err)
+	assert.Nil(t, byLogin)
+
+	byIP, err := throttle.Throttle(context.Background(), model.ThrottleKey(model.ThrottleIP, ip))
+	require.NoError(t, err)
+	require.NotNil(t, byIP)
+	assert.Equal(t, 1, byIP.Failures)
+}
+
+func TestUsecase_LoginUser_Parallel(t *testing.T) {
+	tests := []struct {
+		name      string
+		delayBase time.Duration
+		// wantWrong attempts that get to the password check, the rest are throttled
+		wantWrong int
+	}{
+		{name: "limit", wantWrong: 3},
+		{name: "delay", delayBase: time.Minute, wantWrong: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			lockouts := mockRep.NewMockLoginLockoutRepo(ctrl)
+			lockouts.EXPECT().Add(gomock.Any(), gomock.Any()).Return(nil).MaxTimes(1)
+
+			u := newUsecase(ctrl, repository.NewLoginThrottleMemory(), lockouts, login.Limits{
+				MaxFailures:      3,
+				MaxFailuresPerIP: 100,
+				Window:           time.Hour,
+				LockoutTime:      time.Hour,
+				DelayBase:        tt.delayBase,
+			})
+
+			const attempts = 10
+
+			var (
+				wg               sync.WaitGroup
+				wrong, throttled atomic.Int32
+			)
+
+			for i := 0; i < attempts; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					_, err := u.LoginUser(context.Background(), userLogin, "wrong", ip)
+					switch {
+					case errors.Is(err, login.ErrWrongPassword):
+						wrong.Add(1)
+					case errors.Is(err, login.ErrTooManyAttempts):
+						throttled.Add(1)
+					default:
+						t.Errorf("unexpected error: %v", err)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			assert.EqualValues(t, tt.wantWrong, wrong.Load())
+			assert.EqualValues(t, attempts-tt.wantWrong, throttled.Load())
+		})
+	}
+}
+
+// spyHasher records the hashes passwords are checked against.
+type spyHasher struct {
+	auth.IHasher
+	mu     sync.Mutex
+	hashes []string
+}
+
+func (h *spyHasher) ComparePasswords(hashedPassword, password string) bool {
+	h.mu.Lock()
+	h.hashes = append(h.hashes, hashedPassword)
+	h.mu.Unlock()
+
+	return h.IHasher.ComparePasswords(hashedPassword, password)
+}
+
+func TestUsecase_LoginUser_UnknownLoginIsHashed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	userRepo := mockRep.NewMockUserRepository(ctrl)
+	userRepo.EXPECT().FindByLogin(gomock.Any(), gomock.Any()).Return(nil, nil).Times(2)
+
+	hasher := &spyHasher{IHasher: auth.NewHasher()}
+	guidGen := new(guid.Generator)
+	u := login.NewUsecase(userRepo, hasher, nil, repository.NewLoginThrottleMemory(), mockRep.NewMockLoginLockoutRepo(ctrl), guidGen, login.Limits{
+		MaxFailures:      5,
+		MaxFailuresPerIP: 100,
+		Window:           time.Hour,
+		LockoutTime:      time.Hour,
+	}, mockLog.NewMockLogger(ctrl))
+
+	for _, l := range []string{"first", "second"} {
+		_, err := u.LoginUser(context.Background(), l, "123456", ip)
+		require.ErrorIs(t, err, login.ErrUserNotFound)
+	}
+
+	// the password is checked against the same dummy hash, which is made once
+	require.Len(t, hasher.hashes, 2)
+	assert.NotEmpty(t, hasher.hashes[0])
+	assert.Equal(t, hasher.hashes[0], hasher.hashes[1])
+}
+
+// failingHasher can't hash a password.
+type failingHasher struct {
+	auth.IHasher
+}
+
+func (h failingHasher) HashPassword(string) (string, error) {
+	return "", errors.New("no entropy")
+}
+
+func TestUsecase_LoginUser_ErrorReleasesAttempt(t *testing.T) {
+	tests := []struct {
+		name    string
+		findErr error
+		hasher  auth.IHasher
+		wantErr string
+	}{
+		{name: "lookup_failed", findErr: errors.New("connection refused"), hasher: auth.NewHasher(), wantErr: "connection refused"},
+		{name: "dummy_hash_failed", hasher: failingHasher{IHasher: auth.NewHasher()}, wantErr: "failed to hash dummy password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			userRepo := mockRep.NewMockUserRepository(ctrl)
+			userRepo.EXPECT().FindByLogin(gomock.Any(), userLogin).Return(nil, tt.findErr)
+
+			throttle := repository.NewLoginThrottleMemory()
+			u := login.NewUsecase(userRepo, tt.hasher, nil, throttle, mockRep.NewMockLoginLockoutRepo(ctrl), new(guid.Generator), login.Limits{
+				MaxFailures:      5,
+				MaxFailuresPerIP: 100,
+				Window:           time.Hour,
+				LockoutTime:      time.Hour,
+			}, mockLog.NewMockLogger(ctrl))
+
+			_, err := u.LoginUser(context.Background(), userLogin, "123456", ip)
+			require.ErrorContains(t, err, tt.wantErr)
+
+			for _, key := range []string{model.ThrottleKey(model.ThrottleLogin, userLogin), model.ThrottleKey(model.ThrottleIP, ip)} {
+				got, err := throttle.Throttle(context.Background(), key)
+				require.NoError(t, err)
+				require.NotNil(t, got)
+				assert.Zero(t, got.Failures, key)
+			}
+		})
+	}
+}
+
+func TestUsecase_LoginUser_Rehash(t *testing.T) {
+	hasher := auth.NewArgon2Hasher(auth.Argon2Params{Memory: 64, Time: 1, Threads: 1, SaltLen: 16, KeyLen: 32})
+	current, err := hasher.HashPassword("123456")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		hash      string
+		rehash    bool
+		rehashErr error
+	}{
+		{name: "outdated", hash: passwordHash, rehash: true},
+		{name: "current", hash: current, rehash: false},
+		// the user is logged in with the old hash
+		{name: "failed", hash: passwordHash, rehash: true, rehashErr: errors.New("database is down")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			user := &model.User{GUID: "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7", Login: userLogin, Password: tt.hash}
+			userRepo := mockRep.NewMockUserRepository(ctrl)
+			userRepo.EXPECT().FindByLogin(gomock.Any(), userLogin).Return(user, nil)
+			if tt.rehash {
+				userRepo.EXPECT().
+					UpdatePassword(gomock.Any(), user.GUID, gomock.Any()).
+					DoAndReturn(func(_ context.Context, _, hashed string) error {
+						assert.True(t, hasher.ComparePasswords(hashed, "123456"))
+						assert.False(t, hasher.NeedsRehash(hashed))
+						return tt.rehashErr
+					})
+			}
+
+			log := mockLog.NewMockLogger(ctrl)
+			if tt.rehashErr != nil {
+				log.EXPECT().WithError(tt.rehashErr).Return(log)
+				log.EXPECT().WithField("user", user.GUID).Return(log)
+				log.EXPECT().Error(gomock.Any())
+			}
+
+			tokenRepo := mockRep.NewMockTokenRepo(ctrl)
+			tokenRepo.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil)
+
+			guidGen := new(guid.Generator)
+			tokens := token.NewUsecase(tokenRepo, auth.NewJWTBuilder("secret", time.Hour), guidGen, nil, 24*time.Hour)
+			u := login.NewUsecase(userRepo, hasher, tokens, repository.NewLoginThrottleMemory(), mockRep.NewMockLoginLockoutRepo(ctrl), guidGen, login.Limits{
+				MaxFailures:      5,
+				MaxFailuresPerIP: 20,
+				Window:           time.Hour,
+				LockoutTime:      time.Hour,
+			}, log)
+
+			_, err := u.LoginUser(context.Background(), userLogin, "123456", ip)
+			require.NoError(t, err)
+		})
+	}
+}
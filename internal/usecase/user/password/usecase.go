@@ -0,0 +1,146 @@
+package password
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/notifier"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/login"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
+)
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrWrongPassword     = errors.New("wrong password")
+	ErrInvalidResetToken = errors.New("invalid reset token")
+)
+
+type Usecase struct {
+	userRepo  repository.UserRepository
+	resetRepo repository.PasswordResetRepo
+	hasher    auth.IHasher
+	tokens    *token.Usecase
+	logins    *login.Usecase
+	notifier  notifier.Notifier
+	guidGen   guid.IGenerator
+	resetTTL  time.Duration
+}
+
+func NewUsecase(
+	userRepo repository.UserRepository,
+	resetRepo repository.PasswordResetRepo,
+	hasher auth.IHasher,
+	tokens *token.Usecase,
+	logins *login.Usecase,
+	notifier notifier.Notifier,
+	guidGen guid.IGenerator,
+	resetTTL time.Duration,
+) *Usecase {
+	return &Usecase{
+		userRepo:  userRepo,
+		resetRepo: resetRepo,
+		hasher:    hasher,
+		tokens:    tokens,
+		logins:    logins,
+		notifier:  notifier,
+		guidGen:   guidGen,
+		resetTTL:  resetTTL,
+	}
+}
+
+// Change sets a new password of the user who knows the current one. All sessions are ended, including the one of
+// the request, so a new pair of tokens is returned.
+func (u Usecase) Change(ctx context.Context, userGUID, currentPassword, newPassword string) (*token.Pair, error) {
+	user, err := u.userRepo.FindByGUID(ctx, userGUID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, fmt.Errorf("%w: guid %q", ErrUserNotFound, userGUID)
+	}
+
+	// the check is throttled as a login, otherwise a stolen access token would let guess the password freely
+	err = u.logins.VerifyPassword(ctx, user, currentPassword)
+	if errors.Is(err, login.ErrWrongPassword) {
+		return nil, fmt.Errorf("%w: guid %q", ErrWrongPassword, userGUID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := u.hasher.HashPassword(newPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.updatePassword(ctx, user.GUID, hashedPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	return u.tokens.Issue(ctx, user.GUID)
+}
+
+// RequestReset sends a reset token to the user with the login. An unknown login is not an error, so that the
+// response doesn't tell which logins exist.
+func (u Usecase) RequestReset(ctx context.Context, login string) error {
+	user, err := u.userRepo.FindByLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	resetToken, err := auth.NewResetToken()
+	if err != nil {
+		return err
+	}
+
+	reset := model.NewPasswordReset(u.guidGen.Generate(), user.GUID, auth.HashResetToken(resetToken), u.resetTTL)
+
+	err = u.resetRepo.Create(ctx, reset)
+	if err != nil {
+		return err
+	}
+
+	return u.notifier.PasswordReset(ctx, user, resetToken, reset.ExpiresAt)
+}
+
+// Reset sets a new password with a reset token and ends all sessions of the user. The token works once, and the
+// other tokens of the user stop working with it.
+func (u Usecase) Reset(ctx context.Context, resetToken, newPassword string) error {
+	// the password is hashed before the token is used up, so that a password the hasher rejects doesn't cost it
+	hashedPassword, err := u.hasher.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	userGUID, err := u.resetRepo.Use(ctx, auth.HashResetToken(resetToken))
+	if err != nil {
+		return err
+	}
+	if userGUID == "" {
+		return ErrInvalidResetToken
+	}
+
+	return u.updatePassword(ctx, userGUID, hashedPassword)
+}
+
+// updatePassword sets the password and ends all sessions at once, so that no session outlives the old password.
+func (u Usecase) updatePassword(ctx context.Context, userGUID, hashedPassword string) error {
+	jtis, err := u.userRepo.ChangePassword(ctx, userGUID, hashedPassword)
+	if err != nil {
+		return err
+	}
+
+	u.tokens.Revoked(jtis)
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package password_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	mockLog "github.com/bjlag/go-loyalty/internal/infrastructure/logger/mock"
+	mockNotifier "github.com/bjlag/go-loyalty/internal/infrastructure/notifier/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/login"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/password"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
+)
+
+const (
+	userGUID  = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+	userLogin = "abcd"
+)
+
+type mocks struct {
+	users       *mockRep.MockUserRepository
+	resets      *mockRep.MockPasswordResetRepo
+	tokens      *mockRep.MockTokenRepo
+	lockouts    *mockRep.MockLoginLockoutRepo
+	notifier    *mockNotifier.MockNotifier
+	revocations *auth.RevocationCache
+}
+
+func newUsecase(t *testing.T) (*password.Usecase, mocks, *auth.Hasher) {
+	ctrl := gomock.NewController(t)
+	m := mocks{
+		users:    mockRep.NewMockUserRepository(ctrl),
+		resets:   mockRep.NewMockPasswordResetRepo(ctrl),
+		tokens:   mockRep.NewMockTokenRepo(ctrl),
+		lockouts: mockRep.NewMockLoginLockoutRepo(ctrl),
+		notifier: mockNotifier.NewMockNotifier(ctrl),
+		// answers are only taken from the cache
+		revocations: auth.NewRevocationCache(nil, time.Minute),
+	}
+
+	hasher := auth.NewHasher(auth.WithCost(bcrypt.MinCost))
+	guidGen := new(guid.Generator)
+	tokens := token.NewUsecase(m.tokens, auth.NewJWTBuilder("secret", time.Hour), guidGen, m.revocations, 24*time.Hour)
+	logins := login.NewUsecase(m.users, hasher, tokens, repository.NewLoginThrottleMemory(), m.lockouts, guidGen, login.Limits{
+		MaxFailures:      3,
+		MaxFailuresPerIP: 100,
+		Window:           time.Hour,
+		LockoutTime:      time.Hour,
+	}, mockLog.NewMockLogger(ctrl))
+
+	return password.NewUsecase(m.users, m.resets, hasher, tokens, logins, m.notifier, guidGen, time.Hour), m, hasher
+}
+
+func newUser(t *testing.T, hasher *auth.Hasher, pwd string) *model.User {
+	hashed, err := hasher.HashPassword(pwd)
+	require.NoError(t, err)
+
+	return &model.User{GUID: userGUID, Login: userLogin, Password: hashed}
+}
+
+func TestUsecase_Change(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		u, m, hasher := newUsecase(t)
+
+		m.users.EXPECT().FindByGUID(gomock.Any(), userGUID).Return(newUser(t, hasher, "123456"), nil)
+		gomock.InOrder(
+			m.users.EXPECT().
+				ChangePassword(gomock.Any(), userGUID, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _, hashed string) ([]string, error) {
+					assert.True(t, hasher.ComparePasswords(hashed, "new-password"))
+					return []string{"old-jti"}, nil
+				}),
+			m.tokens.EXPECT().CreateRefreshToken(gomock.Any(), gomock.Any()).Return(nil),
+		)
+
+		pair, err := u.Change(context.Background(), userGUID, "123456", "new-password")
+		require.NoError(t, err)
+		assert.NotEmpty(t, pair.AccessToken)
+		assert.NotEmpty(t, pair.RefreshToken)
+
+		// the old session ends on this replica at once
+		revoked, err := m.revocations.IsRevoked(context.Background(), "old-jti")
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("throttled", func(t *testing.T) {
+		u, m, hasher := newUsecase(t)
+
+		m.users.EXPECT().FindByGUID(gomock.Any(), userGUID).Return(newUser(t, hasher, "123456"), nil).Times(4)
+		m.lockouts.EXPECT().Add(gomock.Any(), gomock.Any()).Return(nil)
+
+		for i := 0; i < 3; i++ {
+			_, err := u.Change(context.Background(), userGUID, "654321", "new-password")
+			require.ErrorIs(t, err, password.ErrWrongPassword)
+		}
+
+		_, err := u.Change(context.Background(), userGUID, "123456", "new-password")
+		assert.ErrorIs(t, err, login.ErrTooManyAttempts)
+	})
+
+	t.Run("wrong_password", func(t *testing.T) {
+		u, m, hasher := newUsecase(t)
+
+		m.users.EXPECT().FindByGUID(gomock.Any(), userGUID).Return(newUser(t, hasher, "123456"), nil)
+
+		_, err := u.Change(context.Background(), userGUID, "654321", "new-password")
+		assert.ErrorIs(t, err, password.ErrWrongPassword)
+	})
+
+	t.Run("user_not_found", func(t *testing.T) {
+		u, m, _ := newUsecase(t)
+
+		m.users.EXPECT().FindByGUID(gomock.Any(), userGUID).Return(nil, nil)
+
+		_, err := u.Change(context.Background(), userGUID, "123456", "new-password")
+		assert.ErrorIs(t, err, password.ErrUserNotFound)
+	})
+}
+
+func TestUsecase_RequestReset(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		u, m, hasher := newUsecase(t)
+		user := newUser(t, hasher, "123456")
+
+		var saved *model.PasswordReset
+		m.users.EXPECT().FindByLogin(gomock.Any(), userLogin).Return(user, nil)
+		m.resets.EXPECT().
+			Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, reset *model.PasswordReset) error {
+				saved = reset
+				return nil
+			})
+		m.notifier.EXPECT().
+			PasswordReset(gomock.Any(), user, gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, _ *model.User, resetToken string, expiresAt time.Time) error {
+				require.NotNil(t, saved)
+				assert.Equal(t, auth.HashResetToken(resetToken), saved.TokenHash)
+				assert.NotEqual(t, resetToken, saved.TokenHash)
+				assert.Equal(t, saved.ExpiresAt, expiresAt)
+				return nil
+			})
+
+		err := u.RequestReset(context.Background(), userLogin)
+		require.NoError(t, err)
+
+		assert.Equal(t, userGUID, saved.UserGUID)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), saved.ExpiresAt, time.Minute)
+	})
+
+	t.Run("unknown_login", func(t *testing.T) {
+		u, m, _ := newUsecase(t)
+
+		m.users.EXPECT().FindByLogin(gomock.Any(), userLogin).Return(nil, nil)
+
+		err := u.RequestReset(context.Background(), userLogin)
+		assert.NoError(t, err)
+	})
+}
+
+func TestUsecase_Reset(t *testing.T) {
+	const resetToken = "reset-token"
+
+	t.Run("success", func(t *testing.T) {
+		u, m, hasher := newUsecase(t)
+
+		m.resets.EXPECT().Use(gomock.Any(), auth.HashResetToken(resetToken)).Return(userGUID, nil)
+		m.users.EXPECT().
+			ChangePassword(gomock.Any(), userGUID, gomock.Any()).
+			DoAndReturn(func(_ context.Context, _, hashed string) ([]string, error) {
+				assert.True(t, hasher.ComparePasswords(hashed, "new-password"))
+				return nil, nil
+			})
+
+		err := u.Reset(context.Background(), resetToken, "new-password")
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid_token", func(t *testing.T) {
+		u, m, _ := newUsecase(t)
+
+		m.resets.EXPECT().Use(gomock.Any(), auth.HashResetToken(resetToken)).Return("", nil)
+
+		err := u.Reset(context.Background(), resetToken, "new-password")
+		assert.ErrorIs(t, err, password.ErrInvalidResetToken)
+	})
+
+	t.Run("password_rejected", func(t *testing.T) {
+		u, _, _ := newUsecase(t)
+
+		// the token is not used up
+		err := u.Reset(context.Background(), resetToken, string(make([]byte, 100)))
+		assert.ErrorIs(t, err, auth.ErrPasswordTooLong)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
 	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
 	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
 )
 
 var ErrUserAlreadyExists = errors.New("user already exists")
@@ -17,30 +18,30 @@ type Usecase struct {
 	userRepo repository.UserRepository
 	guidGen  guid.IGenerator
 	hasher   auth.IHasher
-	jwt      *auth.JWTBuilder
+	tokens   *token.Usecase
 }
 
-func NewUsecase(userRepo repository.UserRepository, guidGen guid.IGenerator, hasher auth.IHasher, jwt *auth.JWTBuilder) *Usecase {
+func NewUsecase(userRepo repository.UserRepository, guidGen guid.IGenerator, hasher auth.IHasher, tokens *token.Usecase) *Usecase {
 	return &Usecase{
 		userRepo: userRepo,
 		guidGen:  guidGen,
 		hasher:   hasher,
-		jwt:      jwt,
+		tokens:   tokens,
 	}
 }
 
-func (u Usecase) RegisterUser(ctx context.Context, login, password string) (string, error) {
+func (u Usecase) RegisterUser(ctx context.Context, login, password string) (*token.Pair, error) {
 	user, err := u.userRepo.FindByLogin(ctx, login)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if user != nil {
-		return "", fmt.Errorf("%w: email %q", ErrUserAlreadyExists, login)
+		return nil, fmt.Errorf("%w: email %q", ErrUserAlreadyExists, login)
 	}
 
 	hashedPassword, err := u.hasher.HashPassword(password)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	user = &model.User{
@@ -51,8 +52,8 @@ func (u Usecase) RegisterUser(ctx context.Context, login, password string) (stri
 
 	err = u.userRepo.Insert(ctx, user)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return u.jwt.BuildJWTString(user.GUID)
+	return u.tokens.Issue(ctx, user.GUID)
 }
@@ -0,0 +1,166 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused a used refresh token is presented again: either the client or a thief holds a copy, so
+	// the whole family is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// Pair an access token with the refresh token that replaces it when it expires.
+type Pair struct {
+	AccessToken  string
+	RefreshToken string
+}
+
+type Usecase struct {
+	tokenRepo   repository.TokenRepo
+	jwt         *auth.JWTBuilder
+	guidGen     guid.IGenerator
+	revocations *auth.RevocationCache
+	refreshExp  time.Duration
+}
+
+func NewUsecase(
+	tokenRepo repository.TokenRepo,
+	jwt *auth.JWTBuilder,
+	guidGen guid.IGenerator,
+	revocations *auth.RevocationCache,
+	refreshExp time.Duration,
+) *Usecase {
+	return &Usecase{
+		tokenRepo:   tokenRepo,
+		jwt:         jwt,
+		guidGen:     guidGen,
+		revocations: revocations,
+		refreshExp:  refreshExp,
+	}
+}
+
+// Issue starts a new family of tokens for a user who has just logged in or registered.
+func (u Usecase) Issue(ctx context.Context, userGUID string) (*Pair, error) {
+	pair, next, err := u.build(userGUID, u.guidGen.Generate())
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.tokenRepo.CreateRefreshToken(ctx, next)
+	if err != nil {
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// Refresh exchanges the refresh token for a new pair of the same family. Each refresh token is exchanged once.
+func (u Usecase) Refresh(ctx context.Context, refreshToken string) (*Pair, error) {
+	used, err := u.tokenRepo.RefreshTokenByHash(ctx, auth.HashRefreshToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if used == nil || used.RevokedAt != nil || !time.Now().Before(used.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if used.UsedAt != nil {
+		return nil, u.revokeFamily(ctx, used)
+	}
+
+	pair, next, err := u.build(used.UserGUID, used.FamilyGUID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = u.tokenRepo.Rotate(ctx, used.GUID, next)
+	if err != nil {
+		// another request has exchanged the same token in the meantime
+		if errors.Is(err, repository.ErrRefreshTokenUsed) {
+			return nil, u.revokeFamily(ctx, used)
+		}
+
+		return nil, err
+	}
+
+	return pair, nil
+}
+
+// Revoke ends the session of the access token: the token and its refresh token family stop working.
+func (u Usecase) Revoke(ctx context.Context, claims *auth.Claims) error {
+	err := u.tokenRepo.RevokeByAccessJTI(ctx, claims.ID, claims.ExpiresAt)
+	if err != nil {
+		return err
+	}
+
+	u.revocations.Revoke(claims.ID)
+
+	return nil
+}
+
+// RevokeUser ends all sessions of the user. Other replicas that have cached an access token as valid accept it until
+// the cache entry expires, this one rejects it at once.
+func (u Usecase) RevokeUser(ctx context.Context, userGUID string) error {
+	jtis, err := u.tokenRepo.RevokeUser(ctx, userGUID)
+	if err != nil {
+		return err
+	}
+
+	u.Revoked(jtis)
+
+	return nil
+}
+
+// Revoked makes this replica reject the access tokens that have just been put on the revocation list.
+func (u Usecase) Revoked(jtis []string) {
+	for _, jti := range jtis {
+		u.revocations.Revoke(jti)
+	}
+}
+
+func (u Usecase) revokeFamily(ctx context.Context, used *model.RefreshToken) error {
+	err := u.tokenRepo.RevokeFamily(ctx, used.FamilyGUID)
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: family %s of user %s", ErrRefreshTokenReused, used.FamilyGUID, used.UserGUID)
+}
+
+func (u Usecase) build(userGUID, familyGUID string) (*Pair, *model.RefreshToken, error) {
+	jti := u.guidGen.Generate()
+
+	accessToken, accessExpiresAt, err := u.jwt.BuildAccessToken(userGUID, jti)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build access token: %w", err)
+	}
+
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	next := &model.RefreshToken{
+		GUID:            u.guidGen.Generate(),
+		FamilyGUID:      familyGUID,
+		UserGUID:        userGUID,
+		TokenHash:       auth.HashRefreshToken(refreshToken),
+		AccessJTI:       jti,
+		AccessExpiresAt: accessExpiresAt,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(u.refreshExp),
+	}
+
+	return &Pair{AccessToken: accessToken, RefreshToken: refreshToken}, next, nil
+}
@@ -0,0 +1,220 @@
+package token_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/auth"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/user/token"
+)
+
+const (
+	userGUID     = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+	familyGUID   = "0f1e8c7b-5d3a-4c2b-9a10-6e7f8d9c0b1a"
+	refreshGUID  = "c0d1b0d4-8c4d-4f0a-9f7e-0b7c1d8e4a11"
+	refreshToken = "old-refresh-token"
+)
+
+func newUsecase(repo repository.TokenRepo, revocations *auth.RevocationCache) (*token.Usecase, *auth.JWTBuilder) {
+	jwt := auth.NewJWTBuilder("secret", time.Hour)
+	return token.NewUsecase(repo, jwt, new(guid.Generator), revocations, 24*time.Hour), jwt
+}
+
+func TestUsecase_Issue(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	var saved *model.RefreshToken
+	repoMock := mockRep.NewMockTokenRepo(ctrl)
+	repoMock.EXPECT().
+		CreateRefreshToken(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, rt *model.RefreshToken) error {
+			saved = rt
+			return nil
+		})
+
+	u, jwt := newUsecase(repoMock, nil)
+
+	pair, err := u.Issue(context.Background(), userGUID)
+	require.NoError(t, err)
+
+	claims, err := jwt.ParseAccessToken(pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, userGUID, claims.UserGUID)
+
+	require.NotNil(t, saved)
+	assert.Equal(t, userGUID, saved.UserGUID)
+	assert.NotEmpty(t, saved.FamilyGUID)
+	assert.Equal(t, claims.ID, saved.AccessJTI)
+	assert.Equal(t, auth.HashRefreshToken(pair.RefreshToken), saved.TokenHash)
+	assert.NotEqual(t, pair.RefreshToken, saved.TokenHash)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), saved.ExpiresAt, time.Minute)
+}
+
+func TestUsecase_Refresh(t *testing.T) {
+	errSomeError := errors.New("some error")
+
+	stored := func() *model.RefreshToken {
+		return &model.RefreshToken{
+			GUID:       refreshGUID,
+			FamilyGUID: familyGUID,
+			UserGUID:   userGUID,
+			TokenHash:  auth.HashRefreshToken(refreshToken),
+			CreatedAt:  time.Now().Add(-time.Hour),
+			ExpiresAt:  time.Now().Add(time.Hour),
+		}
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		repo    func(ctrl *gomock.Controller) *mockRep.MockTokenRepo
+		wantErr error
+	}{
+		{
+			name: "success",
+			repo: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+				repoMock := mockRep.NewMockTokenRepo(ctrl)
+				gomock.InOrder(
+					repoMock.EXPECT().RefreshTokenByHash(gomock.Any(), auth.HashRefreshToken(refreshToken)).Return(stored(), nil),
+					repoMock.EXPECT().
+						Rotate(gomock.Any(), refreshGUID, gomock.Any()).
+						DoAndReturn(func(_ context.Context, _ string, next *model.RefreshToken) error {
+							assert.Equal(t, familyGUID, next.FamilyGUID)
+							assert.Equal(t, userGUID, next.UserGUID)
+							assert.NotEqual(t, refreshGUID, next.GUID)
+							return nil
+						}),
+				)
+				return repoMock
+			},
+		},
+		{
+			name: "unknown",
+			repo: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+				repoMock := mockRep.NewMockTokenRepo(ctrl)
+				repoMock.EXPECT().RefreshTokenByHash(gomock.Any(), gomock.Any()).Return(nil, nil)
+				return repoMock
+			},
+			wantErr: token.ErrInvalidRefreshToken,
+		},
+		{
+			name: "expired",
+			repo: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+				rt := stored()
+				rt.ExpiresAt = now.Add(-time.Minute)
+
+				repoMock := mockRep.NewMockTokenRepo(ctrl)
+				repoMock.EXPECT().RefreshTokenByHash(gomock.Any(), gomock.Any()).Return(rt, nil)
+				return repoMock
+			},
+			wantErr: token.ErrInvalidRefreshToken,
+		},
+		{
+			name: "revoked",
+			repo: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+				rt := stored()
+				rt.RevokedAt = &now
+
+				repoMock := mockRep.NewMockTokenRepo(ctrl)
+				repoMock.EXPECT().RefreshTokenByHash(gomock.Any(), gomock.Any()).Return(rt, nil)
+				return repoMock
+			},
+			wantErr: token.ErrInvalidRefreshToken,
+		},
+		{
+			name: "reused",
+			repo: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+				rt := stored()
+				rt.UsedAt = &now
+
+				repoMock := mockRep.NewMockTokenRepo(ctrl)
+				gomock.InOrder(
+					repoMock.EXPECT().RefreshTokenByHash(gomock.Any(), gomock.Any()).Return(rt, nil),
+					repoMock.EXPECT().RevokeFamily(gomock.Any(), familyGUID).Return(nil),
+				)
+				return repoMock
+			},
+			wantErr: token.ErrRefreshTokenReused,
+		},
+		{
+			name: "reused_concurrently",
+			repo: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+				repoMock := mockRep.NewMockTokenRepo(ctrl)
+				gomock.InOrder(
+					repoMock.EXPECT().RefreshTokenByHash(gomock.Any(), gomock.Any()).Return(stored(), nil),
+					repoMock.EXPECT().
+						Rotate(gomock.Any(), refreshGUID, gomock.Any()).
+						Return(fmt.Errorf("%w: %s", repository.ErrRefreshTokenUsed, refreshGUID)),
+					repoMock.EXPECT().RevokeFamily(gomock.Any(), familyGUID).Return(nil),
+				)
+				return repoMock
+			},
+			wantErr: token.ErrRefreshTokenReused,
+		},
+		{
+			name: "repo_error",
+			repo: func(ctrl *gomock.Controller) *mockRep.MockTokenRepo {
+				repoMock := mockRep.NewMockTokenRepo(ctrl)
+				repoMock.EXPECT().RefreshTokenByHash(gomock.Any(), gomock.Any()).Return(nil, errSomeError)
+				return repoMock
+			},
+			wantErr: errSomeError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			u, jwt := newUsecase(tt.repo(ctrl), nil)
+
+			pair, err := u.Refresh(context.Background(), refreshToken)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, pair)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotEqual(t, refreshToken, pair.RefreshToken)
+
+			claims, err := jwt.ParseAccessToken(pair.AccessToken)
+			require.NoError(t, err)
+			assert.Equal(t, userGUID, claims.UserGUID)
+		})
+	}
+}
+
+func TestUsecase_Revoke(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	claims := &auth.Claims{
+		UserGUID:  userGUID,
+		ID:        "6b0f8d57-1c3f-4a43-9a4f-0f2b0bb4b1a1",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+
+	repoMock := mockRep.NewMockTokenRepo(ctrl)
+	repoMock.EXPECT().RevokeByAccessJTI(gomock.Any(), claims.ID, claims.ExpiresAt).Return(nil)
+
+	revocations := auth.NewRevocationCache(repoMock, time.Minute)
+	u, _ := newUsecase(repoMock, revocations)
+
+	require.NoError(t, u.Revoke(context.Background(), claims))
+
+	// answered by the cache, the repository is not asked
+	revoked, err := revocations.IsRevoked(context.Background(), claims.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,40 @@
+package create
+
+import (
+	"context"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/guid"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+type Usecase struct {
+	repo    repository.WebhookRepo
+	guidGen guid.IGenerator
+}
+
+func NewUsecase(repo repository.WebhookRepo, guidGen guid.IGenerator) *Usecase {
+	return &Usecase{
+		repo:    repo,
+		guidGen: guidGen,
+	}
+}
+
+// CreateWebhook subscribes url to events of the user's orders. The returned webhook holds the signing secret, which
+// is shown to the user only once.
+func (u *Usecase) CreateWebhook(ctx context.Context, userGUID, url string, events []model.EventType) (*model.Webhook, error) {
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	w := model.NewWebhook(u.guidGen.Generate(), userGUID, url, secret, events)
+
+	err = u.repo.Create(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
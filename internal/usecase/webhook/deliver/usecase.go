@@ -0,0 +1,156 @@
+package deliver
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/bjlag/go-loyalty/internal/infrastructure/backoff"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+)
+
+var errRequestFailed = errors.New("request failed")
+
+// Limits bound the work of one Deliver call.
+type Limits struct {
+	// Workers number of requests sent concurrently.
+	Workers int
+	// BatchSize number of deliveries read from the database at once.
+	BatchSize int
+	// LeaseTTL how long a delivery is hidden from other senders. A batch is leased at once and sent by Workers in
+	// turn, so it must cover BatchSize/Workers+1 request timeouts.
+	LeaseTTL time.Duration
+	// BackoffBase and BackoffMax bound the delay before the next attempt. The delay doubles with every attempt.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// MaxAttempts after which the delivery is failed and waits for a manual redelivery.
+	MaxAttempts int
+}
+
+type Result struct {
+	DeliveryGUID string
+	WebhookGUID  string
+	EventType    model.EventType
+	Status       model.DeliveryStatus
+	StatusCode   int
+	// Err is the reason of a failed attempt, or the error of recording the attempt.
+	Err error
+}
+
+type Usecase struct {
+	client *webhook.Client
+	repo   repository.WebhookRepo
+	limits Limits
+}
+
+func NewUsecase(client *webhook.Client, repo repository.WebhookRepo, limits Limits) *Usecase {
+	return &Usecase{
+		client: client,
+		repo:   repo,
+		limits: limits,
+	}
+}
+
+// Deliver sends due deliveries until there are none left or the context is done, and returns the results of the
+// attempts. Requests in flight are finished before it returns.
+func (u Usecase) Deliver(ctx context.Context) ([]Result, error) {
+	g := new(errgroup.Group)
+	g.SetLimit(u.limits.Workers)
+
+	var (
+		mu      sync.Mutex
+		results []Result
+		err     error
+	)
+
+	for ctx.Err() == nil {
+		var batch []model.WebhookDispatch
+		batch, err = u.repo.LeaseDue(ctx, u.limits.BatchSize, u.limits.LeaseTTL)
+		if err != nil {
+			break
+		}
+
+		for _, dispatch := range batch {
+			g.Go(func() error {
+				result, ok := u.send(ctx, dispatch)
+				if ok {
+					mu.Lock()
+					results = append(results, result)
+					mu.Unlock()
+				}
+				return nil
+			})
+		}
+
+		if len(batch) < u.limits.BatchSize {
+			break
+		}
+	}
+
+	_ = g.Wait()
+
+	if err != nil && ctx.Err() == nil {
+		return results, err
+	}
+
+	return results, nil
+}
+
+// send makes one attempt and records it. An attempt cut by shutdown is not recorded: the delivery is sent again
+// when its lease expires.
+func (u Usecase) send(ctx context.Context, dispatch model.WebhookDispatch) (Result, bool) {
+	d := dispatch.Delivery
+	result := Result{
+		DeliveryGUID: d.GUID,
+		WebhookGUID:  d.WebhookGUID,
+		EventType:    d.EventType,
+	}
+
+	code, sendErr := u.client.Send(ctx, dispatch)
+	if sendErr != nil && ctx.Err() != nil {
+		return result, false
+	}
+	result.StatusCode = code
+
+	saveCtx := context.WithoutCancel(ctx)
+
+	if sendErr == nil {
+		result.Status = model.DeliveryDelivered
+		result.Err = u.repo.MarkDelivered(saveCtx, d.GUID, code)
+		return result, true
+	}
+
+	result.Err = sendErr
+
+	var err error
+	if attempts := d.Attempts + 1; attempts < u.limits.MaxAttempts {
+		result.Status = model.DeliveryPending
+		nextAttemptAt := time.Now().Add(backoff.Exponential(u.limits.BackoffBase, u.limits.BackoffMax, attempts))
+		err = u.repo.ScheduleRetry(saveCtx, d.GUID, nextAttemptAt, code, lastError(sendErr))
+	} else {
+		result.Status = model.DeliveryFailed
+		err = u.repo.MarkFailed(saveCtx, d.GUID, code, lastError(sendErr))
+	}
+
+	if err != nil {
+		result.Err = err
+	}
+
+	return result, true
+}
+
+// lastError is the reason of a failed attempt shown to the user in the delivery log. Connection errors are not shown
+// as they are: they would tell what listens on a host, and webhooks would become a port scanner. Result.Err keeps
+// the whole error for the log.
+func lastError(err error) string {
+	if errors.Is(err, webhook.ErrUnexpectedStatus) {
+		return webhook.ErrUnexpectedStatus.Error()
+	}
+
+	return errRequestFailed.Error()
+}
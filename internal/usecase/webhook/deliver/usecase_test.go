@@ -0,0 +1,137 @@
+package deliver_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/service/webhook"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/webhook/deliver"
+)
+
+const secret = "0123456789abcdef"
+
+var limits = deliver.Limits{
+	Workers:     2,
+	BatchSize:   10,
+	LeaseTTL:    time.Minute,
+	BackoffBase: time.Second,
+	BackoffMax:  time.Minute,
+	MaxAttempts: 3,
+}
+
+// receiver verifies the signature and answers code.
+func receiver(t *testing.T, code int) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.NoError(t, webhook.Verify(secret, r.Header.Get(webhook.HeaderSignature), body, time.Minute))
+
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(server.Close)
+
+	return server.URL
+}
+
+func dispatch(url string, attempts int) model.WebhookDispatch {
+	return model.WebhookDispatch{
+		Delivery: model.WebhookDelivery{
+			GUID:        "d1",
+			WebhookGUID: "w1",
+			EventType:   model.EventAccrualInvalid,
+			Payload:     []byte(`{"id":"e1"}`),
+			Attempts:    attempts,
+		},
+		URL:    url,
+		Secret: secret,
+	}
+}
+
+func TestUsecase_Deliver(t *testing.T) {
+	t.Run("delivered", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().LeaseDue(gomock.Any(), 10, time.Minute).Return([]model.WebhookDispatch{dispatch(receiver(t, http.StatusOK), 0)}, nil)
+		repo.EXPECT().MarkDelivered(gomock.Any(), "d1", http.StatusOK).Return(nil)
+
+		results, err := deliver.NewUsecase(webhook.NewClient(time.Second, webhook.WithPrivateAddresses()), repo, limits).Deliver(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, model.DeliveryDelivered, results[0].Status)
+	})
+
+	t.Run("retried_with_backoff", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().LeaseDue(gomock.Any(), 10, time.Minute).Return([]model.WebhookDispatch{dispatch(receiver(t, http.StatusBadGateway), 1)}, nil)
+		repo.EXPECT().
+			ScheduleRetry(gomock.Any(), "d1", gomock.Any(), http.StatusBadGateway, "unexpected status code").
+			DoAndReturn(func(_ context.Context, _ string, nextAttemptAt time.Time, _ int, _ string) error {
+				// the second attempt waits between a half and the whole of two seconds
+				assert.WithinRange(t, nextAttemptAt, time.Now().Add(time.Second-100*time.Millisecond), time.Now().Add(2*time.Second))
+				return nil
+			})
+
+		results, err := deliver.NewUsecase(webhook.NewClient(time.Second, webhook.WithPrivateAddresses()), repo, limits).Deliver(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.ErrorIs(t, results[0].Err, webhook.ErrUnexpectedStatus)
+		assert.Equal(t, model.DeliveryPending, results[0].Status)
+	})
+
+	t.Run("failed_after_max_attempts", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().LeaseDue(gomock.Any(), 10, time.Minute).Return([]model.WebhookDispatch{dispatch(receiver(t, http.StatusInternalServerError), 2)}, nil)
+		repo.EXPECT().MarkFailed(gomock.Any(), "d1", http.StatusInternalServerError, gomock.Any()).Return(nil)
+
+		results, err := deliver.NewUsecase(webhook.NewClient(time.Second, webhook.WithPrivateAddresses()), repo, limits).Deliver(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, model.DeliveryFailed, results[0].Status)
+	})
+
+	t.Run("unreachable_subscriber", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().LeaseDue(gomock.Any(), 10, time.Minute).Return([]model.WebhookDispatch{dispatch(closed.URL, 0)}, nil)
+		repo.EXPECT().ScheduleRetry(gomock.Any(), "d1", gomock.Any(), 0, "request failed").Return(nil)
+
+		results, err := deliver.NewUsecase(webhook.NewClient(time.Second, webhook.WithPrivateAddresses()), repo, limits).Deliver(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Error(t, results[0].Err)
+		assert.Zero(t, results[0].StatusCode)
+	})
+
+	t.Run("private_address", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+
+		repo := mockRep.NewMockWebhookRepo(ctrl)
+		repo.EXPECT().LeaseDue(gomock.Any(), 10, time.Minute).Return([]model.WebhookDispatch{dispatch(receiver(t, http.StatusOK), 0)}, nil)
+		repo.EXPECT().ScheduleRetry(gomock.Any(), "d1", gomock.Any(), 0, "request failed").Return(nil)
+
+		results, err := deliver.NewUsecase(webhook.NewClient(time.Second), repo, limits).Deliver(context.Background())
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.ErrorIs(t, results[0].Err, webhook.ErrForbiddenAddress)
+	})
+}
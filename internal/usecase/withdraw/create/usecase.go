@@ -12,32 +12,22 @@ import (
 
 var (
 	ErrInsufficientBalanceOnAccount = errors.New("insufficient balance on account")
+	ErrOrderAlreadyPaid             = errors.New("order already paid")
 )
 
 type Usecase struct {
 	accrualRepo repository.AccrualRepo
-	accountRepo repository.AccountRepo
 	guidGen     guid.IGenerator
 }
 
-func NewUsecase(accrualRepo repository.AccrualRepo, accountRepo repository.AccountRepo, guidGen guid.IGenerator) *Usecase {
+func NewUsecase(accrualRepo repository.AccrualRepo, guidGen guid.IGenerator) *Usecase {
 	return &Usecase{
 		accrualRepo: accrualRepo,
-		accountRepo: accountRepo,
 		guidGen:     guidGen,
 	}
 }
 
-func (u *Usecase) CreateWithdraw(ctx context.Context, accountGUID, orderNumber string, sum float64) error {
-	balance, _, err := u.accountRepo.Balance(ctx, accountGUID)
-	if err != nil {
-		return err
-	}
-
-	if sum > balance {
-		return ErrInsufficientBalanceOnAccount
-	}
-
+func (u *Usecase) CreateWithdraw(ctx context.Context, accountGUID, orderNumber string, sum model.Points) error {
 	transaction := model.NewWithdrawTransaction(
 		u.guidGen.Generate(),
 		accountGUID,
@@ -46,8 +36,15 @@ func (u *Usecase) CreateWithdraw(ctx context.Context, accountGUID, orderNumber s
 		time.Now(),
 	)
 
-	err = u.accrualRepo.WithdrawBalance(ctx, transaction)
+	err := u.accrualRepo.WithdrawBalance(ctx, transaction)
 	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrInsufficientBalanceOnAccount):
+			return ErrInsufficientBalanceOnAccount
+		case errors.Is(err, repository.ErrOrderAlreadyPaid):
+			return ErrOrderAlreadyPaid
+		}
+
 		return err
 	}
 
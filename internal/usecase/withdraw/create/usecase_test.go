@@ -0,0 +1,126 @@
+package create_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mockGuid "github.com/bjlag/go-loyalty/internal/infrastructure/guid/mock"
+	"github.com/bjlag/go-loyalty/internal/infrastructure/repository"
+	mockRep "github.com/bjlag/go-loyalty/internal/infrastructure/repository/mock"
+	"github.com/bjlag/go-loyalty/internal/model"
+	"github.com/bjlag/go-loyalty/internal/usecase/withdraw/create"
+)
+
+func TestUsecase_CreateWithdraw(t *testing.T) {
+	var errSomeError = errors.New("some error")
+
+	const (
+		accountGUID     = "41d2f86c-6ce5-4732-a485-6d09d7a9b3f7"
+		transactionGUID = "c0d1b0d4-8c4d-4f0a-9f7e-0b7c1d8e4a11"
+		orderNumber     = "2377225624"
+	)
+
+	sum := model.NewPoints(751, 0)
+
+	type fields struct {
+		repo func(ctrl *gomock.Controller) *mockRep.MockAccrualRepo
+	}
+
+	tests := []struct {
+		name    string
+		fields  fields
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name: "success",
+			fields: fields{
+				repo: func(ctrl *gomock.Controller) *mockRep.MockAccrualRepo {
+					repoMock := mockRep.NewMockAccrualRepo(ctrl)
+					repoMock.EXPECT().
+						WithdrawBalance(gomock.Any(), gomock.Any()).
+						DoAndReturn(func(_ context.Context, transaction model.Transaction) error {
+							assert.Equal(t, transactionGUID, transaction.GUID)
+							assert.Equal(t, accountGUID, transaction.AccountGUID)
+							assert.Equal(t, orderNumber, transaction.OrderNumber)
+							assert.Equal(t, model.Withdraw, transaction.Type)
+							assert.Equal(t, sum, transaction.Sum)
+							return nil
+						})
+
+					return repoMock
+				},
+			},
+			wantErr: assert.NoError,
+		},
+		{
+			name: "insufficient_balance",
+			fields: fields{
+				repo: func(ctrl *gomock.Controller) *mockRep.MockAccrualRepo {
+					repoMock := mockRep.NewMockAccrualRepo(ctrl)
+					repoMock.EXPECT().
+						WithdrawBalance(gomock.Any(), gomock.Any()).
+						Return(repository.ErrInsufficientBalanceOnAccount)
+
+					return repoMock
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return errors.Is(err, create.ErrInsufficientBalanceOnAccount)
+			},
+		},
+		{
+			name: "order_already_paid",
+			fields: fields{
+				repo: func(ctrl *gomock.Controller) *mockRep.MockAccrualRepo {
+					repoMock := mockRep.NewMockAccrualRepo(ctrl)
+					repoMock.EXPECT().
+						WithdrawBalance(gomock.Any(), gomock.Any()).
+						Return(fmt.Errorf("%w: %s", repository.ErrOrderAlreadyPaid, orderNumber))
+
+					return repoMock
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return errors.Is(err, create.ErrOrderAlreadyPaid)
+			},
+		},
+		{
+			name: "repository_error",
+			fields: fields{
+				repo: func(ctrl *gomock.Controller) *mockRep.MockAccrualRepo {
+					repoMock := mockRep.NewMockAccrualRepo(ctrl)
+					repoMock.EXPECT().
+						WithdrawBalance(gomock.Any(), gomock.Any()).
+						Return(errSomeError)
+
+					return repoMock
+				},
+			},
+			wantErr: func(t assert.TestingT, err error, i ...interface{}) bool {
+				return errors.Is(err, errSomeError)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			genMock := mockGuid.NewMockIGenerator(ctrl)
+			genMock.EXPECT().Generate().Return(transactionGUID)
+
+			u := create.NewUsecase(tt.fields.repo(ctrl), genMock)
+
+			err := u.CreateWithdraw(context.Background(), accountGUID, orderNumber, sum)
+			if !tt.wantErr(t, err) {
+				require.Fail(t, "Received unexpected error", err)
+			}
+		})
+	}
+}
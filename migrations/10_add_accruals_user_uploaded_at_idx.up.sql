@@ -0,0 +1 @@
+CREATE INDEX accruals_user_guid_uploaded_at_idx ON accruals (user_guid, uploaded_at DESC, order_number DESC);
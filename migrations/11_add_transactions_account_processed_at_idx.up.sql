@@ -0,0 +1 @@
+CREATE INDEX transactions_account_guid_processed_at_idx ON transactions (account_guid, processed_at DESC, guid DESC);
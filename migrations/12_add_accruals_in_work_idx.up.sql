@@ -0,0 +1 @@
+CREATE INDEX accruals_in_work_idx ON accruals (uploaded_at, order_number) WHERE status IN (0, 1);
@@ -0,0 +1,5 @@
+ALTER TABLE accruals ADD COLUMN locked_by varchar(64);
+ALTER TABLE accruals ADD COLUMN locked_until timestamp with time zone;
+
+COMMENT ON COLUMN accruals.locked_by IS 'Экземпляр сервиса, который опрашивает систему начислений по заказу';
+COMMENT ON COLUMN accruals.locked_until IS 'Дата и время, до которых заказ закреплен за экземпляром сервиса';
@@ -0,0 +1,10 @@
+ALTER TABLE accruals ADD COLUMN attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE accruals ADD COLUMN next_check_at timestamp with time zone NOT NULL DEFAULT now();
+ALTER TABLE accruals ADD COLUMN last_error text NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN accruals.attempts IS 'Количество опросов системы начислений без изменения статуса заказа';
+COMMENT ON COLUMN accruals.next_check_at IS 'Дата и время, не раньше которых заказ будет опрошен снова';
+COMMENT ON COLUMN accruals.last_error IS 'Последняя ошибка опроса или причина отказа от обработки заказа';
+
+DROP INDEX accruals_in_work_idx;
+CREATE INDEX accruals_in_work_idx ON accruals (next_check_at, order_number) WHERE status IN (0, 1);
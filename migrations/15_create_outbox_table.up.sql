@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS outbox (
+    id bigserial NOT NULL PRIMARY KEY,
+    guid uuid NOT NULL DEFAULT gen_random_uuid(),
+    type varchar(64) NOT NULL,
+    user_guid uuid NOT NULL REFERENCES users (guid),
+    payload jsonb NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    published_at timestamp with time zone,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX outbox_guid_uniq_idx ON outbox (guid);
+CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL;
+
+COMMENT ON TABLE outbox IS 'Доменные события для внешних систем, пишутся в одной транзакции с изменением данных';
+COMMENT ON COLUMN outbox.id IS 'Порядковый номер события, события публикуются в порядке возрастания';
+COMMENT ON COLUMN outbox.guid IS 'GUID события, по нему получатель отбрасывает повторную доставку';
+COMMENT ON COLUMN outbox.type IS 'Тип события: accrual.processed, accrual.invalid, balance.withdrawn';
+COMMENT ON COLUMN outbox.user_guid IS 'GUID пользователя, события одного пользователя публикуются по порядку';
+COMMENT ON COLUMN outbox.payload IS 'Данные события в JSON';
+COMMENT ON COLUMN outbox.created_at IS 'Дата и время события';
+COMMENT ON COLUMN outbox.published_at IS 'Дата и время публикации, NULL - событие еще не опубликовано';
+COMMENT ON COLUMN outbox.attempts IS 'Неудачные попытки публикации';
+COMMENT ON COLUMN outbox.last_error IS 'Ошибка последней неудачной попытки публикации';
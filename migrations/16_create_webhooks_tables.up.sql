@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS webhooks (
+    guid uuid NOT NULL PRIMARY KEY,
+    user_guid uuid NOT NULL REFERENCES users (guid),
+    url text NOT NULL,
+    secret varchar(64) NOT NULL,
+    events jsonb NOT NULL DEFAULT '[]',
+    created_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX webhooks_user_guid_fk_idx ON webhooks (user_guid);
+
+COMMENT ON TABLE webhooks IS 'Подписки на уведомления об изменении статуса заказов';
+COMMENT ON COLUMN webhooks.guid IS 'GUID подписки';
+COMMENT ON COLUMN webhooks.user_guid IS 'GUID пользователя, о заказах которого уведомляет подписка';
+COMMENT ON COLUMN webhooks.url IS 'Адрес, на который отправляются уведомления';
+COMMENT ON COLUMN webhooks.secret IS 'Ключ подписи уведомлений HMAC-SHA256';
+COMMENT ON COLUMN webhooks.events IS 'Типы событий, пустой массив - все события';
+COMMENT ON COLUMN webhooks.created_at IS 'Дата и время создания подписки';
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    guid uuid NOT NULL PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_guid uuid NOT NULL REFERENCES webhooks (guid) ON DELETE CASCADE,
+    event_guid uuid NOT NULL,
+    event_type varchar(64) NOT NULL,
+    payload jsonb NOT NULL,
+    status smallint NOT NULL DEFAULT 0,
+    attempts integer NOT NULL DEFAULT 0,
+    next_attempt_at timestamp with time zone NOT NULL DEFAULT now(),
+    last_status_code integer NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    delivered_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX webhook_deliveries_event_uniq_idx ON webhook_deliveries (webhook_guid, event_guid);
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 0;
+CREATE INDEX webhook_deliveries_webhook_created_at_idx ON webhook_deliveries (webhook_guid, created_at DESC);
+
+COMMENT ON TABLE webhook_deliveries IS 'Журнал доставки уведомлений подписчикам';
+COMMENT ON COLUMN webhook_deliveries.guid IS 'GUID доставки';
+COMMENT ON COLUMN webhook_deliveries.webhook_guid IS 'GUID подписки';
+COMMENT ON COLUMN webhook_deliveries.event_guid IS 'GUID события из outbox, одно событие доставляется подписчику один раз';
+COMMENT ON COLUMN webhook_deliveries.event_type IS 'Тип события';
+COMMENT ON COLUMN webhook_deliveries.payload IS 'Тело уведомления в JSON';
+COMMENT ON COLUMN webhook_deliveries.status IS 'Статус доставки: 0 - ожидает отправки, 1 - доставлено, 2 - попытки исчерпаны';
+COMMENT ON COLUMN webhook_deliveries.attempts IS 'Неудачные попытки отправки';
+COMMENT ON COLUMN webhook_deliveries.next_attempt_at IS 'Дата и время следующей попытки отправки';
+COMMENT ON COLUMN webhook_deliveries.last_status_code IS 'HTTP-статус последнего ответа подписчика, 0 - ответа не было';
+COMMENT ON COLUMN webhook_deliveries.last_error IS 'Ошибка последней неудачной попытки';
+COMMENT ON COLUMN webhook_deliveries.created_at IS 'Дата и время постановки в очередь';
+COMMENT ON COLUMN webhook_deliveries.delivered_at IS 'Дата и время успешной доставки';
@@ -0,0 +1,40 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    guid uuid NOT NULL PRIMARY KEY,
+    family_guid uuid NOT NULL,
+    user_guid uuid NOT NULL REFERENCES users (guid),
+    token_hash char(64) NOT NULL,
+    access_jti uuid NOT NULL,
+    access_expires_at timestamp with time zone NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    used_at timestamp with time zone,
+    revoked_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX refresh_tokens_token_hash_uniq_idx ON refresh_tokens (token_hash);
+CREATE UNIQUE INDEX refresh_tokens_access_jti_uniq_idx ON refresh_tokens (access_jti);
+CREATE INDEX refresh_tokens_family_guid_idx ON refresh_tokens (family_guid);
+CREATE INDEX refresh_tokens_user_guid_fk_idx ON refresh_tokens (user_guid);
+
+COMMENT ON TABLE refresh_tokens IS 'Токены обновления, каждый используется один раз и заменяется новым из того же семейства';
+COMMENT ON COLUMN refresh_tokens.guid IS 'GUID токена';
+COMMENT ON COLUMN refresh_tokens.family_guid IS 'GUID семейства: все токены, выданные по цепочке обновлений от одного входа';
+COMMENT ON COLUMN refresh_tokens.user_guid IS 'GUID пользователя';
+COMMENT ON COLUMN refresh_tokens.token_hash IS 'SHA-256 токена в hex, сам токен не хранится';
+COMMENT ON COLUMN refresh_tokens.access_jti IS 'Идентификатор (jti) токена доступа, выданного вместе с токеном обновления';
+COMMENT ON COLUMN refresh_tokens.access_expires_at IS 'Дата и время истечения токена доступа';
+COMMENT ON COLUMN refresh_tokens.created_at IS 'Дата и время выдачи';
+COMMENT ON COLUMN refresh_tokens.expires_at IS 'Дата и время истечения';
+COMMENT ON COLUMN refresh_tokens.used_at IS 'Дата и время обмена на новую пару токенов, повторный обмен отзывает семейство';
+COMMENT ON COLUMN refresh_tokens.revoked_at IS 'Дата и время отзыва';
+
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti uuid NOT NULL PRIMARY KEY,
+    expires_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
+
+COMMENT ON TABLE revoked_tokens IS 'Отозванные токены доступа, которые еще не истекли';
+COMMENT ON COLUMN revoked_tokens.jti IS 'Идентификатор (jti) токена доступа';
+COMMENT ON COLUMN revoked_tokens.expires_at IS 'Дата и время истечения токена, после нее запись не нужна';
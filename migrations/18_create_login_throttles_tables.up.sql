@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key varchar(300) NOT NULL PRIMARY KEY,
+    failures integer NOT NULL DEFAULT 0,
+    window_started_at timestamp with time zone NOT NULL,
+    blocked_until timestamp with time zone
+);
+
+COMMENT ON TABLE login_throttles IS 'Неудачные попытки входа по логину и по IP-адресу';
+COMMENT ON COLUMN login_throttles.key IS 'Ключ: login:<логин> или ip:<адрес>';
+COMMENT ON COLUMN login_throttles.failures IS 'Неудачные попытки с начала окна';
+COMMENT ON COLUMN login_throttles.window_started_at IS 'Дата и время первой неудачной попытки окна, после окончания окна счет начинается заново';
+COMMENT ON COLUMN login_throttles.blocked_until IS 'Дата и время, до которых попытки входа отклоняются';
+
+CREATE TABLE IF NOT EXISTS login_lockouts (
+    guid uuid NOT NULL PRIMARY KEY,
+    subject varchar(10) NOT NULL,
+    value varchar(255) NOT NULL,
+    failures integer NOT NULL,
+    locked_until timestamp with time zone NOT NULL,
+    created_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX login_lockouts_subject_value_idx ON login_lockouts (subject, value, created_at DESC);
+
+COMMENT ON TABLE login_lockouts IS 'Журнал блокировок входа после серии неудачных попыток';
+COMMENT ON COLUMN login_lockouts.guid IS 'GUID записи';
+COMMENT ON COLUMN login_lockouts.subject IS 'Что заблокировано: login - логин, ip - IP-адрес';
+COMMENT ON COLUMN login_lockouts.value IS 'Логин или IP-адрес';
+COMMENT ON COLUMN login_lockouts.failures IS 'Неудачные попытки, после которых вход заблокирован';
+COMMENT ON COLUMN login_lockouts.locked_until IS 'Дата и время окончания блокировки';
+COMMENT ON COLUMN login_lockouts.created_at IS 'Дата и время блокировки';
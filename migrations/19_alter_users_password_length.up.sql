@@ -0,0 +1,3 @@
+ALTER TABLE users ALTER COLUMN password TYPE varchar(255);
+
+COMMENT ON COLUMN users.password IS 'Хеш пароля: Argon2id в формате PHC или bcrypt';
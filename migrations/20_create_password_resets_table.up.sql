@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS password_resets (
+    guid uuid NOT NULL PRIMARY KEY,
+    user_guid uuid NOT NULL REFERENCES users (guid),
+    token_hash char(64) NOT NULL,
+    created_at timestamp with time zone NOT NULL,
+    expires_at timestamp with time zone NOT NULL,
+    used_at timestamp with time zone
+);
+
+CREATE UNIQUE INDEX password_resets_token_hash_uniq_idx ON password_resets (token_hash);
+CREATE INDEX password_resets_user_guid_fk_idx ON password_resets (user_guid);
+
+COMMENT ON TABLE password_resets IS 'Запросы на сброс пароля, токен сброса используется один раз';
+COMMENT ON COLUMN password_resets.guid IS 'GUID запроса';
+COMMENT ON COLUMN password_resets.user_guid IS 'GUID пользователя';
+COMMENT ON COLUMN password_resets.token_hash IS 'SHA-256 токена сброса в hex, сам токен не хранится';
+COMMENT ON COLUMN password_resets.created_at IS 'Дата и время запроса';
+COMMENT ON COLUMN password_resets.expires_at IS 'Дата и время истечения токена';
+COMMENT ON COLUMN password_resets.used_at IS 'Дата и время использования токена или его отмены при сбросе пароля другим токеном';
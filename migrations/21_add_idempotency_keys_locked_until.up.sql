@@ -0,0 +1,5 @@
+ALTER TABLE idempotency_keys ADD COLUMN locked_until timestamp with time zone;
+
+CREATE INDEX idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+
+COMMENT ON COLUMN idempotency_keys.locked_until IS 'Дата и время, до которых запрос с ключом считается обрабатываемым, после них ключ можно занять повторно';
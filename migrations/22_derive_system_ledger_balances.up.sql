@@ -0,0 +1,34 @@
+-- Каждое начисление и списание проводится по одному из двух системных счетов, поэтому обновление их строки
+-- сериализует все операции. Остатки системных счетов считаются по проводкам, триггер обновляет только счета
+-- пользователей.
+CREATE OR REPLACE FUNCTION ledger_apply_posting() RETURNS trigger AS $$
+BEGIN
+    UPDATE ledger_accounts
+    SET balance      = balance + NEW.amount,
+        total_debit  = total_debit + GREATEST(-NEW.amount, 0),
+        total_credit = total_credit + GREATEST(NEW.amount, 0),
+        updated_at   = NEW.created_at
+    WHERE guid = NEW.account_guid
+      AND type = 0;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+UPDATE ledger_accounts SET balance = 0, total_debit = 0, total_credit = 0 WHERE type <> 0;
+
+CREATE VIEW ledger_system_balances AS
+SELECT a.guid,
+       a.type,
+       COALESCE(SUM(p.amount), 0)                             AS balance,
+       COALESCE(SUM(-p.amount) FILTER (WHERE p.amount < 0), 0) AS total_debit,
+       COALESCE(SUM(p.amount) FILTER (WHERE p.amount > 0), 0)  AS total_credit
+FROM ledger_accounts a
+LEFT JOIN ledger_postings p ON p.account_guid = a.guid
+WHERE a.type <> 0
+GROUP BY a.guid, a.type;
+
+COMMENT ON COLUMN ledger_accounts.balance IS 'Остаток счета пользователя, материализуется из проводок триггером; для системных счетов см. ledger_system_balances';
+COMMENT ON COLUMN ledger_accounts.total_debit IS 'Оборот по дебету счета пользователя, материализуется из проводок триггером';
+COMMENT ON COLUMN ledger_accounts.total_credit IS 'Оборот по кредиту счета пользователя, материализуется из проводок триггером';
+COMMENT ON VIEW ledger_system_balances IS 'Остатки и обороты системных счетов, посчитанные по проводкам';
@@ -0,0 +1,2 @@
+CREATE INDEX ledger_postings_account_guid_created_at_idx ON ledger_postings (account_guid, created_at, entry_guid);
+DROP INDEX ledger_postings_account_guid_fk_idx;
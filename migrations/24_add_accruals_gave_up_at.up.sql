@@ -0,0 +1,8 @@
+ALTER TABLE accruals ADD COLUMN gave_up_at timestamp with time zone;
+
+COMMENT ON COLUMN accruals.gave_up_at IS 'Дата и время, когда опрос системы начислений прекращен без окончательного статуса заказа';
+COMMENT ON COLUMN accruals.attempts IS 'Количество ответов системы начислений без изменения статуса заказа';
+COMMENT ON COLUMN accruals.last_error IS 'Причина отказа от обработки заказа или прекращения опроса';
+
+DROP INDEX accruals_in_work_idx;
+CREATE INDEX accruals_in_work_idx ON accruals (next_check_at, order_number) WHERE status IN (0, 1) AND gave_up_at IS NULL;
@@ -0,0 +1,12 @@
+ALTER TABLE outbox ADD COLUMN claimed_until timestamp with time zone;
+ALTER TABLE outbox ADD COLUMN next_attempt_at timestamp with time zone;
+ALTER TABLE outbox ADD COLUMN parked_at timestamp with time zone;
+
+DROP INDEX outbox_unpublished_idx;
+CREATE INDEX outbox_unpublished_idx ON outbox (id) WHERE published_at IS NULL AND parked_at IS NULL;
+CREATE INDEX outbox_unpublished_user_guid_idx ON outbox (user_guid, id) WHERE published_at IS NULL AND parked_at IS NULL;
+
+COMMENT ON COLUMN outbox.id IS 'Порядковый номер события, события одного пользователя публикуются в порядке возрастания';
+COMMENT ON COLUMN outbox.claimed_until IS 'Дата и время, до которых событие публикуется одной из реплик';
+COMMENT ON COLUMN outbox.next_attempt_at IS 'Дата и время, не раньше которых событие и следующие события пользователя публикуются снова';
+COMMENT ON COLUMN outbox.parked_at IS 'Дата и время, когда публикация прекращена после неудачных попыток, остальные события пользователя публикуются дальше';
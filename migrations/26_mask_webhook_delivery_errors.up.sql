@@ -0,0 +1,5 @@
+UPDATE webhook_deliveries
+SET last_error = CASE WHEN last_error LIKE 'unexpected status code%' THEN 'unexpected status code' ELSE 'request failed' END
+WHERE last_error <> '';
+
+COMMENT ON COLUMN webhook_deliveries.last_error IS 'Обобщенная причина последней неудачной попытки, подробности пишутся только в лог';
@@ -0,0 +1,3 @@
+ALTER TABLE idempotency_keys ADD COLUMN lock_token uuid;
+
+COMMENT ON COLUMN idempotency_keys.lock_token IS 'Токен текущего обработчика запроса, сохранить ответ или освободить ключ может только он';
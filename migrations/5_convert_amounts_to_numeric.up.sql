@@ -0,0 +1,12 @@
+ALTER TABLE accruals
+    ALTER COLUMN accrual TYPE numeric(16, 2) USING round(accrual::numeric, 2),
+    ALTER COLUMN accrual SET DEFAULT 0;
+
+ALTER TABLE accounts
+    ALTER COLUMN balance TYPE numeric(16, 2) USING round(balance::numeric, 2),
+    ALTER COLUMN balance SET DEFAULT 0,
+    ALTER COLUMN withdraw_sum TYPE numeric(16, 2) USING round(withdraw_sum::numeric, 2),
+    ALTER COLUMN withdraw_sum SET DEFAULT 0;
+
+ALTER TABLE transactions
+    ALTER COLUMN sum TYPE numeric(16, 2) USING round(sum::numeric, 2);
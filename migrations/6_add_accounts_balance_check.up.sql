@@ -0,0 +1,32 @@
+ALTER TABLE accounts
+    ADD CONSTRAINT accounts_balance_non_negative_chk CHECK (balance >= 0) NOT VALID;
+
+-- Параллельные списания уводили остаток в минус. Перерасход списывается в пользу пользователя корректирующим
+-- начислением в момент, когда остаток впервые стал отрицательным, так что он не отрицателен ни в какой момент истории.
+WITH running AS (
+    SELECT account_guid,
+           processed_at,
+           SUM(CASE WHEN type = 0 THEN sum ELSE -sum END)
+               OVER (PARTITION BY account_guid ORDER BY processed_at, type, guid) AS balance
+    FROM transactions
+), overdraft AS (
+    SELECT account_guid,
+           MIN(processed_at) FILTER (WHERE balance < 0) AS overdrawn_at,
+           MIN(balance) AS lowest
+    FROM running
+    GROUP BY account_guid
+    HAVING MIN(balance) < 0
+), correction AS (
+    INSERT INTO transactions (guid, account_guid, order_number, type, sum, processed_at)
+    SELECT gen_random_uuid(), account_guid, 'overdraft-correction', 0, -lowest, overdrawn_at
+    FROM overdraft
+    RETURNING account_guid, sum
+)
+UPDATE accounts a
+SET balance    = a.balance + c.sum,
+    updated_at = now()
+FROM correction c
+WHERE a.guid = c.account_guid;
+
+ALTER TABLE accounts
+    VALIDATE CONSTRAINT accounts_balance_non_negative_chk;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key varchar(255) NOT NULL,
+    user_guid uuid NOT NULL REFERENCES users (guid),
+    request_hash char(64) NOT NULL,
+    response_status smallint,
+    response_content_type varchar(255),
+    response_body bytea,
+    created_at timestamp with time zone NOT NULL,
+    PRIMARY KEY (user_guid, key)
+);
+
+COMMENT ON TABLE idempotency_keys IS 'Ключи идемпотентности запросов';
+COMMENT ON COLUMN idempotency_keys.key IS 'Значение заголовка Idempotency-Key';
+COMMENT ON COLUMN idempotency_keys.user_guid IS 'GUID пользователя';
+COMMENT ON COLUMN idempotency_keys.request_hash IS 'SHA-256 метода, пути и тела запроса';
+COMMENT ON COLUMN idempotency_keys.response_status IS 'HTTP статус сохраненного ответа, NULL пока запрос обрабатывается';
+COMMENT ON COLUMN idempotency_keys.response_content_type IS 'Content-Type сохраненного ответа';
+COMMENT ON COLUMN idempotency_keys.response_body IS 'Тело сохраненного ответа';
+COMMENT ON COLUMN idempotency_keys.created_at IS 'Дата и время первого запроса с ключом';
@@ -0,0 +1,27 @@
+-- Повторные списания по одному заказу возвращаются на счет пользователя, остается самое раннее списание.
+WITH duplicate AS (
+    DELETE FROM transactions
+    WHERE guid IN (
+        SELECT guid
+        FROM (
+            SELECT guid,
+                   ROW_NUMBER() OVER (PARTITION BY order_number ORDER BY processed_at, guid) AS n
+            FROM transactions
+            WHERE type = 1
+        ) withdrawals
+        WHERE n > 1
+    )
+    RETURNING account_guid, sum
+), refund AS (
+    SELECT account_guid, SUM(sum) AS sum
+    FROM duplicate
+    GROUP BY account_guid
+)
+UPDATE accounts a
+SET balance      = a.balance + r.sum,
+    withdraw_sum = a.withdraw_sum - r.sum,
+    updated_at   = now()
+FROM refund r
+WHERE a.guid = r.account_guid;
+
+CREATE UNIQUE INDEX transactions_withdraw_order_number_uniq_idx ON transactions (order_number) WHERE type = 1;
@@ -0,0 +1,155 @@
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    guid uuid NOT NULL PRIMARY KEY,
+    type smallint NOT NULL,
+    user_guid uuid REFERENCES users (guid),
+    balance numeric(16, 2) NOT NULL DEFAULT 0,
+    total_debit numeric(16, 2) NOT NULL DEFAULT 0,
+    total_credit numeric(16, 2) NOT NULL DEFAULT 0,
+    created_at timestamp with time zone NOT NULL,
+    updated_at timestamp with time zone NOT NULL,
+    CONSTRAINT ledger_accounts_user_guid_chk CHECK ((type = 0) = (user_guid IS NOT NULL)),
+    CONSTRAINT ledger_accounts_user_balance_non_negative_chk CHECK (type <> 0 OR balance >= 0)
+);
+
+CREATE UNIQUE INDEX ledger_accounts_user_guid_uniq_idx ON ledger_accounts (user_guid) WHERE user_guid IS NOT NULL;
+
+COMMENT ON TABLE ledger_accounts IS 'Счета главной книги баллов лояльности';
+COMMENT ON COLUMN ledger_accounts.guid IS 'GUID счета, для счетов пользователей совпадает с GUID пользователя';
+COMMENT ON COLUMN ledger_accounts.type IS 'Тип счета: 0 - счет пользователя, 1 - источник начислений, 2 - погашение баллов';
+COMMENT ON COLUMN ledger_accounts.user_guid IS 'GUID пользователя, только для счетов пользователей';
+COMMENT ON COLUMN ledger_accounts.balance IS 'Остаток, материализуется из проводок триггером';
+COMMENT ON COLUMN ledger_accounts.total_debit IS 'Оборот по дебету, материализуется из проводок триггером';
+COMMENT ON COLUMN ledger_accounts.total_credit IS 'Оборот по кредиту, материализуется из проводок триггером';
+COMMENT ON COLUMN ledger_accounts.created_at IS 'Дата и время открытия счета';
+COMMENT ON COLUMN ledger_accounts.updated_at IS 'Дата и время последней проводки по счету';
+
+CREATE TABLE IF NOT EXISTS ledger_entries (
+    guid uuid NOT NULL PRIMARY KEY,
+    type smallint NOT NULL,
+    user_guid uuid NOT NULL REFERENCES users (guid),
+    order_number varchar(50) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX ledger_entries_user_guid_fk_idx ON ledger_entries (user_guid);
+
+COMMENT ON TABLE ledger_entries IS 'Журнал хозяйственных операций, только добавление';
+COMMENT ON COLUMN ledger_entries.guid IS 'GUID операции, совпадает с GUID транзакции';
+COMMENT ON COLUMN ledger_entries.type IS 'Тип операции: 0 - начисление, 1 - списание, 2 - корректировка';
+COMMENT ON COLUMN ledger_entries.user_guid IS 'GUID пользователя';
+COMMENT ON COLUMN ledger_entries.order_number IS 'Номер заказа, по которому проведена операция';
+COMMENT ON COLUMN ledger_entries.description IS 'Описание операции';
+COMMENT ON COLUMN ledger_entries.created_at IS 'Дата и время операции';
+
+CREATE TABLE IF NOT EXISTS ledger_postings (
+    id bigserial NOT NULL PRIMARY KEY,
+    entry_guid uuid NOT NULL REFERENCES ledger_entries (guid),
+    account_guid uuid NOT NULL REFERENCES ledger_accounts (guid),
+    amount numeric(16, 2) NOT NULL CHECK (amount <> 0),
+    created_at timestamp with time zone NOT NULL
+);
+
+CREATE INDEX ledger_postings_entry_guid_fk_idx ON ledger_postings (entry_guid);
+CREATE INDEX ledger_postings_account_guid_fk_idx ON ledger_postings (account_guid);
+
+COMMENT ON TABLE ledger_postings IS 'Проводки по счетам главной книги, только добавление';
+COMMENT ON COLUMN ledger_postings.entry_guid IS 'GUID операции';
+COMMENT ON COLUMN ledger_postings.account_guid IS 'GUID счета';
+COMMENT ON COLUMN ledger_postings.amount IS 'Сумма проводки: больше нуля - кредит, меньше нуля - дебет';
+COMMENT ON COLUMN ledger_postings.created_at IS 'Дата и время проводки';
+
+CREATE FUNCTION ledger_forbid_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'table % is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_entries_append_only
+    BEFORE UPDATE OR DELETE ON ledger_entries
+    FOR EACH ROW EXECUTE FUNCTION ledger_forbid_change();
+
+CREATE TRIGGER ledger_postings_append_only
+    BEFORE UPDATE OR DELETE ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION ledger_forbid_change();
+
+CREATE FUNCTION ledger_apply_posting() RETURNS trigger AS $$
+BEGIN
+    UPDATE ledger_accounts
+    SET balance      = balance + NEW.amount,
+        total_debit  = total_debit + GREATEST(-NEW.amount, 0),
+        total_credit = total_credit + GREATEST(NEW.amount, 0),
+        updated_at   = NEW.created_at
+    WHERE guid = NEW.account_guid;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER ledger_postings_apply
+    AFTER INSERT ON ledger_postings
+    FOR EACH ROW EXECUTE FUNCTION ledger_apply_posting();
+
+CREATE FUNCTION ledger_check_entry_balanced() RETURNS trigger AS $$
+DECLARE
+    total numeric;
+    postings integer;
+BEGIN
+    SELECT COALESCE(SUM(amount), 0), COUNT(*) INTO total, postings
+    FROM ledger_postings
+    WHERE entry_guid = NEW.entry_guid;
+
+    IF total <> 0 OR postings < 2 THEN
+        RAISE EXCEPTION 'ledger entry % is not balanced', NEW.entry_guid;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER ledger_postings_balanced
+    AFTER INSERT ON ledger_postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION ledger_check_entry_balanced();
+
+INSERT INTO ledger_accounts (guid, type, created_at, updated_at)
+VALUES ('00000000-0000-0000-0000-000000000001', 1, now(), now()),
+       ('00000000-0000-0000-0000-000000000002', 2, now(), now());
+
+-- Перенос истории: каждая транзакция становится операцией с двумя проводками.
+INSERT INTO ledger_accounts (guid, type, user_guid, created_at, updated_at)
+SELECT guid, 0, guid, updated_at, updated_at
+FROM accounts;
+
+INSERT INTO ledger_entries (guid, type, user_guid, order_number, created_at)
+SELECT guid, type, account_guid, order_number, processed_at
+FROM transactions
+WHERE sum <> 0;
+
+INSERT INTO ledger_postings (entry_guid, account_guid, amount, created_at)
+SELECT entry_guid, account_guid, amount, created_at
+FROM (
+    SELECT guid AS entry_guid,
+           account_guid,
+           CASE WHEN type = 0 THEN sum ELSE -sum END AS amount,
+           processed_at AS created_at,
+           type
+    FROM transactions
+    WHERE sum <> 0
+    UNION ALL
+    SELECT guid,
+           CASE WHEN type = 0 THEN '00000000-0000-0000-0000-000000000001'::uuid
+                ELSE '00000000-0000-0000-0000-000000000002'::uuid END,
+           CASE WHEN type = 0 THEN -sum ELSE sum END,
+           processed_at,
+           type
+    FROM transactions
+    WHERE sum <> 0
+) history
+ORDER BY created_at, type;
+
+ALTER TABLE transactions DROP CONSTRAINT transactions_account_guid_fkey;
+ALTER TABLE transactions ADD CONSTRAINT transactions_account_guid_fkey FOREIGN KEY (account_guid) REFERENCES users (guid);
+
+-- Остатки теперь материализуются из проводок в ledger_accounts.
+DROP TABLE accounts;